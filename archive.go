@@ -0,0 +1,21 @@
+package browserpass
+
+// ArchiveDir is the store subtree for retired accounts. Its entries are left
+// out of searches unless asked for, but can still be opened.
+const ArchiveDir = "archive"
+
+// isArchived reports whether item is in the archive.
+func isArchived(item string) bool {
+	return withinSubtrees(item, []string{ArchiveDir})
+}
+
+// withoutArchived returns the items of list outside the archive.
+func withoutArchived(list []string) []string {
+	var active []string
+	for _, item := range list {
+		if !isArchived(item) {
+			active = append(active, item)
+		}
+	}
+	return active
+}
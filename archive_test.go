@@ -0,0 +1,37 @@
+package browserpass
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestRunArchived(t *testing.T) {
+	s := fakeStore{"archive/example.com/alice", "example.com/bob", "archived.com/carol"}
+	caller := AllowedOrigins[0]
+
+	tests := []struct {
+		archived string
+		expected []string
+	}{
+		{"", []string{"example.com/bob", "archived.com/carol"}},
+		{"true", []string{"archive/example.com/alice", "example.com/bob", "archived.com/carol"}},
+	}
+	for _, test := range tests {
+		for _, action := range []string{"search", "list"} {
+			var results []string
+			roundTrip(t, s, caller, map[string]string{"action": action, "domain": "", "archived": test.archived}, &results)
+			if !reflect.DeepEqual(results, test.expected) {
+				t.Errorf("%s, archived=%q: expected %v, got %v", action, test.archived, test.expected, results)
+			}
+		}
+	}
+
+	list, err := Lookup(context.Background(), fakeStore{"archive/example.com/alice", "example.com/bob"}, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"example.com/bob"}; !reflect.DeepEqual(list, expected) {
+		t.Errorf("Lookup: expected %v, got %v", expected, list)
+	}
+}
@@ -0,0 +1,250 @@
+package browserpass
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Audit records credential accesses when set. It is nil unless enabled with
+// OpenAuditLog.
+var Audit *AuditLog
+
+// AuditRecord is a single line of the audit log. It never contains secrets.
+type AuditRecord struct {
+	Time   time.Time `json:"time"`
+	Origin string    `json:"origin"`
+	// Host is the host of the tab the entry was requested for, if known
+	Host  string `json:"host,omitempty"`
+	Entry string `json:"entry"`
+	// ParentUnchecked is set when the browser that launched the host
+	// couldn't be checked, see ParentIsBrowser
+	ParentUnchecked bool `json:"parent_unchecked,omitempty"`
+	// Prev is the SHA-256 of the previous line, or its HMAC-SHA256 with the
+	// log's key, chaining the records together.
+	Prev string `json:"prev"`
+}
+
+// wholeStore is the entry audited for requests reading every entry of the
+// store, like "reindex". Entry names can't start with a slash.
+const wholeStore = "/"
+
+// AuditLog is an append-only file of AuditRecords, one JSON object per line.
+// Each record carries the hash of the line before it, so removing or editing
+// a record breaks the chain from that point on. Keyed with HMAC, the chain
+// can't be rebuilt after editing without the key either.
+type AuditLog struct {
+	mu     sync.Mutex
+	f      *os.File
+	key    []byte
+	prev   string
+	broken error
+}
+
+// OpenAuditLog opens or creates the audit log at path, resuming its chain.
+// The chain is keyed with key unless it is nil. A record torn by a crash
+// while it was written is cut off the end. A chain broken before is no
+// reason to stop recording: new records are chained to the last line and
+// Broken reports where it broke.
+func OpenAuditLog(path string, key []byte) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	tail, err := readAuditLog(f, key, nil)
+	if err == nil && tail.torn {
+		err = f.Truncate(tail.size)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &AuditLog{f: f, key: key, prev: tail.prev, broken: tail.broken}, nil
+}
+
+// Broken returns why the chain of the log was broken when it was opened,
+// nil if it was intact.
+func (l *AuditLog) Broken() error {
+	return l.broken
+}
+
+// LoadAuditKey reads the key of an audit log from the file at path, creating
+// it with a random key if it doesn't exist.
+func LoadAuditKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if !os.IsNotExist(err) {
+		return key, err
+	}
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(key); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return key, f.Close()
+}
+
+// Record appends an access of entry by origin, for a tab on host, to the
+// log.
+func (l *AuditLog) Record(origin, host, entry string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	line, err := json.Marshal(AuditRecord{Time: time.Now().UTC(), Origin: origin, Host: host, Entry: entry, ParentUnchecked: parentUnchecked, Prev: l.prev})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if _, err := l.f.Write(line); err != nil {
+		return err
+	}
+	l.prev = hashLine(line, l.key)
+	return nil
+}
+
+// Recent returns the last n records of the log, oldest first, after
+// verifying the chain.
+func (l *AuditLog) Recent(n int) ([]AuditRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var records []AuditRecord
+	tail, err := readAuditLog(io.NewSectionReader(l.f, 0, 1<<62), l.key, func(rec AuditRecord) {
+		if len(records) == n {
+			records = append(records[:0], records[1:]...)
+		}
+		records = append(records, rec)
+	})
+	if err == nil {
+		err = tail.broken
+	}
+	return records, err
+}
+
+// Limits of the "audit" action.
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 1000
+)
+
+// recentAccesses answers with the last records of the audit log, up to
+// "limit", for entries the caller may see. A broken chain is reported with
+// CodeAuditBroken rather than the records.
+func (c *conn) recentAccesses(ctx context.Context, data map[string]string) (interface{}, error) {
+	if Audit == nil {
+		return errorResponse{Message: "the audit log is off", Code: CodeUnavailable}, nil
+	}
+	limit := defaultAuditLimit
+	if data["limit"] != "" {
+		n, err := strconv.Atoi(data["limit"])
+		if err != nil || n < 1 || n > maxAuditLimit {
+			return errorResponse{Message: fmt.Sprintf("limit must be between 1 and %d", maxAuditLimit), Code: CodeInvalidRequest}, nil
+		}
+		limit = n
+	}
+	records, err := Audit.Recent(maxAuditLimit)
+	if err != nil {
+		return errorResponse{Message: err.Error(), Code: CodeAuditBroken}, nil
+	}
+	visible := []AuditRecord{}
+	for _, rec := range records {
+		if allowedItem(c.caller, data["container"], rec.Entry) {
+			visible = append(visible, rec)
+		}
+	}
+	if len(visible) > limit {
+		visible = visible[len(visible)-limit:]
+	}
+	return visible, nil
+}
+
+// Close closes the underlying file.
+func (l *AuditLog) Close() error {
+	return l.f.Close()
+}
+
+// VerifyAuditLog checks the hash chain of the audit log read from r, keyed
+// with key unless it is nil.
+func VerifyAuditLog(r io.Reader, key []byte) error {
+	tail, err := readAuditLog(r, key, nil)
+	if err != nil {
+		return err
+	}
+	return tail.broken
+}
+
+// auditTail is the end of the log found by readAuditLog.
+type auditTail struct {
+	// prev is the hash of the last line
+	prev string
+	// size is the length of the log up to the end of the last line
+	size int64
+	// torn is set if the log ends in a partly written line after size
+	torn bool
+	// broken is where the chain first broke, nil if it didn't
+	broken error
+}
+
+// readAuditLog walks the log, passing its records to fn if not nil. It
+// reads on after the chain breaks, so the tail is that of the whole log.
+// The error is only set if the log couldn't be read.
+func readAuditLog(r io.Reader, key []byte, fn func(AuditRecord)) (auditTail, error) {
+	var tail auditTail
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			// Records are written whole with their newline, so a
+			// line without one was cut short
+			tail.torn = len(line) > 0
+			return tail, nil
+		}
+		if err != nil {
+			return tail, err
+		}
+
+		var rec AuditRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			if tail.broken == nil {
+				tail.broken = fmt.Errorf("audit log: line %d: %v", n, err)
+			}
+		} else if rec.Prev != tail.prev {
+			if tail.broken == nil {
+				tail.broken = fmt.Errorf("audit log: chain broken at line %d", n)
+			}
+		} else if fn != nil {
+			fn(rec)
+		}
+		tail.prev = hashLine(line, key)
+		tail.size += int64(len(line))
+	}
+}
+
+// hashLine returns the SHA-256 of line, or its HMAC-SHA256 with key unless
+// key is nil.
+func hashLine(line, key []byte) string {
+	if key == nil {
+		sum := sha256.Sum256(line)
+		return hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(line)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,168 @@
+package browserpass
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditLogChain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "browserpass-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	l, err := OpenAuditLog(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Record("browserpass@dannyvankooten.com", "example.com", "example.com/alice")
+	l.Close()
+
+	// Reopening resumes the chain
+	l, err = OpenAuditLog(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Record("browserpass@dannyvankooten.com", "", "example.com/bob")
+	l.Close()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyAuditLog(bytes.NewReader(data), nil); err != nil {
+		t.Errorf("Untouched log failed verification: %v", err)
+	}
+
+	tampered := bytes.Replace(data, []byte("alice"), []byte("carol"), 1)
+	if err := VerifyAuditLog(bytes.NewReader(tampered), nil); err == nil {
+		t.Error("Tampered log passed verification")
+	}
+}
+
+func TestAuditLogKey(t *testing.T) {
+	dir := t.TempDir()
+	key, err := LoadAuditKey(filepath.Join(dir, "audit.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := LoadAuditKey(filepath.Join(dir, "audit.key")); err != nil || !bytes.Equal(again, key) {
+		t.Fatalf("Key changed when loaded again: %v", err)
+	}
+
+	path := filepath.Join(dir, "audit.log")
+	l, err := OpenAuditLog(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for _, entry := range []string{"example.com/alice", "example.com/bob", "example.org/carol"} {
+		l.Record("browserpass@dannyvankooten.com", "example.com", entry)
+	}
+
+	records, err := l.Recent(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Entry != "example.com/bob" || records[1].Host != "example.com" {
+		t.Errorf("Unexpected recent records %+v", records)
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	if err := VerifyAuditLog(bytes.NewReader(data), key); err != nil {
+		t.Errorf("Untouched log failed verification: %v", err)
+	}
+	if err := VerifyAuditLog(bytes.NewReader(data), nil); err == nil {
+		t.Error("Keyed log passed verification without the key")
+	}
+}
+
+func TestRunAudit(t *testing.T) {
+	var err error
+	if Audit, err = OpenAuditLog(filepath.Join(t.TempDir(), "audit.log"), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { Audit.Close(); Audit = nil }()
+	s := fakeStore{"example.com/alice", "personal/example.org/bob"}
+	caller := AllowedOrigins[0]
+
+	var login map[string]string
+	roundTrip(t, s, caller, map[string]string{"action": "get", "entry": "example.com/alice", "host": "example.com"}, &login)
+	roundTrip(t, s, caller, map[string]string{"action": "get", "entry": "personal/example.org/bob"}, &login)
+
+	var records []AuditRecord
+	roundTrip(t, s, caller, map[string]string{"action": "audit", "limit": "1"}, &records)
+	if len(records) != 1 || records[0].Entry != "personal/example.org/bob" || records[0].Origin != caller {
+		t.Errorf("audit returned %+v", records)
+	}
+
+	Policies = map[string][]string{caller: {"example.com"}}
+	defer func() { Policies = nil }()
+	roundTrip(t, s, caller, map[string]string{"action": "audit"}, &records)
+	if len(records) != 1 || records[0].Entry != "example.com/alice" || records[0].Host != "example.com" {
+		t.Errorf("audit outside of the policy returned %+v", records)
+	}
+}
+
+func TestAuditLogTorn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := OpenAuditLog(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Record("browserpass@dannyvankooten.com", "", "example.com/alice")
+	l.Close()
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	f.WriteString(`{"time":"2026-`)
+	f.Close()
+
+	if l, err = OpenAuditLog(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if l.Broken() != nil {
+		t.Errorf("Torn record broke the chain: %v", l.Broken())
+	}
+	l.Record("browserpass@dannyvankooten.com", "", "example.com/bob")
+	records, err := l.Recent(10)
+	if err != nil || len(records) != 2 || records[1].Entry != "example.com/bob" {
+		t.Errorf("Recent after a torn record returned %+v, %v", records, err)
+	}
+}
+
+func TestAuditLogBroken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := OpenAuditLog(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Record("browserpass@dannyvankooten.com", "", "example.com/alice")
+	l.Record("browserpass@dannyvankooten.com", "", "example.com/bob")
+	l.Close()
+	data, _ := ioutil.ReadFile(path)
+	ioutil.WriteFile(path, bytes.Replace(data, []byte("alice"), []byte("carol"), 1), 0600)
+
+	if Audit, err = OpenAuditLog(path, nil); err != nil {
+		t.Fatalf("Broken log wasn't opened: %v", err)
+	}
+	defer func() { Audit.Close(); Audit = nil }()
+	if Audit.Broken() == nil {
+		t.Error("Broken chain wasn't reported")
+	}
+	Audit.Record("browserpass@dannyvankooten.com", "", "example.com/dave")
+	data, _ = ioutil.ReadFile(path)
+	if err := VerifyAuditLog(bytes.NewReader(data), nil); err == nil || err.Error() != "audit log: chain broken at line 2" {
+		t.Errorf("Verifying the broken log returned %v", err)
+	}
+
+	var resp map[string][]string
+	roundTrip(t, fakeStore{}, AllowedOrigins[0], map[string]string{"action": "warnings"}, &resp)
+	if len(resp["warnings"]) != 1 || resp["warnings"][0] != Audit.Broken().Error() {
+		t.Errorf("warnings returned %v", resp)
+	}
+}
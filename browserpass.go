@@ -1,153 +1,828 @@
 package browserpass
 
 import (
-	"bufio"
 	"bytes"
-	"encoding/binary"
-	"encoding/json"
+	"context"
+	"encoding/base64"
 	"errors"
 	"io"
-	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/dannyvankooten/browserpass/pass"
+	"github.com/dannyvankooten/browserpass/passkey"
+	"github.com/dannyvankooten/browserpass/protocol"
 )
 
 // Login represents a single pass login.
 type Login struct {
-	Username string `json:"u"`
-	Password string `json:"p"`
+	Username string
+	Password *SecureBytes
+	// URL is the first URL listed in the entry
+	URL string
+	// OTP is the entry's otpauth URI, or nil
+	OTP *SecureBytes
+	// Fields holds the entry's "key: value" lines by lowercase key
+	Fields map[string]string
+	// confidence is how sure detectUsername is of Username
+	confidence float64
 }
 
-var endianness = binary.LittleEndian
+// Wipe wipes the password and OTP seed of l.
+func (l *Login) Wipe() {
+	l.Password.Wipe()
+	if l.OTP != nil {
+		l.OTP.Wipe()
+	}
+}
+
+// resolveUsername settles the username of l, the login in entry, following
+// the settings of its store.
+func (l *Login) resolveUsername(entry string, settings *pass.Settings) {
+	l.useUsernameFields(settings.UsernameFields)
+	l.fillUsername(entry, settings.UsernameOrder(entry))
+}
 
-// Run starts browserpass.
-func Run(stdin io.Reader, stdout io.Writer, s pass.Store) error {
-	for {
-		// Get message length, 4 bytes
-		var n uint32
-		if err := binary.Read(stdin, endianness, &n); err != nil {
-			return err
+// fillUsername takes the username from the first of the sources in order,
+// see pass.Settings.UsernameFrom, that has one. Usernames found in the entry
+// only count if confident, a weak guess is kept if no source has one.
+func (l *Login) fillUsername(entry string, order []string) {
+	for _, source := range order {
+		var guess string
+		switch source {
+		case pass.UsernameFromBody:
+			if l.confidence >= minUsernameConfidence {
+				return
+			}
+		case pass.UsernameFromFilename:
+			guess = guessUsername(entry)
+		case pass.UsernameFromDirectory:
+			guess = guessDirectoryUsername(entry)
+		}
+		if guess != "" {
+			l.Username = guess
+			return
 		}
+	}
+}
 
-		// Get message body
-		var data map[string]string
-		lr := &io.LimitedReader{R: stdin, N: int64(n)}
-		if err := json.NewDecoder(lr).Decode(&data); err != nil {
-			return err
+// useUsernameFields takes the username from the first of fields, keys
+// configured in the store's settings, that the entry has.
+func (l *Login) useUsernameFields(fields []string) {
+	for _, field := range fields {
+		if value := l.Fields[strings.ToLower(field)]; value != "" {
+			l.Username, l.confidence = value, confidenceLabel
+			return
 		}
+	}
+}
+
+// frame encodes l as a native message. The JSON is assembled in locked
+// memory so the password is never copied into swappable buffers.
+func (l *Login) frame() (lockedFrame, error) {
+	buf, err := NewSecureBytes(64 + len(l.Username) + 2*l.Password.Len())
+	if err != nil {
+		return lockedFrame{}, err
+	}
+
+	buf.WriteString(`{"u":`)
+	if err := buf.appendJSONString([]byte(l.Username)); err != nil {
+		buf.Wipe()
+		return lockedFrame{}, err
+	}
+	buf.WriteString(`,"p":`)
+	if err := buf.appendJSONString(l.Password.Bytes()); err != nil {
+		buf.Wipe()
+		return lockedFrame{}, err
+	}
+	if _, err := buf.WriteString("}\n"); err != nil {
+		buf.Wipe()
+		return lockedFrame{}, err
+	}
+	return lockedFrame{buf}, nil
+}
+
+// lockedFrame is a response encoded in locked memory. It is wiped once
+// written.
+type lockedFrame struct {
+	*SecureBytes
+}
+
+// WriteFrame implements protocol.Framer.
+func (f lockedFrame) WriteFrame(w io.Writer) error {
+	defer f.Wipe()
+	return protocol.WriteFrame(w, f.Bytes())
+}
+
+// searchResult is a search match annotated for the tab that requested it.
+// Inexact results were found for a parent domain of the tab's host, and the
+// extension must confirm with the user before filling them.
+type searchResult struct {
+	Entry   string `json:"entry"`
+	Inexact bool   `json:"inexact"`
+}
+
+// Error codes sent to the extension in structured error responses.
+const (
+	CodeInvalidItem = "INVALID_ITEM"
+	CodeNotFound    = "NOT_FOUND"
+	CodeLocked      = "LOCKED"
+	CodeBadSession  = "INVALID_SESSION"
+	CodeNoSession   = "NO_SESSION"
+	// CodeDecryptFailed is returned when an entry fails to decrypt for
+	// another reason than those below.
+	CodeDecryptFailed = "DECRYPT_FAILED"
+	// CodeStoreUnavailable is returned when the store can't be read.
+	CodeStoreUnavailable = "STORE_UNAVAILABLE"
+	// CodeStoreNotInitialized is returned until the store is created.
+	CodeStoreNotInitialized = "STORE_NOT_INITIALIZED"
+	// CodeInvalidRequest is returned for requests with missing or malformed
+	// fields.
+	CodeInvalidRequest = "INVALID_REQUEST"
+
+	// CodeConfirmationRequired is returned for a "get" of an entry that
+	// doesn't match the requesting tab's host. The extension has to ask the
+	// user and repeat the request with "confirmed" set to "true".
+	CodeConfirmationRequired = "CONFIRMATION_REQUIRED"
+
+	// CodeAuditBroken is returned by the "audit" action when records of the
+	// audit log were edited or removed.
+	CodeAuditBroken = "AUDIT_BROKEN"
+
+	// CodeDenied is returned when the user doesn't approve an entry being
+	// returned, see ConfirmCommand.
+	CodeDenied = "DENIED"
+
+	// CodeRateLimited is returned when more entries were decrypted in the
+	// last minute than DecryptionsPerMinute allows.
+	CodeRateLimited = "RATE_LIMITED"
+
+	// CodeExists is returned for a "create" of an entry that exists.
+	CodeExists = "EXISTS"
+
+	// CodeUnavailable is returned for a "copy" on a system without a
+	// clipboard tool.
+	CodeUnavailable = "UNAVAILABLE"
+
+	// CodeNoAgent, CodeNoPinentry and CodeNoSecretKey are returned when gpg
+	// can't decrypt because of its setup. They come with a hint for fixing
+	// it.
+	CodeNoAgent     = "NO_AGENT"
+	CodeNoPinentry  = "NO_PINENTRY"
+	CodeNoSecretKey = "NO_SECRET_KEY"
+
+	// CodeBadPassphrase, CodeCanceled and CodeKeyExpired tell why gpg
+	// couldn't decrypt an entry with the key it's encrypted to.
+	CodeBadPassphrase = "BAD_PASSPHRASE"
+	CodeCanceled      = "CANCELED"
+	CodeKeyExpired    = "KEY_EXPIRED"
+)
+
+// errorResponse is sent to the extension instead of a result when a request
+// is rejected.
+type errorResponse = protocol.Error
+
+// Run starts browserpass. Requests from a caller that isn't one of
+// AllowedOrigins are answered as if the store held no matching entries.
+func Run(stdin io.Reader, stdout io.Writer, s pass.Store, caller string) error {
+	c := &conn{s: s, caller: caller, authorized: authorizeCaller(caller), needsSession: true}
+	return c.mux().Serve(stdin, stdout)
+}
+
+// mux returns the handlers of the actions of the protocol.
+func (c *conn) mux() protocol.Mux {
+	m := protocol.Mux{
+		"echo":           protocol.Echo,
+		"handshake":      c.handshake,
+		"warnings":       c.restricted(c.warnings, map[string][]string{"warnings": {}}),
+		"rules":          c.restricted(c.rules, matchingRules{}),
+		"list":           c.restricted(c.list, []string{}),
+		"search":         c.restricted(c.search, []string{}),
+		"lookup":         c.restricted(c.lookup, []searchResult{}),
+		"get":            c.restricted(c.get, ErrNotFound),
+		"fetch":          c.restricted(c.get, ErrNotFound),
+		"fetch_all":      c.restricted(c.fetchMetadata, []entryMetadata{}),
+		"meta":           c.restricted(c.meta, ErrNotFound),
+		"passkey_get":    c.restricted(c.passkeyGet, ErrNotFound),
+		"passkey_create": c.restricted(c.passkeyCreate, ErrNotFound),
+		"otp":            c.restricted(c.otpCode, ErrNotFound),
+		"copy":           c.restricted(c.copySecret, ErrNotFound),
+		"create":         c.restricted(c.create, ErrNotFound),
+		"update":         c.restricted(c.update, ErrNotFound),
+		"generate":       c.restricted(c.generatePassword, ErrNotFound),
+		"delete":         c.restricted(c.remove, ErrNotFound),
+		"move":           c.restricted(c.move, ErrNotFound),
+		"init":           c.restricted(c.initStore, ErrNotFound),
+		"reindex":        c.restricted(c.reindex, ErrNotFound),
+		"doctor":         c.restricted(c.doctor, storeReport{Problems: []pass.Problem{}}),
+		"audit":          c.restricted(c.recentAccesses, []AuditRecord{}),
+		"stats":          c.restricted(c.stats, pass.Stats{}),
+		"favicons":       c.restricted(c.favicons, map[string]string{}),
+		"secret":         c.socketOnly(c.secret),
+		"proxy":          c.socketOnly(c.proxy),
+	}
+	for action, h := range m {
+		m[action] = classified(h)
+	}
+	return m
+}
+
+// restricted answers requests from unauthorized callers with empty, after a
+// delay slowing down probing.
+func (c *conn) restricted(h protocol.Handler, empty interface{}) protocol.Handler {
+	return func(ctx context.Context, data map[string]string) (interface{}, error) {
+		if !c.authorized {
+			time.Sleep(unauthorizedDelay)
+			return empty, nil
+		}
+		return h(ctx, data)
+	}
+}
+
+// socketOnly makes h an invalid action for anything but local tools on the
+// Unix socket, including browsers relayed by a proxy.
+func (c *conn) socketOnly(h protocol.Handler) protocol.Handler {
+	return func(ctx context.Context, data map[string]string) (interface{}, error) {
+		if c.caller != SocketCaller {
+			return nil, protocol.ErrInvalidAction
+		}
+		return h(ctx, data)
+	}
+}
+
+// proxy makes a connection relaying a sandboxed browser take on its caller.
+// This message gets no response.
+func (c *conn) proxy(ctx context.Context, data map[string]string) (interface{}, error) {
+	c.caller = data["caller"]
+	c.authorized = c.caller != SocketCaller && authorizeCaller(c.caller)
+	return nil, nil
+}
+
+func (c *conn) handshake(ctx context.Context, data map[string]string) (interface{}, error) {
+	token, err := c.sess.start(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"token": token, "capabilities": c.mux().Capabilities()}, nil
+}
+
+func (c *conn) warnings(ctx context.Context, data map[string]string) (interface{}, error) {
+	warnings := []string{}
+	if checker, ok := c.s.(pass.Checker); ok {
+		list, err := checker.Warnings()
+		if err != nil {
+			return nil, err
+		}
+		warnings = append(warnings, list...)
+	}
+	if Audit != nil && Audit.Broken() != nil {
+		warnings = append(warnings, Audit.Broken().Error())
+	}
+	return map[string][]string{"warnings": warnings}, nil
+}
+
+func (c *conn) rules(ctx context.Context, data map[string]string) (interface{}, error) {
+	return rulesFor(c.s, c.caller, data["container"])
+}
+
+func (c *conn) list(ctx context.Context, data map[string]string) (interface{}, error) {
+	list, err := c.s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if data["archived"] != "true" {
+		list = withoutArchived(list)
+	}
+	return filterAllowed(c.caller, data["container"], list), nil
+}
+
+func (c *conn) search(ctx context.Context, data map[string]string) (interface{}, error) {
+	page, refused := pageOptions(data)
+	if refused != nil {
+		return refused, nil
+	}
+	var opts []pass.SearchOption
+	switch mode := data["mode"]; mode {
+	case "", pass.SearchPrefix, pass.SearchSubstring:
+		opts = append(opts, pass.WithMode(mode))
+	case pass.SearchRegex:
+		if _, err := regexp.Compile(data["domain"]); err != nil {
+			return errorResponse{Message: err.Error(), Code: CodeInvalidRequest}, nil
+		}
+		opts = append(opts, pass.WithMode(mode))
+	default:
+		return errorResponse{Message: "unknown search mode " + mode, Code: CodeInvalidRequest}, nil
+	}
+	list, err := c.s.Search(ctx, data["domain"], opts...)
+	if err != nil {
+		return nil, err
+	}
+	if list, err = c.appendIndexed(ctx, list, data["domain"], data["host"]); err != nil {
+		return nil, err
+	}
+	// Retired accounts only show up when asked for
+	if data["archived"] != "true" {
+		list = withoutArchived(list)
+	}
+	host := data["host"]
+	if host != "" {
+		list = appendKnownURLs(list, host)
+	}
+	list = pass.Page(filterAllowed(c.caller, data["container"], list), page...)
+	if host == "" {
+		return list, nil
+	}
+
+	// Requests naming the tab's host get annotated results
+	settings, err := pass.SettingsOf(c.s)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]searchResult, len(list))
+	for i, entry := range list {
+		results[i] = searchResult{Entry: entry, Inexact: !matchesHost(entry, host, settings) && !knownURLs.has(entry, host)}
+	}
+	return results, nil
+}
+
+// lookup returns the entries for "host", see Lookup, sending each as an
+// "entry" event once found if the request asks for events. Like search
+// results they are annotated as inexact if they are for a parent domain of
+// the host.
+func (c *conn) lookup(ctx context.Context, data map[string]string) (interface{}, error) {
+	page, refused := pageOptions(data)
+	if refused != nil {
+		return refused, nil
+	}
+	// Requests asking for events get the entries as the store's walk finds
+	// them, ahead of the page of them in the response
+	var found func(entry string, inexact bool)
+	if data["events"] == "true" {
+		sent := make(map[string]bool)
+		found = func(entry string, inexact bool) {
+			if !sent[entry] && allowedItem(c.caller, data["container"], entry) {
+				sent[entry] = true
+				protocol.NotifyWith(ctx, "entry", map[string]string{"entry": entry, "inexact": strconv.FormatBool(inexact)})
+			}
+		}
+	}
+	list, inexact, err := lookup(ctx, c.s, data["host"], found)
+	if err != nil {
+		return nil, err
+	}
+	list = pass.Page(filterAllowed(c.caller, data["container"], list), page...)
+	results := make([]searchResult, len(list))
+	for i, entry := range list {
+		results[i] = searchResult{Entry: entry, Inexact: inexact}
+	}
+	return results, nil
+}
+
+// pageOptions returns the page of results a request asks for with its
+// optional "limit" and "offset". Results are paged once filtered, so pages
+// don't depend on what the caller may see.
+func pageOptions(data map[string]string) ([]pass.SearchOption, *errorResponse) {
+	var opts []pass.SearchOption
+	for key, option := range map[string]func(int) pass.SearchOption{"limit": pass.WithLimit, "offset": pass.WithOffset} {
+		if data[key] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(data[key])
+		if err != nil || n < 0 {
+			return nil, &errorResponse{Message: key + " must be a non-negative number", Code: CodeInvalidRequest}
+		}
+		opts = append(opts, option(n))
+	}
+	return opts, nil
+}
+
+func (c *conn) get(ctx context.Context, data map[string]string) (interface{}, error) {
+	plaintext, refused, err := c.decryptEntry(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	if refused != nil {
+		return refused, nil
+	}
+	login, err := ParseLogin(plaintext.Bytes())
+	plaintext.Wipe()
+	if err != nil {
+		return nil, err
+	}
+	settings, err := pass.SettingsOf(c.s)
+	if err != nil {
+		login.Wipe()
+		return nil, err
+	}
+	login.resolveUsername(data["entry"], settings)
+	frame, err := login.frame()
+	login.Wipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.audit(data["entry"], data["host"]); err != nil {
+		frame.Wipe()
+		return nil, err
+	}
+	return frame, nil
+}
 
-		var resp interface{}
-		switch data["action"] {
-		case "search":
-			list, err := s.Search(data["domain"])
-			if err != nil {
-				return err
+// secret serves SSH keys and API tokens to local tools on the socket, never
+// to browsers.
+func (c *conn) secret(ctx context.Context, data map[string]string) (interface{}, error) {
+	plaintext, refused, err := c.decryptEntry(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	if refused != nil {
+		return refused, nil
+	}
+	kind, secret, err := parseSecret(plaintext.Bytes())
+	plaintext.Wipe()
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return errorResponse{Message: "entry holds no SSH key or token", Code: CodeInvalidRequest}, nil
+	}
+	frame, err := secretFrame(kind, secret)
+	secret.Wipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.audit(data["entry"], data["host"]); err != nil {
+		frame.Wipe()
+		return nil, err
+	}
+	return frame, nil
+}
+
+// conn is the state of a single connection to the extension.
+type conn struct {
+	s          pass.Store
+	caller     string
+	authorized bool
+	sess       session
+	// needsSession is set for connections speaking the protocol, whose
+	// privileged requests must carry the token of a handshake. Requests made
+	// in-process, like from the command line, have no session.
+	needsSession bool
+	// internal is set for decryptions browserpass needs itself, which
+	// Policies, DecryptionsPerMinute and the lockout don't apply to and
+	// which aren't audited
+	internal bool
+}
+
+// decryptEntry decrypts the entry requested in data after running the
+// checks guarding every decryption, and has the user confirm it is returned
+// if ConfirmCommand is set. A non-nil *errorResponse means the
+// request was refused and should be answered with it.
+func (c *conn) decryptEntry(ctx context.Context, data map[string]string) (*SecureBytes, *errorResponse, error) {
+	if refused := c.checkSession(data); refused != nil {
+		return nil, refused, nil
+	}
+	plaintext, refused, err := c.decryptItem(ctx, data["entry"], data)
+	if plaintext == nil {
+		return nil, refused, err
+	}
+	if refused, err = c.confirm(ctx, data); refused != nil || err != nil {
+		plaintext.Wipe()
+		return nil, refused, err
+	}
+	return plaintext, nil, nil
+}
+
+// decryptItem is decryptEntry for item once the session is verified. It is
+// safe to run concurrently, see fetchAll.
+func (c *conn) decryptItem(ctx context.Context, item string, data map[string]string) (*SecureBytes, *errorResponse, error) {
+	if err := validateItem(item); err != nil {
+		return nil, &errorResponse{Message: err.Error(), Code: CodeInvalidItem}, nil
+	}
+	// Entries outside the caller's policy or container don't exist as far as
+	// it knows
+	if !c.internal && !allowedItem(c.caller, data["container"], item) {
+		refused := ErrNotFound
+		return nil, &refused, nil
+	}
+
+	rc, err := c.s.Open(ctx, item)
+	if err == pass.ErrNotFound {
+		return nil, &errorResponse{Message: err.Error(), Code: CodeNotFound}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rc.Close()
+
+	// Never decrypt while the desktop session is locked. A failed check must
+	// not lock users out, so it counts as unlocked.
+	if locked, _ := sessionLocked(); locked {
+		lockSession()
+		return nil, &errorResponse{Message: "session is locked", Code: CodeLocked}, nil
+	}
+
+	plaintext, err := Plaintexts.get(item)
+	if err != nil {
+		return nil, nil, err
+	}
+	if plaintext == nil {
+		// Back off after repeated decryption failures, which internal ones
+		// don't count toward
+		if err := checkLockout(time.Now()); err != nil {
+			return nil, &errorResponse{Message: err.Error(), Code: CodeLocked}, nil
+		}
+		if !c.internal {
+			if err := takeDecryption(time.Now()); err != nil {
+				return nil, &errorResponse{Message: err.Error(), Code: CodeRateLimited}, nil
 			}
-			resp = list
-		case "get":
-			rc, err := s.Open(data["entry"])
-			if err != nil {
-				return err
+		}
+		if plaintext, err = decrypt(ctx, rc); err != nil {
+			// Requests the browser gave up on and failures of the setup of
+			// gpg are no failed decryptions, wrong passphrases are
+			refused := gpgRefusal(err)
+			if !c.internal && ctx.Err() == nil && (refused == nil || refused.Code == CodeBadPassphrase) {
+				recordDecryption(false, time.Now())
 			}
-			defer rc.Close()
-			login, err := readLoginGPG(rc)
-			if err != nil {
-				return err
+			if refused != nil {
+				return nil, refused, nil
 			}
-			if login.Username == "" {
-				login.Username = guessUsername(data["entry"])
+			if ctx.Err() != nil {
+				return nil, nil, err
 			}
-			resp = login
-		default:
-			return errors.New("Invalid action")
+			refused = &errorResponse{Code: CodeDecryptFailed, Message: err.Error()}
+			return nil, refused, nil
 		}
-
-		var b bytes.Buffer
-		if err := json.NewEncoder(&b).Encode(resp); err != nil {
-			return err
+		if !c.internal {
+			if err := recordDecryption(true, time.Now()); err != nil {
+				plaintext.Wipe()
+				return nil, nil, err
+			}
 		}
+		if err := Plaintexts.put(item, plaintext); err != nil {
+			plaintext.Wipe()
+			return nil, nil, err
+		}
+	}
 
-		if err := binary.Write(stdout, endianness, uint32(b.Len())); err != nil {
-			return err
+	// Entries match the tab by name or by one of their URLs
+	hosts := entryHosts(plaintext.Bytes())
+	knownURLs.update(item, hosts)
+	settings, err := pass.SettingsOf(c.s)
+	if err != nil {
+		plaintext.Wipe()
+		return nil, nil, err
+	}
+	if data["confirmed"] != "true" && !c.internal {
+		// Without the tab's host, entries for sites are as good as
+		// requested from another site
+		host := data["host"]
+		if host == "" && len(hosts) > 0 {
+			plaintext.Wipe()
+			return nil, &errorResponse{Message: "entry is for " + hosts[0] + " but no host was given", Code: CodeConfirmationRequired}, nil
 		}
-		if _, err := b.WriteTo(stdout); err != nil {
-			return err
+		if host != "" && !matchesHost(item, host, settings) && !containsHost(hosts, host) {
+			plaintext.Wipe()
+			return nil, &errorResponse{Message: "entry does not match " + host, Code: CodeConfirmationRequired}, nil
 		}
 	}
+	return plaintext, nil, nil
 }
 
-// readLoginGPG reads a encrypted login from r using the system's GPG binary.
-func readLoginGPG(r io.Reader) (*Login, error) {
-	// Assume gpg1
-	gpgbin := "gpg"
-	opts := []string{"--decrypt", "--yes", "--quiet"}
-
-	// Check if gpg2 is available
-	which := exec.Command("which", "gpg2")
-	if err := which.Run(); err == nil {
-		gpgbin = "gpg2"
-		opts = append(opts, "--use-agent", "--batch")
+// passkeyGet signs a WebAuthn assertion with the passkey stored in the
+// requested entry, for the relying party "rp_id" and the base64url encoded
+// "client_data_hash" in data.
+func (c *conn) passkeyGet(ctx context.Context, data map[string]string) (interface{}, error) {
+	clientDataHash, err := base64.RawURLEncoding.DecodeString(data["client_data_hash"])
+	if err != nil {
+		return errorResponse{Message: "invalid client_data_hash", Code: CodeInvalidRequest}, nil
+	}
+	if host := data["host"]; host != "" && !passkey.ValidRPID(data["rp_id"], host) {
+		return errorResponse{Message: data["rp_id"] + " is not valid for " + host, Code: CodeInvalidRequest}, nil
 	}
 
-	// Tell gpg to read from stdin
-	opts = append(opts, "-")
+	plaintext, refused, err := c.decryptEntry(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	if refused != nil {
+		return refused, nil
+	}
+	p, err := passkey.Parse(plaintext.Bytes())
+	plaintext.Wipe()
+	if err != nil {
+		return errorResponse{Message: err.Error(), Code: CodeInvalidRequest}, nil
+	}
+	if p.RPID != data["rp_id"] {
+		return errorResponse{Message: "passkey is not for " + data["rp_id"], Code: CodeInvalidRequest}, nil
+	}
 
-	// Run gpg
-	cmd := exec.Command(gpgbin, opts...)
+	// Nothing here verifies the user, a PIN or biometrics, so only their
+	// presence is asserted
+	authData, signature, err := p.Assert(clientDataHash, passkey.FlagUserPresent)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.audit(data["entry"], data["host"]); err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"credential_id":      base64.RawURLEncoding.EncodeToString(p.CredentialID),
+		"user_handle":        base64.RawURLEncoding.EncodeToString(p.UserHandle),
+		"authenticator_data": base64.RawURLEncoding.EncodeToString(authData),
+		"signature":          base64.RawURLEncoding.EncodeToString(signature),
+	}, nil
+}
 
-	cmd.Stdin = r
+// passkeyCreate creates a passkey for the relying party "rp_id" and the
+// account "user_name" with the base64url encoded "user_handle" in data, and
+// saves it as a new entry, for navigator.credentials.create. Like create it
+// fails with CodeExists if the entry exists.
+func (c *conn) passkeyCreate(ctx context.Context, data map[string]string) (interface{}, error) {
+	item := data["entry"]
+	if refused := c.checkWrite(data); refused != nil {
+		return refused, nil
+	}
+	if data["rp_id"] == "" {
+		return errorResponse{Message: "missing rp_id", Code: CodeInvalidRequest}, nil
+	}
+	if host := data["host"]; host != "" && !passkey.ValidRPID(data["rp_id"], host) {
+		return errorResponse{Message: data["rp_id"] + " is not valid for " + host, Code: CodeInvalidRequest}, nil
+	}
+	if err := singleLine(data["rp_id"], data["user_name"]); err != nil {
+		return errorResponse{Message: err.Error(), Code: CodeInvalidRequest}, nil
+	}
+	userHandle, err := base64.RawURLEncoding.DecodeString(data["user_handle"])
+	if err != nil || len(userHandle) == 0 {
+		return errorResponse{Message: "invalid user_handle", Code: CodeInvalidRequest}, nil
+	}
 
-	rc, err := cmd.StdoutPipe()
+	p, err := passkey.New(data["rp_id"], data["user_name"], userHandle)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := p.Marshal()
 	if err != nil {
 		return nil, err
 	}
+	// The first line is the password, which a passkey entry doesn't have
+	content := append([]byte("\n"), fields...)
+	err = c.s.Create(item, content)
+	for i := range content {
+		content[i] = 0
+	}
+	for i := range fields {
+		fields[i] = 0
+	}
+	Plaintexts.Forget(item)
+	dropLoadedIndex()
+	switch err {
+	case nil:
+	case pass.ErrExists:
+		return errorResponse{Message: err.Error(), Code: CodeExists}, nil
+	case pass.ErrReadOnly:
+		return errorResponse{Message: err.Error(), Code: CodeInvalidRequest}, nil
+	default:
+		return nil, err
+	}
+	if err := c.audit(item, data["host"]); err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"entry":              item,
+		"credential_id":      base64.RawURLEncoding.EncodeToString(p.CredentialID),
+		"attestation_object": base64.RawURLEncoding.EncodeToString(p.AttestationObject(passkey.FlagUserPresent)),
+	}, nil
+}
 
-	var errbuf bytes.Buffer
-	cmd.Stderr = &errbuf
+// audit records that the caller was sent a secret from item, for a tab on
+// host if known. Internal decryptions aren't recorded.
+func (c *conn) audit(item, host string) error {
+	if Audit == nil || c.internal {
+		return nil
+	}
+	return Audit.Record(c.caller, host, item)
+}
 
-	if err := cmd.Start(); err != nil {
-		return nil, err
+// matchesHost reports whether one of entry's path segments that may name a
+// domain, see pass.Settings.DomainNames, names host exactly, ignoring a
+// leading "www.". Entries stored under a parent domain, or only sharing a
+// prefix with it, don't match.
+func matchesHost(entry, host string, settings *pass.Settings) bool {
+	host = canonicalHost(host)
+	for _, seg := range settings.DomainNames(entry) {
+		if canonicalHost(seg) == host {
+			return true
+		}
+	}
+	return false
+}
+
+// validateItem rejects entry names that could escape the password store or
+// confuse a Store backend: empty names, NUL and control characters, absolute
+// paths and ".." segments.
+func validateItem(item string) error {
+	if item == "" {
+		return errors.New("empty item name")
 	}
+	for _, r := range item {
+		if r == 0 || unicode.IsControl(r) {
+			return errors.New("item name contains control characters")
+		}
+	}
+	if filepath.IsAbs(item) || strings.HasPrefix(item, "/") || strings.HasPrefix(item, `\`) {
+		return errors.New("item name must be relative")
+	}
+	for _, seg := range strings.FieldsFunc(item, isPathSeparator) {
+		if seg == ".." {
+			return errors.New("item name must not contain '..'")
+		}
+	}
+	return nil
+}
+
+func isPathSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
 
-	// Read decrypted output
-	login, err := parseLogin(rc)
+// decrypt decrypts an entry read from r with DefaultDecrypter, straight
+// into locked memory.
+func decrypt(ctx context.Context, r io.Reader) (*SecureBytes, error) {
+	plaintext, err := NewSecureBytes(4096)
 	if err != nil {
 		return nil, err
 	}
-	rc.Close()
-
-	if err := cmd.Wait(); err != nil {
-		return nil, errors.New(err.Error() + "\n" + errbuf.String())
+	if _, ok := r.(pass.Unencrypted); ok {
+		err = copyPlaintext(plaintext, r)
+	} else {
+		err = DefaultDecrypter.Decrypt(ctx, plaintext, r)
 	}
-	return login, nil
+	if err != nil {
+		plaintext.Wipe()
+		return nil, err
+	}
+	return plaintext, nil
 }
 
-// parseLogin parses a login and a password from a decrypted password file.
-func parseLogin(r io.Reader) (*Login, error) {
-	login := new(Login)
+// ParseLogin parses a login from a decrypted password file in the usual pass
+// layout: the password on the first line, followed by "key: value" lines
+// such as "login:" and "url:" and possibly an otpauth:// URI. The password
+// and OTP URI are copied into their own locked buffers; the caller remains
+// responsible for wiping plaintext and, with Login.Wipe, the login.
+func ParseLogin(plaintext []byte) (*Login, error) {
+	login := &Login{Fields: make(map[string]string)}
+
+	lines := bytes.Split(plaintext, []byte("\n"))
 
-	scanner := bufio.NewScanner(r)
+	// The first line is the password, unless it is the otpauth URI of an
+	// OTP-only entry. OTP seeds are never sent to the extension.
+	password := bytes.TrimSuffix(lines[0], []byte("\r"))
+	if isOTPURI(password) {
+		password = nil
+	}
+	var err error
+	if login.Password, err = NewSecureBytes(len(password)); err != nil {
+		return nil, err
+	}
+	login.Password.Write(password)
 
-	// The first line is the password
-	scanner.Scan()
-	login.Password = scanner.Text()
+	if uri := otpURI(plaintext); uri != nil {
+		if login.OTP, err = NewSecureBytes(len(uri)); err != nil {
+			login.Wipe()
+			return nil, err
+		}
+		login.OTP.Write(uri)
+	}
 
-	// Keep reading file for string in "login:", "username:" or "user:" format (case insensitive).
-	re := regexp.MustCompile("(?i)^(login|username|user):")
-	for scanner.Scan() {
-		line := scanner.Text()
-		replaced := re.ReplaceAllString(line, "")
-		if len(replaced) != len(line) {
-			login.Username = strings.TrimSpace(replaced)
+	login.Username, login.confidence = detectUsername(lines[1:])
+	if urls := entryURLs(plaintext); len(urls) > 0 {
+		login.URL = urls[0]
+	}
+	for _, line := range lines[1:] {
+		if isOTPURI(line) {
+			continue
+		}
+		// Skip bare URLs, "https" is no key
+		if key, value, ok := strings.Cut(string(line), ":"); ok && !strings.HasPrefix(value, "//") {
+			key = strings.ToLower(strings.TrimSpace(key))
+			if key != "" && !strings.ContainsAny(key, " \t") {
+				login.Fields[key] = strings.TrimSpace(value)
+			}
 		}
 	}
 
 	return login, nil
 }
 
+// isOTPURI reports whether line holds an otpauth:// URI.
+func isOTPURI(line []byte) bool {
+	line = bytes.TrimSpace(line)
+	return len(line) >= len("otpauth://") && strings.EqualFold(string(line[:len("otpauth://")]), "otpauth://")
+}
+
 // guessLogin tries to guess a username from an entry's name.
 func guessUsername(name string) string {
 	if strings.Count(name, "/") >= 1 {
@@ -155,3 +830,13 @@ func guessUsername(name string) string {
 	}
 	return ""
 }
+
+// guessDirectoryUsername guesses a username from the name of an entry's
+// directory, for stores laid out as "example.com/alice/main". Top-level
+// directories name sites, not users.
+func guessDirectoryUsername(name string) string {
+	if strings.Count(name, "/") >= 2 {
+		return filepath.Base(filepath.Dir(name))
+	}
+	return ""
+}
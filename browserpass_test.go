@@ -1,26 +1,96 @@
 package browserpass
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
+
+	"github.com/dannyvankooten/browserpass/fixture"
+	"github.com/dannyvankooten/browserpass/gpg"
+	"github.com/dannyvankooten/browserpass/pass"
+	"github.com/dannyvankooten/browserpass/passkey"
+	"github.com/dannyvankooten/browserpass/protocol"
 )
 
 func TestParseLogin(t *testing.T) {
-	r := strings.NewReader("password\n\nfoo\nlogin: bar")
-
-	login, err := parseLogin(r)
+	login, err := ParseLogin([]byte("password\n\nfoo\nlogin: bar"))
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer login.Wipe()
 
-	if login.Password != "password" {
-		t.Errorf("Password is %s, expected %s", login.Password, "password")
+	if password := string(login.Password.Bytes()); password != "password" {
+		t.Errorf("Password is %s, expected %s", password, "password")
 	}
 	if login.Username != "bar" {
 		t.Errorf("Username is %s, expected %s", login.Username, "bar")
 	}
 }
 
+func TestParseLoginOTPOnly(t *testing.T) {
+	login, err := ParseLogin([]byte("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP\nlogin: alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer login.Wipe()
+
+	if login.Password.Len() != 0 {
+		t.Errorf("OTP seed returned as password: %s", login.Password.Bytes())
+	}
+}
+
+func TestParseLoginFields(t *testing.T) {
+	login, err := ParseLogin([]byte("hunter2\r\nuser: alice\nURL: https://example.com/login\nPIN : 1234\notpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP\nhttps://example.org\nsome notes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer login.Wipe()
+
+	if password := string(login.Password.Bytes()); password != "hunter2" {
+		t.Errorf("Password is %q, expected hunter2", password)
+	}
+	if login.Username != "alice" {
+		t.Errorf("Username is %q, expected alice", login.Username)
+	}
+	if login.URL != "https://example.com/login" {
+		t.Errorf("URL is %q, expected https://example.com/login", login.URL)
+	}
+	if login.OTP == nil || string(login.OTP.Bytes()) != "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP" {
+		t.Errorf("Unexpected OTP %v", login.OTP)
+	}
+	expected := map[string]string{"user": "alice", "url": "https://example.com/login", "pin": "1234"}
+	if !reflect.DeepEqual(login.Fields, expected) {
+		t.Errorf("Fields are %v, expected %v", login.Fields, expected)
+	}
+}
+
+func TestLoginUsernameFields(t *testing.T) {
+	login, err := ParseLogin([]byte("hunter2\nlogin: alice\nEmail: alice@example.com\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer login.Wipe()
+
+	login.useUsernameFields([]string{"phone", "email"})
+	if login.Username != "alice@example.com" {
+		t.Errorf("Username is %q, expected the email field", login.Username)
+	}
+	login.fillUsername("example.com/bob", pass.DefaultUsernameFrom)
+	if login.Username != "alice@example.com" {
+		t.Errorf("Username field replaced by %q", login.Username)
+	}
+}
+
 func TestGuessUsername(t *testing.T) {
 	tests := map[string]string{
 		"foo":     "",
@@ -32,4 +102,453 @@ func TestGuessUsername(t *testing.T) {
 			t.Errorf("guessUsername(%s): expected %s, got %s", input, expected, username)
 		}
 	}
+	for input, expected := range map[string]string{"foo/bar": "", "foo/bar/baz": "bar", "work:foo/bar/baz": "bar"} {
+		if username := guessDirectoryUsername(input); username != expected {
+			t.Errorf("guessDirectoryUsername(%s): expected %s, got %s", input, expected, username)
+		}
+	}
+}
+
+func TestValidateItem(t *testing.T) {
+	tests := map[string]bool{
+		"example.com/alice":      true,
+		"example.com":            true,
+		"work/..hidden/bob":      true,
+		"":                       false,
+		"/etc/passwd":            false,
+		`\windows\system32`:      false,
+		"../outside":             false,
+		"example.com/../../etc":  false,
+		"example.com/al\x00ice":  false,
+		"example.com/alice\nbob": false,
+	}
+
+	for input, valid := range tests {
+		if err := validateItem(input); (err == nil) != valid {
+			t.Errorf("validateItem(%q): expected valid=%v, got error %v", input, valid, err)
+		}
+	}
+}
+
+func TestLoginFrame(t *testing.T) {
+	password, err := NewSecureBytes(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer password.Wipe()
+	password.WriteString("p\"a\\s\ts\u00e9")
+
+	frame, err := (&Login{Username: "bar", Password: password}).frame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := frame.WriteFrame(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]string
+	if err := protocol.ReadMessage(&b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 0 {
+		t.Errorf("%d bytes left after the frame", b.Len())
+	}
+	if decoded["u"] != "bar" || decoded["p"] != "p\"a\\s\ts\u00e9" {
+		t.Errorf("Unexpected login %v", decoded)
+	}
+}
+
+func TestMatchesHost(t *testing.T) {
+	tests := []struct {
+		entry, host string
+		expected    bool
+	}{
+		{"example.com/alice", "example.com", true},
+		{"example.com/alice", "www.example.com", true},
+		{"sites/Example.com", "example.com", true},
+		{"example.com/alice", "projects.example.com", false},
+		{"example.community/alice", "example.com", false},
+		{"projects.example.com/alice", "projects.example.com", true},
+		{"work:example.com/alice", "example.com", true},
+	}
+
+	for _, test := range tests {
+		if actual := matchesHost(test.entry, test.host, &pass.Settings{}); actual != test.expected {
+			t.Errorf("matchesHost(%s, %s): expected %v, got %v", test.entry, test.host, test.expected, actual)
+		}
+	}
+
+	// Stores may tell which segments name domains
+	settings := &pass.Settings{DomainSegments: []int{-2}}
+	if !matchesHost("sites/example.com/alice", "example.com", settings) || matchesHost("example.com/alice/example.org", "example.org", settings) {
+		t.Error("matchesHost didn't follow the domain segments")
+	}
+}
+
+// fakeStore is a pass.Store serving a fixed list of entries.
+type fakeStore []string
+
+func (s fakeStore) Search(ctx context.Context, query string, opts ...pass.SearchOption) ([]string, error) {
+	var matches []string
+	for _, item := range s {
+		if strings.HasPrefix(item, query) {
+			matches = append(matches, item)
+		}
+	}
+	return pass.Page(matches, opts...), nil
+}
+
+func (s fakeStore) List(ctx context.Context) ([]string, error) {
+	return s, nil
+}
+
+// Open returns fixture entries with the password "password-of-<item>".
+func (s fakeStore) Open(ctx context.Context, item string) (io.ReadCloser, error) {
+	for _, i := range s {
+		if i == item {
+			return ioutil.NopCloser(strings.NewReader(fixture.FakeHeader + "password-of-" + item + "\n")), nil
+		}
+	}
+	return nil, pass.ErrNotFound
+}
+
+// Create accepts new entries without storing them.
+func (s fakeStore) Create(item string, content []byte) error {
+	for _, i := range s {
+		if i == item {
+			return pass.ErrExists
+		}
+	}
+	return nil
+}
+
+// Delete pretends to delete existing entries.
+func (s fakeStore) Delete(item string) error {
+	for _, i := range s {
+		if i == item {
+			return nil
+		}
+	}
+	return pass.ErrNotFound
+}
+
+func (s fakeStore) LookupStream(ctx context.Context, domain string) (<-chan string, <-chan error) {
+	return pass.StreamSearch(ctx, s, domain)
+}
+
+// roundTrip sends a single request to Run and decodes the response into resp.
+// The input stays open until the response is read, like a browser's. The
+// request carries the token of a handshake unless it has one.
+func roundTrip(t *testing.T, s pass.Store, caller string, req map[string]string, resp interface{}) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := Run(inR, outW, s, caller)
+		outW.CloseWithError(err)
+		done <- err
+	}()
+	if _, ok := req["token"]; !ok {
+		if err := protocol.WriteMessage(inW, map[string]string{"action": "handshake"}); err != nil {
+			t.Fatal(err)
+		}
+		var handshake struct {
+			Token string `json:"token"`
+		}
+		if err := protocol.ReadMessage(outR, &handshake); err != nil {
+			t.Fatal(err)
+		}
+		req = copyRequest(req)
+		req["token"] = handshake.Token
+	}
+	if err := protocol.WriteMessage(inW, req); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := protocol.ReadMessage(outR, resp); err != nil {
+		t.Fatal(err)
+	}
+	inW.Close()
+	if err := <-done; err != io.EOF {
+		t.Fatalf("Run returned %v, expected EOF", err)
+	}
+}
+
+// copyRequest returns a copy of req to add fields to.
+func copyRequest(req map[string]string) map[string]string {
+	c := make(map[string]string, len(req)+1)
+	for k, v := range req {
+		c[k] = v
+	}
+	return c
+}
+
+func TestRunGetConfirmation(t *testing.T) {
+	s := fakeStore{"example.com/alice"}
+	caller := AllowedOrigins[0]
+
+	var resp errorResponse
+	roundTrip(t, s, caller, map[string]string{"action": "get", "entry": "example.com/alice", "host": "evil.com"}, &resp)
+	if resp.Code != CodeConfirmationRequired {
+		t.Errorf("Code is %s, expected %s", resp.Code, CodeConfirmationRequired)
+	}
+
+	var login map[string]string
+	roundTrip(t, s, caller, map[string]string{"action": "get", "entry": "example.com/alice", "host": "evil.com", "confirmed": "true"}, &login)
+	if login["p"] != "password-of-example.com/alice" || login["u"] != "alice" {
+		t.Errorf("Unexpected login %v", login)
+	}
+}
+
+func TestRunGetConfirmCommand(t *testing.T) {
+	ConfirmCommand = []string{"sh", "-c", `test "$BROWSERPASS_ENTRY" = example.com/alice && test "$BROWSERPASS_HOST" = example.com`}
+	defer func() { ConfirmCommand = nil }()
+	s := fakeStore{"example.com/alice", "example.com/bob"}
+	caller := AllowedOrigins[0]
+
+	var login map[string]string
+	roundTrip(t, s, caller, map[string]string{"action": "get", "entry": "example.com/alice", "host": "example.com"}, &login)
+	if login["p"] != "password-of-example.com/alice" {
+		t.Errorf("Unexpected login %v", login)
+	}
+
+	var resp errorResponse
+	roundTrip(t, s, caller, map[string]string{"action": "get", "entry": "example.com/bob", "host": "example.com"}, &resp)
+	if resp.Code != CodeDenied {
+		t.Errorf("Code is %s, expected %s", resp.Code, CodeDenied)
+	}
+}
+
+func TestRunSearchMode(t *testing.T) {
+	s := fakeStore{"example.com/alice"}
+	caller := AllowedOrigins[0]
+	for _, req := range []map[string]string{{"mode": "regex", "domain": "[a-"}, {"mode": "glob", "domain": "*"}} {
+		var resp errorResponse
+		req["action"] = "search"
+		roundTrip(t, s, caller, req, &resp)
+		if resp.Code != CodeInvalidRequest {
+			t.Errorf("search %v returned %+v", req, resp)
+		}
+	}
+}
+
+func TestRunSearchPage(t *testing.T) {
+	s := fakeStore{"example.com/alice", "example.com/bob", "example.com/carol", "example.com/dave"}
+	caller := AllowedOrigins[0]
+
+	var list []string
+	roundTrip(t, s, caller, map[string]string{"action": "search", "domain": "example.com", "limit": "2", "offset": "1"}, &list)
+	if expected := []string{"example.com/bob", "example.com/carol"}; !reflect.DeepEqual(list, expected) {
+		t.Errorf("search: expected %v, got %v", expected, list)
+	}
+	var results []searchResult
+	roundTrip(t, s, caller, map[string]string{"action": "lookup", "host": "example.com", "limit": "2", "offset": "1"}, &results)
+	if expected := []searchResult{{Entry: "example.com/bob"}, {Entry: "example.com/carol"}}; !reflect.DeepEqual(results, expected) {
+		t.Errorf("lookup: expected %v, got %v", expected, results)
+	}
+
+	var resp errorResponse
+	roundTrip(t, s, caller, map[string]string{"action": "search", "domain": "example.com", "limit": "-1"}, &resp)
+	if resp.Code != CodeInvalidRequest {
+		t.Errorf("Code is %q, expected %s", resp.Code, CodeInvalidRequest)
+	}
+}
+
+func TestRunLookupEvents(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{".gpg-id", "example.com/alice.gpg", "example.com/bob.gpg", "example.community/carol.gpg", "personal/example.com/dave.gpg"} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0700)
+		os.WriteFile(filepath.Join(dir, name), []byte("alice@example.com\n"), 0600)
+	}
+	s, err := pass.NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caller := AllowedOrigins[0]
+	Policies = map[string][]string{caller: {"example.com"}}
+	defer func() { Policies = nil }()
+
+	// Like roundTrip, reading the events before the response
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := Run(inR, outW, s, caller)
+		outW.CloseWithError(err)
+		done <- err
+	}()
+	protocol.WriteMessage(inW, map[string]string{"action": "lookup", "host": "example.com", "limit": "1", "events": "true"})
+	var found []string
+	var list []searchResult
+	for {
+		var msg json.RawMessage
+		if err := protocol.ReadMessage(outR, &msg); err != nil {
+			t.Fatal(err)
+		}
+		var event map[string]string
+		if json.Unmarshal(msg, &event) != nil {
+			if err := json.Unmarshal(msg, &list); err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+		if event["event"] != "entry" || event["inexact"] != "false" {
+			t.Fatalf("Unexpected event %v", event)
+		}
+		found = append(found, event["entry"])
+	}
+	inW.Close()
+	if err := <-done; err != io.EOF {
+		t.Fatalf("Run returned %v, expected EOF", err)
+	}
+
+	sort.Strings(found)
+	if expected := []string{"example.com/alice", "example.com/bob"}; !reflect.DeepEqual(found, expected) {
+		t.Errorf("Entries found are %v, expected %v", found, expected)
+	}
+	// The response is the page a lookup without events returns
+	var page []searchResult
+	roundTrip(t, s, caller, map[string]string{"action": "lookup", "host": "example.com", "limit": "1"}, &page)
+	if !reflect.DeepEqual(list, page) || len(page) != 1 {
+		t.Errorf("Response is %v, expected %v", list, page)
+	}
+}
+
+func TestRunLookupInexact(t *testing.T) {
+	s := fakeStore{"example.com/alice", "projects.example.com/bob"}
+	caller := AllowedOrigins[0]
+
+	tests := map[string][]searchResult{
+		"projects.example.com": {{Entry: "projects.example.com/bob"}},
+		"wiki.example.com":     {{Entry: "example.com/alice", Inexact: true}},
+	}
+	for host, expected := range tests {
+		var results []searchResult
+		roundTrip(t, s, caller, map[string]string{"action": "lookup", "host": host}, &results)
+		if !reflect.DeepEqual(results, expected) {
+			t.Errorf("lookup %s: expected %v, got %v", host, expected, results)
+		}
+	}
+}
+
+func TestRunPasskeyGet(t *testing.T) {
+	s := fakeStore{"example.com/alice"}
+	caller := AllowedOrigins[0]
+
+	tests := map[string]map[string]string{
+		"foreign rp_id": {"action": "passkey_get", "entry": "example.com/alice", "rp_id": "example.com", "host": "evil.com", "client_data_hash": "AAAA"},
+		"not a passkey": {"action": "passkey_get", "entry": "example.com/alice", "rp_id": "example.com", "host": "example.com", "client_data_hash": "AAAA"},
+		"bad hash":      {"action": "passkey_get", "entry": "example.com/alice", "rp_id": "example.com", "client_data_hash": "!"},
+	}
+	for name, req := range tests {
+		var resp errorResponse
+		roundTrip(t, s, caller, req, &resp)
+		if resp.Code != CodeInvalidRequest {
+			t.Errorf("%s: code is %q, expected %s", name, resp.Code, CodeInvalidRequest)
+		}
+	}
+}
+
+// passkeyStore is a hotpStore new entries can be created in.
+type passkeyStore struct {
+	hotpStore
+}
+
+func (s passkeyStore) Create(item string, content []byte) error {
+	if _, ok := s.content[item]; ok {
+		return pass.ErrExists
+	}
+	s.content[item] = string(content)
+	return nil
+}
+
+func TestRunPasskeyCreate(t *testing.T) {
+	s := passkeyStore{hotpStore{fakeStore{"example.com/alice", "example.com/passkey"}, map[string]string{"example.com/alice": "hunter2\n"}}}
+	caller := AllowedOrigins[0]
+	tests := map[string]struct {
+		req  map[string]string
+		code string
+	}{
+		"foreign rp_id": {map[string]string{"entry": "example.com/passkey", "rp_id": "example.com", "host": "evil.com", "user_handle": "AQID"}, CodeInvalidRequest},
+		"line break":    {map[string]string{"entry": "example.com/passkey", "rp_id": "example.com", "user_name": "alice\npasskey_rp_id: evil.com", "user_handle": "AQID"}, CodeInvalidRequest},
+		"no handle":     {map[string]string{"entry": "example.com/passkey", "rp_id": "example.com"}, CodeInvalidRequest},
+		"existing":      {map[string]string{"entry": "example.com/alice", "rp_id": "example.com", "user_handle": "AQID"}, CodeExists},
+		"outside store": {map[string]string{"entry": "../passkey", "rp_id": "example.com", "user_handle": "AQID"}, CodeInvalidItem},
+	}
+	for name, test := range tests {
+		test.req["action"] = "passkey_create"
+		var resp errorResponse
+		roundTrip(t, s, caller, test.req, &resp)
+		if resp.Code != test.code {
+			t.Errorf("%s: code is %q, expected %s", name, resp.Code, test.code)
+		}
+	}
+	if _, ok := s.content["example.com/passkey"]; ok {
+		t.Fatal("Refused passkey was saved")
+	}
+
+	var created struct {
+		errorResponse
+		Entry             string `json:"entry"`
+		CredentialID      string `json:"credential_id"`
+		AttestationObject string `json:"attestation_object"`
+	}
+	roundTrip(t, s, caller, map[string]string{"action": "passkey_create", "entry": "example.com/passkey", "rp_id": "example.com", "host": "login.example.com", "user_name": "alice", "user_handle": "AQID"}, &created)
+	if created.Code != "" || created.Entry != "example.com/passkey" || created.CredentialID == "" || created.AttestationObject == "" {
+		t.Fatalf("Unexpected response %+v", created)
+	}
+
+	hash := base64.RawURLEncoding.EncodeToString(make([]byte, 32))
+	var asserted struct {
+		errorResponse
+		CredentialID      string `json:"credential_id"`
+		UserHandle        string `json:"user_handle"`
+		AuthenticatorData string `json:"authenticator_data"`
+	}
+	roundTrip(t, s, caller, map[string]string{"action": "passkey_get", "entry": "example.com/passkey", "rp_id": "example.com", "host": "example.com", "client_data_hash": hash}, &asserted)
+	if asserted.Code != "" || asserted.CredentialID != created.CredentialID || asserted.UserHandle != "AQID" {
+		t.Fatalf("Unexpected response %+v", asserted)
+	}
+	authData, err := base64.RawURLEncoding.DecodeString(asserted.AuthenticatorData)
+	if err != nil || len(authData) != 37 || authData[32] != passkey.FlagUserPresent {
+		t.Errorf("Authenticator data is %x, %v", authData, err)
+	}
+}
+
+func TestMain(m *testing.M) {
+	DefaultDecrypter = fixture.FakeDecrypter{}
+	LockoutFile = ""
+	os.Exit(m.Run())
+}
+
+// failingDecrypter fails like gpg with its error output.
+type failingDecrypter string
+
+func (d failingDecrypter) Decrypt(ctx context.Context, dst io.Writer, src io.Reader) error {
+	return &gpg.Error{Err: errors.New("exit status 2"), Stderr: string(d)}
+}
+
+func TestRunGetGPGSetup(t *testing.T) {
+	LockoutFile = filepath.Join(t.TempDir(), "lockout.json")
+	DefaultDecrypter = failingDecrypter("gpg: public key decryption failed: No pinentry\ngpg: decryption failed: No secret key\n")
+	defer func() { DefaultDecrypter, LockoutFile = fixture.FakeDecrypter{}, "" }()
+
+	var resp errorResponse
+	roundTrip(t, fakeStore{"example.com/alice"}, AllowedOrigins[0], map[string]string{"action": "get", "entry": "example.com/alice"}, &resp)
+	if resp.Code != CodeNoPinentry || resp.Hint == "" {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+	if l := loadLockout(LockoutFile); l.Failures != 0 {
+		t.Errorf("%d failures recorded for a missing pinentry", l.Failures)
+	}
+
+	DefaultDecrypter = failingDecrypter("gpg: public key decryption failed: Bad passphrase\ngpg: decryption failed: No secret key\n")
+	roundTrip(t, fakeStore{"example.com/alice"}, AllowedOrigins[0], map[string]string{"action": "get", "entry": "example.com/alice"}, &resp)
+	if resp.Code != CodeBadPassphrase {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+	if l := loadLockout(LockoutFile); l.Failures != 1 {
+		t.Errorf("%d failures recorded for a bad passphrase", l.Failures)
+	}
 }
@@ -0,0 +1,131 @@
+// Package clipboard copies secrets to the system clipboard and restores the
+// previous clipboard contents after a timeout.
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// Selection is a clipboard buffer. Secrets only go to Clipboard: the
+// X11/Wayland primary selection is pasted by any middle click and handed to
+// any app asking for it, clipboard managers don't know it holds a secret.
+type Selection int
+
+const (
+	Clipboard Selection = iota
+)
+
+// ErrUnavailable is returned when no supported clipboard tool is installed.
+var ErrUnavailable = errors.New("clipboard: no clipboard tool available")
+
+// tool describes the commands used to read and write a selection, or the
+// platform's API if native is set, see nativeRead and nativeWrite.
+type tool struct {
+	name   string
+	read   map[Selection][]string
+	write  map[Selection][]string
+	native bool
+}
+
+var (
+	wlClipboard = tool{
+		name:  "wl-copy",
+		read:  map[Selection][]string{Clipboard: {"wl-paste", "--no-newline"}},
+		write: map[Selection][]string{Clipboard: {"wl-copy"}},
+	}
+	xclip = tool{
+		name:  "xclip",
+		read:  map[Selection][]string{Clipboard: {"xclip", "-o", "-selection", "clipboard"}},
+		write: map[Selection][]string{Clipboard: {"xclip", "-i", "-selection", "clipboard"}},
+	}
+	pbcopy = tool{
+		name:  "pbcopy",
+		read:  map[Selection][]string{Clipboard: {"pbpaste"}},
+		write: map[Selection][]string{Clipboard: {"pbcopy"}},
+	}
+	win32 = tool{name: "user32", native: true}
+)
+
+// toolFor picks the clipboard tool for the platform and display server.
+func toolFor(goos string, getenv func(string) string) *tool {
+	switch {
+	case goos == "darwin":
+		return &pbcopy
+	case goos == "windows":
+		return &win32
+	case getenv("WAYLAND_DISPLAY") != "":
+		return &wlClipboard
+	case getenv("DISPLAY") != "":
+		return &xclip
+	}
+	return nil
+}
+
+func detect() (*tool, error) {
+	t := toolFor(runtime.GOOS, os.Getenv)
+	if t == nil {
+		return nil, ErrUnavailable
+	}
+	if t.native {
+		return t, nil
+	}
+	if _, err := exec.LookPath(t.name); err != nil {
+		return nil, ErrUnavailable
+	}
+	return t, nil
+}
+
+// Selections returns the selections supported on this system.
+func Selections() ([]Selection, error) {
+	t, err := detect()
+	if err != nil {
+		return nil, err
+	}
+	var sels []Selection
+	for _, sel := range []Selection{Clipboard} {
+		if _, ok := t.write[sel]; ok || t.native {
+			sels = append(sels, sel)
+		}
+	}
+	return sels, nil
+}
+
+// Read returns the contents of sel.
+func Read(sel Selection) ([]byte, error) {
+	t, err := detect()
+	if err != nil {
+		return nil, err
+	}
+	if t.native {
+		return nativeRead()
+	}
+	args, ok := t.read[sel]
+	if !ok {
+		return nil, ErrUnavailable
+	}
+	// An empty selection makes most tools exit non-zero; treat it as empty
+	out, _ := exec.Command(args[0], args[1:]...).Output()
+	return out, nil
+}
+
+// Write replaces the contents of sel with data.
+func Write(sel Selection, data []byte) error {
+	t, err := detect()
+	if err != nil {
+		return err
+	}
+	if t.native {
+		return nativeWrite(data)
+	}
+	args, ok := t.write[sel]
+	if !ok {
+		return ErrUnavailable
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	return cmd.Run()
+}
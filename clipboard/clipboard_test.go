@@ -0,0 +1,28 @@
+package clipboard
+
+import "testing"
+
+func TestToolFor(t *testing.T) {
+	tests := []struct {
+		goos     string
+		env      map[string]string
+		expected string
+	}{
+		{"darwin", nil, "pbcopy"},
+		{"windows", nil, "user32"},
+		{"linux", map[string]string{"WAYLAND_DISPLAY": "wayland-0", "DISPLAY": ":0"}, "wl-copy"},
+		{"linux", map[string]string{"DISPLAY": ":0"}, "xclip"},
+		{"linux", nil, ""},
+	}
+
+	for _, test := range tests {
+		tool := toolFor(test.goos, func(key string) string { return test.env[key] })
+		name := ""
+		if tool != nil {
+			name = tool.name
+		}
+		if name != test.expected {
+			t.Errorf("toolFor(%s, %v): expected %q, got %q", test.goos, test.env, test.expected, name)
+		}
+	}
+}
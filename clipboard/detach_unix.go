@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package clipboard
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach starts cmd in its own session, so it survives the browser killing
+// the host's process group.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,14 @@
+package clipboard
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+const createNewProcessGroup = 0x00000200
+
+// detach starts cmd in its own process group, so it survives the browser
+// terminating the host.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: createNewProcessGroup}
+}
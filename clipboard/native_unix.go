@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package clipboard
+
+// nativeRead is only needed on Windows, other platforms have clipboard
+// tools.
+func nativeRead() ([]byte, error) {
+	return nil, ErrUnavailable
+}
+
+// nativeWrite is only needed on Windows, see nativeRead.
+func nativeWrite(data []byte) error {
+	return ErrUnavailable
+}
@@ -0,0 +1,151 @@
+package clipboard
+
+import (
+	"errors"
+	"syscall"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// The clipboard is used through user32 directly: clip.exe reads its input
+// in the console's code page and nothing in Windows reads the clipboard
+// back without PowerShell.
+var (
+	user32   = syscall.NewLazyDLL("user32.dll")
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	openClipboard           = user32.NewProc("OpenClipboard")
+	closeClipboard          = user32.NewProc("CloseClipboard")
+	emptyClipboard          = user32.NewProc("EmptyClipboard")
+	getClipboardData        = user32.NewProc("GetClipboardData")
+	setClipboardData        = user32.NewProc("SetClipboardData")
+	registerClipboardFormat = user32.NewProc("RegisterClipboardFormatW")
+	globalAlloc             = kernel32.NewProc("GlobalAlloc")
+	globalFree              = kernel32.NewProc("GlobalFree")
+	globalLock              = kernel32.NewProc("GlobalLock")
+	globalUnlock            = kernel32.NewProc("GlobalUnlock")
+	globalSize              = kernel32.NewProc("GlobalSize")
+	moveMemory              = kernel32.NewProc("RtlMoveMemory")
+)
+
+const (
+	cfUnicodeText = 13
+	gmemMoveable  = 0x0002
+)
+
+// privateFormats keep secrets out of the clipboard history and cloud
+// clipboard, and tell clipboard monitors to leave them alone.
+var privateFormats = []string{"ExcludeClipboardContentFromMonitorProcessing", "CanIncludeInClipboardHistory", "CanUploadToCloudClipboard"}
+
+// open opens the clipboard, which another program may hold for a moment.
+func open() error {
+	var err error
+	for i := 0; i < 10; i++ {
+		var r uintptr
+		if r, _, err = openClipboard.Call(0); r != 0 {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return err
+}
+
+// nativeRead returns the text on the clipboard, nothing if it holds none.
+func nativeRead() ([]byte, error) {
+	if err := open(); err != nil {
+		return nil, err
+	}
+	defer closeClipboard.Call()
+	h, _, _ := getClipboardData.Call(cfUnicodeText)
+	if h == 0 {
+		return nil, nil
+	}
+	size, _, _ := globalSize.Call(h)
+	p, _, err := globalLock.Call(h)
+	if p == 0 {
+		return nil, err
+	}
+	defer globalUnlock.Call(h)
+	text := make([]uint16, size/2)
+	if len(text) == 0 {
+		return nil, nil
+	}
+	moveMemory.Call(uintptr(unsafe.Pointer(&text[0])), p, uintptr(len(text)*2))
+	var out []byte
+	for _, r := range utf16.Decode(text) {
+		if r == 0 {
+			break
+		}
+		out = utf8.AppendRune(out, r)
+	}
+	wipe16(text)
+	return out, nil
+}
+
+// nativeWrite replaces the text on the clipboard with data, emptying it if
+// data is empty. The text is kept out of the clipboard history.
+func nativeWrite(data []byte) error {
+	var text []uint16
+	for rest := data; len(rest) > 0; {
+		r, n := utf8.DecodeRune(rest)
+		text = utf16.AppendRune(text, r)
+		rest = rest[n:]
+	}
+	text = append(text, 0)
+	defer wipe16(text)
+
+	if err := open(); err != nil {
+		return err
+	}
+	defer closeClipboard.Call()
+	if r, _, err := emptyClipboard.Call(); r == 0 {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if err := setData(cfUnicodeText, uintptr(unsafe.Pointer(&text[0])), uintptr(len(text)*2)); err != nil {
+		return err
+	}
+	var zero uint32
+	for _, name := range privateFormats {
+		format, _, _ := registerClipboardFormat.Call(uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(name))))
+		if format != 0 {
+			setData(format, uintptr(unsafe.Pointer(&zero)), unsafe.Sizeof(zero))
+		}
+	}
+	return nil
+}
+
+// setData puts the size bytes at src on the open clipboard in format. The
+// clipboard owns the memory once it took it.
+func setData(format, src, size uintptr) error {
+	h, _, err := globalAlloc.Call(gmemMoveable, size)
+	if h == 0 {
+		return err
+	}
+	p, _, err := globalLock.Call(h)
+	if p == 0 {
+		globalFree.Call(h)
+		return err
+	}
+	moveMemory.Call(p, src, size)
+	globalUnlock.Call(h)
+	if r, _, err := setClipboardData.Call(format, h); r == 0 {
+		globalFree.Call(h)
+		if err == nil {
+			err = errors.New("clipboard: SetClipboardData failed")
+		}
+		return err
+	}
+	return nil
+}
+
+// wipe16 zeroes text, a secret in UTF-16.
+func wipe16(text []uint16) {
+	for i := range text {
+		text[i] = 0
+	}
+}
@@ -0,0 +1,145 @@
+package clipboard
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// RestoreCommand is the argument that makes the browserpass binary run as a
+// detached clipboard clearer, see Restore.
+const RestoreCommand = "clipboard-restore"
+
+// restoreJob is handed to the clearer on stdin. It holds the previous
+// contents of each selection and a hash of the secret, never the secret
+// itself.
+type restoreJob struct {
+	Timeout  time.Duration        `json:"timeout"`
+	Hash     []byte               `json:"hash"`
+	Previous map[Selection][]byte `json:"previous"`
+}
+
+// Copy puts secret on every supported selection and starts a detached
+// process that puts the previous contents back after timeout. The clearer
+// outlives the host, which the browser may kill at any moment.
+func Copy(secret []byte, timeout time.Duration) error {
+	sels, err := Selections()
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(secret)
+	job := restoreJob{Timeout: timeout, Hash: hash[:], Previous: make(map[Selection][]byte)}
+	for _, sel := range sels {
+		prev, err := Read(sel)
+		if err != nil {
+			return err
+		}
+		job.Previous[sel] = prev
+	}
+
+	for _, sel := range sels {
+		if err := Write(sel, secret); err != nil {
+			return err
+		}
+	}
+	owned.Lock()
+	owned.hash = job.Hash
+	owned.Unlock()
+	return startClearer(job)
+}
+
+// owned is the hash of the last secret this process put on the clipboard.
+var owned struct {
+	sync.Mutex
+	hash []byte
+}
+
+// Disown forgets the last secret this process copied, leaving it to the
+// detached clearer. Commands exiting right after a copy call it so that
+// ClearOwned doesn't remove the secret at once.
+func Disown() {
+	owned.Lock()
+	owned.hash = nil
+	owned.Unlock()
+}
+
+// ClearOwned empties every selection that still holds the last secret
+// copied by this process. The detached clearer then finds the secret gone
+// and leaves the selections empty.
+func ClearOwned() error {
+	owned.Lock()
+	hash := owned.hash
+	owned.hash = nil
+	owned.Unlock()
+	if hash == nil {
+		return nil
+	}
+
+	sels, err := Selections()
+	if err != nil {
+		return err
+	}
+	for _, sel := range sels {
+		current, err := Read(sel)
+		if err != nil {
+			return err
+		}
+		if h := sha256.Sum256(current); bytes.Equal(h[:], hash) {
+			if err := Write(sel, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func startClearer(job restoreJob) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(exe, RestoreCommand)
+	cmd.Stdin = bytes.NewReader(b)
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// Restore runs the clearer: it reads a job from r, waits for its timeout and
+// restores each selection that still holds the secret. Selections changed in
+// the meantime, by the user or a clipboard manager, are left alone.
+func Restore(r io.Reader) error {
+	var job restoreJob
+	if err := json.NewDecoder(r).Decode(&job); err != nil {
+		return err
+	}
+
+	time.Sleep(job.Timeout)
+
+	for sel, prev := range job.Previous {
+		current, err := Read(sel)
+		if err != nil {
+			return err
+		}
+		if hash := sha256.Sum256(current); !bytes.Equal(hash[:], job.Hash) {
+			continue
+		}
+		if err := Write(sel, prev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build fakegpg
+// +build fakegpg
+
+package main
+
+import (
+	"github.com/dannyvankooten/browserpass"
+	"github.com/dannyvankooten/browserpass/fixture"
+)
+
+// Builds with the fakegpg tag read plaintext fixture stores instead of
+// decrypting with gpg. Never ship them.
+func init() {
+	browserpass.DefaultDecrypter = fixture.FakeDecrypter{}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/dannyvankooten/browserpass"
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// fetchFavicons implements "browserpass favicons", which fetches the
+// favicons of the domains in the store that weren't fetched yet.
+func fetchFavicons(s pass.Store) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fetched, err := browserpass.FetchFavicons(ctx, s)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Fetched %d favicons into %s\n", fetched, browserpass.FaviconDir)
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/dannyvankooten/browserpass/fixture"
+)
+
+// genFixture implements "browserpass gen-fixture", which writes a synthetic
+// password store for testing and reproducing bugs.
+func genFixture(args []string) error {
+	var opts fixture.Options
+	flags := flag.NewFlagSet("gen-fixture", flag.ContinueOnError)
+	flags.IntVar(&opts.Entries, "entries", 100, "number of entries")
+	flags.IntVar(&opts.Depth, "depth", 2, "path segments per entry (1: domain, 2: domain/user, 3: category/domain/user, ...)")
+	flags.BoolVar(&opts.Unicode, "unicode", false, "include internationalized domains and usernames")
+	flags.Int64Var(&opts.Seed, "seed", 1, "random seed, the same seed generates the same store")
+	encrypt := flags.Bool("gpg", false, "encrypt entries to a throwaway key in a new GnuPG home")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: browserpass gen-fixture [flags] DIR")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("gen-fixture: missing store directory")
+	}
+
+	if *encrypt {
+		home, err := ioutil.TempDir("", "browserpass-fixture-gnupg")
+		if err != nil {
+			return err
+		}
+		if err := fixture.NewKey(home); err != nil {
+			return err
+		}
+		opts.GPGHome = home
+	}
+
+	if err := fixture.Generate(flags.Arg(0), opts); err != nil {
+		return err
+	}
+	if opts.GPGHome != "" {
+		fmt.Printf("Entries are encrypted to %s, use GNUPGHOME=%s to decrypt them\n", fixture.KeyID, opts.GPGHome)
+	}
+	return nil
+}
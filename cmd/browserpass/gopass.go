@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/dannyvankooten/browserpass/config"
+	"github.com/dannyvankooten/browserpass/gitstore"
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// openGopass returns the stores of gopass's config, mounted like gopass
+// does, and their directories.
+func openGopass(cfg *config.Config) (pass.Store, []string, error) {
+	path := cfg.GopassConfig
+	if path == "" {
+		var err error
+		if path, err = pass.GopassConfigPath(); err != nil {
+			return nil, nil, err
+		}
+	}
+	mountDirs, err := pass.GopassMounts(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	mounts := make(map[string]pass.Store)
+	var dirs []string
+	for point, dir := range mountDirs {
+		s, err := pass.NewDiskStore(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		mounts[point] = gitstore.Wrap(s, dir, cfg.GitPush)
+		dirs = append(dirs, dir)
+	}
+	return pass.NewGopassStore(mounts), dirs, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dannyvankooten/browserpass"
+	"github.com/dannyvankooten/browserpass/platform"
+)
+
+// install implements "browserpass install", which registers this binary as
+// the native messaging host of browsers. It does what install.sh does, on
+// Windows too.
+func install(args []string) error {
+	flags := flag.NewFlagSet("install", flag.ContinueOnError)
+	system := flags.Bool("system", false, "install for every user instead of the current one")
+	user := flags.Bool("user", false, "install for the current user only, the default")
+	browsers := flags.String("browser", "", "comma-separated browsers to install for, or \"all\"")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: browserpass install [-user|-system] -browser BROWSER[,BROWSER...]")
+		fmt.Fprintln(flags.Output(), "BROWSER is one of", platform.Browsers, "or all")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *user && *system {
+		flags.Usage()
+		return errors.New("install: -user and -system are exclusive")
+	}
+	// The browser used to be the only argument
+	names := *browsers
+	if names == "" && flags.NArg() == 1 {
+		names = flags.Arg(0)
+	}
+	if names == "" || flags.NArg() > 1 || *browsers != "" && flags.NArg() > 0 {
+		flags.Usage()
+		return errors.New("install: missing browser")
+	}
+	targets, err := parseBrowsers(names)
+	if err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	for _, b := range targets {
+		path, err := platform.Install(b, exe, browserpass.AllowedOrigins, *system)
+		if err != nil {
+			return fmt.Errorf("install for %s: %w", b, err)
+		}
+		fmt.Println("Native messaging host for", b, "installed to", path)
+	}
+	return nil
+}
+
+// parseBrowsers returns the browsers in a comma-separated list of names,
+// every supported one for "all".
+func parseBrowsers(names string) ([]platform.Browser, error) {
+	if names == "all" {
+		return platform.Browsers, nil
+	}
+	var browsers []platform.Browser
+	for _, name := range strings.Split(names, ",") {
+		b := platform.Browser(strings.ToLower(strings.TrimSpace(name)))
+		known := false
+		for _, supported := range platform.Browsers {
+			known = known || b == supported
+		}
+		if !known {
+			return nil, fmt.Errorf("install: unknown browser %q", name)
+		}
+		browsers = append(browsers, b)
+	}
+	return browsers, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dannyvankooten/browserpass"
+	"github.com/dannyvankooten/browserpass/config"
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// keePassPasswordTTL is how long the database password is kept once
+// decrypted, since keepassxc-cli needs it for every listing and entry.
+const keePassPasswordTTL = 5 * time.Minute
+
+// openKeePass returns the KeePass database of cfg, unlocked with its key
+// file and the password in its password entry of s.
+func openKeePass(s pass.Store, cfg *config.Config) (pass.Store, error) {
+	var unlock func(w io.Writer) error
+	if entry := cfg.KeePassPasswordEntry; entry != "" {
+		unlock = (&keePassPassword{s: s, entry: entry}).write
+	}
+	return pass.NewKdbxStore(cfg.KeePassDatabase, cfg.KeePassKeyFile, unlock)
+}
+
+// keePassPassword is the database password in entry of s, decrypted when
+// first needed and wiped keePassPasswordTTL later. Its decryptions aren't
+// fetches by a caller, see browserpass.ReadPassword.
+type keePassPassword struct {
+	s     pass.Store
+	entry string
+
+	mu       sync.Mutex
+	password *browserpass.SecureBytes
+}
+
+// write writes the password to w, decrypting it if it isn't kept.
+func (p *keePassPassword) write(w io.Writer) error {
+	// write runs in a goroutine of the KeePass store
+	defer browserpass.Guard()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.password == nil {
+		password, err := browserpass.ReadPassword(context.Background(), p.s, p.entry)
+		if err != nil {
+			log.Println("could not unlock the KeePass database:", err)
+			return err
+		}
+		p.password = password
+		time.AfterFunc(keePassPasswordTTL, p.forget)
+	}
+	_, err := w.Write(p.password.Bytes())
+	return err
+}
+
+// forget wipes the kept password.
+func (p *keePassPassword) forget() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.password.Wipe()
+	p.password = nil
+}
@@ -1,22 +1,342 @@
 package main
 
 import (
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"time"
 
 	"github.com/dannyvankooten/browserpass"
+	"github.com/dannyvankooten/browserpass/clipboard"
+	"github.com/dannyvankooten/browserpass/config"
+	"github.com/dannyvankooten/browserpass/gitstore"
+	"github.com/dannyvankooten/browserpass/gpg"
+	"github.com/dannyvankooten/browserpass/logging"
 	"github.com/dannyvankooten/browserpass/pass"
+	"github.com/dannyvankooten/browserpass/protocol"
 )
 
 func main() {
 	log.SetPrefix("[Browserpass] ")
 
-	s, err := pass.NewDefaultStore()
+	// Browsers may start the host without $HOME, which gpg and the config
+	// and cache directories need
+	if os.Getenv("HOME") == "" && runtime.GOOS != "windows" {
+		if home, err := pass.HomeDir(); err == nil {
+			os.Setenv("HOME", home)
+		}
+	}
+
+	// "-config FILE" in front of the command overrides the config file
+	configPath, err := config.Path()
+	if len(os.Args) > 2 && os.Args[1] == "-config" {
+		configPath, err = os.Args[2], nil
+		os.Args = append(os.Args[:1], os.Args[3:]...)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Sandboxed browsers start a copy of the binary installed under this name
+	if filepath.Base(os.Args[0]) == "browserpass-proxy" {
+		if err := proxy(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case clipboard.RestoreCommand:
+			// Detached process restoring the clipboard after a secret was copied
+			if err := clipboard.Restore(os.Stdin); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "proxy":
+			if err := proxy(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "install":
+			if err := install(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "gen-fixture":
+			if err := genFixture(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	// Wipe secrets however the host goes down. SIGKILL can't be caught; the
+	// detached clipboard clearer still covers the clipboard then.
+	defer browserpass.Guard()
+	protocol.OnPanic = browserpass.Shutdown
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGPIPE)
+	go func() {
+		<-signals
+		browserpass.Shutdown()
+		os.Exit(1)
+	}()
+
+	// Keep decrypted passwords out of core dumps and debuggers
+	if err := browserpass.HardenProcess(); err != nil {
+		log.Println("could not harden process:", err)
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sink, err := logging.Setup(cfg.LogFile, cfg.LogLevel)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer sink.Close()
+
+	if cfg.AuditLog != "" {
+		var key []byte
+		if cfg.AuditKeyFile != "" {
+			if key, err = browserpass.LoadAuditKey(cfg.AuditKeyFile); err != nil {
+				log.Fatal(err)
+			}
+		}
+		audit, err := browserpass.OpenAuditLog(cfg.AuditLog, key)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := audit.Broken(); err != nil {
+			log.Println(err)
+		}
+		browserpass.Audit = audit
+	}
+
+	if len(cfg.AllowedExtensions) > 0 {
+		browserpass.AllowedOrigins = nil
+		for _, id := range cfg.AllowedExtensions {
+			browserpass.AllowedOrigins = append(browserpass.AllowedOrigins, browserpass.ExtensionOrigin(id))
+		}
+	}
+	if len(cfg.AllowedBrowsers) > 0 {
+		browserpass.AllowedBrowsers = cfg.AllowedBrowsers
+	}
+	browserpass.ConfirmCommand = cfg.ConfirmCommand
+	if cfg.DecryptionsPerMinute > 0 {
+		browserpass.DecryptionsPerMinute = cfg.DecryptionsPerMinute
+	}
+	if cfg.ClipboardTimeout > 0 {
+		browserpass.ClipboardTimeout = time.Duration(cfg.ClipboardTimeout) * time.Second
+	}
+	if dir, err := os.UserCacheDir(); err == nil && cfg.MetadataIndex {
+		browserpass.MetadataIndexFile = filepath.Join(dir, "browserpass", "index.gpg")
+	}
+	if dir, err := os.UserCacheDir(); err == nil && cfg.Favicons {
+		browserpass.FaviconDir = filepath.Join(dir, "browserpass", "favicons")
+	}
+	if cfg.FetchWorkers > 0 {
+		browserpass.FetchWorkers = cfg.FetchWorkers
+	}
+	if cfg.RequestTimeout > 0 {
+		protocol.RequestTimeout = time.Duration(cfg.RequestTimeout) * time.Second
+	}
+	if cfg.PlaintextCacheTTL > 0 {
+		browserpass.Plaintexts = browserpass.NewPlaintextCache(time.Duration(cfg.PlaintextCacheTTL) * time.Second)
+	}
+
+	browserpass.Policies = cfg.Policies
+	browserpass.Containers = cfg.Containers
+
+	if cfg.Sort == "bytes" {
+		pass.SortOrder = pass.CollateBytes
+	}
+	pass.FuzzySearch = cfg.FuzzySearch
+	pass.IgnorePatterns = cfg.Ignore
+	pass.CacheTTL = 10 * time.Second
+	if cfg.CacheTTL != 0 {
+		pass.CacheTTL = time.Duration(cfg.CacheTTL) * time.Second
+	}
+	gpg.Binary = cfg.GPGBinary
+	gpg.HomeDir = cfg.GPGHome
+	gpg.ExtraArgs = cfg.GPGArgs
+
+	// The service answers many requests, so it keeps the store indexed
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		pass.IndexStores = true
+	}
+
+	// Changes are committed like pass does in stores kept in git
+	var s pass.Store
+	var dirs []string
+	if cfg.Backend == "gopass" {
+		if s, dirs, err = openGopass(cfg); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		pass.StoreDir = cfg.StorePaths[""]
+		path, err := pass.DefaultStorePath()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if s, err = pass.OpenStore(path); err != nil {
+			log.Fatal(err)
+		}
+		if !pass.IsRemoteStore(path) {
+			s = gitstore.Wrap(s, path, cfg.GitPush)
+			dirs = []string{path}
+		}
+	}
+
+	// Other stores, like a work store, with their entries as "name:entry"
+	storeDirs := make(map[string]string)
+	for name, dir := range cfg.StorePaths {
+		if name != "" {
+			storeDirs[name] = dir
+		}
+	}
+	if len(storeDirs) > 0 {
+		multi, err := pass.NewMultiStore(s, storeDirs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i, named := range multi[1:] {
+			if dir := storeDirs[named.Name]; !pass.IsRemoteStore(dir) {
+				multi[i+1].Store = gitstore.Wrap(named.Store, dir, cfg.GitPush)
+				dirs = append(dirs, dir)
+			}
+		}
+		s = multi
+	}
+
+	// Entries already moved to age, behind the gpg ones
+	var files []string
+	if cfg.AgeStore != "" {
+		identities := cfg.AgeIdentities
+		if identities == "" {
+			if _, identities, err = pass.DefaultAgeStorePath(); err != nil {
+				log.Fatal(err)
+			}
+		}
+		age, err := pass.NewAgeStore(cfg.AgeStore, identities)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s = pass.Merge(s, age)
+		files = append(files, cfg.AgeStore, identities)
+	}
+
+	// A KeePass database, unlocked with a password kept in the store
+	if cfg.KeePassDatabase != "" {
+		kdbx, err := openKeePass(s, cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s = pass.Merge(s, kdbx)
+		files = append(files, cfg.KeePassDatabase)
+		if cfg.KeePassKeyFile != "" {
+			files = append(files, cfg.KeePassKeyFile)
+		}
+	}
+
+	// Credentials saved by other apps, behind the ones in the store
+	if cfg.Keyring {
+		keyring, err := pass.NewKeyringStore()
+		if err != nil {
+			log.Fatal(err)
+		}
+		s = pass.Merge(s, keyring)
+	}
+
+	s = logging.Wrap(s)
+
+	if c, ok := s.(pass.Checker); ok {
+		warnings, err := c.Warnings()
+		if err != nil {
+			log.Println("could not check store permissions:", err)
+		}
+		for _, warning := range warnings {
+			log.Println("warning:", warning)
+		}
+	}
+
+	// Hosts serving browsers forget secrets as soon as the session locks.
+	// The watcher is a program of its own, started before the sandbox.
+	if len(os.Args) < 2 || !oneShotCommands[os.Args[1]] {
+		browserpass.WatchSession()
+	}
+
+	// Optionally confine the host to the files it needs from here on
+	if cfg.Sandbox {
+		if err := sandbox(dirs, files, cfg); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	if err := browserpass.Run(os.Stdin, os.Stdout, s); err != nil {
+	switch {
+	case len(os.Args) > 1 && os.Args[1] == "serve":
+		err = serve(s)
+	case len(os.Args) > 1 && os.Args[1] == "otp-qr":
+		err = otpQR(s, os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "menu":
+		err = pickLogin(s, os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "share":
+		err = share(s, os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "favicons":
+		err = fetchFavicons(s)
+	case len(os.Args) > 2 && os.Args[1] == "git-credential":
+		err = browserpass.GitCredential(os.Stdin, os.Stdout, s, os.Args[2])
+	default:
+		err = runStdio(s)
+	}
+	browserpass.Shutdown()
+	if err != nil && err != io.EOF {
 		log.Fatal(err)
 	}
 }
+
+// oneShotCommands are the commands doing one thing and exiting instead of
+// serving browsers.
+var oneShotCommands = map[string]bool{"otp-qr": true, "menu": true, "share": true, "favicons": true, "git-credential": true}
+
+// runStdio serves a single browser over stdin and stdout.
+func runStdio(s pass.Store) error {
+	// Only serve secrets to a browser, not whatever process started us
+	caller := browserpass.CallerFromArgs(os.Args[1:])
+	if ok, err := browserpass.ParentIsBrowser(); err != nil {
+		log.Println("could not inspect parent process:", err)
+		caller = ""
+	} else if !ok {
+		log.Println("not launched by an allowed browser")
+		caller = ""
+	}
+
+	return browserpass.Run(os.Stdin, os.Stdout, s, caller)
+}
+
+// sandbox restricts the process to the password stores in stores, the other
+// files it reads, browserpass's config and cache directories, its log files
+// and the configured GnuPG home.
+func sandbox(stores, files []string, cfg *config.Config) error {
+	// Landlock only takes files that exist, so stores not initialized yet
+	// get their directory now, which they stay uninitialized with until init
+	for _, dir := range stores {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	rw := append(append([]string{}, stores...), files...)
+	if dir, err := os.UserConfigDir(); err == nil {
+		rw = append(rw, filepath.Join(dir, "browserpass"))
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		rw = append(rw, filepath.Join(dir, "browserpass"))
+	}
+	return browserpass.Sandbox(append(rw, cfg.AuditLog, cfg.LogFile, cfg.GPGHome)...)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/dannyvankooten/browserpass"
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// pickLogin implements "browserpass menu", which picks an entry with dmenu,
+// rofi or fuzzel and types or copies its login.
+func pickLogin(s pass.Store, args []string) error {
+	flags := flag.NewFlagSet("menu", flag.ContinueOnError)
+	autotype := flags.Bool("type", false, "type the username and password instead of copying the password")
+	timeout := flags.Duration("timeout", 45*time.Second, "how long the password stays on the clipboard")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: browserpass menu [flags]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	return browserpass.Menu(s, *autotype, *timeout)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dannyvankooten/browserpass"
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// otpQR implements "browserpass otp-qr", which shows an entry's OTP seed as
+// a QR code after the user confirms.
+func otpQR(s pass.Store, args []string) error {
+	flags := flag.NewFlagSet("otp-qr", flag.ContinueOnError)
+	png := flags.String("png", "", "write a PNG image to this file instead of printing to the terminal")
+	yes := flags.Bool("yes", false, "don't ask for confirmation")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: browserpass otp-qr [flags] ENTRY")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("otp-qr: missing entry")
+	}
+
+	if !*yes {
+		fmt.Fprintf(os.Stderr, "The QR code contains the OTP secret of %s. Anyone who sees it can generate your codes.\nShow it? [y/N] ", flags.Arg(0))
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+			return errors.New("otp-qr: cancelled")
+		}
+	}
+
+	if *png == "" {
+		return browserpass.OTPQRCode(os.Stdout, s, flags.Arg(0), false)
+	}
+	f, err := os.OpenFile(*png, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := browserpass.OTPQRCode(f, s, flags.Arg(0), true); err != nil {
+		f.Close()
+		os.Remove(*png)
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/dannyvankooten/browserpass"
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd socket
+// activation, see sd_listen_fds(3).
+const listenFdsStart = 3
+
+// serve implements "browserpass serve", a long-running host listening on a
+// Unix socket. Under systemd socket activation it uses the socket passed by
+// systemd, otherwise it creates browserpass.SocketPath().
+func serve(s pass.Store) error {
+	l, err := activationListener()
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		if l, err = browserpass.Listen(browserpass.SocketPath()); err != nil {
+			return err
+		}
+	}
+	defer l.Close()
+	return browserpass.Serve(l, s)
+}
+
+// activationListener returns the socket passed by systemd, or nil if the
+// process wasn't socket activated.
+func activationListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, nil
+	}
+	if n > 1 {
+		return nil, errors.New("serve: expected a single socket from systemd, got " + strconv.Itoa(n))
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	f := os.NewFile(listenFdsStart, "systemd-socket")
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// proxy implements "browserpass proxy", which a sandboxed browser starts as
+// its native host. It relays the browser to the host serving the socket in
+// $BROWSERPASS_SOCKET, or browserpass.SocketPath() by default.
+func proxy(args []string) error {
+	path := os.Getenv("BROWSERPASS_SOCKET")
+	if path == "" {
+		path = browserpass.SocketPath()
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return browserpass.Proxy(os.Stdin, os.Stdout, conn, browserpass.CallerFromArgs(args))
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dannyvankooten/browserpass"
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// share implements "browserpass share", which prints one entry encrypted to
+// someone else's GPG key.
+func share(s pass.Store, args []string) error {
+	flags := flag.NewFlagSet("share", flag.ContinueOnError)
+	recipient := flags.String("recipient", "", "GPG key ID or email of the recipient")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: browserpass share -recipient KEY ENTRY")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || *recipient == "" {
+		flags.Usage()
+		return errors.New("share: missing entry or recipient")
+	}
+	return browserpass.ShareEntry(os.Stdout, s, flags.Arg(0), *recipient)
+}
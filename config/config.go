@@ -0,0 +1,248 @@
+// Package config loads the settings of the host from config.json in the
+// browserpass config directory. Browsers start native hosts with an
+// environment of their own, so the environment variables browserpass also
+// reads don't reliably reach it.
+//
+// Settings come from, in order of precedence, the environment, config.json
+// and the files policies.json, containers.json and stores.json next to it
+// that older versions read.
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config is the host's configuration.
+type Config struct {
+	// StorePaths maps store names to directories. The store named "" is the
+	// default store, the others are served with their entries qualified as
+	// "name:entry". Stores on SSH servers are given by URL, see
+	// pass.IsRemoteStore.
+	StorePaths map[string]string `json:"store_paths"`
+	// Policies restricts extensions to store subtrees, see
+	// browserpass.Policies
+	Policies map[string][]string `json:"policies"`
+	// Containers restricts Firefox containers to store subtrees, see
+	// browserpass.Containers
+	Containers map[string]string `json:"containers"`
+	// Backend is "gopass" to serve the stores of gopass's config instead
+	// of StorePaths, otherwise pass stores are served
+	Backend string `json:"backend"`
+	// GopassConfig is gopass's config file, see pass.GopassConfigPath
+	GopassConfig string `json:"gopass_config"`
+	// AgeStore is a passage store served after the others, for users
+	// moving from gpg to age
+	AgeStore string `json:"age_store"`
+	// AgeIdentities is the identities file decrypting AgeStore, see
+	// pass.DefaultAgeStorePath
+	AgeIdentities string `json:"age_identities"`
+	// KeePassDatabase is a KeePass database served after the stores
+	KeePassDatabase string `json:"keepass_database"`
+	// KeePassKeyFile is the key file unlocking KeePassDatabase
+	KeePassKeyFile string `json:"keepass_key_file"`
+	// KeePassPasswordEntry is the store entry holding the password of
+	// KeePassDatabase
+	KeePassPasswordEntry string `json:"keepass_password_entry"`
+	// GPGBinary is the gpg program to run, found in $PATH if not absolute
+	GPGBinary string `json:"gpg_binary"`
+	// GPGHome is the GnuPG home directory, see gpg.HomeDir
+	GPGHome string `json:"gpg_home"`
+	// GPGArgs are options added to every run of gpg, see gpg.ExtraArgs
+	GPGArgs []string `json:"gpg_args"`
+	// FuzzySearch matches searches fuzzily, see pass.FuzzySearch
+	FuzzySearch bool `json:"fuzzy_search"`
+	// Ignore leaves files and directories out of every store, in the syntax
+	// of .gitignore, see pass.IgnorePatterns
+	Ignore []string `json:"ignore"`
+	// CacheTTL is how many seconds search results are remembered, 10 if
+	// 0, none if negative, see pass.CacheTTL
+	CacheTTL int `json:"cache_ttl"`
+	// PlaintextCacheTTL is how many seconds decrypted entries are kept,
+	// none if 0, see browserpass.Plaintexts
+	PlaintextCacheTTL int `json:"plaintext_cache_ttl"`
+	// ClipboardTimeout is how many seconds the "copy" action leaves secrets
+	// on the clipboard, 45 if 0
+	ClipboardTimeout int `json:"clipboard_timeout"`
+	// FetchWorkers is how many entries are decrypted at once for the
+	// "fetch_all" action, 4 if 0
+	FetchWorkers int `json:"fetch_workers"`
+	// RequestTimeout is how many seconds a request from the browser may
+	// take, no limit if 0, see protocol.RequestTimeout
+	RequestTimeout int `json:"request_timeout"`
+	// Sort is "bytes" to sort entries by their bytes, see pass.SortOrder
+	Sort string `json:"sort"`
+	// LogFile receives the host's log instead of the browser, syslog if
+	// it is "syslog", see logging.Setup
+	LogFile string `json:"log_file"`
+	// LogLevel is the least level logged, "debug" to log every request
+	LogLevel string `json:"log_level"`
+	// MetadataIndex keeps an encrypted index of usernames and URLs for
+	// searches, see browserpass.MetadataIndexFile
+	MetadataIndex bool `json:"metadata_index"`
+	// Favicons keeps the favicons fetched by "browserpass favicons" for the
+	// extension to show, see browserpass.FaviconDir
+	Favicons bool `json:"favicons"`
+	// AuditLog records every entry served, see browserpass.OpenAuditLog
+	AuditLog string `json:"audit_log"`
+	// AuditKeyFile holds the key chaining AuditLog with HMAC, see
+	// browserpass.LoadAuditKey
+	AuditKeyFile string `json:"audit_key_file"`
+	// Keyring serves the OS keyring's internet passwords too
+	Keyring bool `json:"keyring"`
+	// Sandbox confines the host to the files it needs
+	Sandbox bool `json:"sandbox"`
+	// GitPush pushes the commits made for changes to stores kept in git
+	GitPush bool `json:"git_push"`
+	// DecryptionsPerMinute limits decryptions, see
+	// browserpass.DecryptionsPerMinute
+	DecryptionsPerMinute int `json:"decryptions_per_minute"`
+	// ConfirmCommand asks before entries are returned, see
+	// browserpass.ConfirmCommand
+	ConfirmCommand []string `json:"confirm_command"`
+	// AllowedExtensions are the IDs of the extensions served instead of
+	// those of browserpass, see browserpass.AllowedOrigins
+	AllowedExtensions []string `json:"allowed_extensions"`
+	// AllowedBrowsers are the executables allowed to launch the host
+	// instead of the installed browsers, see browserpass.AllowedBrowsers
+	AllowedBrowsers []string `json:"allowed_browsers"`
+}
+
+// Path returns the location of the config file: $BROWSERPASS_CONFIG if set,
+// otherwise config.json in the browserpass config directory.
+func Path() (string, error) {
+	if path := os.Getenv("BROWSERPASS_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "browserpass", "config.json"), nil
+}
+
+// Load reads the config file at path, a missing file being an empty config.
+// The environment variables browserpass reads take precedence over it.
+func Load(path string) (*Config, error) {
+	c := new(Config)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, &os.PathError{Op: "parse", Path: path, Err: err}
+		}
+	}
+	if err := c.applyLegacy(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	c.applyEnv()
+
+	for name, dir := range c.StorePaths {
+		if c.StorePaths[name], err = expandHome(dir); err != nil {
+			return nil, err
+		}
+	}
+	for _, path := range []*string{&c.LogFile, &c.AuditLog, &c.AuditKeyFile, &c.GopassConfig, &c.AgeStore, &c.AgeIdentities, &c.KeePassDatabase, &c.KeePassKeyFile, &c.GPGBinary, &c.GPGHome} {
+		if *path, err = expandHome(*path); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// applyLegacy fills in what c leaves unset from the files in dir that held
+// policies, containers and stores before config.json did. Stores are added
+// by name, so config.json only wins for the stores it names.
+func (c *Config) applyLegacy(dir string) error {
+	if c.Policies == nil {
+		if err := loadJSON(filepath.Join(dir, "policies.json"), &c.Policies); err != nil {
+			return err
+		}
+	}
+	if c.Containers == nil {
+		if err := loadJSON(filepath.Join(dir, "containers.json"), &c.Containers); err != nil {
+			return err
+		}
+	}
+	var stores map[string]string
+	if err := loadJSON(filepath.Join(dir, "stores.json"), &stores); err != nil {
+		return err
+	}
+	for name, dir := range stores {
+		if _, ok := c.StorePaths[name]; !ok {
+			if c.StorePaths == nil {
+				c.StorePaths = make(map[string]string)
+			}
+			c.StorePaths[name] = dir
+		}
+	}
+	return nil
+}
+
+// loadJSON decodes the file at path into v, leaving v alone if it doesn't
+// exist.
+func loadJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return &os.PathError{Op: "parse", Path: path, Err: err}
+	}
+	return nil
+}
+
+// applyEnv overrides c with the environment variables that are set.
+func (c *Config) applyEnv() {
+	if dir := os.Getenv("PASSWORD_STORE_DIR"); dir != "" {
+		if c.StorePaths == nil {
+			c.StorePaths = make(map[string]string)
+		}
+		c.StorePaths[""] = dir
+	}
+	values := map[string]*string{
+		"BROWSERPASS_BACKEND":   &c.Backend,
+		"BROWSERPASS_GPG":       &c.GPGBinary,
+		"BROWSERPASS_SORT":      &c.Sort,
+		"BROWSERPASS_LOG_FILE":  &c.LogFile,
+		"BROWSERPASS_LOG_LEVEL": &c.LogLevel,
+		"BROWSERPASS_AUDIT_LOG": &c.AuditLog,
+	}
+	for name, v := range values {
+		if value := os.Getenv(name); value != "" {
+			*v = value
+		}
+	}
+	switches := map[string]*bool{
+		"BROWSERPASS_KEYRING":  &c.Keyring,
+		"BROWSERPASS_SANDBOX":  &c.Sandbox,
+		"BROWSERPASS_GIT_PUSH": &c.GitPush,
+	}
+	for name, v := range switches {
+		if os.Getenv(name) != "" {
+			*v = true
+		}
+	}
+	if os.Getenv("BROWSERPASS_SEARCH") == "fuzzy" {
+		c.FuzzySearch = true
+	}
+}
+
+// expandHome expands a leading "~/" in path to the home directory.
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[2:]), nil
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// clearEnv unsets the environment variables Load reads for the test.
+func clearEnv(t *testing.T) {
+	for _, v := range []string{
+		"PASSWORD_STORE_DIR", "BROWSERPASS_BACKEND", "BROWSERPASS_GPG", "BROWSERPASS_SORT", "BROWSERPASS_LOG_FILE", "BROWSERPASS_LOG_LEVEL",
+		"BROWSERPASS_AUDIT_LOG", "BROWSERPASS_KEYRING", "BROWSERPASS_SANDBOX",
+		"BROWSERPASS_GIT_PUSH", "BROWSERPASS_SEARCH",
+	} {
+		t.Setenv(v, "")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	clearEnv(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{
+		"store_paths": {"": "~/pass", "work": "/srv/work"},
+		"gpg_binary": "gpg2",
+		"gpg_home": "~/.gnupg-browser",
+		"gpg_args": ["--pinentry-mode=loopback"],
+		"fuzzy_search": true,
+		"log_file": "~/browserpass.log"
+	}`), 0600)
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Config{
+		StorePaths:  map[string]string{"": filepath.Join(home, "pass"), "work": "/srv/work"},
+		GPGBinary:   "gpg2",
+		GPGHome:     filepath.Join(home, ".gnupg-browser"),
+		GPGArgs:     []string{"--pinentry-mode=loopback"},
+		FuzzySearch: true,
+		LogFile:     filepath.Join(home, "browserpass.log"),
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("Loaded %+v, expected %+v", c, expected)
+	}
+
+	// The environment takes precedence
+	t.Setenv("PASSWORD_STORE_DIR", "/srv/pass")
+	t.Setenv("BROWSERPASS_GPG", "/usr/local/bin/gpg")
+	t.Setenv("BROWSERPASS_SANDBOX", "1")
+	if c, err = Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if c.StorePaths[""] != "/srv/pass" || c.StorePaths["work"] != "/srv/work" || c.GPGBinary != "/usr/local/bin/gpg" || !c.Sandbox {
+		t.Errorf("Environment not applied to %+v", c)
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	clearEnv(t)
+	c, err := Load(filepath.Join(t.TempDir(), "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, &Config{}) {
+		t.Errorf("Missing file loaded as %+v", c)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"store_paths": []}`), 0600)
+	if _, err := Load(path); err == nil {
+		t.Error("Loaded an invalid config")
+	}
+}
+
+func TestLoadLegacy(t *testing.T) {
+	clearEnv(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	os.WriteFile(filepath.Join(dir, "policies.json"), []byte(`{"work@example.com": ["work/"]}`), 0600)
+	os.WriteFile(filepath.Join(dir, "containers.json"), []byte(`{"Work": "work/"}`), 0600)
+	os.WriteFile(filepath.Join(dir, "stores.json"), []byte(`{"work": "/srv/old-work", "team": "/srv/team"}`), 0600)
+
+	// Without config.json the old files are read
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Config{
+		StorePaths: map[string]string{"work": "/srv/old-work", "team": "/srv/team"},
+		Policies:   map[string][]string{"work@example.com": {"work/"}},
+		Containers: map[string]string{"Work": "work/"},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("Loaded %+v, expected %+v", c, expected)
+	}
+
+	// config.json wins over them
+	os.WriteFile(path, []byte(`{
+		"store_paths": {"work": "/srv/work"},
+		"policies": {"other@example.com": ["other/"]},
+		"containers": {}
+	}`), 0600)
+	if c, err = Load(path); err != nil {
+		t.Fatal(err)
+	}
+	expected = &Config{
+		StorePaths: map[string]string{"work": "/srv/work", "team": "/srv/team"},
+		Policies:   map[string][]string{"other@example.com": {"other/"}},
+		Containers: map[string]string{},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("Loaded %+v, expected %+v", c, expected)
+	}
+}
@@ -0,0 +1,54 @@
+package browserpass
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// ConfirmCommand is run before an entry decrypted for a browser or a socket
+// client is returned, with the entry in $BROWSERPASS_ENTRY, the host of the
+// requesting tab in $BROWSERPASS_HOST and the caller in $BROWSERPASS_CALLER.
+// Like a zenity or kdialog question, it must exit with status 0 for the
+// entry to be returned. Nothing is run if it is empty.
+var ConfirmCommand []string
+
+// confirmTimeout is how long ConfirmCommand may wait for an answer.
+const confirmTimeout = 2 * time.Minute
+
+// confirmMu keeps concurrent requests from asking at once.
+var confirmMu sync.Mutex
+
+// confirm asks the user through ConfirmCommand whether the entry requested in
+// data may be returned to the caller, answering with CodeDenied if not.
+// Commands run by the user themselves aren't asked about.
+func (c *conn) confirm(ctx context.Context, data map[string]string) (*errorResponse, error) {
+	if len(ConfirmCommand) == 0 || c.caller == CLICaller {
+		return nil, nil
+	}
+	confirmMu.Lock()
+	defer confirmMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, confirmTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, ConfirmCommand[0], ConfirmCommand[1:]...)
+	cmd.Env = append(os.Environ(),
+		"BROWSERPASS_ENTRY="+data["entry"],
+		"BROWSERPASS_HOST="+data["host"],
+		"BROWSERPASS_CALLER="+c.caller)
+	err := cmd.Run()
+	var exit *exec.ExitError
+	switch {
+	case err == nil:
+		return nil, nil
+	case errors.As(err, &exit):
+		slog.Info("entry denied", "entry", data["entry"], "host", data["host"], "caller", c.caller)
+		return &errorResponse{Message: "the user denied access to the entry", Code: CodeDenied}, nil
+	default:
+		return nil, err
+	}
+}
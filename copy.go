@@ -0,0 +1,69 @@
+package browserpass
+
+import (
+	"context"
+	"time"
+
+	"github.com/dannyvankooten/browserpass/clipboard"
+)
+
+// ClipboardTimeout is how long secrets copied with the "copy" action stay on
+// the clipboard, like "pass -c".
+var ClipboardTimeout = 45 * time.Second
+
+// copyToClipboard is clipboard.Copy, replaced in tests.
+var copyToClipboard = clipboard.Copy
+
+// copySecret copies the password of an entry, or its current OTP code if
+// "field" is "otp", to the clipboard, for sites that block filling. The
+// previous clipboard contents come back after ClipboardTimeout.
+func (c *conn) copySecret(ctx context.Context, data map[string]string) (interface{}, error) {
+	field := data["field"]
+	if field == "" {
+		field = "password"
+	}
+	if field != "password" && field != "otp" {
+		return errorResponse{Message: "unknown field " + field, Code: CodeInvalidRequest}, nil
+	}
+	var secret []byte
+	if field == "otp" {
+		// HOTP counters advance like for the "otp" action
+		code, _, refused, err := c.nextOTP(ctx, data)
+		if err != nil {
+			return nil, err
+		}
+		if refused != nil {
+			return refused, nil
+		}
+		secret = []byte(code)
+	} else {
+		plaintext, refused, err := c.decryptEntry(ctx, data)
+		if err != nil {
+			return nil, err
+		}
+		if refused != nil {
+			return refused, nil
+		}
+		defer plaintext.Wipe()
+		login, err := ParseLogin(plaintext.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		defer login.Wipe()
+		secret = login.Password.Bytes()
+	}
+
+	switch err := copyToClipboard(secret, ClipboardTimeout); err {
+	case nil:
+	case clipboard.ErrUnavailable:
+		return errorResponse{Message: err.Error(), Code: CodeUnavailable}, nil
+	default:
+		return nil, err
+	}
+	// Browsers stop the host after every message, the clearer takes over
+	clipboard.Disown()
+	if err := c.audit(data["entry"], data["host"]); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"copied": field, "clear_after": int(ClipboardTimeout / time.Second)}, nil
+}
@@ -0,0 +1,67 @@
+package browserpass
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dannyvankooten/browserpass/clipboard"
+)
+
+func TestRunCopy(t *testing.T) {
+	var copied string
+	var timeout time.Duration
+	copyToClipboard = func(secret []byte, d time.Duration) error {
+		copied, timeout = string(secret), d
+		return nil
+	}
+	defer func() { copyToClipboard = clipboard.Copy }()
+	s := otpStore{fakeStore{"example.com/alice"}}
+	caller := AllowedOrigins[0]
+
+	var resp map[string]interface{}
+	roundTrip(t, s, caller, map[string]string{"action": "copy", "entry": "example.com/alice"}, &resp)
+	if copied != "hunter2" || timeout != ClipboardTimeout || resp["copied"] != "password" {
+		t.Errorf("Copied %q for %v, responded %v", copied, timeout, resp)
+	}
+	roundTrip(t, s, caller, map[string]string{"action": "copy", "entry": "example.com/alice", "field": "otp"}, &resp)
+	if len(copied) != 6 || resp["copied"] != "otp" {
+		t.Errorf("Copied OTP code %q, responded %v", copied, resp)
+	}
+
+	var refused errorResponse
+	roundTrip(t, s, caller, map[string]string{"action": "copy", "entry": "example.com/alice", "field": "notes"}, &refused)
+	if refused.Code != CodeInvalidRequest {
+		t.Errorf("Copy of an unknown field returned %+v", refused)
+	}
+	copyToClipboard = func([]byte, time.Duration) error { return clipboard.ErrUnavailable }
+	roundTrip(t, s, caller, map[string]string{"action": "copy", "entry": "example.com/alice"}, &refused)
+	if refused.Code != CodeUnavailable {
+		t.Errorf("Copy without a clipboard returned %+v", refused)
+	}
+}
+
+func TestRunCopyHOTP(t *testing.T) {
+	var copied []string
+	copyToClipboard = func(secret []byte, d time.Duration) error {
+		copied = append(copied, string(secret))
+		return nil
+	}
+	defer func() { copyToClipboard = clipboard.Copy }()
+	s := writableHOTPStore{hotpStore{fakeStore{"example.com/alice"}, map[string]string{
+		"example.com/alice": "hunter2\notpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=0\n",
+	}}}
+
+	req := map[string]string{"action": "copy", "entry": "example.com/alice", "field": "otp"}
+	for i := 0; i < 2; i++ {
+		var resp map[string]interface{}
+		roundTrip(t, s, AllowedOrigins[0], req, &resp)
+	}
+	// RFC 4226 appendix D, from the counter after the last one used
+	if expected := []string{"287082", "359152"}; !reflect.DeepEqual(copied, expected) {
+		t.Errorf("Copied %q, expected %q", copied, expected)
+	}
+	if expected := "hunter2\notpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=2\n"; s.content["example.com/alice"] != expected {
+		t.Errorf("Entry is %q, expected %q", s.content["example.com/alice"], expected)
+	}
+}
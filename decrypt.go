@@ -0,0 +1,73 @@
+package browserpass
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/dannyvankooten/browserpass/gpg"
+	"github.com/dannyvankooten/browserpass/pass"
+	"github.com/dannyvankooten/browserpass/protocol"
+)
+
+// Decrypter decrypts password store entries.
+type Decrypter interface {
+	// Decrypt writes the plaintext of the entry read from src to dst. It
+	// gives up once ctx is done.
+	Decrypt(ctx context.Context, dst io.Writer, src io.Reader) error
+}
+
+// DefaultDecrypter is used to decrypt entries for the extension.
+var DefaultDecrypter Decrypter = GPGDecrypter{}
+
+// GPGDecrypter decrypts entries using the system's GPG binary.
+type GPGDecrypter struct{}
+
+// Decrypt implements Decrypter. gpg-agent is probed first, so a host started
+// without access to it fails with gpg.ErrNoAgent. What gpg waits for, such
+// as the touch of a smartcard, is sent to the browser as protocol events.
+func (GPGDecrypter) Decrypt(ctx context.Context, dst io.Writer, src io.Reader) error {
+	if err := gpg.ProbeAgent(ctx); err != nil {
+		return err
+	}
+	events := gpg.WithEvents(ctx, func(e gpg.Event) { protocol.Notify(ctx, string(e)) })
+	return gpg.DecryptTo(events, dst, src)
+}
+
+// gpgRefusals are the answers to decryption failures gpg told the cause of,
+// with hints for fixing it.
+var gpgRefusals = []struct {
+	err error
+	errorResponse
+}{
+	{gpg.ErrNoAgent, errorResponse{Code: CodeNoAgent, Hint: "Make sure gpg-agent runs for the browser too: start it with \"gpgconf --launch gpg-agent\", and check that the browser sees the same GNUPGHOME."}},
+	{gpg.ErrNoPinentry, errorResponse{Code: CodeNoPinentry, Hint: "gpg-agent has no pinentry that can ask for your passphrase from the browser. Install a graphical one, such as pinentry-gnome3, pinentry-qt or pinentry-mac, set it as pinentry-program in gpg-agent.conf and run \"gpgconf --kill gpg-agent\"."}},
+	{pass.ErrCanceled, errorResponse{Code: CodeCanceled, Hint: "The passphrase prompt was closed without an answer."}},
+	{pass.ErrBadPassphrase, errorResponse{Code: CodeBadPassphrase, Hint: "gpg-agent was given a wrong passphrase for your key."}},
+	{pass.ErrKeyExpired, errorResponse{Code: CodeKeyExpired, Hint: "The key the entry is encrypted to has expired. Extend it with \"gpg --quick-set-expire\"."}},
+	{pass.ErrNoSecretKey, errorResponse{Code: CodeNoSecretKey, Hint: "The entry is encrypted to a key whose secret part isn't in your keyring. Compare \"gpg --list-secret-keys\" with the .gpg-id of the store."}},
+}
+
+// gpgRefusal returns the answer to the decryption failure err if gpg told
+// its cause, nil otherwise.
+func gpgRefusal(err error) *errorResponse {
+	for _, refusal := range gpgRefusals {
+		if errors.Is(err, refusal.err) {
+			resp := refusal.errorResponse
+			resp.Message = refusal.err.Error()
+			return &resp
+		}
+	}
+	return nil
+}
+
+// copyPlaintext copies src to dst, letting dst read for itself when it can
+// so plaintext never passes through an intermediate buffer.
+func copyPlaintext(dst io.Writer, src io.Reader) error {
+	if rf, ok := dst.(io.ReaderFrom); ok {
+		_, err := rf.ReadFrom(src)
+		return err
+	}
+	_, err := io.Copy(dst, src)
+	return err
+}
@@ -0,0 +1,37 @@
+package browserpass
+
+import (
+	"context"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// storeReport answers the "doctor" action.
+type storeReport struct {
+	Entries  int            `json:"entries"`
+	Problems []pass.Problem `json:"problems"`
+}
+
+// doctor answers the "doctor" action with the problems of the store that
+// may keep entries from showing up or from being decrypted, see
+// pass.Diagnose and pass.Duplicates. Problems of files the caller may not
+// access are left out.
+func (c *conn) doctor(ctx context.Context, data map[string]string) (interface{}, error) {
+	list, err := c.s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list = filterAllowed(c.caller, data["container"], list)
+	found, err := pass.Diagnose(ctx, c.s)
+	if err != nil {
+		return nil, err
+	}
+	report := storeReport{Entries: len(list), Problems: []pass.Problem{}}
+	for _, p := range found {
+		if allowedItem(c.caller, data["container"], p.Path) {
+			report.Problems = append(report.Problems, p)
+		}
+	}
+	report.Problems = append(report.Problems, pass.Duplicates(list)...)
+	return report, nil
+}
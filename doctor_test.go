@@ -0,0 +1,42 @@
+package browserpass
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// sickStore is a fakeStore with problems.
+type sickStore struct {
+	fakeStore
+}
+
+func (s sickStore) Diagnose(ctx context.Context) ([]pass.Problem, error) {
+	return []pass.Problem{
+		{Kind: pass.ProblemUnreadable, Path: "example.com/carol.gpg", Detail: "permission denied"},
+		{Kind: pass.ProblemBrokenLink, Path: "work/example.com.gpg", Detail: "the link is broken or leads outside the store"},
+	}, nil
+}
+
+func TestRunDoctor(t *testing.T) {
+	s := sickStore{fakeStore{"example.com/alice", "old/example.com/alice", "work/example.org/bob"}}
+
+	var report storeReport
+	roundTrip(t, s, AllowedOrigins[0], map[string]string{"action": "doctor"}, &report)
+	kinds := []string{}
+	for _, p := range report.Problems {
+		kinds = append(kinds, p.Kind)
+	}
+	if report.Entries != 3 || !reflect.DeepEqual(kinds, []string{pass.ProblemUnreadable, pass.ProblemBrokenLink, pass.ProblemDuplicateEntry}) {
+		t.Errorf("doctor returned %+v", report)
+	}
+
+	Policies = map[string][]string{AllowedOrigins[0]: {"work"}}
+	defer func() { Policies = nil }()
+	roundTrip(t, s, AllowedOrigins[0], map[string]string{"action": "doctor"}, &report)
+	if report.Entries != 1 || len(report.Problems) != 1 || report.Problems[0].Kind != pass.ProblemBrokenLink {
+		t.Errorf("doctor under a policy returned %+v", report)
+	}
+}
@@ -0,0 +1,140 @@
+package e2e
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/fixture"
+)
+
+var (
+	launcher, host string
+	// env points the host at a fixture store and away from the user's
+	// real configuration.
+	env []string
+)
+
+const origin = "browserpass@dannyvankooten.com"
+
+func TestMain(m *testing.M) {
+	RunLauncher()
+
+	flag.Parse()
+	if testing.Short() {
+		os.Exit(0)
+	}
+
+	dir, err := ioutil.TempDir("", "browserpass-e2e")
+	if err != nil {
+		panic(err)
+	}
+	code := func() int {
+		defer os.RemoveAll(dir)
+
+		// Without gpg, the host reads a plaintext fixture store
+		store := filepath.Join(dir, "store")
+		gnupg := filepath.Join(dir, "gnupg")
+		opts := fixture.Options{Entries: 20, Depth: 2, Seed: 1}
+		var tags []string
+		os.Mkdir(gnupg, 0700)
+		if _, err := exec.LookPath("gpg"); err == nil && fixture.NewKey(gnupg) == nil {
+			opts.GPGHome = gnupg
+		} else {
+			tags = append(tags, "fakegpg")
+		}
+		if err := fixture.Generate(store, opts); err != nil {
+			panic(err)
+		}
+
+		if host, err = Build(dir, tags...); err != nil {
+			panic(err)
+		}
+		if launcher, err = NewLauncher(dir); err != nil {
+			panic(err)
+		}
+		// The launcher is the only browser the host accepts
+		os.MkdirAll(filepath.Join(dir, "config", "browserpass"), 0700)
+		cfg, _ := json.Marshal(map[string][]string{"allowed_browsers": {launcher}})
+		if err := ioutil.WriteFile(filepath.Join(dir, "config", "browserpass", "config.json"), cfg, 0600); err != nil {
+			panic(err)
+		}
+		env = []string{
+			"PASSWORD_STORE_DIR=" + store,
+			"GNUPGHOME=" + gnupg,
+			"XDG_CONFIG_HOME=" + filepath.Join(dir, "config"),
+			"XDG_CACHE_HOME=" + filepath.Join(dir, "cache"),
+		}
+		return m.Run()
+	}()
+	os.Exit(code)
+}
+
+func startHost(t *testing.T, args ...string) *Host {
+	h, err := Start(launcher, host, env, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestSearchAndGet(t *testing.T) {
+	h := startHost(t, "/path/to/manifest.json", origin)
+	defer h.Close()
+
+	var results []string
+	if err := h.Call(map[string]string{"action": "search", "domain": "example"}, &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 {
+		t.Fatal("Search found no entries")
+	}
+
+	token, err := h.Handshake()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var login map[string]string
+	host := strings.SplitN(results[0], "/", 2)[0]
+	if err := h.Call(map[string]string{"action": "get", "entry": results[0], "host": host, "token": token}, &login); err != nil {
+		t.Fatal(err)
+	}
+	if login["p"] == "" || login["u"] == "" {
+		t.Errorf("Unexpected login %v", login)
+	}
+}
+
+func TestInvalidItem(t *testing.T) {
+	h := startHost(t, "/path/to/manifest.json", origin)
+	defer h.Close()
+
+	token, err := h.Handshake()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp map[string]string
+	if err := h.Call(map[string]string{"action": "get", "entry": "../../etc/passwd", "token": token}, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp["code"] != "INVALID_ITEM" {
+		t.Errorf("Expected INVALID_ITEM, got %v", resp)
+	}
+}
+
+func TestUnknownCaller(t *testing.T) {
+	h := startHost(t, "/path/to/manifest.json", "rogue@example.com")
+	defer h.Close()
+
+	var results []string
+	if err := h.Call(map[string]string{"action": "search", "domain": "example"}, &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Unknown caller got results %v", results)
+	}
+}
@@ -0,0 +1,142 @@
+// Package e2e runs the browserpass binary as a native messaging host and
+// talks to it over pipes, like a browser does.
+package e2e
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// launcherEnv tells a copy of the test binary to act as the browser and run
+// the host given in the variable, see RunLauncher.
+const launcherEnv = "BROWSERPASS_E2E_HOST"
+
+// Host is a running native messaging host.
+type Host struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+}
+
+// Build compiles the browserpass binary with the given build tags into dir.
+func Build(dir string, tags ...string) (string, error) {
+	bin := filepath.Join(dir, "browserpass")
+	cmd := exec.Command("go", "build", "-tags", strings.Join(tags, ","), "-o", bin, "github.com/dannyvankooten/browserpass/cmd/browserpass")
+	cmd.Stderr = os.Stderr
+	return bin, cmd.Run()
+}
+
+// Start launches the host binary with the given environment and arguments.
+// The host is started through launcher, an executable named like a browser,
+// because the host refuses to serve secrets to any other parent process.
+// Use NewLauncher to create one.
+func Start(launcher, host string, env []string, args ...string) (*Host, error) {
+	cmd := exec.Command(launcher, args...)
+	cmd.Env = append(append(os.Environ(), env...), launcherEnv+"="+host)
+	cmd.Stderr = os.Stderr
+
+	h := &Host{cmd: cmd}
+	var err error
+	if h.stdin, err = cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	if h.stdout, err = cmd.StdoutPipe(); err != nil {
+		return nil, err
+	}
+	return h, cmd.Start()
+}
+
+// Send writes a length-prefixed JSON request.
+func (h *Host) Send(req interface{}) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(h.stdin, binary.LittleEndian, uint32(len(b))); err != nil {
+		return err
+	}
+	_, err = h.stdin.Write(b)
+	return err
+}
+
+// Receive reads a length-prefixed JSON response into resp.
+func (h *Host) Receive(resp interface{}) error {
+	var n uint32
+	if err := binary.Read(h.stdout, binary.LittleEndian, &n); err != nil {
+		return err
+	}
+	return json.NewDecoder(io.LimitReader(h.stdout, int64(n))).Decode(resp)
+}
+
+// Call sends req and receives the response into resp.
+func (h *Host) Call(req, resp interface{}) error {
+	if err := h.Send(req); err != nil {
+		return err
+	}
+	return h.Receive(resp)
+}
+
+// Handshake starts a session, returning the token privileged requests must
+// carry.
+func (h *Host) Handshake() (string, error) {
+	var resp struct {
+		Token string `json:"token"`
+	}
+	err := h.Call(map[string]string{"action": "handshake"}, &resp)
+	return resp.Token, err
+}
+
+// Close closes the host's stdin, as a browser does when the extension
+// disconnects, and waits for it to exit.
+func (h *Host) Close() error {
+	h.stdin.Close()
+	return h.cmd.Wait()
+}
+
+// NewLauncher copies the running test binary into dir under a browser's
+// name. The copy must call RunLauncher first thing in TestMain.
+func NewLauncher(dir string) (string, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	src, err := os.Open(self)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	launcher := filepath.Join(dir, "firefox")
+	dst, err := os.OpenFile(launcher, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0700)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return "", err
+	}
+	return launcher, dst.Close()
+}
+
+// RunLauncher runs the host and exits if the process was started as a
+// launcher by Start. It returns otherwise.
+func RunLauncher() {
+	host := os.Getenv(launcherEnv)
+	if host == "" {
+		return
+	}
+	cmd := exec.Command(host, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exit, ok := err.(*exec.ExitError); ok {
+			os.Exit(exit.ExitCode())
+		}
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,58 @@
+package browserpass
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+
+	"github.com/dannyvankooten/browserpass/pass"
+	"github.com/dannyvankooten/browserpass/protocol"
+)
+
+// Errors requests are answered with, see protocol.Error. Handlers return
+// them, or errors wrapping them, and the extension tells them apart by
+// code; the Code constants list the rest.
+var (
+	// ErrNotFound answers requests for entries that don't exist, or that
+	// the caller mustn't know about
+	ErrNotFound = errorResponse{Code: CodeNotFound, Message: pass.ErrNotFound.Error()}
+	// ErrInvalidItem answers requests for entries with invalid names
+	ErrInvalidItem = errorResponse{Code: CodeInvalidItem, Message: "invalid entry name"}
+	// ErrInvalidRequest answers requests with missing or malformed fields
+	ErrInvalidRequest = errorResponse{Code: CodeInvalidRequest, Message: "invalid request"}
+	// ErrNoSession answers privileged requests on a connection that didn't
+	// do a handshake
+	ErrNoSession = errorResponse{Code: CodeNoSession, Message: "no session, a handshake must come first"}
+	// ErrBadSession answers requests without a valid session token
+	ErrBadSession = errorResponse{Code: CodeBadSession, Message: "invalid or expired session token"}
+	// ErrDecrypt answers requests for entries that failed to decrypt for
+	// another reason than the setup of gpg
+	ErrDecrypt = errorResponse{Code: CodeDecryptFailed, Message: "the entry could not be decrypted"}
+	// ErrStoreUnavailable answers requests the store couldn't be read for
+	ErrStoreUnavailable = errorResponse{Code: CodeStoreUnavailable, Message: "the password store could not be read"}
+	// ErrStoreNotInitialized answers requests before the store is created,
+	// see the "init" action
+	ErrStoreNotInitialized = errorResponse{Code: CodeStoreNotInitialized, Message: "the password store doesn't exist yet", Hint: "Create it with \"pass init\" and your GPG key, or from the extension."}
+)
+
+// classified answers the errors of h as the errors above when they are of
+// their kind: entries gone since they were listed as ErrNotFound, stores
+// not created yet as ErrStoreNotInitialized and files of the store that
+// can't be read as ErrStoreUnavailable. Other errors are left to
+// protocol.Mux.Serve.
+func classified(h protocol.Handler) protocol.Handler {
+	return func(ctx context.Context, data map[string]string) (interface{}, error) {
+		resp, err := h(ctx, data)
+		var pathErr *fs.PathError
+		switch {
+		case err == nil || ctx.Err() != nil:
+		case errors.Is(err, pass.ErrNotFound):
+			err = ErrNotFound
+		case errors.Is(err, pass.ErrStoreNotInitialized):
+			err = ErrStoreNotInitialized
+		case errors.As(err, &pathErr):
+			err = errorResponse{Code: CodeStoreUnavailable, Message: err.Error()}
+		}
+		return resp, err
+	}
+}
@@ -0,0 +1,43 @@
+package browserpass
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/fixture"
+)
+
+// unreadableStore is a fakeStore whose directory can't be read.
+type unreadableStore struct {
+	fakeStore
+}
+
+func (s unreadableStore) List(ctx context.Context) ([]string, error) {
+	return nil, &fs.PathError{Op: "open", Path: ".", Err: fs.ErrPermission}
+}
+
+func TestRunErrors(t *testing.T) {
+	LockoutFile = filepath.Join(t.TempDir(), "lockout.json")
+	DefaultDecrypter = failingDecrypter("gpg: decryption failed: Invalid packet\n")
+	defer func() { DefaultDecrypter, LockoutFile = fixture.FakeDecrypter{}, "" }()
+
+	var resp map[string]interface{}
+	roundTrip(t, fakeStore{"example.com/alice"}, AllowedOrigins[0], map[string]string{"action": "get", "entry": "example.com/alice", "version": "3"}, &resp)
+	if resp["status"] != "error" || resp["code"] != CodeDecryptFailed || resp["message"] == "" {
+		t.Errorf("Failed decryption answered with %v", resp)
+	}
+
+	resp = nil
+	roundTrip(t, unreadableStore{}, AllowedOrigins[0], map[string]string{"action": "list", "version": "3"}, &resp)
+	if resp["status"] != "error" || resp["code"] != CodeStoreUnavailable {
+		t.Errorf("Unreadable store answered with %v", resp)
+	}
+
+	resp = nil
+	roundTrip(t, fakeStore{}, AllowedOrigins[0], map[string]string{"action": "get", "entry": "example.com/nobody"}, &resp)
+	if resp["error"] != ErrNotFound.Message || resp["code"] != CodeNotFound {
+		t.Errorf("Missing entry answered version 1 with %v", resp)
+	}
+}
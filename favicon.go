@@ -0,0 +1,195 @@
+package browserpass
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// FaviconDir is where FetchFavicons keeps the favicons of the domains in
+// the store, for the "favicons" action to send. Favicons are off if empty.
+var FaviconDir string
+
+// faviconURL is the URL of the favicon of a domain, formatted with it.
+var faviconURL = "https://%s/favicon.ico"
+
+// faviconClient fetches favicons, giving up on slow sites.
+var faviconClient = &http.Client{Timeout: 10 * time.Second}
+
+const (
+	// maxFaviconSize is the size of the largest favicon kept, in bytes
+	maxFaviconSize = 64 << 10
+	// maxFaviconDomains is how many domains the "favicons" action takes
+	maxFaviconDomains = 1000
+)
+
+// FetchFavicons fetches the favicon of each domain of the store not fetched
+// before into FaviconDir, returning how many it fetched. Sites answering
+// without an image are remembered so they aren't asked again; unreachable
+// ones are logged and tried on the next run, so it can run offline.
+func FetchFavicons(ctx context.Context, s pass.Store) (int, error) {
+	if FaviconDir == "" {
+		return 0, errors.New("favicons are off, set favicons in the config")
+	}
+	if err := os.MkdirAll(FaviconDir, 0700); err != nil {
+		return 0, err
+	}
+	list, err := s.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	settings, err := pass.SettingsOf(s)
+	if err != nil {
+		return 0, err
+	}
+	seen := make(map[string]bool)
+	var domains []string
+	for _, item := range list {
+		if domain := canonicalHost(pass.DomainOf(item, settings)); validHost(domain) && !seen[domain] {
+			seen[domain] = true
+			domains = append(domains, domain)
+		}
+	}
+	sort.Strings(domains)
+
+	fetched := 0
+	for _, domain := range domains {
+		if _, err := os.Stat(faviconFile(domain)); err == nil {
+			continue
+		}
+		icon, err := fetchFavicon(ctx, domain)
+		if ctx.Err() != nil {
+			return fetched, ctx.Err()
+		}
+		if err != nil {
+			slog.Warn("favicon not fetched", "domain", domain, "err", err)
+			continue
+		}
+		if err := writeFavicon(domain, icon); err != nil {
+			return fetched, err
+		}
+		if len(icon) > 0 {
+			fetched++
+		}
+	}
+	return fetched, nil
+}
+
+// fetchFavicon returns the favicon of domain, empty if the site answers
+// without one.
+func fetchFavicon(ctx context.Context, domain string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(faviconURL, domain), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := faviconClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+	icon, err := io.ReadAll(io.LimitReader(resp.Body, maxFaviconSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(icon) > maxFaviconSize || !strings.HasPrefix(http.DetectContentType(icon), "image/") {
+		return nil, nil
+	}
+	return icon, nil
+}
+
+// writeFavicon saves the favicon of domain, replacing it atomically.
+func writeFavicon(domain string, icon []byte) error {
+	f, err := pass.CreateTemp(FaviconDir)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(icon); err != nil {
+		pass.Shred(f)
+		return err
+	}
+	return pass.Replace(f, faviconFile(domain))
+}
+
+// faviconFile returns the file keeping the favicon of domain, empty if the
+// site has none.
+func faviconFile(domain string) string {
+	return filepath.Join(FaviconDir, domain+".icon")
+}
+
+// cachedFavicon returns the favicon of domain as a data URI, empty if it
+// wasn't fetched. It never goes to the network.
+func cachedFavicon(domain string) string {
+	icon, err := os.ReadFile(faviconFile(domain))
+	if err != nil || len(icon) == 0 {
+		return ""
+	}
+	return "data:" + http.DetectContentType(icon) + ";base64," + base64.StdEncoding.EncodeToString(icon)
+}
+
+// validHost reports whether host is a lowercase ASCII host name with a dot,
+// safe to name a file after.
+func validHost(host string) bool {
+	if !strings.Contains(host, ".") || len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || strings.HasPrefix(label, "-") {
+			return false
+		}
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// favicons answers the "favicons" action with the cached favicons of the
+// newline separated "domains", as data URIs keyed by domain. Domains without
+// one or without entries the caller may access are left out, and nothing is
+// fetched.
+func (c *conn) favicons(ctx context.Context, data map[string]string) (interface{}, error) {
+	domains := strings.FieldsFunc(data["domains"], func(r rune) bool { return r == '\n' })
+	if len(domains) > maxFaviconDomains {
+		return errorResponse{Message: "too many domains", Code: CodeInvalidRequest}, nil
+	}
+	icons := make(map[string]string)
+	if FaviconDir == "" {
+		return icons, nil
+	}
+	list, err := c.s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := pass.SettingsOf(c.s)
+	if err != nil {
+		return nil, err
+	}
+	allowed := make(map[string]bool)
+	for _, item := range filterAllowed(c.caller, data["container"], list) {
+		allowed[canonicalHost(pass.DomainOf(item, settings))] = true
+	}
+	for _, domain := range domains {
+		if host := canonicalHost(domain); validHost(host) && allowed[host] {
+			if icon := cachedFavicon(host); icon != "" {
+				icons[domain] = icon
+			}
+		}
+	}
+	return icons, nil
+}
@@ -0,0 +1,61 @@
+package browserpass
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFetchFavicons(t *testing.T) {
+	s := fakeStore{"example.com/alice", "example.com/bob", "work/example.org/carol"}
+	FaviconDir = t.TempDir()
+	defer func() { FaviconDir, faviconURL = "", "https://%s/favicon.ico" }()
+
+	var asked []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		asked = append(asked, r.URL.Path)
+		if r.URL.Path != "/example.com" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("\x89PNG\r\n\x1a\nicon"))
+	}))
+	faviconURL = srv.URL + "/%s"
+
+	// Offline, nothing is fetched nor remembered
+	srv.Close()
+	if fetched, err := FetchFavicons(context.Background(), s); fetched != 0 || err != nil {
+		t.Fatalf("FetchFavicons offline returned %d, %v", fetched, err)
+	}
+
+	srv = httptest.NewServer(srv.Config.Handler)
+	defer srv.Close()
+	faviconURL = srv.URL + "/%s"
+	if fetched, err := FetchFavicons(context.Background(), s); fetched != 1 || err != nil {
+		t.Fatalf("FetchFavicons returned %d, %v", fetched, err)
+	}
+	if fetched, err := FetchFavicons(context.Background(), s); fetched != 0 || err != nil {
+		t.Fatalf("FetchFavicons again returned %d, %v", fetched, err)
+	}
+	if expected := []string{"/example.com", "/example.org"}; !reflect.DeepEqual(asked, expected) {
+		t.Errorf("Asked for %v, expected %v", asked, expected)
+	}
+
+	var icons map[string]string
+	req := map[string]string{"action": "favicons", "domains": "example.com\nexample.org\n../etc"}
+	roundTrip(t, s, AllowedOrigins[0], req, &icons)
+	if len(icons) != 1 || !strings.HasPrefix(icons["example.com"], "data:image/png;base64,") {
+		t.Errorf("favicons returned %v", icons)
+	}
+
+	Policies = map[string][]string{AllowedOrigins[0]: {"work"}}
+	defer func() { Policies = nil }()
+	icons = nil
+	roundTrip(t, s, AllowedOrigins[0], req, &icons)
+	if len(icons) != 0 {
+		t.Errorf("favicons under a policy returned %v", icons)
+	}
+}
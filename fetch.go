@@ -0,0 +1,168 @@
+package browserpass
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// FetchWorkers is how many entries the "fetch_all" action decrypts at once.
+var FetchWorkers = 4
+
+// maxFetchEntries is the most entries a single "fetch_all" may ask for.
+const maxFetchEntries = 100
+
+// entryMetadata describes an entry without its secrets. Entries that
+// couldn't be decrypted carry the error and code "get" would have answered
+// with instead.
+type entryMetadata struct {
+	Entry    string            `json:"entry"`
+	Username string            `json:"username,omitempty"`
+	URL      string            `json:"url,omitempty"`
+	OTP      bool              `json:"otp"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Code     string            `json:"code,omitempty"`
+
+	// hosts are those of the entry's URLs, for the metadata index
+	hosts []string
+}
+
+// fetchMetadata answers the "fetch_all" action with the username of each of
+// the newline separated "entries" and whether it has an OTP, for the popup
+// to show next to the matches of a search.
+func (c *conn) fetchMetadata(ctx context.Context, data map[string]string) (interface{}, error) {
+	items := strings.FieldsFunc(data["entries"], func(r rune) bool { return r == '\n' })
+	if len(items) > maxFetchEntries {
+		return errorResponse{Message: "too many entries", Code: CodeInvalidRequest}, nil
+	}
+	if refused := c.checkSession(data); refused != nil {
+		return refused, nil
+	}
+	return c.fetchAll(ctx, items, data)
+}
+
+// meta answers the "meta" action with the entryMetadata of a single entry,
+// for listing it without sending its password.
+func (c *conn) meta(ctx context.Context, data map[string]string) (interface{}, error) {
+	if refused := c.checkSession(data); refused != nil {
+		return refused, nil
+	}
+	settings, err := pass.SettingsOf(c.s)
+	if err != nil {
+		return nil, err
+	}
+	var r entryMetadata
+	if err := c.fetchOne(ctx, data["entry"], data, settings, &r); err != nil {
+		return nil, err
+	}
+	if r.Code != "" {
+		return errorResponse{Message: r.Error, Code: r.Code}, nil
+	}
+	return r, nil
+}
+
+// fetchAll decrypts items with up to FetchWorkers gpg processes at a time.
+// The first one is decrypted on its own, so the agent asks for the
+// passphrase at most once.
+func (c *conn) fetchAll(ctx context.Context, items []string, data map[string]string) ([]entryMetadata, error) {
+	results := make([]entryMetadata, len(items))
+	if len(items) == 0 {
+		return results, nil
+	}
+	settings, err := pass.SettingsOf(c.s)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.fetchOne(ctx, items[0], data, settings, &results[0]); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	next := make(chan int)
+	errs := make(chan error, 1)
+	var wg sync.WaitGroup
+	for w := 0; w < FetchWorkers && w < len(items)-1; w++ {
+		wg.Add(1)
+		go func() {
+			defer Guard()
+			defer wg.Done()
+			for i := range next {
+				if err := c.fetchOne(ctx, items[i], data, settings, &results[i]); err != nil {
+					select {
+					case errs <- err:
+					default:
+					}
+					cancel()
+				}
+			}
+		}()
+	}
+feed:
+	for i := 1; i < len(items); i++ {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	select {
+	case err := <-errs:
+		return nil, err
+	default:
+	}
+	return results, ctx.Err()
+}
+
+// fetchOne decrypts item into r, and records it in the audit log. Refused
+// items are recorded in r, only failures to decrypt are returned.
+func (c *conn) fetchOne(ctx context.Context, item string, data map[string]string, settings *pass.Settings, r *entryMetadata) error {
+	r.Entry = item
+	plaintext, refused, err := c.decryptItem(ctx, item, data)
+	if err != nil {
+		return err
+	}
+	if refused != nil {
+		r.Error, r.Code = refused.Message, refused.Code
+		return nil
+	}
+	r.hosts = entryHosts(plaintext.Bytes())
+	login, err := ParseLogin(plaintext.Bytes())
+	plaintext.Wipe()
+	if err != nil {
+		return err
+	}
+	defer login.Wipe()
+	login.resolveUsername(item, settings)
+	r.Username, r.URL, r.OTP = login.Username, login.URL, login.OTP != nil
+	r.Fields = publicFields(login.Fields)
+	return c.audit(item, data["host"])
+}
+
+// secretFieldWords mark the keys of fields holding secrets, which
+// entryMetadata leaves out.
+var secretFieldWords = []string{"pass", "pin", "secret", "token", "key", "cvv", "cvc", "otp", "recovery"}
+
+// publicFields returns the fields of an entry but those whose key suggests a
+// secret, nil if there are none.
+func publicFields(fields map[string]string) map[string]string {
+	var public map[string]string
+fields:
+	for key, value := range fields {
+		for _, word := range secretFieldWords {
+			if strings.Contains(key, word) {
+				continue fields
+			}
+		}
+		if public == nil {
+			public = make(map[string]string)
+		}
+		public[key] = value
+	}
+	return public
+}
@@ -0,0 +1,109 @@
+package browserpass
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dannyvankooten/browserpass/fixture"
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// slowDecrypter records how many decryptions of the fake decrypter run at
+// once.
+type slowDecrypter struct {
+	mu          sync.Mutex
+	running     int
+	mostRunning int
+}
+
+func (d *slowDecrypter) Decrypt(ctx context.Context, dst io.Writer, src io.Reader) error {
+	d.mu.Lock()
+	d.running++
+	if d.running > d.mostRunning {
+		d.mostRunning = d.running
+	}
+	d.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	d.mu.Lock()
+	d.running--
+	d.mu.Unlock()
+	return fixture.FakeDecrypter{}.Decrypt(ctx, dst, src)
+}
+
+func TestRunFetchAll(t *testing.T) {
+	d := &slowDecrypter{}
+	DefaultDecrypter = d
+	defer func() { DefaultDecrypter = fixture.FakeDecrypter{} }()
+	var entries []string
+	for _, name := range []string{"alice", "bob", "carol", "dave", "erin", "frank", "grace"} {
+		entries = append(entries, "example.com/"+name)
+	}
+	s := otpStore{fakeStore(entries)}
+	caller := AllowedOrigins[0]
+
+	var resp []entryMetadata
+	req := map[string]string{"action": "fetch_all", "entries": "example.com/alice\nexample.com/nobody\nexample.com/bob\nexample.com/carol\nexample.com/dave\nexample.com/erin\nexample.com/frank\nexample.com/grace"}
+	roundTrip(t, s, caller, req, &resp)
+	expected := []entryMetadata{
+		{Entry: "example.com/alice", Username: "alice", OTP: true},
+		{Entry: "example.com/nobody", Error: pass.ErrNotFound.Error(), Code: CodeNotFound},
+	}
+	if len(resp) != 8 || !reflect.DeepEqual(resp[:2], expected) || !reflect.DeepEqual(resp[7], entryMetadata{Entry: "example.com/grace", Username: "grace", OTP: true}) {
+		t.Errorf("fetch_all returned %+v", resp)
+	}
+	if d.mostRunning > FetchWorkers || d.mostRunning < 2 {
+		t.Errorf("%d decryptions ran at once, expected up to %d", d.mostRunning, FetchWorkers)
+	}
+
+	roundTrip(t, s, "https://evil.example", req, &resp)
+	if len(resp) != 0 {
+		t.Errorf("Unauthorized caller got %+v", resp)
+	}
+	var refused errorResponse
+	roundTrip(t, s, caller, map[string]string{"action": "fetch_all", "entries": strings.Repeat("example.com/alice\n", maxFetchEntries+1)}, &refused)
+	if refused.Code != CodeInvalidRequest {
+		t.Errorf("fetch_all of too many entries returned %+v", refused)
+	}
+}
+
+// fieldsStore is a fakeStore whose entries have secret and public fields.
+type fieldsStore struct {
+	fakeStore
+}
+
+func (s fieldsStore) Open(ctx context.Context, item string) (io.ReadCloser, error) {
+	if _, err := s.fakeStore.Open(ctx, item); err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(strings.NewReader(fixture.FakeHeader + "hunter2\nlogin: alice@mail.test\nurl: https://example.com/login\nemail: alice@mail.test\npin: 1234\napi_key: abcdef\notpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP\n")), nil
+}
+
+func TestRunMeta(t *testing.T) {
+	s := fieldsStore{fakeStore{"example.com/alice"}}
+	caller := AllowedOrigins[0]
+
+	var resp entryMetadata
+	roundTrip(t, s, caller, map[string]string{"action": "meta", "entry": "example.com/alice", "host": "example.com"}, &resp)
+	expected := entryMetadata{
+		Entry:    "example.com/alice",
+		Username: "alice@mail.test",
+		URL:      "https://example.com/login",
+		OTP:      true,
+		Fields:   map[string]string{"login": "alice@mail.test", "url": "https://example.com/login", "email": "alice@mail.test"},
+	}
+	if !reflect.DeepEqual(resp, expected) {
+		t.Errorf("meta returned %+v, expected %+v", resp, expected)
+	}
+
+	var refused errorResponse
+	roundTrip(t, s, caller, map[string]string{"action": "meta", "entry": "example.com/nobody"}, &refused)
+	if refused.Code != CodeNotFound {
+		t.Errorf("meta of a missing entry returned %+v", refused)
+	}
+}
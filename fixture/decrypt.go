@@ -0,0 +1,35 @@
+package fixture
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+)
+
+// FakeHeader starts every entry of a store generated without a GPG key. The
+// rest of the file is the entry's plaintext.
+const FakeHeader = "-----BEGIN BROWSERPASS FIXTURE-----\n"
+
+// ErrNotFake is returned by FakeDecrypter for files without FakeHeader.
+var ErrNotFake = errors.New("fixture: not a fake encrypted entry")
+
+// FakeDecrypter "decrypts" entries of plaintext fixture stores, so tests and
+// development don't need gpg or a keyring. It satisfies the browserpass
+// Decrypter interface.
+type FakeDecrypter struct{}
+
+// Decrypt writes the plaintext of the fake encrypted entry read from src to
+// dst.
+func (FakeDecrypter) Decrypt(ctx context.Context, dst io.Writer, src io.Reader) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	br := bufio.NewReader(src)
+	header, err := br.ReadString('\n')
+	if err != nil || header != FakeHeader {
+		return ErrNotFake
+	}
+	_, err = br.WriteTo(dst)
+	return err
+}
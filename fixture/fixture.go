@@ -0,0 +1,117 @@
+// Package fixture generates synthetic password stores for tests, benchmarks
+// and bug reports.
+package fixture
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Options control the generated store.
+type Options struct {
+	// Entries is the number of entries to generate.
+	Entries int
+	// Depth is the number of path segments of each entry: 1 is
+	// "domain.gpg", 2 "domain/user.gpg", 3 "category/domain/user.gpg" and
+	// so on.
+	Depth int
+	// Unicode mixes internationalized domains and usernames into the store.
+	Unicode bool
+	// Seed makes the generated store reproducible.
+	Seed int64
+	// GPGHome, if set, is a GnuPG home directory holding a throwaway key
+	// the entries are encrypted to, see NewKey. Otherwise entries are
+	// written as plaintext marked with FakeHeader, see FakeDecrypter.
+	GPGHome string
+}
+
+var (
+	words        = []string{"example", "mail", "shop", "bank", "forum", "cloud", "news", "git", "wiki", "travel"}
+	unicodeWords = []string{"münchen", "bücher", "日本", "пример", "café", "ñandú"}
+	tlds         = []string{"com", "org", "net", "io", "co.uk", "de"}
+	users        = []string{"alice", "bob", "carol", "dave", "eve", "mallory"}
+	unicodeUsers = []string{"zoë", "jürgen", "山田", "élise"}
+	categories   = []string{"work", "personal", "shared", "archive"}
+)
+
+// Generate writes a store with opts to dir, which is created if needed.
+func Generate(dir string, opts Options) error {
+	if opts.Depth < 1 {
+		return fmt.Errorf("fixture: depth must be at least 1, got %d", opts.Depth)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if opts.GPGHome != "" {
+		if err := os.WriteFile(filepath.Join(dir, ".gpg-id"), []byte(KeyID+"\n"), 0600); err != nil {
+			return err
+		}
+	}
+
+	r := rand.New(rand.NewSource(opts.Seed))
+	seen := make(map[string]bool)
+	for i := 0; i < opts.Entries; i++ {
+		domain := fmt.Sprintf("%s%d.%s", pick(r, words, unicodeWords, opts.Unicode), r.Intn(opts.Entries+1), tlds[r.Intn(len(tlds))])
+		user := pick(r, users, unicodeUsers, opts.Unicode)
+
+		var segments []string
+		for j := 0; j < opts.Depth-2; j++ {
+			segments = append(segments, categories[r.Intn(len(categories))])
+		}
+		segments = append(segments, domain)
+		if opts.Depth > 1 {
+			segments = append(segments, user)
+		}
+		item := strings.Join(segments, "/")
+		if seen[item] {
+			i--
+			continue
+		}
+		seen[item] = true
+
+		content := fmt.Sprintf("%s\nlogin: %s\nurl: https://%s/\n", password(r), user, domain)
+		if err := writeEntry(dir, item, []byte(content), opts.GPGHome); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pick(r *rand.Rand, plain, unicode []string, useUnicode bool) string {
+	if useUnicode && r.Intn(3) == 0 {
+		return unicode[r.Intn(len(unicode))]
+	}
+	return plain[r.Intn(len(plain))]
+}
+
+func password(r *rand.Rand) string {
+	const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!@#$%^&*"
+	b := make([]byte, 20)
+	for i := range b {
+		b[i] = chars[r.Intn(len(chars))]
+	}
+	return string(b)
+}
+
+func writeEntry(dir, item string, content []byte, gpgHome string) error {
+	path := filepath.Join(dir, filepath.FromSlash(item)+".gpg")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if gpgHome == "" {
+		return os.WriteFile(path, append([]byte(FakeHeader), content...), 0600)
+	}
+
+	cmd := exec.Command("gpg", "--homedir", gpgHome, "--batch", "--yes", "--trust-model", "always",
+		"--recipient", KeyID, "--output", path, "--encrypt")
+	cmd.Stdin = bytes.NewReader(content)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("fixture: gpg: %v: %s", err, out)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package fixture
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "browserpass-fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := Generate(dir, Options{Entries: 50, Depth: 3, Unicode: true, Seed: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries int
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if n := len(strings.Split(rel, string(filepath.Separator))); n != 3 {
+			t.Errorf("%s has %d segments, expected 3", rel, n)
+		}
+		entries++
+		return nil
+	})
+	if entries != 50 {
+		t.Errorf("Generated %d entries, expected 50", entries)
+	}
+}
@@ -0,0 +1,20 @@
+package fixture
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// KeyID is the user ID of the throwaway key created by NewKey.
+const KeyID = "browserpass-fixture@example.invalid"
+
+// NewKey creates a passphrase-less throwaway key in the GnuPG home directory
+// gpgHome, for generating encrypted fixtures. Never use it for real secrets.
+func NewKey(gpgHome string) error {
+	cmd := exec.Command("gpg", "--homedir", gpgHome, "--batch", "--pinentry-mode", "loopback", "--passphrase", "",
+		"--quick-generate-key", "browserpass fixture <"+KeyID+">", "default", "default", "never")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("fixture: gpg: %v: %s", err, out)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package browserpass
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/pass"
+	"github.com/dannyvankooten/browserpass/protocol"
+)
+
+func FuzzParseLogin(f *testing.F) {
+	f.Add([]byte("password\n\nfoo\nlogin: bar"))
+	f.Add([]byte("p\r\nUser: \xff\xfe\nusername:"))
+	f.Add([]byte("otpauth://totp/x?secret=JBSWY3DPEHPK3PXP"))
+	f.Add([]byte(""))
+
+	f.Fuzz(func(t *testing.T, plaintext []byte) {
+		login, err := ParseLogin(plaintext)
+		if err != nil {
+			return
+		}
+		defer login.Wipe()
+		frame, err := login.frame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := frame.WriteFrame(ioutil.Discard); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzMatchesHost(f *testing.F) {
+	f.Add("example.com/alice", "www.example.com")
+	f.Add("münchen.de/ß", "MÜNCHEN.DE")
+	f.Add("////", "")
+
+	f.Fuzz(func(t *testing.T, entry, host string) {
+		matchesHost(entry, host, &pass.Settings{})
+		validateItem(entry)
+	})
+}
+
+// FuzzRun feeds arbitrary bytes to the native messaging loop, which must
+// return instead of panicking or hanging.
+func FuzzRun(f *testing.F) {
+	frame := func(body string) []byte {
+		var b bytes.Buffer
+		protocol.WriteFrame(&b, []byte(body))
+		return b.Bytes()
+	}
+	f.Add(frame(`{"action":"search","domain":"example.com"}`))
+	f.Add(frame(`{"action":"get","entry":"../../etc/passwd"}`))
+	f.Add(frame(`{"action":"handshake"}`))
+	f.Add(append(frame(`{"action":"search","domain":"a","host":"b"}`), 0xff, 0xff, 0xff, 0xff))
+	f.Add([]byte{0x10, 0, 0, 0, '{'})
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		Run(bytes.NewReader(input), ioutil.Discard, fakeStore{"example.com/alice"}, AllowedOrigins[0])
+	})
+}
@@ -0,0 +1,209 @@
+// Package generate produces random passwords with the policies of pass
+// generate: a length and a character set, read from the same environment
+// variables, plus pronounceable passwords and passphrases of words.
+package generate
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Character sets of pass generate, with and without symbols.
+const (
+	DefaultCharset          = "[:punct:][:alnum:]"
+	DefaultCharsetNoSymbols = "[:alnum:]"
+)
+
+// Defaults of pass generate and for passphrases.
+const (
+	DefaultLength = 25
+	DefaultWords  = 6
+)
+
+// Limits on what a Policy may ask for.
+const (
+	MaxLength = 1024
+	MaxWords  = 64
+)
+
+//go:embed words.txt
+var wordList string
+
+// words are the words of passphrases, one of 1391 or about 10.4 bits each.
+var words = strings.Fields(wordList)
+
+// Policy describes the passwords to generate.
+type Policy struct {
+	// Length is the number of characters of passwords
+	Length int
+	// Charset is the characters of passwords in the syntax of tr, like
+	// "[:alnum:]_-"
+	Charset string
+	// Pronounceable passwords alternate consonants and vowels, Charset is
+	// ignored
+	Pronounceable bool
+	// Words makes passphrases of this many words instead of characters
+	Words int
+	// Separator goes between the words of passphrases
+	Separator string
+}
+
+// DefaultPolicy returns the policy of pass generate, or of pass generate
+// --no-symbols if noSymbols is set. Like pass, it honors
+// PASSWORD_STORE_GENERATED_LENGTH, PASSWORD_STORE_CHARACTER_SET and
+// PASSWORD_STORE_CHARACTER_SET_NO_SYMBOLS.
+func DefaultPolicy(noSymbols bool) Policy {
+	p := Policy{Length: DefaultLength, Charset: DefaultCharset, Separator: "-"}
+	if n, err := strconv.Atoi(os.Getenv("PASSWORD_STORE_GENERATED_LENGTH")); err == nil && n > 0 {
+		p.Length = n
+	}
+	if set := os.Getenv("PASSWORD_STORE_CHARACTER_SET"); set != "" {
+		p.Charset = set
+	}
+	if noSymbols {
+		p.Charset = DefaultCharsetNoSymbols
+		if set := os.Getenv("PASSWORD_STORE_CHARACTER_SET_NO_SYMBOLS"); set != "" {
+			p.Charset = set
+		}
+	}
+	return p
+}
+
+// Password returns a new password following p.
+func (p Policy) Password() (string, error) {
+	if p.Words > 0 {
+		return p.passphrase()
+	}
+	if p.Length <= 0 || p.Length > MaxLength {
+		return "", fmt.Errorf("generate: length must be between 1 and %d", MaxLength)
+	}
+	if p.Pronounceable {
+		return p.pronounceable()
+	}
+	charset, err := ExpandCharset(p.Charset)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, p.Length)
+	for i := range b {
+		n, err := randomInt(len(charset))
+		if err != nil {
+			return "", err
+		}
+		b[i] = charset[n]
+	}
+	return string(b), nil
+}
+
+// passphrase joins p.Words random words.
+func (p Policy) passphrase() (string, error) {
+	if p.Words > MaxWords {
+		return "", fmt.Errorf("generate: at most %d words", MaxWords)
+	}
+	chosen := make([]string, p.Words)
+	for i := range chosen {
+		n, err := randomInt(len(words))
+		if err != nil {
+			return "", err
+		}
+		chosen[i] = words[n]
+	}
+	return strings.Join(chosen, p.Separator), nil
+}
+
+const (
+	consonants = "bcdfghjklmnprstvz"
+	vowels     = "aeiou"
+)
+
+// pronounceable alternates random consonants and vowels.
+func (p Policy) pronounceable() (string, error) {
+	b := make([]byte, p.Length)
+	for i := range b {
+		letters := consonants
+		if i%2 == 1 {
+			letters = vowels
+		}
+		n, err := randomInt(len(letters))
+		if err != nil {
+			return "", err
+		}
+		b[i] = letters[n]
+	}
+	return string(b), nil
+}
+
+// randomInt returns a uniform random number in [0, n).
+func randomInt(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
+
+// classes are the character classes of tr, in ASCII.
+var classes = map[string]string{
+	"alnum":  "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+	"alpha":  "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+	"digit":  "0123456789",
+	"lower":  "abcdefghijklmnopqrstuvwxyz",
+	"upper":  "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"xdigit": "0123456789ABCDEFabcdef",
+	"punct":  "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~",
+}
+
+// ExpandCharset returns the characters of spec, a set in the syntax of tr as
+// pass takes it: "[:class:]" classes, "a-z" ranges and single printable
+// ASCII characters. Characters named more than once count once.
+func ExpandCharset(spec string) (string, error) {
+	var seen [128]bool
+	var set []byte
+	add := func(c byte) {
+		if !seen[c] {
+			seen[c] = true
+			set = append(set, c)
+		}
+	}
+	for i := 0; i < len(spec); i++ {
+		c := spec[i]
+		switch {
+		case strings.HasPrefix(spec[i:], "[:"):
+			end := strings.Index(spec[i:], ":]")
+			class, ok := "", false
+			if end > 0 {
+				class, ok = classes[spec[i+2:i+end]]
+			}
+			if !ok {
+				return "", fmt.Errorf("generate: unknown character class in %q", spec)
+			}
+			for j := 0; j < len(class); j++ {
+				add(class[j])
+			}
+			i += end + 1
+		case c < ' ' || c > '~':
+			return "", errors.New("generate: character sets are printable ASCII")
+		case i+2 < len(spec) && spec[i+1] == '-':
+			last := spec[i+2]
+			if last < c || last > '~' {
+				return "", fmt.Errorf("generate: invalid range %q", spec[i:i+3])
+			}
+			for r := c; r <= last; r++ {
+				add(r)
+			}
+			i += 2
+		default:
+			add(c)
+		}
+	}
+	if len(set) == 0 {
+		return "", errors.New("generate: empty character set")
+	}
+	return string(set), nil
+}
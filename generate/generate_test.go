@@ -0,0 +1,55 @@
+package generate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandCharset(t *testing.T) {
+	tests := map[string]string{
+		"[:digit:]":        "0123456789",
+		"a-f0-3":           "abcdef0123",
+		"[:xdigit:]a-z_":   "0123456789ABCDEFabcdefghijklmnopqrstuvwxyz_",
+		"ab-":              "ab-",
+		"[:lower:][:upper": "",
+		"z-a":              "",
+		"":                 "",
+	}
+	for spec, expected := range tests {
+		actual, err := ExpandCharset(spec)
+		if expected == "" && err == nil {
+			t.Errorf("%q: expected an error, got %q", spec, actual)
+		} else if expected != "" && (err != nil || actual != expected) {
+			t.Errorf("%q: expected %q, got %q (%v)", spec, expected, actual, err)
+		}
+	}
+}
+
+func TestPassword(t *testing.T) {
+	t.Setenv("PASSWORD_STORE_GENERATED_LENGTH", "")
+	t.Setenv("PASSWORD_STORE_CHARACTER_SET_NO_SYMBOLS", "[:digit:]")
+	p := DefaultPolicy(true)
+	password, err := p.Password()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(password) != DefaultLength || strings.Trim(password, "0123456789") != "" {
+		t.Errorf("Password %q doesn't follow %+v", password, p)
+	}
+
+	p = Policy{Length: 8, Pronounceable: true}
+	if password, _ = p.Password(); len(password) != 8 || !strings.ContainsRune(vowels, rune(password[1])) {
+		t.Errorf("Password %q isn't pronounceable", password)
+	}
+
+	p = Policy{Words: 4, Separator: " "}
+	if password, _ = p.Password(); len(strings.Fields(password)) != 4 {
+		t.Errorf("Passphrase %q doesn't have 4 words", password)
+	}
+
+	for _, p := range []Policy{{Length: MaxLength + 1, Charset: DefaultCharset}, {Words: MaxWords + 1}, {Length: 8, Charset: "[:emoji:]"}} {
+		if _, err := p.Password(); err == nil {
+			t.Errorf("%+v: expected an error", p)
+		}
+	}
+}
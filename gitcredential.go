@@ -0,0 +1,95 @@
+package browserpass
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// GitCaller is the caller git credential helper requests are audited as and
+// matched against Policies with.
+const GitCaller = "git-credential"
+
+// GitCredential implements operation of the git credential helper protocol,
+// reading the request attributes from stdin. Only "get" is answered; the
+// store is never written, so "store" and "erase" are ignored like unknown
+// operations. Requests without a matching entry get an empty answer, leaving
+// git to try its other helpers.
+func GitCredential(stdin io.Reader, stdout io.Writer, s pass.Store, operation string) error {
+	attrs := make(map[string]string)
+	scanner := bufio.NewScanner(stdin)
+	for scanner.Scan() && scanner.Text() != "" {
+		if key, value, ok := strings.Cut(scanner.Text(), "="); ok {
+			attrs[key] = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if operation != "get" || attrs["host"] == "" {
+		return nil
+	}
+
+	host := attrs["host"]
+	if i := strings.LastIndex(host, ":"); i >= 0 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+	ctx := context.Background()
+	list, err := Lookup(ctx, s, host)
+	if err != nil {
+		return err
+	}
+	entry := pickGitEntry(filterAllowed(GitCaller, "", list), attrs["username"], attrs["path"])
+	if entry == "" {
+		return nil
+	}
+
+	login, err := OpenLogin(ctx, s, GitCaller, entry)
+	if err != nil {
+		return err
+	}
+	defer login.Wipe()
+	if strings.ContainsAny(login.Username, "\n\x00") {
+		return errors.New("username of " + entry + " can't be passed to git")
+	}
+
+	buf, err := NewSecureBytes(32 + len(login.Username) + login.Password.Len())
+	if err != nil {
+		return err
+	}
+	defer buf.Wipe()
+	if login.Username != "" {
+		buf.WriteString("username=" + login.Username + "\n")
+	}
+	buf.WriteString("password=")
+	buf.Write(login.Password.Bytes())
+	buf.WriteString("\n")
+	_, err = stdout.Write(buf.Bytes())
+	return err
+}
+
+// pickGitEntry chooses the entry for a git request: the one named after the
+// requested username, else the one named after the first segment of the
+// repository path, else the first.
+func pickGitEntry(list []string, username, path string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	want := username
+	if want == "" {
+		want, _, _ = strings.Cut(path, "/")
+	}
+	for _, entry := range list {
+		if want != "" && guessUsername(entry) == want {
+			return entry
+		}
+	}
+	if username != "" {
+		return ""
+	}
+	return list[0]
+}
@@ -0,0 +1,32 @@
+package browserpass
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGitCredential(t *testing.T) {
+	s := fakeStore{"github.com/alice", "github.com/bob"}
+	tests := []struct {
+		operation string
+		request   string
+		expected  string
+	}{
+		{"get", "protocol=https\nhost=github.com\n\n", "username=alice\npassword=password-of-github.com/alice\n"},
+		{"get", "protocol=https\nhost=github.com\npath=bob/repo.git\n", "username=bob\npassword=password-of-github.com/bob\n"},
+		{"get", "protocol=https\nhost=github.com:443\nusername=bob\n", "username=bob\npassword=password-of-github.com/bob\n"},
+		{"get", "protocol=https\nhost=github.com\nusername=carol\n", ""},
+		{"get", "protocol=https\nhost=gitlab.com\n", ""},
+		{"store", "protocol=https\nhost=github.com\nusername=alice\npassword=new\n", ""},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		if err := GitCredential(strings.NewReader(test.request), &out, s, test.operation); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.expected {
+			t.Errorf("%s %q: expected %q, got %q", test.operation, test.request, test.expected, out.String())
+		}
+	}
+}
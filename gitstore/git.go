@@ -0,0 +1,59 @@
+package gitstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// isRepo reports whether dir is the root of a git repository, as set up for
+// a store by pass git init.
+func isRepo(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// repoLocks holds a *sync.Mutex per repository, see lockRepo.
+var repoLocks sync.Map
+
+// lockRepo locks the repository at dir against the commits and pushes of
+// concurrent requests, which would otherwise race for git's index.lock and
+// refs, and returns the function unlocking it.
+func lockRepo(dir string) func() {
+	mu, _ := repoLocks.LoadOrStore(dir, new(sync.Mutex))
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// commit commits the changes to files, given relative to the repository at
+// dir, with message.
+func commit(dir, message string, files ...string) error {
+	ctx := context.Background()
+	if err := git(ctx, dir, append([]string{"add", "--all", "--"}, files...)...); err != nil {
+		return err
+	}
+	return git(ctx, dir, append([]string{"commit", "--quiet", "--message", message, "--"}, files...)...)
+}
+
+// git runs git with args in the repository at dir. Paths are taken
+// literally, so entries named like "*" can't stand for other files.
+func git(ctx context.Context, dir string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"--literal-pathspecs", "-C", dir}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %v\n%s", args[0], err, out)
+	}
+	return nil
+}
+
+// head returns the commit checked out in the repository at dir.
+func head(ctx context.Context, dir string) (string, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse: %v", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,136 @@
+// Package gitstore commits the changes made through a password store to the
+// git repository it is kept in, with the commit messages pass uses.
+package gitstore
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// pushTimeout is how long a push may take before it is given up, so an
+// unreachable remote doesn't hold up the change.
+const pushTimeout = 30 * time.Second
+
+// Store is a pass.Store committing every change made through it.
+type Store struct {
+	pass.Store
+	// Dir is the root of the store and its repository
+	Dir string
+	// Push pushes each commit to the repository's upstream
+	Push bool
+}
+
+// Wrap returns s, kept in dir, committing its changes if dir is a git
+// repository, and s itself otherwise.
+func Wrap(s pass.Store, dir string, push bool) pass.Store {
+	if !isRepo(dir) {
+		return s
+	}
+	return &Store{Store: s, Dir: dir, Push: push}
+}
+
+func (s *Store) Create(item string, content []byte) error {
+	if err := s.Store.Create(item, content); err != nil {
+		return err
+	}
+	s.commit("Add given password for "+item+" to store.", item+".gpg")
+	return nil
+}
+
+// Update implements pass.Updater if the wrapped store does.
+func (s *Store) Update(item string, content []byte) error {
+	u, ok := s.Store.(pass.Updater)
+	if !ok {
+		return pass.ErrReadOnly
+	}
+	if err := u.Update(item, content); err != nil {
+		return err
+	}
+	s.commit("Edit password for "+item+" using browserpass.", item+".gpg")
+	return nil
+}
+
+func (s *Store) Delete(item string) error {
+	if err := s.Store.Delete(item); err != nil {
+		return err
+	}
+	s.commit("Remove "+item+" from store.", item+".gpg")
+	return nil
+}
+
+// Move implements pass.Mover if the wrapped store does. Both sides of the
+// rename are committed together, so git records it as git mv would.
+func (s *Store) Move(src, dst string) error {
+	from, to := strings.TrimSuffix(src, "/"), strings.TrimSuffix(dst, "/")
+	message := "Rename " + from + " to " + to + "."
+	if !pass.IsFolder(src) {
+		if _, err := os.Stat(filepath.Join(s.Dir, filepath.FromSlash(from)+".gpg")); err == nil {
+			from, to = from+".gpg", to+".gpg"
+		}
+	}
+	if err := pass.Move(s.Store, src, dst); err != nil {
+		return err
+	}
+	s.commit(message, from, to)
+	return nil
+}
+
+// Diagnose implements pass.Diagnoser if the wrapped store does.
+func (s *Store) Diagnose(ctx context.Context) ([]pass.Problem, error) {
+	return pass.Diagnose(ctx, s.Store)
+}
+
+// Stats implements pass.Statter, with the commit checked out in the
+// repository as Head.
+func (s *Store) Stats(ctx context.Context) (pass.Stats, error) {
+	stats, err := pass.FileStats(ctx, s.Store)
+	if err != nil {
+		return pass.Stats{}, err
+	}
+	// A repository without commits has no head yet
+	stats.Head, _ = head(ctx, s.Dir)
+	return stats, nil
+}
+
+// Warnings implements pass.Checker if the wrapped store does.
+func (s *Store) Warnings() ([]string, error) {
+	if c, ok := s.Store.(pass.Checker); ok {
+		return c.Warnings()
+	}
+	return nil, nil
+}
+
+// StoreKeys implements pass.Keyed if the wrapped store does.
+func (s *Store) StoreKeys() ([]string, error) {
+	return pass.KeysOf(s.Store)
+}
+
+// StoreSettings implements pass.Configured with the wrapped store's settings.
+func (s *Store) StoreSettings() (*pass.Settings, error) {
+	return pass.SettingsOf(s.Store)
+}
+
+// commit commits the change to files. The change is saved either way, so
+// failures to commit or push are only logged, as is a push taking longer
+// than pushTimeout. Failing the request would have the extension report the
+// change as lost and try it again.
+func (s *Store) commit(message string, files ...string) {
+	defer lockRepo(s.Dir)()
+	if err := commit(s.Dir, message, files...); err != nil {
+		log.Println("saved but not committed:", err)
+		return
+	}
+	if s.Push {
+		ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+		defer cancel()
+		if err := git(ctx, s.Dir, "push", "--quiet"); err != nil {
+			log.Println(err)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package gitstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/fixture"
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// newRepo returns a store kept in a new git repository, pushing to a bare
+// repository, which is returned too.
+func newRepo(t *testing.T) (pass.Store, string, string) {
+	for _, tool := range []string{"git", "gpg"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skip(tool + " is not installed")
+		}
+	}
+	for _, v := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(v, "browserpass")
+	}
+	for _, v := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(v, "browserpass@example.invalid")
+	}
+	home := t.TempDir()
+	if err := fixture.NewKey(home); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GNUPGHOME", home)
+
+	remote := t.TempDir()
+	if err := git(context.Background(), remote, "init", "--quiet", "--bare"); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".gpg-id"), []byte(fixture.KeyID+"\n"), 0600)
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "origin", remote},
+		{"add", ".gpg-id"},
+		{"commit", "--quiet", "--message", "Add current contents of password store."},
+		{"push", "--quiet", "--set-upstream", "origin", "HEAD"},
+	} {
+		if err := git(context.Background(), dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := pass.NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Wrap(s, dir, true), dir, remote
+}
+
+// lastCommit returns the subject and changed files of the last commit in the
+// repository at dir.
+func lastCommit(t *testing.T, dir string) string {
+	out, err := exec.Command("git", "-C", dir, "log", "-1", "--format=%s", "--name-status").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestStore(t *testing.T) {
+	s, dir, remote := newRepo(t)
+
+	if err := s.Create("example.com/alice", []byte("hunter2\n")); err != nil {
+		t.Fatal(err)
+	}
+	if commit := lastCommit(t, dir); commit != "Add given password for example.com/alice to store.\n\nA\texample.com/alice.gpg" {
+		t.Errorf("Unexpected commit %q", commit)
+	}
+	if err := s.(pass.Updater).Update("example.com/alice", []byte("hunter3\n")); err != nil {
+		t.Fatal(err)
+	}
+	if commit := lastCommit(t, dir); commit != "Edit password for example.com/alice using browserpass.\n\nM\texample.com/alice.gpg" {
+		t.Errorf("Unexpected commit %q", commit)
+	}
+	if err := s.Delete("example.com/alice"); err != nil {
+		t.Fatal(err)
+	}
+	if commit := lastCommit(t, dir); commit != "Remove example.com/alice from store.\n\nD\texample.com/alice.gpg" {
+		t.Errorf("Unexpected commit %q", commit)
+	}
+
+	if out, _ := exec.Command("git", "-C", dir, "status", "--porcelain").Output(); len(out) != 0 {
+		t.Errorf("Repository left dirty: %s", out)
+	}
+	if local, pushed := lastCommit(t, dir), lastCommit(t, remote); local != pushed {
+		t.Errorf("Remote is at %q, expected %q", pushed, local)
+	}
+}
+
+func TestWrapNoRepo(t *testing.T) {
+	dir := t.TempDir()
+	s, err := pass.NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Wrap(s, dir, false) != s {
+		t.Error("Wrapped a store outside git")
+	}
+}
+
+func TestStats(t *testing.T) {
+	s, dir, _ := newRepo(t)
+	if err := s.Create("example.com/alice", []byte("hunter2\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := pass.StatsOf(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 1 || stats.Domains != 1 || stats.Size == 0 || stats.Head != strings.TrimSpace(string(out)) {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestMove(t *testing.T) {
+	s, dir, _ := newRepo(t)
+	for _, item := range []string{"example.com/alice", "example.com/bob"} {
+		if err := s.Create(item, []byte("hunter2\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := pass.Move(s, "example.com/alice", "example.org/alice"); err != nil {
+		t.Fatal(err)
+	}
+	if commit := lastCommit(t, dir); commit != "Rename example.com/alice to example.org/alice.\n\nR100\texample.com/alice.gpg\texample.org/alice.gpg" {
+		t.Errorf("Unexpected commit %q", commit)
+	}
+	if err := pass.Move(s, "example.com/", "example.net/"); err != nil {
+		t.Fatal(err)
+	}
+	if commit := lastCommit(t, dir); commit != "Rename example.com to example.net.\n\nR100\texample.com/bob.gpg\texample.net/bob.gpg" {
+		t.Errorf("Unexpected commit %q", commit)
+	}
+	if out, _ := exec.Command("git", "-C", dir, "status", "--porcelain").Output(); len(out) != 0 {
+		t.Errorf("Repository left dirty: %s", out)
+	}
+}
+
+func TestStoreLiteralPaths(t *testing.T) {
+	s, dir, _ := newRepo(t)
+	if err := s.Create("team*/alice", []byte("hunter2\n")); err != nil {
+		t.Fatal(err)
+	}
+	// Once moved, the folder's name as a pattern would match other files
+	os.MkdirAll(filepath.Join(dir, "teams"), 0700)
+	os.WriteFile(filepath.Join(dir, "teams", "bob.gpg"), nil, 0600)
+	if err := pass.Move(s, "team*/", "work/"); err != nil {
+		t.Fatal(err)
+	}
+	if commit := lastCommit(t, dir); commit != "Rename team* to work.\n\nR100\tteam*/alice.gpg\twork/alice.gpg" {
+		t.Errorf("Unexpected commit %q", commit)
+	}
+}
+
+func TestStoreConcurrent(t *testing.T) {
+	s, dir, _ := newRepo(t)
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- s.Create(fmt.Sprintf("example.com/user%d", i), []byte("hunter2\n"))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if out, _ := exec.Command("git", "-C", dir, "status", "--porcelain").Output(); len(out) != 0 {
+		t.Errorf("Repository left dirty: %s", out)
+	}
+}
+
+func TestStoreCommitFails(t *testing.T) {
+	s, dir, _ := newRepo(t)
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	// Another git holding the index keeps the change from being committed
+	os.WriteFile(filepath.Join(dir, ".git", "index.lock"), nil, 0600)
+	if err := s.Create("example.com/alice", []byte("hunter2\n")); err != nil {
+		t.Errorf("Create failed although the entry was saved: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "example.com", "alice.gpg")); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(logged.String(), "saved but not committed") {
+		t.Errorf("Failed commit wasn't logged: %q", logged.String())
+	}
+}
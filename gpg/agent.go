@@ -0,0 +1,133 @@
+package gpg
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Causes of gpg failures told from its status and error output, see Error.
+var (
+	ErrNoAgent       = errors.New("gpg: gpg-agent can't be reached")
+	ErrNoPinentry    = errors.New("gpg: no pinentry can ask for the passphrase")
+	ErrCanceled      = errors.New("gpg: passphrase entry was canceled")
+	ErrBadPassphrase = errors.New("gpg: bad passphrase")
+	ErrKeyExpired    = errors.New("gpg: key expired")
+	ErrNoSecretKey   = errors.New("gpg: no secret key for the entry")
+)
+
+// causes are the status keywords, the codes of ERROR and FAILURE status
+// lines (see gpg-error.h) and the messages telling each cause, checked in
+// order: a failing or canceled pinentry also makes gpg say it has no secret
+// key.
+var causes = []struct {
+	err      error
+	keywords []string
+	codes    []int
+	messages []string
+}{
+	{ErrNoAgent, nil, nil, []string{"can't connect to the agent", "no gpg-agent running", "No agent running"}},
+	{ErrNoPinentry, nil, []int{85}, []string{"No pinentry", "Inappropriate ioctl for device", "pinentry launched", "cannot open display", "Cannot open display"}},
+	{ErrCanceled, nil, []int{99}, []string{"Operation cancelled"}},
+	{ErrBadPassphrase, []string{"BAD_PASSPHRASE"}, []int{11}, []string{"Bad passphrase"}},
+	{ErrKeyExpired, []string{"KEYEXPIRED"}, []int{153}, nil},
+	{ErrNoSecretKey, []string{"NO_SECKEY"}, []int{17}, []string{"No secret key"}},
+}
+
+// Error is a failure of gpg.
+type Error struct {
+	Err    error
+	Stderr string
+	// Status are the status lines of gpg run with --status-fd, without
+	// their "[GNUPG:] " prefix
+	Status []string
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error() + "\n" + e.Stderr
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the output of gpg tells it failed for target, one of
+// the causes like ErrBadPassphrase.
+func (e *Error) Is(target error) bool {
+	for _, cause := range causes {
+		if e.shows(cause.keywords, cause.codes, cause.messages) {
+			return cause.err == target
+		}
+	}
+	return false
+}
+
+// shows reports whether the output of gpg has one of keywords, codes or
+// messages.
+func (e *Error) shows(keywords []string, codes []int, messages []string) bool {
+	for _, line := range e.Status {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		for _, keyword := range keywords {
+			if fields[0] == keyword {
+				return true
+			}
+		}
+		// ERROR <location> <code>, FAILURE <location> <code>
+		if (fields[0] == "ERROR" || fields[0] == "FAILURE") && len(fields) > 2 {
+			code, err := strconv.Atoi(fields[2])
+			for _, c := range codes {
+				if err == nil && code&0xffff == c {
+					return true
+				}
+			}
+		}
+	}
+	for _, message := range messages {
+		if strings.Contains(e.Stderr, message) {
+			return true
+		}
+	}
+	return false
+}
+
+// agentReached is set once ProbeAgent reached gpg-agent.
+var agentReached atomic.Bool
+
+// ProbeAgent returns ErrNoAgent if gpg-agent can't be reached, starting it
+// if needed like gpg would. Browsers start the host with an environment of
+// their own, which may lack what the agent needs. Once reached, the agent
+// isn't probed again. Without gpg-connect-agent, as with gpg1, nothing is
+// probed.
+func ProbeAgent(ctx context.Context) error {
+	if agentReached.Load() {
+		return nil
+	}
+	connect := "gpg-connect-agent"
+	if filepath.IsAbs(Binary) {
+		connect = filepath.Join(filepath.Dir(Binary), connect)
+	}
+	path, err := exec.LookPath(connect)
+	if err != nil {
+		return nil
+	}
+	var args []string
+	if HomeDir != "" {
+		args = append(args, "--homedir", HomeDir)
+	}
+	cmd := exec.CommandContext(ctx, path, append(args, "/bye")...)
+	if err := run(cmd, nil); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &Error{Err: ErrNoAgent, Stderr: err.Error()}
+	}
+	agentReached.Store(true)
+	return nil
+}
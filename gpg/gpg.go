@@ -0,0 +1,349 @@
+// Package gpg decrypts and encrypts password store entries with the system's
+// GnuPG.
+package gpg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// children are the gpg processes currently running.
+var children = struct {
+	sync.Mutex
+	m map[*os.Process]struct{}
+}{m: make(map[*os.Process]struct{})}
+
+// KillAll kills the gpg processes started by this package that are still
+// running.
+func KillAll() {
+	children.Lock()
+	defer children.Unlock()
+	for p := range children.m {
+		p.Kill()
+	}
+}
+
+// Binary is the gpg program to run. If empty, gpg2 is preferred, falling
+// back to gpg.
+var Binary string
+
+// HomeDir is the GnuPG home directory gpg is run with, GNUPGHOME or
+// ~/.gnupg if empty.
+var HomeDir string
+
+// ExtraArgs are options added to every run of gpg, such as
+// "--pinentry-mode=loopback".
+var ExtraArgs []string
+
+// Command returns a command running gpg with args on its stdin.
+func Command(args ...string) *exec.Cmd {
+	return CommandContext(context.Background(), args...)
+}
+
+// CommandContext is like Command, but gpg is killed once ctx is done.
+func CommandContext(ctx context.Context, args ...string) *exec.Cmd {
+	// Tell gpg to read from stdin
+	return command(ctx, append(args, "-")...)
+}
+
+// command returns a command running gpg with args.
+func command(ctx context.Context, args ...string) *exec.Cmd {
+	// Assume gpg1
+	gpgbin := "gpg"
+
+	// Check if gpg2 is available. A configured gpg is taken to be one too.
+	if Binary != "" {
+		gpgbin = Binary
+		args = append([]string{"--use-agent", "--batch"}, args...)
+	} else if _, err := exec.LookPath("gpg2"); err == nil {
+		gpgbin = "gpg2"
+		args = append([]string{"--use-agent", "--batch"}, args...)
+	}
+
+	cmd := exec.CommandContext(ctx, gpgbin, append(options(), args...)...)
+
+	// Browsers start the host without a terminal, so the agent falls back to
+	// a graphical pinentry. From a terminal, point pinentry at it.
+	if os.Getenv("GPG_TTY") == "" {
+		if tty := stdinTTY(); tty != "" {
+			cmd.Env = append(os.Environ(), "GPG_TTY="+tty)
+		}
+	}
+	return cmd
+}
+
+// options returns the options of gpg set by HomeDir and ExtraArgs.
+func options() []string {
+	var opts []string
+	if HomeDir != "" {
+		opts = append(opts, "--homedir", HomeDir)
+	}
+	return append(opts, ExtraArgs...)
+}
+
+// stdinTTY returns the terminal on stdin, or "" if stdin isn't one or it
+// can't be told.
+func stdinTTY() string {
+	tty, err := os.Readlink("/proc/self/fd/0")
+	if err != nil || !(strings.HasPrefix(tty, "/dev/pts/") || strings.HasPrefix(tty, "/dev/tty")) {
+		return ""
+	}
+	return tty
+}
+
+// ErrNoKey is returned by Keys for names without a key in the keyring.
+var ErrNoKey = errors.New("gpg: no key in the keyring")
+
+// Recipients returns the long IDs of the keys src is encrypted to, read
+// without decrypting it.
+func Recipients(ctx context.Context, src io.Reader) ([]string, error) {
+	cmd := CommandContext(ctx, "--list-only", "--list-packets")
+	cmd.Stdin = src
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := run(cmd, nil); err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if !strings.HasPrefix(line, ":pubkey enc packet:") {
+			continue
+		}
+		if _, id, ok := strings.Cut(line, "keyid "); ok {
+			ids = append(ids, strings.ToUpper(strings.TrimSpace(id)))
+		}
+	}
+	return ids, nil
+}
+
+// Keys returns the long IDs of the public keys and subkeys the keyring has
+// for name, a user ID or key ID as in a .gpg-id file.
+func Keys(ctx context.Context, name string) ([]string, error) {
+	cmd := command(ctx, "--with-colons", "--list-keys", "--", name)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	err := run(cmd, nil)
+	var ids []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if fields := strings.Split(line, ":"); len(fields) > 4 && (fields[0] == "pub" || fields[0] == "sub") {
+			ids = append(ids, strings.ToUpper(fields[4]))
+		}
+	}
+	if len(ids) == 0 && cmd.ProcessState != nil {
+		// gpg ran and found nothing
+		return nil, ErrNoKey
+	}
+	return ids, err
+}
+
+// run runs cmd, killing it with KillAll if needed. Failures of gpg are
+// returned as an *Error with its error output.
+func run(cmd *exec.Cmd, output func() error) error {
+	stderr, ok := cmd.Stderr.(*statusWriter)
+	if !ok {
+		stderr = new(statusWriter)
+		cmd.Stderr = stderr
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	children.Lock()
+	children.m[cmd.Process] = struct{}{}
+	children.Unlock()
+	defer func() {
+		children.Lock()
+		delete(children.m, cmd.Process)
+		children.Unlock()
+	}()
+
+	if output != nil {
+		if err := output(); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return err
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		return &Error{Err: err, Stderr: stderr.String(), Status: stderr.status}
+	}
+	return nil
+}
+
+// DecryptTo writes the plaintext of src to dst. Destinations implementing
+// io.ReaderFrom read the plaintext themselves, so it never passes through an
+// intermediate buffer. gpg is killed if ctx is done first, waiting for a
+// passphrase for instance. Contexts from WithEvents are told what gpg waits
+// for meanwhile.
+func DecryptTo(ctx context.Context, dst io.Writer, src io.Reader) error {
+	_, err := decryptTo(ctx, dst, src)
+	return err
+}
+
+// decryptTo is DecryptTo returning the status lines of gpg.
+func decryptTo(ctx context.Context, dst io.Writer, src io.Reader) ([]string, error) {
+	cmd := CommandContext(ctx, "--status-fd", "2", "--decrypt", "--yes", "--quiet")
+	cmd.Stdin = src
+	stderr := new(statusWriter)
+	cmd.Stderr = stderr
+	if notify, ok := ctx.Value(eventsKey{}).(func(Event)); ok {
+		watcher := &touchWatcher{notify: notify, onCard: func(keyID string) bool { return keyOnCard(ctx, keyID) }}
+		defer watcher.stop()
+		stderr.handle = watcher.handle
+	}
+	rc, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = run(cmd, func() error {
+		if rf, ok := dst.(io.ReaderFrom); ok {
+			_, err := rf.ReadFrom(rc)
+			return err
+		}
+		_, err := io.Copy(dst, rc)
+		return err
+	})
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return stderr.status, err
+}
+
+// ErrBadSignature is returned by DecryptSignedTo for data that isn't signed
+// by one of the expected keys.
+var ErrBadSignature = errors.New("gpg: not signed by a trusted key")
+
+// DecryptSignedTo is DecryptTo for data signed by one of signers, user IDs
+// or key IDs as in a .gpg-id file, failing with ErrBadSignature otherwise.
+// Anyone can encrypt to a public key, only the signature tells who wrote
+// the data. dst gets the plaintext either way, callers must discard it on
+// errors.
+func DecryptSignedTo(ctx context.Context, dst io.Writer, src io.Reader, signers ...string) error {
+	status, err := decryptTo(ctx, dst, src)
+	if err != nil {
+		return err
+	}
+	var good bool
+	var fingerprints []string
+	for _, line := range status {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "GOODSIG":
+			good = true
+		case "BADSIG", "ERRSIG", "EXPSIG", "EXPKEYSIG", "REVKEYSIG":
+			return ErrBadSignature
+		case "VALIDSIG":
+			// The fingerprints of the signing key and of its primary key
+			fingerprints = append(fingerprints, fields[1])
+			if len(fields) > 10 {
+				fingerprints = append(fingerprints, fields[10])
+			}
+		}
+	}
+	if !good {
+		return ErrBadSignature
+	}
+	for _, signer := range signers {
+		ids, err := Keys(ctx, signer)
+		if err == ErrNoKey {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			for _, fpr := range fingerprints {
+				if strings.HasSuffix(strings.ToUpper(fpr), id) {
+					return nil
+				}
+			}
+		}
+	}
+	return ErrBadSignature
+}
+
+// Decrypt returns the plaintext of r. The plaintext is in ordinary memory;
+// use DecryptTo to decrypt into locked memory.
+func Decrypt(r io.Reader) ([]byte, error) {
+	var plaintext bytes.Buffer
+	if err := DecryptTo(context.Background(), &plaintext, r); err != nil {
+		return nil, err
+	}
+	return plaintext.Bytes(), nil
+}
+
+// EncryptToSelf writes src to dst encrypted to the user's default key, for
+// files only they read.
+func EncryptToSelf(dst io.Writer, src io.Reader) error {
+	cmd := Command("--status-fd", "2", "--encrypt", "--default-recipient-self")
+	cmd.Stdin = src
+	cmd.Stdout = dst
+	return run(cmd, nil)
+}
+
+// ErrNoSigner is returned by SignEncrypt when the keyring has the secret key
+// of none of the recipients.
+var ErrNoSigner = errors.New("gpg: no secret key of the recipients to sign with")
+
+// SignEncrypt writes src to dst encrypted to recipients, user IDs or key IDs
+// as in a .gpg-id file, and signed with the first of them the keyring has
+// the secret key of, for files DecryptSignedTo must tell were written by
+// one of them.
+func SignEncrypt(ctx context.Context, dst io.Writer, src io.Reader, recipients ...string) error {
+	if len(recipients) == 0 {
+		return errors.New("gpg: no recipients")
+	}
+	signer := ""
+	for _, r := range recipients {
+		if hasSecretKey(ctx, r) {
+			signer = r
+			break
+		}
+	}
+	if signer == "" {
+		return ErrNoSigner
+	}
+	args := []string{"--status-fd", "2", "--sign", "--local-user", signer, "--encrypt"}
+	for _, r := range recipients {
+		args = append(args, "--recipient", r)
+	}
+	cmd := CommandContext(ctx, args...)
+	cmd.Stdin = src
+	cmd.Stdout = dst
+	return run(cmd, nil)
+}
+
+// hasSecretKey reports whether the keyring has a secret key for name.
+func hasSecretKey(ctx context.Context, name string) bool {
+	cmd := command(ctx, "--with-colons", "--list-secret-keys", "--", name)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if run(cmd, nil) != nil {
+		return false
+	}
+	return strings.HasPrefix(out.String(), "sec:") || strings.Contains(out.String(), "\nsec:")
+}
+
+// Encrypt writes src to dst encrypted to recipients, as ASCII armor.
+// Recipients' keys must be in the keyring and trusted.
+func Encrypt(dst io.Writer, src io.Reader, recipients ...string) error {
+	if len(recipients) == 0 {
+		return errors.New("gpg: no recipients")
+	}
+	args := []string{"--status-fd", "2", "--encrypt", "--armor"}
+	for _, r := range recipients {
+		args = append(args, "--recipient", r)
+	}
+	cmd := Command(args...)
+	cmd.Stdin = src
+	cmd.Stdout = dst
+	return run(cmd, nil)
+}
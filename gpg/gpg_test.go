@@ -0,0 +1,195 @@
+package gpg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dannyvankooten/browserpass/fixture"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not installed")
+	}
+	home, err := ioutil.TempDir("", "browserpass-gpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	if err := fixture.NewKey(home); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GNUPGHOME", home)
+
+	var armored bytes.Buffer
+	if err := Encrypt(&armored, strings.NewReader("hunter2\nlogin: alice\n"), fixture.KeyID); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(armored.String(), "-----BEGIN PGP MESSAGE-----") {
+		t.Fatalf("Output is not ASCII armored: %q", armored.String())
+	}
+
+	plaintext, err := Decrypt(&armored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "hunter2\nlogin: alice\n" {
+		t.Errorf("Decrypted %q", plaintext)
+	}
+
+	if _, err := Decrypt(strings.NewReader("not encrypted")); err == nil {
+		t.Error("Decrypted garbage")
+	}
+	if err := Encrypt(ioutil.Discard, strings.NewReader("x"), "nobody@example.invalid"); err == nil {
+		t.Error("Encrypted to a recipient without a key")
+	}
+}
+
+func TestRecipientsKeys(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not installed")
+	}
+	home := t.TempDir()
+	if err := fixture.NewKey(home); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GNUPGHOME", home)
+	ctx := context.Background()
+
+	keys, err := Keys(ctx, fixture.KeyID)
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("Keys returned %v, %v; expected a key and its subkey", keys, err)
+	}
+	if _, err := Keys(ctx, "nobody@example.invalid"); err != ErrNoKey {
+		t.Errorf("Keys of a missing key returned %v", err)
+	}
+
+	var armored bytes.Buffer
+	if err := Encrypt(&armored, strings.NewReader("hunter2\n"), fixture.KeyID); err != nil {
+		t.Fatal(err)
+	}
+	recipients, err := Recipients(ctx, &armored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recipients) != 1 || recipients[0] != keys[1] {
+		t.Errorf("Recipients returned %v, expected the subkey of %v", recipients, keys)
+	}
+}
+
+func TestDecryptSigned(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not installed")
+	}
+	home := t.TempDir()
+	if err := fixture.NewKey(home); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GNUPGHOME", home)
+	ctx := context.Background()
+
+	var signed, unsigned bytes.Buffer
+	if err := SignEncrypt(ctx, &signed, strings.NewReader("index"), fixture.KeyID); err != nil {
+		t.Fatal(err)
+	}
+	if err := EncryptToSelf(&unsigned, strings.NewReader("forged")); err != nil {
+		t.Fatal(err)
+	}
+
+	var plaintext bytes.Buffer
+	if err := DecryptSignedTo(ctx, &plaintext, bytes.NewReader(signed.Bytes()), fixture.KeyID); err != nil || plaintext.String() != "index" {
+		t.Errorf("DecryptSignedTo returned %q, %v", plaintext.String(), err)
+	}
+	if err := SignEncrypt(ctx, ioutil.Discard, strings.NewReader("index"), "nobody@example.invalid"); err != ErrNoSigner {
+		t.Errorf("SignEncrypt without a secret key returned %v", err)
+	}
+	if err := DecryptSignedTo(ctx, ioutil.Discard, bytes.NewReader(signed.Bytes()), "nobody@example.invalid"); err != ErrBadSignature {
+		t.Errorf("DecryptSignedTo for another signer returned %v", err)
+	}
+	if err := DecryptSignedTo(ctx, ioutil.Discard, &unsigned, fixture.KeyID); err != ErrBadSignature {
+		t.Errorf("DecryptSignedTo of unsigned data returned %v", err)
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	tests := []struct {
+		stderr   string
+		status   []string
+		expected error
+	}{
+		{"gpg: public key decryption failed: Inappropriate ioctl for device\ngpg: decryption failed: No secret key\n", []string{"NO_SECKEY 0123456789ABCDEF"}, ErrNoPinentry},
+		{"gpg: decryption failed: No secret key\n", nil, ErrNoSecretKey},
+		{"gpg: can't connect to the agent: IPC connect call failed\n", nil, ErrNoAgent},
+		{"", []string{"ERROR pkdecrypt_failed 83886179", "NO_SECKEY 0123456789ABCDEF"}, ErrCanceled},
+		{"gpg: public key decryption failed: Bad passphrase\n", []string{"ENC_TO 0123456789ABCDEF 1 0", "ERROR pkdecrypt_failed 67108875", "DECRYPTION_FAILED"}, ErrBadPassphrase},
+		{"", []string{"BAD_PASSPHRASE 0123456789ABCDEF"}, ErrBadPassphrase},
+		{"", []string{"KEYEXPIRED 1500000000"}, ErrKeyExpired},
+		{"gpg: no valid OpenPGP data found.\n", []string{"NODATA 1"}, nil},
+	}
+	causes := []error{ErrNoAgent, ErrNoPinentry, ErrCanceled, ErrBadPassphrase, ErrKeyExpired, ErrNoSecretKey}
+	for _, test := range tests {
+		err := &Error{Err: errors.New("exit status 2"), Stderr: test.stderr, Status: test.status}
+		for _, cause := range causes {
+			if errors.Is(err, cause) != (cause == test.expected) {
+				t.Errorf("%q %q: errors.Is(%v) is %v", test.stderr, test.status, cause, cause != test.expected)
+			}
+		}
+	}
+}
+
+func TestCommandOptions(t *testing.T) {
+	Binary, HomeDir, ExtraArgs = "/opt/gnupg/bin/gpg", "/home/alice/.gnupg-browser", []string{"--pinentry-mode=loopback"}
+	defer func() { Binary, HomeDir, ExtraArgs = "", "", nil }()
+	cmd := Command("--decrypt")
+	expected := []string{"/opt/gnupg/bin/gpg", "--homedir", "/home/alice/.gnupg-browser", "--pinentry-mode=loopback", "--use-agent", "--batch", "--decrypt", "-"}
+	if !reflect.DeepEqual(cmd.Args, expected) {
+		t.Errorf("Command runs %q, expected %q", cmd.Args, expected)
+	}
+}
+
+func TestTouchWatcher(t *testing.T) {
+	defer func(delay time.Duration) { TouchDelay = delay }(TouchDelay)
+	TouchDelay = 10 * time.Millisecond
+	tests := map[string][]Event{
+		"[GNUPG:] ENC_TO 0123456789ABCDEF 18 0\n":                                   {EventTouch},
+		"[GNUPG:] ENC_TO FEDCBA9876543210 18 0\n":                                   nil,
+		"[GNUPG:] ENC_TO 0123456789ABCDEF 18 0\n[GNUPG:] BEGIN_DECRYPTION\n":        nil,
+		"[GNUPG:] PINENTRY_LAUNCHED 1234 gnome3 1.2.1 - - - -\ngpg: some message\n": {EventPassphrase},
+	}
+	for status, expected := range tests {
+		var mu sync.Mutex
+		var events []Event
+		watcher := &touchWatcher{
+			notify: func(e Event) {
+				mu.Lock()
+				events = append(events, e)
+				mu.Unlock()
+			},
+			onCard: func(keyID string) bool { return keyID == "0123456789ABCDEF" },
+		}
+		w := &statusWriter{handle: watcher.handle}
+		// Lines may come in pieces
+		for _, part := range strings.SplitAfter(status, " ") {
+			w.Write([]byte(part))
+		}
+		time.Sleep(5 * TouchDelay)
+		watcher.stop()
+		mu.Lock()
+		if !reflect.DeepEqual(events, expected) {
+			t.Errorf("%q: got events %v, expected %v", status, events, expected)
+		}
+		mu.Unlock()
+		if text := w.String(); strings.Contains(text, "[GNUPG:]") {
+			t.Errorf("%q: status lines kept in %q", status, text)
+		}
+	}
+}
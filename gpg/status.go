@@ -0,0 +1,145 @@
+package gpg
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is something gpg waits for while decrypting, which the user may
+// have to act on.
+type Event string
+
+// Events of DecryptTo.
+const (
+	// EventPassphrase is sent once pinentry asks for a passphrase or PIN
+	EventPassphrase Event = "waiting_for_passphrase"
+	// EventTouch is sent when decryption with a key on a smartcard, such
+	// as a YubiKey, doesn't go on within TouchDelay: the card likely waits
+	// to be touched
+	EventTouch Event = "waiting_for_touch"
+)
+
+// TouchDelay is how long a smartcard may take before EventTouch is sent.
+var TouchDelay = 500 * time.Millisecond
+
+type eventsKey struct{}
+
+// WithEvents returns a context making DecryptTo call notify with the events
+// of decryptions run with it. notify may be called from other goroutines.
+func WithEvents(ctx context.Context, notify func(Event)) context.Context {
+	return context.WithValue(ctx, eventsKey{}, notify)
+}
+
+// statusWriter receives the error output of gpg run with --status-fd 2,
+// keeping status lines apart from the rest and passing them to handle.
+type statusWriter struct {
+	text    bytes.Buffer
+	status  []string
+	partial []byte
+	handle  func(keyword string, args []string)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := string(w.partial[:i+1])
+		w.partial = w.partial[i+1:]
+		if !strings.HasPrefix(line, "[GNUPG:] ") {
+			w.text.WriteString(line)
+			continue
+		}
+		status := strings.TrimSpace(line[len("[GNUPG:] "):])
+		w.status = append(w.status, status)
+		fields := strings.Fields(status)
+		if len(fields) > 0 && w.handle != nil {
+			w.handle(fields[0], fields[1:])
+		}
+	}
+}
+
+// String returns the error output of gpg but status lines.
+func (w *statusWriter) String() string {
+	return w.text.String() + string(w.partial)
+}
+
+// touchWatcher turns the status lines of a decryption into events.
+type touchWatcher struct {
+	mu     sync.Mutex
+	notify func(Event)
+	onCard func(keyID string) bool
+	timer  *time.Timer
+	done   bool
+}
+
+func (t *touchWatcher) handle(keyword string, args []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch keyword {
+	case "PINENTRY_LAUNCHED":
+		t.notify(EventPassphrase)
+	case "ENC_TO":
+		if t.timer == nil && !t.done && len(args) > 0 && t.onCard(args[0]) {
+			t.timer = time.AfterFunc(TouchDelay, t.fire)
+		}
+	case "BEGIN_DECRYPTION", "DECRYPTION_OKAY", "DECRYPTION_FAILED", "ERROR":
+		t.stopLocked()
+	}
+}
+
+// fire sends EventTouch unless the decryption went on meanwhile.
+func (t *touchWatcher) fire() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.done {
+		t.notify(EventTouch)
+	}
+}
+
+// stop ends the watch once gpg is done.
+func (t *touchWatcher) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopLocked()
+}
+
+func (t *touchWatcher) stopLocked() {
+	t.done = true
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
+
+// cardKeys are the IDs of the secret keys kept on smartcards, read once.
+var cardKeys struct {
+	sync.Mutex
+	ids map[string]bool
+}
+
+// keyOnCard reports whether the secret key keyID is on a smartcard,
+// according to the token serial numbers gpg lists for secret keys.
+func keyOnCard(ctx context.Context, keyID string) bool {
+	cardKeys.Lock()
+	defer cardKeys.Unlock()
+	if cardKeys.ids == nil {
+		cardKeys.ids = make(map[string]bool)
+		cmd := command(ctx, "--with-colons", "--list-secret-keys")
+		var out bytes.Buffer
+		cmd.Stdout = &out
+		if run(cmd, nil) == nil {
+			for _, line := range strings.Split(out.String(), "\n") {
+				fields := strings.Split(line, ":")
+				if len(fields) > 14 && (fields[0] == "sec" || fields[0] == "ssb") && fields[14] != "" && fields[14] != "#" {
+					cardKeys.ids[strings.ToUpper(fields[4])] = true
+				}
+			}
+		}
+	}
+	return cardKeys.ids[strings.ToUpper(keyID)]
+}
@@ -0,0 +1,18 @@
+package browserpass
+
+import "syscall"
+
+// ptDenyAttach is PT_DENY_ATTACH from <sys/ptrace.h>.
+const ptDenyAttach = 31
+
+// HardenProcess disables core dumps and denies debuggers attaching to the
+// process.
+func HardenProcess() error {
+	if err := syscall.Setrlimit(syscall.RLIMIT_CORE, &syscall.Rlimit{}); err != nil {
+		return err
+	}
+	if _, _, errno := syscall.Syscall6(syscall.SYS_PTRACE, ptDenyAttach, 0, 0, 0, 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package browserpass
+
+import "syscall"
+
+// HardenProcess disables core dumps and marks the process non-dumpable, which
+// also prevents same-user debuggers from attaching with ptrace.
+func HardenProcess() error {
+	if err := syscall.Setrlimit(syscall.RLIMIT_CORE, &syscall.Rlimit{}); err != nil {
+		return err
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_SET_DUMPABLE, 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
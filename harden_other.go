@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package browserpass
+
+// HardenProcess is a no-op on platforms without a supported way to disable
+// core dumps and debugger attachment.
+func HardenProcess() error {
+	return nil
+}
@@ -0,0 +1,202 @@
+package browserpass
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// canonicalHost returns host in the form hosts are compared in: lowercase,
+// without "www." and with internationalized labels in their ASCII form, so
+// "www.München.de" becomes "xn--mnchen-3ya.de".
+func canonicalHost(host string) string {
+	return strings.TrimPrefix(asciiHost(host), "www.")
+}
+
+// asciiHost lowercases host and converts its labels holding other than
+// ASCII characters to punycode, prefixed with "xn--". Unlike full IDNA it
+// doesn't apply Unicode normalization.
+func asciiHost(host string) string {
+	labels := strings.Split(strings.ToLower(host), ".")
+	for i, label := range labels {
+		if !isASCII(label) {
+			labels[i] = "xn--" + punycodeEncode(label)
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// unicodeHost lowercases host and decodes its punycode labels. Labels that
+// aren't valid punycode are kept as they are.
+func unicodeHost(host string) string {
+	labels := strings.Split(strings.ToLower(host), ".")
+	for i, label := range labels {
+		if strings.HasPrefix(label, "xn--") {
+			if decoded, err := punycodeDecode(label[len("xn--"):]); err == nil {
+				labels[i] = decoded
+			}
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// Punycode parameters, see RFC 3492 section 5.
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+var errPunycode = errors.New("invalid punycode")
+
+// punycodeEncode encodes s as in RFC 3492 section 6.3.
+func punycodeEncode(s string) string {
+	input := []rune(s)
+	var out strings.Builder
+	for _, r := range input {
+		if r < utf8.RuneSelf {
+			out.WriteRune(r)
+		}
+	}
+	basic := out.Len()
+	if basic > 0 {
+		out.WriteByte('-')
+	}
+
+	n, delta, bias := rune(punyInitialN), 0, punyInitialBias
+	for h := basic; h < len(input); n++ {
+		// The smallest code point not encoded yet
+		m := rune(unicode.MaxRune)
+		for _, r := range input {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		delta += int(m-n) * (h + 1)
+		n = m
+		for _, r := range input {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := punyThreshold(k, bias)
+				if q < t {
+					break
+				}
+				out.WriteByte(punyDigit(t + (q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out.WriteByte(punyDigit(q))
+			bias = punyAdapt(delta, h+1, h == basic)
+			delta = 0
+			h++
+		}
+		delta++
+	}
+	return out.String()
+}
+
+// punycodeDecode decodes s as in RFC 3492 section 6.2.
+func punycodeDecode(s string) (string, error) {
+	var output []rune
+	rest := s
+	if i := strings.LastIndexByte(s, '-'); i >= 0 {
+		if !isASCII(s[:i]) {
+			return "", errPunycode
+		}
+		output, rest = []rune(s[:i]), s[i+1:]
+	}
+
+	n, i, bias := rune(punyInitialN), 0, punyInitialBias
+	for pos := 0; pos < len(rest); {
+		oldi, w := i, 1
+		for k := punyBase; ; k += punyBase {
+			if pos == len(rest) {
+				return "", errPunycode
+			}
+			digit, ok := punyValue(rest[pos])
+			pos++
+			if !ok || digit > (1<<30-i)/w {
+				return "", errPunycode
+			}
+			i += digit * w
+			t := punyThreshold(k, bias)
+			if digit < t {
+				break
+			}
+			w *= punyBase - t
+		}
+		length := len(output) + 1
+		bias = punyAdapt(i-oldi, length, oldi == 0)
+		n += rune(i / length)
+		i %= length
+		if n > unicode.MaxRune {
+			return "", errPunycode
+		}
+		output = append(output[:i], append([]rune{n}, output[i:]...)...)
+		i++
+	}
+	return string(output), nil
+}
+
+func punyThreshold(k, bias int) int {
+	switch {
+	case k <= bias:
+		return punyTMin
+	case k >= bias+punyTMax:
+		return punyTMax
+	}
+	return k - bias
+}
+
+func punyAdapt(delta, points int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / points
+	k := 0
+	for delta > (punyBase-punyTMin)*punyTMax/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punyValue(c byte) (int, bool) {
+	switch {
+	case 'a' <= c && c <= 'z':
+		return int(c - 'a'), true
+	case 'A' <= c && c <= 'Z':
+		return int(c - 'A'), true
+	case '0' <= c && c <= '9':
+		return int(c-'0') + 26, true
+	}
+	return 0, false
+}
@@ -0,0 +1,34 @@
+package browserpass
+
+import "testing"
+
+func TestIDNA(t *testing.T) {
+	tests := map[string]string{
+		"münchen.de":       "xn--mnchen-3ya.de",
+		"Bücher.example":   "xn--bcher-kva.example",
+		"пример.испытание": "xn--e1afmkfd.xn--80akhbyknj4f",
+		"例え.テスト":           "xn--r8jz45g.xn--zckzah",
+		"ñ.com":            "xn--ida.com",
+		"Example.COM":      "example.com",
+	}
+	for unicode, ascii := range tests {
+		if actual := asciiHost(unicode); actual != ascii {
+			t.Errorf("asciiHost(%s) is %s, expected %s", unicode, actual, ascii)
+		}
+		if actual, expected := unicodeHost(ascii), unicodeHost(unicode); actual != expected {
+			t.Errorf("unicodeHost(%s) is %s, expected %s", ascii, actual, expected)
+		}
+	}
+
+	for _, invalid := range []string{"ü-abc", "99999999", "abc-d!", "abc-9"} {
+		if _, err := punycodeDecode(invalid); err == nil {
+			t.Errorf("Decoded invalid punycode %q", invalid)
+		}
+	}
+}
+
+func TestCanonicalHost(t *testing.T) {
+	if host := canonicalHost("www.München.DE"); host != "xn--mnchen-3ya.de" {
+		t.Errorf("Canonical host is %s", host)
+	}
+}
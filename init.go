@@ -0,0 +1,42 @@
+package browserpass
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"github.com/dannyvankooten/browserpass/gpg"
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// keyFingerprint matches the long key IDs and fingerprints "init" takes.
+var keyFingerprint = regexp.MustCompile(`^(0x)?([0-9A-Fa-f]{16}|[0-9A-Fa-f]{40})$`)
+
+// gpgKeys is gpg.Keys, replaced in tests.
+var gpgKeys = gpg.Keys
+
+// initStore answers the "init" action, creating a store that doesn't exist
+// yet with a .gpg-id holding "key", the fingerprint of a key in the
+// keyring, for the first run of the extension.
+func (c *conn) initStore(ctx context.Context, data map[string]string) (interface{}, error) {
+	if refused := c.checkSession(data); refused != nil {
+		return refused, nil
+	}
+	key := data["key"]
+	if !keyFingerprint.MatchString(key) {
+		return errorResponse{Message: "key must be a key ID or fingerprint", Code: CodeInvalidRequest}, nil
+	}
+	if _, err := gpgKeys(ctx, key); errors.Is(err, gpg.ErrNoKey) {
+		return errorResponse{Message: "no key in the keyring for " + key, Code: CodeInvalidRequest}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	switch err := pass.Init(c.s, []string{key}); err {
+	case nil:
+	case pass.ErrExists:
+		return errorResponse{Message: "the password store exists already", Code: CodeExists}, nil
+	default:
+		return nil, err
+	}
+	return map[string]string{"key": key}, nil
+}
@@ -0,0 +1,61 @@
+package browserpass
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/gpg"
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+func TestRunInit(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "store")
+	s, err := pass.NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "0123456789ABCDEF0123456789ABCDEF01234567"
+	gpgKeys = func(ctx context.Context, name string) ([]string, error) {
+		if name != key {
+			return nil, gpg.ErrNoKey
+		}
+		return []string{name[24:]}, nil
+	}
+	defer func() { gpgKeys = gpg.Keys }()
+
+	var refused errorResponse
+	roundTrip(t, s, AllowedOrigins[0], map[string]string{"action": "list"}, &refused)
+	if refused.Code != CodeStoreNotInitialized || refused.Hint == "" {
+		t.Errorf("list before init answered with %+v", refused)
+	}
+
+	tests := []struct {
+		key, code string
+	}{
+		{"alice@example.com", CodeInvalidRequest},
+		{"FEDCBA9876543210", CodeInvalidRequest},
+		{key, ""},
+		{key, CodeExists},
+	}
+	for _, test := range tests {
+		var resp struct {
+			errorResponse
+			Key string `json:"key"`
+		}
+		roundTrip(t, s, AllowedOrigins[0], map[string]string{"action": "init", "key": test.key}, &resp)
+		if resp.Code != test.code {
+			t.Errorf("init with %s: code is %q, expected %q", test.key, resp.Code, test.code)
+		}
+	}
+
+	if id, err := os.ReadFile(filepath.Join(dir, ".gpg-id")); err != nil || string(id) != key+"\n" {
+		t.Errorf(".gpg-id holds %q, %v", id, err)
+	}
+	var list []string
+	roundTrip(t, s, AllowedOrigins[0], map[string]string{"action": "list"}, &list)
+	if list == nil || len(list) != 0 {
+		t.Errorf("list after init returned %v", list)
+	}
+}
@@ -52,7 +52,9 @@ echo "1) Chrome"
 echo "2) Chromium"
 echo "3) Firefox"
 echo "4) Vivaldi"
-echo -n "1-4: "
+echo "5) Firefox (Flatpak)"
+echo "6) Chromium (Flatpak)"
+echo -n "1-6: "
 read BROWSER
 echo ""
 
@@ -77,6 +79,29 @@ if [[ "$BROWSER" == "4" ]]; then
   TARGET_DIR="$TARGET_DIR_VIVALDI"
 fi
 
+# Flatpak browsers can't start the host, they get a proxy talking to
+# "browserpass serve" over a Unix socket instead
+if [[ "$BROWSER" == "5" ]]; then
+  BROWSER_NAME="Firefox (Flatpak)"
+  FLATPAK_ID="org.mozilla.firefox"
+  TARGET_DIR="$HOME/.var/app/$FLATPAK_ID/.mozilla/native-messaging-hosts"
+fi
+
+if [[ "$BROWSER" == "6" ]]; then
+  BROWSER_NAME="Chromium (Flatpak)"
+  FLATPAK_ID="org.chromium.Chromium"
+  TARGET_DIR="$HOME/.var/app/$FLATPAK_ID/config/chromium/NativeMessagingHosts"
+fi
+
+if [ -n "$FLATPAK_ID" ]; then
+  PROXY_DIR="$HOME/.var/app/$FLATPAK_ID/data/browserpass"
+  mkdir -p "$PROXY_DIR"
+  cp "$HOST_FILE" "$PROXY_DIR/browserpass-proxy"
+  HOST_FILE="$PROXY_DIR/browserpass-proxy"
+  ESCAPED_HOST_FILE=${HOST_FILE////\\/}
+  flatpak override --user --filesystem=xdg-run/browserpass "$FLATPAK_ID"
+fi
+
 echo "Installing $BROWSER_NAME host config"
 
 # Create config dir if not existing
@@ -87,6 +112,10 @@ if [ "$BROWSER" == "1" ] || [ "$BROWSER" == "2" ] || [ "$BROWSER" == "4" ]; then
   cp "$DIR/chrome-host.json" "$TARGET_DIR/$APP_NAME.json"
 	mkdir -p "$TARGET_DIR"/../policies/managed/
 	cp "$DIR/chrome-policy.json" "$TARGET_DIR"/../policies/managed/"$APP_NAME.json"
+elif [ "$BROWSER" == "6" ]; then
+  cp "$DIR/chrome-host.json" "$TARGET_DIR/$APP_NAME.json"
+elif [ "$BROWSER" == "5" ]; then
+  cp "$DIR/firefox-host.json" "$TARGET_DIR/$APP_NAME.json"
 else
   cp "$DIR/firefox-host.json" "$TARGET_DIR_FIREFOX/$APP_NAME.json"
 fi
@@ -98,3 +127,8 @@ sed -i -e "s/%%replace%%/$ESCAPED_HOST_FILE/" "$TARGET_DIR/$APP_NAME.json"
 chmod o+r "$TARGET_DIR/$APP_NAME.json"
 
 echo "Native messaging host for $BROWSER_NAME has been installed to $TARGET_DIR."
+
+if [ -n "$FLATPAK_ID" ]; then
+  echo "Flatpak browsers need the host running outside the sandbox: enable the"
+  echo "systemd units in systemd/ or keep \"$DIR/browserpass serve\" running."
+fi
@@ -0,0 +1,158 @@
+package browserpass
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// LockoutFile persists consecutive decryption failures. Browsers start a new
+// host for every message, so the state has to live on disk. Backoff is
+// disabled if it is empty.
+var LockoutFile = defaultLockoutFile()
+
+const (
+	// freeFailures is how many decryption failures in a row go unpunished,
+	// leaving room for a mistyped passphrase.
+	freeFailures = 3
+	maxLockout   = 15 * time.Minute
+)
+
+// DecryptionsPerMinute limits how many entries may be decrypted in a
+// minute, in bursts of up to as many, bounding what a compromised browser can
+// read at once. There is no limit if it is 0.
+var DecryptionsPerMinute = 0
+
+// lockoutMu serializes the updates of LockoutFile by concurrent
+// decryptions.
+var lockoutMu sync.Mutex
+
+// checkLockout returns an error if decryption is locked at now.
+func checkLockout(now time.Time) error {
+	lockoutMu.Lock()
+	defer lockoutMu.Unlock()
+	return loadLockout(LockoutFile).check(now)
+}
+
+// takeDecryption counts a decryption at now against DecryptionsPerMinute,
+// returning an error if there are none left.
+func takeDecryption(now time.Time) error {
+	if DecryptionsPerMinute <= 0 {
+		return nil
+	}
+	lockoutMu.Lock()
+	defer lockoutMu.Unlock()
+	return loadLockout(LockoutFile).take(now, DecryptionsPerMinute)
+}
+
+// recordDecryption records a decryption at now in LockoutFile, failed
+// unless ok.
+func recordDecryption(ok bool, now time.Time) error {
+	lockoutMu.Lock()
+	defer lockoutMu.Unlock()
+	l := loadLockout(LockoutFile)
+	if ok {
+		return l.succeed()
+	}
+	return l.fail(now)
+}
+
+// lockout is the decryption backoff and rate limiting state.
+type lockout struct {
+	path     string
+	Failures int       `json:"failures"`
+	Until    time.Time `json:"until"`
+	// Tokens is how many decryptions were left at Refilled
+	Tokens   float64   `json:"tokens,omitempty"`
+	Refilled time.Time `json:"refilled,omitempty"`
+}
+
+func defaultLockoutFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "browserpass", "lockout.json")
+}
+
+// loadLockout reads the state at path. A missing or corrupt file is treated
+// as no failures.
+func loadLockout(path string) *lockout {
+	l := &lockout{path: path}
+	if path == "" {
+		return l
+	}
+	if b, err := ioutil.ReadFile(path); err == nil {
+		json.Unmarshal(b, l)
+	}
+	return l
+}
+
+// check returns an error if decryption is locked at now.
+func (l *lockout) check(now time.Time) error {
+	if now.Before(l.Until) {
+		return fmt.Errorf("too many failed decryptions, locked for %s", l.Until.Sub(now).Round(time.Second))
+	}
+	return nil
+}
+
+// fail records a failed decryption, locking for exponentially longer
+// periods once freeFailures is exceeded.
+func (l *lockout) fail(now time.Time) error {
+	l.Failures++
+	if n := l.Failures - freeFailures; n > 0 {
+		d := maxLockout
+		if n < 20 {
+			if backoff := time.Second << uint(n-1); backoff < maxLockout {
+				d = backoff
+			}
+		}
+		l.Until = now.Add(d)
+	}
+	return l.save()
+}
+
+// take uses up one of perMinute decryptions, refilled evenly over a minute.
+func (l *lockout) take(now time.Time, perMinute int) error {
+	limit := float64(perMinute)
+	if l.Refilled.IsZero() || now.Before(l.Refilled) {
+		l.Tokens = limit
+	} else {
+		l.Tokens = math.Min(limit, l.Tokens+now.Sub(l.Refilled).Minutes()*limit)
+	}
+	l.Refilled = now
+	if l.Tokens < 1 {
+		wait := time.Duration((1 - l.Tokens) / limit * float64(time.Minute))
+		return fmt.Errorf("too many decryptions, try again in %s", wait.Round(time.Second))
+	}
+	l.Tokens--
+	return l.save()
+}
+
+// succeed resets the failure count after a successful decryption.
+func (l *lockout) succeed() error {
+	if l.Failures == 0 {
+		return nil
+	}
+	l.Failures, l.Until = 0, time.Time{}
+	return l.save()
+}
+
+func (l *lockout) save() error {
+	if l.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return err
+	}
+	b, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(l.path, b, 0600)
+}
@@ -0,0 +1,67 @@
+package browserpass
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "browserpass-lockout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "lockout.json")
+	now := time.Now()
+
+	for i := 0; i < freeFailures; i++ {
+		l := loadLockout(path)
+		if err := l.check(now); err != nil {
+			t.Fatalf("Locked after %d failures", i)
+		}
+		l.fail(now)
+	}
+
+	l := loadLockout(path)
+	l.fail(now)
+	if err := loadLockout(path).check(now); err == nil {
+		t.Error("Not locked after exceeding free failures")
+	}
+	if err := loadLockout(path).check(now.Add(2 * time.Second)); err != nil {
+		t.Errorf("First lockout lasted longer than a second: %v", err)
+	}
+
+	l = loadLockout(path)
+	l.fail(now)
+	if err := l.check(now.Add(time.Second)); err == nil {
+		t.Error("Backoff did not grow")
+	}
+
+	l.succeed()
+	if l := loadLockout(path); l.Failures != 0 || l.check(now) != nil {
+		t.Error("Success did not reset the lockout")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lockout.json")
+	now := time.Now()
+
+	for i := 0; i < 6; i++ {
+		if err := loadLockout(path).take(now, 6); err != nil {
+			t.Fatalf("Limited after %d decryptions: %v", i, err)
+		}
+	}
+	if err := loadLockout(path).take(now, 6); err == nil {
+		t.Error("Not limited after a burst")
+	}
+	if err := loadLockout(path).take(now.Add(10*time.Second), 6); err != nil {
+		t.Errorf("Not refilled after 10 seconds: %v", err)
+	}
+	if err := loadLockout(path).take(now.Add(11*time.Second), 6); err == nil {
+		t.Error("Refilled too fast")
+	}
+}
@@ -0,0 +1,48 @@
+// Package logging sets up the host's structured log, leveled and written to
+// a file or syslog, which the rest of the host writes to through log/slog.
+// Browsers run the host out of sight, so this log is where reports of empty
+// popups get debugged. It never records secrets.
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Setup makes the default slog logger, and the log package, write records
+// of level and above to sink: syslog if sink is "syslog", the file at sink
+// in JSON lines if it is a path, and stderr if it is empty. level is
+// "debug", "info", "warn" or "error", "info" if empty. The returned Closer
+// closes the sink.
+func Setup(sink, level string) (io.Closer, error) {
+	var l slog.Level
+	if level != "" {
+		if err := l.UnmarshalText([]byte(level)); err != nil {
+			return nil, err
+		}
+	}
+	opts := &slog.HandlerOptions{Level: l}
+
+	var h slog.Handler
+	var closer io.Closer = io.NopCloser(nil)
+	switch {
+	case sink == "":
+		h = slog.NewTextHandler(os.Stderr, opts)
+	case strings.EqualFold(sink, "syslog"):
+		w, err := openSyslog()
+		if err != nil {
+			return nil, err
+		}
+		h, closer = slog.NewTextHandler(w, opts), w
+	default:
+		f, err := os.OpenFile(sink, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		h, closer = slog.NewJSONHandler(f, opts), f
+	}
+	slog.SetDefault(slog.New(h))
+	return closer, nil
+}
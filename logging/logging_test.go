@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"encoding/json"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	defer func(l *slog.Logger) { slog.SetDefault(l) }(slog.Default())
+	path := filepath.Join(t.TempDir(), "browserpass.log")
+	sink, err := Setup(path, "warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slog.Info("left out")
+	slog.Warn("kept", "action", "search")
+	log.Println("from the log package")
+	slog.Error("kept too")
+	sink.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Logged %q, expected 2 records", lines)
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["msg"] != "kept" || record["level"] != "WARN" || record["action"] != "search" {
+		t.Errorf("Unexpected record %v", record)
+	}
+
+	if _, err := Setup(path, "loud"); err == nil {
+		t.Error("Set up an unknown level")
+	}
+}
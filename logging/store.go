@@ -0,0 +1,142 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// Store is a pass.Store logging its operations at debug level, with how
+// long they took and how many items they found. Entry names are logged,
+// their contents never.
+type Store struct {
+	pass.Store
+}
+
+// Wrap returns s logging its operations.
+func Wrap(s pass.Store) pass.Store {
+	return &Store{s}
+}
+
+// done logs the operation op started at start.
+func done(op string, start time.Time, err error, attrs ...any) {
+	attrs = append(attrs, "op", op, "duration", time.Since(start))
+	if err != nil {
+		slog.Warn("store operation failed", append(attrs, "error", err)...)
+		return
+	}
+	slog.Debug("store operation", attrs...)
+}
+
+func (s *Store) Search(ctx context.Context, query string, opts ...pass.SearchOption) ([]string, error) {
+	start := time.Now()
+	items, err := s.Store.Search(ctx, query, opts...)
+	done("search", start, err, "query", query, "items", len(items))
+	return items, err
+}
+
+func (s *Store) List(ctx context.Context) ([]string, error) {
+	start := time.Now()
+	items, err := s.Store.List(ctx)
+	done("list", start, err, "items", len(items))
+	return items, err
+}
+
+// LookupStream logs once the stream ends.
+func (s *Store) LookupStream(ctx context.Context, domain string) (<-chan string, <-chan error) {
+	start := time.Now()
+	items, errs := s.Store.LookupStream(ctx, domain)
+	out, outErrs := make(chan string), make(chan error, 1)
+	go func() {
+		defer close(outErrs)
+		n := 0
+		for item := range items {
+			out <- item
+			n++
+		}
+		close(out)
+		err := <-errs
+		done("lookup", start, err, "domain", domain, "items", n)
+		if err != nil {
+			outErrs <- err
+		}
+	}()
+	return out, outErrs
+}
+
+func (s *Store) Open(ctx context.Context, item string) (io.ReadCloser, error) {
+	start := time.Now()
+	rc, err := s.Store.Open(ctx, item)
+	done("open", start, err, "entry", item)
+	return rc, err
+}
+
+func (s *Store) Create(item string, content []byte) error {
+	start := time.Now()
+	err := s.Store.Create(item, content)
+	done("create", start, err, "entry", item)
+	return err
+}
+
+func (s *Store) Delete(item string) error {
+	start := time.Now()
+	err := s.Store.Delete(item)
+	done("delete", start, err, "entry", item)
+	return err
+}
+
+// Update implements pass.Updater if the wrapped store does.
+func (s *Store) Update(item string, content []byte) error {
+	u, ok := s.Store.(pass.Updater)
+	if !ok {
+		return pass.ErrReadOnly
+	}
+	start := time.Now()
+	err := u.Update(item, content)
+	done("update", start, err, "entry", item)
+	return err
+}
+
+// Move implements pass.Mover if the wrapped store does.
+func (s *Store) Move(src, dst string) error {
+	start := time.Now()
+	err := pass.Move(s.Store, src, dst)
+	done("move", start, err, "entry", src, "to", dst)
+	return err
+}
+
+// Diagnose implements pass.Diagnoser if the wrapped store does.
+func (s *Store) Diagnose(ctx context.Context) ([]pass.Problem, error) {
+	return pass.Diagnose(ctx, s.Store)
+}
+
+// Stats implements pass.Statter if the wrapped store does.
+func (s *Store) Stats(ctx context.Context) (pass.Stats, error) {
+	return pass.FileStats(ctx, s.Store)
+}
+
+// StoreKeys implements pass.Keyed if the wrapped store does.
+func (s *Store) StoreKeys() ([]string, error) {
+	return pass.KeysOf(s.Store)
+}
+
+// Init implements pass.Initializer if the wrapped store does.
+func (s *Store) Init(recipients []string) error {
+	return pass.Init(s.Store, recipients)
+}
+
+// Warnings implements pass.Checker if the wrapped store does.
+func (s *Store) Warnings() ([]string, error) {
+	if c, ok := s.Store.(pass.Checker); ok {
+		return c.Warnings()
+	}
+	return nil, nil
+}
+
+// StoreSettings implements pass.Configured with the wrapped store's settings.
+func (s *Store) StoreSettings() (*pass.Settings, error) {
+	return pass.SettingsOf(s.Store)
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// listStore is a store of a fixed list of items.
+type listStore []string
+
+func (s listStore) Search(ctx context.Context, query string, opts ...pass.SearchOption) ([]string, error) {
+	return pass.Page(s, opts...), nil
+}
+
+func (s listStore) List(ctx context.Context) ([]string, error) {
+	return s, nil
+}
+
+func (s listStore) LookupStream(ctx context.Context, domain string) (<-chan string, <-chan error) {
+	return pass.StreamSearch(ctx, s, domain)
+}
+
+func (s listStore) Open(ctx context.Context, item string) (io.ReadCloser, error) {
+	return nil, pass.ErrNotFound
+}
+
+func (s listStore) Create(item string, content []byte) error {
+	return pass.ErrReadOnly
+}
+
+func (s listStore) Delete(item string) error {
+	return pass.ErrReadOnly
+}
+
+func TestStore(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(l *slog.Logger) { slog.SetDefault(l) }(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	s := Wrap(listStore{"example.com/alice", "example.com/bob"})
+	if items, _ := s.Search(context.Background(), "example.com"); len(items) != 2 {
+		t.Errorf("Search returned %v", items)
+	}
+	var streamed []string
+	items, errs := s.LookupStream(context.Background(), "example.com")
+	for item := range items {
+		streamed = append(streamed, item)
+	}
+	if err := <-errs; err != nil || !reflect.DeepEqual(streamed, []string{"example.com/alice", "example.com/bob"}) {
+		t.Errorf("Streamed %v, %v", streamed, err)
+	}
+	s.Open(context.Background(), "example.com/carol")
+	if err := s.(pass.Updater).Update("example.com/alice", []byte("secret")); err != pass.ErrReadOnly {
+		t.Errorf("Update returned %v", err)
+	}
+
+	logged := buf.String()
+	for _, expected := range []string{
+		"level=DEBUG msg=\"store operation\" query=example.com items=2 op=search",
+		"domain=example.com items=2 op=lookup",
+		"level=WARN msg=\"store operation failed\" entry=example.com/carol op=open",
+	} {
+		if !strings.Contains(logged, expected) {
+			t.Errorf("Log %q lacks %q", logged, expected)
+		}
+	}
+	if strings.Contains(logged, "secret") {
+		t.Error("Logged the content of an entry")
+	}
+}
@@ -0,0 +1,13 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package logging
+
+import (
+	"errors"
+	"io"
+)
+
+func openSyslog() (io.WriteCloser, error) {
+	return nil, errors.New("logging: no syslog on this platform")
+}
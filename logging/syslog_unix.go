@@ -0,0 +1,13 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package logging
+
+import (
+	"io"
+	"log/syslog"
+)
+
+func openSyslog() (io.WriteCloser, error) {
+	return syslog.New(syslog.LOG_INFO|syslog.LOG_USER, "browserpass")
+}
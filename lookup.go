@@ -0,0 +1,170 @@
+package browserpass
+
+import (
+	"context"
+	"strings"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// Lookup returns the entries in s naming host, leaving out the archive.
+// Both layouts of pass stores match: entries in a directory named after the
+// host, like "example.com/alice", come first, then entries named after it,
+// like "example.com" or "work/example.com". If there are none, the parent
+// domains of host are tried in turn down to its registrable domain, so
+// "a.foo.co.uk" falls back to "foo.co.uk" but never to "co.uk".
+//
+// Domains the store's settings alias to another domain are looked up as that
+// domain. Entries decrypted earlier that list the domain in a URL or
+// "aliases:" line match too, after the others. opts select a page of the
+// matches.
+func Lookup(ctx context.Context, s pass.Store, host string, opts ...pass.SearchOption) ([]string, error) {
+	list, _, err := lookup(ctx, s, host, nil, opts...)
+	return list, err
+}
+
+// lookup is Lookup, also reporting whether the entries are inexact: found
+// for a parent domain of host rather than host itself. It calls found if
+// not nil with the entries matching host by name as the walk of the store
+// finds them, see pass.Store.LookupStream. An entry may be found more than
+// once.
+func lookup(ctx context.Context, s pass.Store, host string, found func(entry string, inexact bool), opts ...pass.SearchOption) ([]string, bool, error) {
+	host = canonicalHost(host)
+	if !strings.Contains(host, ".") {
+		return nil, false, nil
+	}
+	settings, err := pass.SettingsOf(s)
+	if err != nil {
+		return nil, false, err
+	}
+	last := publicSuffixes().registrableDomain(host)
+	if last == "" {
+		last = host
+	}
+	for domain := host; ; domain = domain[strings.Index(domain, ".")+1:] {
+		inexact := domain != host
+		name := domain
+		if alias := aliasOf(settings, domain); alias != "" {
+			name = canonicalHost(alias)
+		}
+		search := func(query string) ([]string, error) {
+			if found == nil {
+				return s.Search(ctx, query)
+			}
+			return streamSearch(ctx, s, query, func(entry string) {
+				if !isArchived(entry) && matchesHost(entry, name, settings) {
+					found(entry, inexact)
+				}
+			})
+		}
+		// Entries may be named with either form of internationalized
+		// domains
+		list, err := search(name)
+		if err != nil {
+			return nil, false, err
+		}
+		if u := unicodeHost(name); u != name {
+			more, err := search(u)
+			if err != nil {
+				return nil, false, err
+			}
+			list = append(list, more...)
+		}
+		var matches []string
+		seen := make(map[string]bool)
+		for _, entry := range withoutArchived(list) {
+			if matchesHost(entry, name, settings) && !seen[entry] {
+				seen[entry] = true
+				matches = append(matches, entry)
+			}
+		}
+		matches = withoutArchived(appendKnownURLs(matches, domain))
+		if len(matches) > 0 {
+			return pass.Page(matches, opts...), inexact, nil
+		}
+		if domain == last {
+			return nil, false, nil
+		}
+	}
+}
+
+// streamSearch searches s for query like Search without options, calling
+// found with each item as the walk of the store finds it.
+func streamSearch(ctx context.Context, s pass.Store, query string, found func(item string)) ([]string, error) {
+	items, errs := s.LookupStream(ctx, query)
+	var list []string
+	for item := range items {
+		found(item)
+		list = append(list, item)
+	}
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+	pass.Sort(list)
+	return pass.SearchItems(list, query)
+}
+
+// aliasOf returns the domain the store settings alias domain to, if any.
+// Aliases are compared in canonical form.
+func aliasOf(settings *pass.Settings, domain string) string {
+	for alias, target := range settings.Aliases {
+		if canonicalHost(alias) == domain {
+			return target
+		}
+	}
+	return ""
+}
+
+// OpenLogin decrypts the login in entry for caller, through the same checks
+// and audit as the "get" action. The user chose the entry, so it needs no
+// confirmation for a host. The caller must wipe the login.
+func OpenLogin(ctx context.Context, s pass.Store, caller, entry string) (*Login, error) {
+	c := &conn{s: s, caller: caller, authorized: true}
+	plaintext, refused, err := c.decryptEntry(ctx, map[string]string{"entry": entry, "confirmed": "true"})
+	if err != nil {
+		return nil, err
+	}
+	if refused != nil {
+		return nil, *refused
+	}
+	login, err := ParseLogin(plaintext.Bytes())
+	plaintext.Wipe()
+	if err != nil {
+		return nil, err
+	}
+	settings, err := pass.SettingsOf(s)
+	if err != nil {
+		login.Wipe()
+		return nil, err
+	}
+	login.resolveUsername(entry, settings)
+	if err := c.audit(entry, ""); err != nil {
+		login.Wipe()
+		return nil, err
+	}
+	return login, nil
+}
+
+// ReadPassword decrypts the password in entry for browserpass's own use, like
+// unlocking a KeePass database. Unlike OpenLogin it is no fetch by a caller:
+// it isn't audited and DecryptionsPerMinute doesn't count it. The caller must
+// wipe the password.
+func ReadPassword(ctx context.Context, s pass.Store, entry string) (*SecureBytes, error) {
+	c := &conn{s: s, caller: CLICaller, authorized: true, internal: true}
+	plaintext, refused, err := c.decryptItem(ctx, entry, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	if refused != nil {
+		return nil, *refused
+	}
+	login, err := ParseLogin(plaintext.Bytes())
+	plaintext.Wipe()
+	if err != nil {
+		return nil, err
+	}
+	if login.OTP != nil {
+		login.OTP.Wipe()
+	}
+	return login.Password, nil
+}
@@ -0,0 +1,140 @@
+package browserpass
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+func TestLookup(t *testing.T) {
+	s := fakeStore{"github.com/alice", "github.com/bob", "github.com/work/carol", "example.com/alice", "work/example.com"}
+	tests := map[string][]string{
+		"github.com":          {"github.com/alice", "github.com/bob", "github.com/work/carol"},
+		"www.github.com":      {"github.com/alice", "github.com/bob", "github.com/work/carol"},
+		"gist.github.com":     {"github.com/alice", "github.com/bob", "github.com/work/carol"},
+		"git.sub.example.com": {"example.com/alice"},
+		"gitlab.com":          nil,
+	}
+	for host, expected := range tests {
+		actual, err := Lookup(context.Background(), s, host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Lookup(%s): expected %v, got %v", host, expected, actual)
+		}
+	}
+}
+
+func TestLookupLayouts(t *testing.T) {
+	dir := t.TempDir()
+	for _, item := range []string{"example.com/alice", "example.com", "work/example.com", "example.org/bob"} {
+		path := filepath.Join(dir, filepath.FromSlash(item)+".gpg")
+		os.MkdirAll(filepath.Dir(path), 0700)
+		os.WriteFile(path, nil, 0600)
+	}
+	s, err := pass.NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries in a domain directory come before ones named after the domain
+	actual, err := Lookup(context.Background(), s, "login.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"example.com/alice", "example.com", "work/example.com"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func TestLookupIDN(t *testing.T) {
+	s := fakeStore{"münchen.de/alice", "xn--bcher-kva.example/bob"}
+	tests := map[string][]string{
+		"xn--mnchen-3ya.de": {"münchen.de/alice"},
+		"www.München.de":    {"münchen.de/alice"},
+		"bücher.example":    {"xn--bcher-kva.example/bob"},
+	}
+	for host, expected := range tests {
+		actual, err := Lookup(context.Background(), s, host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Lookup(%s): expected %v, got %v", host, expected, actual)
+		}
+	}
+}
+
+// configuredStore is a fakeStore with settings.
+type configuredStore struct {
+	fakeStore
+	settings pass.Settings
+}
+
+func (s configuredStore) StoreSettings() (*pass.Settings, error) {
+	return &s.settings, nil
+}
+
+func TestLookupAliases(t *testing.T) {
+	s := configuredStore{
+		fakeStore{"google.com/alice", "youtube.com/bob"},
+		pass.Settings{Aliases: map[string]string{"youtube.com": "google.com"}},
+	}
+	for _, host := range []string{"youtube.com", "m.youtube.com"} {
+		actual, err := Lookup(context.Background(), s, host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := []string{"google.com/alice"}; !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Lookup(%s): expected %v, got %v", host, expected, actual)
+		}
+	}
+}
+
+func TestLookupKnownURLs(t *testing.T) {
+	defer func() { knownURLs = &urlIndex{entries: make(map[string][]string)} }()
+	knownURLs.update("corp.com/alice", []string{"login.corp.com", "jira.corp.com"})
+	knownURLs.update("archive/corp.com/bob", []string{"jira.corp.com"})
+
+	s := fakeStore{"jira.corp.com/carol", "corp.com/alice", "archive/corp.com/bob"}
+	actual, err := Lookup(context.Background(), s, "jira.corp.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"jira.corp.com/carol", "corp.com/alice"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	var err error
+	if Audit, err = OpenAuditLog(filepath.Join(t.TempDir(), "audit.log"), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { Audit.Close(); Audit = nil }()
+	LockoutFile, DecryptionsPerMinute = filepath.Join(t.TempDir(), "lockout.json"), 1
+	defer func() { LockoutFile, DecryptionsPerMinute = "", 0 }()
+
+	s := fakeStore{"keepass"}
+	for i := 0; i < 2; i++ {
+		password, err := ReadPassword(context.Background(), s, "keepass")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(password.Bytes()) != "password-of-keepass" {
+			t.Errorf("Password is %q", password.Bytes())
+		}
+		password.Wipe()
+	}
+	if records, err := Audit.Recent(10); err != nil || len(records) != 0 {
+		t.Errorf("Reading the password was audited: %+v, %v", records, err)
+	}
+	if _, err := OpenLogin(context.Background(), s, CLICaller, "keepass"); err != nil {
+		t.Errorf("Reading the password was rate limited: %v", err)
+	}
+}
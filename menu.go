@@ -0,0 +1,59 @@
+package browserpass
+
+import (
+	"context"
+	"time"
+
+	"github.com/dannyvankooten/browserpass/clipboard"
+	"github.com/dannyvankooten/browserpass/menu"
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// Menu lets the user pick an entry from a desktop menu like dmenu, then
+// types its username, a tab and its password into the focused window, or
+// with autotype unset copies the password to the clipboard for timeout.
+func Menu(s pass.Store, autotype bool, timeout time.Duration) error {
+	ctx := context.Background()
+	entries, err := menuEntries(ctx, s)
+	if err != nil {
+		return err
+	}
+	entry, err := menu.Pick(entries)
+	if err != nil {
+		return err
+	}
+	login, err := OpenLogin(ctx, s, CLICaller, entry)
+	if err != nil {
+		return err
+	}
+	defer login.Wipe()
+
+	if !autotype {
+		if err := clipboard.Copy(login.Password.Bytes(), timeout); err != nil {
+			return err
+		}
+		// The host exits now, the clearer takes over
+		clipboard.Disown()
+		return nil
+	}
+	text, err := NewSecureBytes(len(login.Username) + 1 + login.Password.Len())
+	if err != nil {
+		return err
+	}
+	defer text.Wipe()
+	if login.Username != "" {
+		text.WriteString(login.Username + "\t")
+	}
+	text.Write(login.Password.Bytes())
+	return menu.Type(text.Bytes())
+}
+
+// menuEntries returns every entry the menu offers: the whole store except
+// the archive and entries outside the CLI's policy.
+func menuEntries(ctx context.Context, s pass.Store) ([]string, error) {
+	list, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return filterAllowed(CLICaller, "", withoutArchived(list)), nil
+}
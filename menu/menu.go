@@ -0,0 +1,89 @@
+// Package menu lets the user pick from a list with a desktop menu such as
+// dmenu, rofi or fuzzel, and types text into the focused window.
+package menu
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ErrUnavailable is returned when no supported menu or typing tool is
+// installed.
+var ErrUnavailable = errors.New("menu: no supported tool available")
+
+// ErrCancelled is returned by Pick when the user closes the menu without
+// picking anything.
+var ErrCancelled = errors.New("menu: cancelled")
+
+// pickers are the supported menus in order of preference. fuzzel only runs
+// on Wayland, dmenu only on X11.
+var pickers = []struct {
+	args    []string
+	wayland bool
+	x11     bool
+}{
+	{[]string{"fuzzel", "--dmenu", "--prompt", "browserpass> "}, true, false},
+	{[]string{"rofi", "-dmenu", "-i", "-p", "browserpass"}, true, true},
+	{[]string{"dmenu", "-i", "-p", "browserpass"}, false, true},
+}
+
+// pickerFor returns the command line of the first installed menu usable on
+// the display server.
+func pickerFor(getenv func(string) string, lookPath func(string) (string, error)) []string {
+	wayland, x11 := getenv("WAYLAND_DISPLAY") != "", getenv("DISPLAY") != ""
+	for _, p := range pickers {
+		if !(wayland && p.wayland || x11 && p.x11) {
+			continue
+		}
+		if _, err := lookPath(p.args[0]); err == nil {
+			return p.args
+		}
+	}
+	return nil
+}
+
+// Pick shows items in a menu and returns the one the user picked.
+func Pick(items []string) (string, error) {
+	args := pickerFor(os.Getenv, exec.LookPath)
+	if args == nil {
+		return "", ErrUnavailable
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(strings.Join(items, "\n"))
+	out, err := cmd.Output()
+	choice := strings.TrimSpace(string(out))
+	if choice == "" {
+		return "", ErrCancelled
+	}
+	return choice, err
+}
+
+// typerFor returns the command line of the tool typing its stdin on the
+// display server.
+func typerFor(getenv func(string) string) []string {
+	switch {
+	case getenv("WAYLAND_DISPLAY") != "":
+		return []string{"wtype", "-"}
+	case getenv("DISPLAY") != "":
+		return []string{"xdotool", "type", "--clearmodifiers", "--file", "-"}
+	}
+	return nil
+}
+
+// Type types text into the focused window with wtype or xdotool. The text
+// goes through stdin, command lines are visible to other users.
+func Type(text []byte) error {
+	args := typerFor(os.Getenv)
+	if args == nil {
+		return ErrUnavailable
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return ErrUnavailable
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(text)
+	return cmd.Run()
+}
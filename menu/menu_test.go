@@ -0,0 +1,55 @@
+package menu
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPickerFor(t *testing.T) {
+	tests := []struct {
+		env       map[string]string
+		installed []string
+		expected  string
+	}{
+		{map[string]string{"WAYLAND_DISPLAY": "wayland-0"}, []string{"fuzzel", "rofi", "dmenu"}, "fuzzel"},
+		{map[string]string{"WAYLAND_DISPLAY": "wayland-0"}, []string{"dmenu"}, ""},
+		{map[string]string{"DISPLAY": ":0"}, []string{"fuzzel", "dmenu"}, "dmenu"},
+		{map[string]string{"DISPLAY": ":0"}, []string{"rofi", "dmenu"}, "rofi"},
+		{nil, []string{"fuzzel", "rofi", "dmenu"}, ""},
+	}
+	for _, test := range tests {
+		lookPath := func(name string) (string, error) {
+			for _, installed := range test.installed {
+				if installed == name {
+					return "/usr/bin/" + name, nil
+				}
+			}
+			return "", errors.New("not found")
+		}
+		args := pickerFor(func(key string) string { return test.env[key] }, lookPath)
+		name := ""
+		if args != nil {
+			name = args[0]
+		}
+		if name != test.expected {
+			t.Errorf("pickerFor(%v, %v): expected %q, got %q", test.env, test.installed, test.expected, name)
+		}
+	}
+}
+
+func TestTyperFor(t *testing.T) {
+	tests := []struct {
+		env      map[string]string
+		expected []string
+	}{
+		{map[string]string{"WAYLAND_DISPLAY": "wayland-0", "DISPLAY": ":0"}, []string{"wtype", "-"}},
+		{map[string]string{"DISPLAY": ":0"}, []string{"xdotool", "type", "--clearmodifiers", "--file", "-"}},
+		{nil, nil},
+	}
+	for _, test := range tests {
+		if actual := typerFor(func(key string) string { return test.env[key] }); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("typerFor(%v): expected %v, got %v", test.env, test.expected, actual)
+		}
+	}
+}
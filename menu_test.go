@@ -0,0 +1,21 @@
+package browserpass
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestMenuEntries(t *testing.T) {
+	Policies = map[string][]string{CLICaller: {"personal"}}
+	defer func() { Policies = nil }()
+
+	s := fakeStore{"personal/example.com/alice", "personal/Zeta.com", "work/example.com/bob", "archive/personal/old.com"}
+	entries, err := menuEntries(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"personal/example.com/alice", "personal/Zeta.com"}; !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected %v, got %v", expected, entries)
+	}
+}
@@ -0,0 +1,252 @@
+package browserpass
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dannyvankooten/browserpass/gpg"
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// MetadataIndexFile keeps the usernames and URL hosts of every entry,
+// encrypted to the keys in the store's .gpg-id, so searches find entries by them without
+// decrypting each. The "reindex" action rebuilds it. Disabled if empty.
+var MetadataIndexFile string
+
+// encryptIndex and decryptIndex are gpg.SignEncrypt and
+// gpg.DecryptSignedTo, replaced in tests.
+var (
+	encryptIndex = gpg.SignEncrypt
+	decryptIndex = gpg.DecryptSignedTo
+)
+
+// metadataIndex is the content of MetadataIndexFile.
+type metadataIndex struct {
+	Built   time.Time              `json:"built"`
+	Entries map[string]indexRecord `json:"entries"`
+}
+
+// indexRecord is what the index knows about an entry.
+type indexRecord struct {
+	Username string   `json:"username,omitempty"`
+	Hosts    []string `json:"hosts,omitempty"`
+}
+
+// loadedIndex is the index decrypted by this process, nil until loaded.
+var loadedIndex struct {
+	sync.Mutex
+	index *metadataIndex
+}
+
+// loadMetadataIndex returns the index in MetadataIndexFile, nil if there is
+// none. The index must be signed by a key of s: its hosts decide which
+// entries are offered on which sites, so a forged one, which anyone could
+// encrypt to the store's keys, is refused.
+func loadMetadataIndex(ctx context.Context, s pass.Store) (*metadataIndex, error) {
+	if MetadataIndexFile == "" {
+		return nil, nil
+	}
+	loadedIndex.Lock()
+	defer loadedIndex.Unlock()
+	if loadedIndex.index != nil {
+		return loadedIndex.index, nil
+	}
+	f, err := os.Open(MetadataIndexFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	keys, err := pass.KeysOf(s)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := NewSecureBytes(4096)
+	if err != nil {
+		return nil, err
+	}
+	defer plaintext.Wipe()
+	if err := decryptIndex(ctx, plaintext, f, keys...); err != nil {
+		return nil, err
+	}
+	index := new(metadataIndex)
+	if err := json.Unmarshal(plaintext.Bytes(), index); err != nil {
+		return nil, &fs.PathError{Op: "parse", Path: MetadataIndexFile, Err: err}
+	}
+	loadedIndex.index = index
+	return index, nil
+}
+
+// saveMetadataIndex signs and encrypts index to MetadataIndexFile with the
+// keys of s, replacing it atomically.
+func saveMetadataIndex(ctx context.Context, s pass.Store, index *metadataIndex) error {
+	keys, err := pass.KeysOf(s)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(MetadataIndexFile)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	f, err := pass.CreateTemp(dir)
+	if err != nil {
+		return err
+	}
+	if err := encryptIndex(ctx, f, bytes.NewReader(data), keys...); err != nil {
+		pass.Shred(f)
+		return err
+	}
+	if err := pass.Replace(f, MetadataIndexFile); err != nil {
+		return err
+	}
+	loadedIndex.Lock()
+	loadedIndex.index = index
+	loadedIndex.Unlock()
+	return nil
+}
+
+// moveIndexed renames the entries moved from src to dst in
+// MetadataIndexFile, if there is one, see pass.Moved.
+func moveIndexed(ctx context.Context, s pass.Store, src, dst string) error {
+	index, err := loadMetadataIndex(ctx, s)
+	if err != nil || index == nil {
+		return err
+	}
+	// Searches may be reading the loaded index, it is replaced instead
+	moved := &metadataIndex{Built: index.Built, Entries: make(map[string]indexRecord, len(index.Entries))}
+	changed := false
+	for entry, r := range index.Entries {
+		name, ok := pass.Moved(entry, src, dst)
+		moved.Entries[name] = r
+		changed = changed || ok
+	}
+	if !changed {
+		return nil
+	}
+	return saveMetadataIndex(ctx, s, moved)
+}
+
+// matches returns the entries whose username or URL hosts contain query, or
+// with a URL for host.
+func (x *metadataIndex) matches(query, host string) []string {
+	query = strings.ToLower(query)
+	var entries []string
+	for entry, r := range x.Entries {
+		match := query != "" && strings.Contains(strings.ToLower(r.Username), query)
+		for _, h := range r.Hosts {
+			match = match || query != "" && strings.Contains(h, query)
+		}
+		if match || host != "" && containsHost(r.Hosts, host) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// appendIndexed appends the entries the metadata index matches for query
+// and host to list, skipping those already in it and those no longer in the
+// store. Searches go on without the index if it can't be read.
+func (c *conn) appendIndexed(ctx context.Context, list []string, query, host string) ([]string, error) {
+	index, err := loadMetadataIndex(ctx, c.s)
+	if err != nil {
+		slog.Warn("metadata index unavailable", "error", err)
+		return list, nil
+	}
+	if index == nil {
+		return list, nil
+	}
+	found := make(map[string]bool, len(list))
+	for _, entry := range list {
+		found[entry] = true
+	}
+	var extra []string
+	for _, entry := range index.matches(query, host) {
+		if !found[entry] {
+			extra = append(extra, entry)
+		}
+	}
+	if len(extra) == 0 {
+		return list, nil
+	}
+
+	all, err := c.s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool, len(all))
+	for _, entry := range all {
+		exists[entry] = true
+	}
+	var live []string
+	for _, entry := range extra {
+		if exists[entry] {
+			live = append(live, entry)
+			knownURLs.update(entry, index.Entries[entry].Hosts)
+		}
+	}
+	pass.Sort(live)
+	return append(list, live...), nil
+}
+
+// dropLoadedIndex makes the next search read MetadataIndexFile again, once
+// an entry changed or the index was rebuilt by another host.
+func dropLoadedIndex() {
+	loadedIndex.Lock()
+	loadedIndex.index = nil
+	loadedIndex.Unlock()
+}
+
+// reindex rebuilds MetadataIndexFile from every entry of the store, which
+// are decrypted like for "fetch_all". The index serves every caller, so it
+// is built from the whole store whatever the policy or container of the
+// caller, whose response only counts the entries. Its decryptions are
+// internal: they don't count against DecryptionsPerMinute or toward the
+// lockout, and the reindex is audited once, as wholeStore.
+func (c *conn) reindex(ctx context.Context, data map[string]string) (interface{}, error) {
+	if MetadataIndexFile == "" {
+		return errorResponse{Message: "the metadata index is disabled", Code: CodeUnavailable}, nil
+	}
+	if refused := c.checkSession(data); refused != nil {
+		return refused, nil
+	}
+	items, err := c.s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	all := &conn{s: c.s, caller: c.caller, authorized: true, internal: true}
+	results, err := all.fetchAll(ctx, items, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	index := &metadataIndex{Built: time.Now(), Entries: make(map[string]indexRecord, len(results))}
+	skipped := 0
+	for _, r := range results {
+		if r.Code != "" {
+			skipped++
+			continue
+		}
+		index.Entries[r.Entry] = indexRecord{Username: r.Username, Hosts: r.hosts}
+	}
+	if err := saveMetadataIndex(ctx, c.s, index); err != nil {
+		return nil, err
+	}
+	if err := c.audit(wholeStore, data["host"]); err != nil {
+		return nil, err
+	}
+	return map[string]int{"entries": len(index.Entries), "skipped": skipped}, nil
+}
@@ -0,0 +1,162 @@
+package browserpass
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dannyvankooten/browserpass/fixture"
+	"github.com/dannyvankooten/browserpass/gpg"
+)
+
+// metaStore is a fakeStore whose entries have usernames and URLs.
+type metaStore struct {
+	fakeStore
+}
+
+func (s metaStore) Open(ctx context.Context, item string) (io.ReadCloser, error) {
+	if _, err := s.fakeStore.Open(ctx, item); err != nil {
+		return nil, err
+	}
+	name := filepath.Base(item)
+	return ioutil.NopCloser(strings.NewReader(fixture.FakeHeader + "hunter2\nlogin: " + name + "@mail.test\nurl: https://sso." + name + ".test/\n")), nil
+}
+
+// StoreKeys implements pass.Keyed.
+func (s metaStore) StoreKeys() ([]string, error) {
+	return []string{"alice@example.com"}, nil
+}
+
+// fakeSigned marks index files written by the fake encryptIndex, which
+// signs them with the key after it, the first recipient like gpg.SignEncrypt.
+const fakeSigned = "signed by "
+
+func TestRunReindex(t *testing.T) {
+	MetadataIndexFile = filepath.Join(t.TempDir(), "index.gpg")
+	encryptIndex = func(ctx context.Context, w io.Writer, r io.Reader, recipients ...string) error {
+		io.WriteString(w, fakeSigned+recipients[0]+"\n")
+		_, err := io.Copy(w, r)
+		return err
+	}
+	decryptIndex = func(ctx context.Context, w io.Writer, r io.Reader, signers ...string) error {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		signer, rest, _ := strings.Cut(string(data), "\n")
+		for _, key := range signers {
+			if signer == fakeSigned+key {
+				_, err := io.WriteString(w, rest)
+				return err
+			}
+		}
+		return gpg.ErrBadSignature
+	}
+	defer func() {
+		MetadataIndexFile, encryptIndex, decryptIndex = "", gpg.SignEncrypt, gpg.DecryptSignedTo
+		loadedIndex.index = nil
+	}()
+	s := metaStore{fakeStore{"example.com/alice", "example.org/bob"}}
+	caller := AllowedOrigins[0]
+
+	var resp map[string]int
+	roundTrip(t, s, caller, map[string]string{"action": "reindex"}, &resp)
+	if resp["entries"] != 2 || resp["skipped"] != 0 {
+		t.Fatalf("reindex returned %v", resp)
+	}
+
+	// A new host reads the index from its file
+	loadedIndex.index = nil
+	search := func(s metaStore, req map[string]string, expected ...string) {
+		t.Helper()
+		var results []string
+		req["action"] = "search"
+		roundTrip(t, s, caller, req, &results)
+		if len(results)+len(expected) > 0 && !reflect.DeepEqual(results, expected) {
+			t.Errorf("search %v returned %v, expected %v", req, results, expected)
+		}
+	}
+	search(s, map[string]string{"domain": "bob@mail"}, "example.org/bob")
+	search(s, map[string]string{"domain": "sso.alice"}, "example.com/alice")
+
+	knownURLs.update("example.org/bob", nil)
+	var annotated []searchResult
+	roundTrip(t, s, caller, map[string]string{"action": "search", "domain": "sso.bob", "host": "sso.bob.test"}, &annotated)
+	if !reflect.DeepEqual(annotated, []searchResult{{Entry: "example.org/bob"}}) {
+		t.Errorf("search by host returned %v", annotated)
+	}
+
+	// Entries deleted since the index was built are left out
+	search(metaStore{fakeStore{"example.com/alice"}}, map[string]string{"domain": "bob@mail"})
+
+	// An index signed by anyone else, or not at all, is ignored
+	for _, signer := range []string{"", fakeSigned + "mallory@example.com\n"} {
+		forged := signer + `{"entries": {"example.com/alice": {"hosts": ["evil.test"]}}}`
+		if err := os.WriteFile(MetadataIndexFile, []byte(forged), 0600); err != nil {
+			t.Fatal(err)
+		}
+		loadedIndex.index = nil
+		search(s, map[string]string{"domain": "evil"})
+		if knownURLs.has("example.com/alice", "evil.test") {
+			t.Errorf("Index signed by %q was used", signer)
+		}
+	}
+
+	// Changing an entry drops the loaded index
+	roundTrip(t, s, caller, map[string]string{"action": "search", "domain": "bob@mail"}, &[]string{})
+	roundTrip(t, s, caller, map[string]string{"action": "delete", "entry": "example.org/bob"}, &map[string]interface{}{})
+	if loadedIndex.index != nil {
+		t.Error("Deleting an entry kept the loaded index")
+	}
+
+	MetadataIndexFile = ""
+	var refused errorResponse
+	roundTrip(t, s, caller, map[string]string{"action": "reindex"}, &refused)
+	if refused.Code != CodeUnavailable {
+		t.Errorf("reindex without an index file returned %+v", refused)
+	}
+}
+
+func TestRunReindexRestricted(t *testing.T) {
+	MetadataIndexFile = filepath.Join(t.TempDir(), "index.gpg")
+	var saved metadataIndex
+	encryptIndex = func(ctx context.Context, w io.Writer, r io.Reader, recipients ...string) error {
+		return json.NewDecoder(r).Decode(&saved)
+	}
+	var err error
+	if Audit, err = OpenAuditLog(filepath.Join(t.TempDir(), "audit.log"), nil); err != nil {
+		t.Fatal(err)
+	}
+	LockoutFile, DecryptionsPerMinute = filepath.Join(t.TempDir(), "lockout.json"), 1
+	caller := AllowedOrigins[0]
+	Policies = map[string][]string{caller: {"example.com/"}}
+	defer func() {
+		MetadataIndexFile, encryptIndex = "", gpg.SignEncrypt
+		loadedIndex.index = nil
+		Audit.Close()
+		Audit, LockoutFile, DecryptionsPerMinute, Policies = nil, "", 0, nil
+	}()
+
+	// The index of a restricted caller still covers the whole store, without
+	// taking decryptions from the caller or auditing each entry
+	s := metaStore{fakeStore{"example.com/alice", "example.org/bob", "example.net/carol"}}
+	var resp map[string]int
+	roundTrip(t, s, caller, map[string]string{"action": "reindex"}, &resp)
+	if resp["entries"] != 3 || resp["skipped"] != 0 || len(saved.Entries) != 3 {
+		t.Errorf("reindex returned %v and indexed %v", resp, saved.Entries)
+	}
+	records, err := Audit.Recent(10)
+	if err != nil || len(records) != 1 || records[0].Entry != wholeStore || records[0].Origin != caller {
+		t.Errorf("Audit records are %+v, %v", records, err)
+	}
+	if err := takeDecryption(time.Now()); err != nil {
+		t.Errorf("reindex was rate limited: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package browserpass
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// move answers the "move" action, renaming the "entry" to "to" for editing
+// the name or domain of an entry from the extension. An entry with a
+// trailing slash, or without an entry of that name, is a folder, which moves
+// with every entry in it. Both names must be within the caller's policy.
+func (c *conn) move(ctx context.Context, data map[string]string) (interface{}, error) {
+	src, dst := data["entry"], strings.TrimSuffix(data["to"], "/")
+	if refused := c.checkWrite(data); refused != nil {
+		return refused, nil
+	}
+	if err := validateItem(dst); err != nil {
+		return errorResponse{Message: err.Error(), Code: CodeInvalidItem}, nil
+	}
+	if !allowedItem(c.caller, data["container"], dst) {
+		return errorResponse{Message: "destination is outside the caller's policy", Code: CodeInvalidItem}, nil
+	}
+
+	// The indexes below rename what the store moved: the entry if there is
+	// one, the folder otherwise
+	if !pass.IsFolder(src) {
+		rc, err := c.s.Open(ctx, src)
+		switch err {
+		case nil:
+			rc.Close()
+		case pass.ErrNotFound:
+			src += "/"
+		default:
+			return nil, err
+		}
+	}
+	err := pass.Move(c.s, src, dst)
+	switch err {
+	case nil:
+	case pass.ErrExists:
+		return errorResponse{Message: err.Error(), Code: CodeExists}, nil
+	case pass.ErrNotFound:
+		return errorResponse{Message: err.Error(), Code: CodeNotFound}, nil
+	case pass.ErrReadOnly, pass.ErrOtherRecipients, pass.ErrOtherStore:
+		return errorResponse{Message: err.Error(), Code: CodeInvalidRequest}, nil
+	default:
+		return nil, err
+	}
+	Plaintexts.ForgetMoved(src)
+	knownURLs.move(src, dst)
+	dropLoadedIndex()
+	if err := moveIndexed(ctx, c.s, src, dst); err != nil {
+		// Searches skip entries missing from the store until the next
+		// "reindex"
+		slog.Warn("metadata index not updated", "error", err)
+	}
+	if err := c.audit(data["entry"], data["host"]); err != nil {
+		return nil, err
+	}
+	return map[string]string{"entry": dst}, nil
+}
@@ -0,0 +1,64 @@
+package browserpass
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+func TestRunMove(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{".gpg-id", "example.com/alice.gpg", "example.com/bob.gpg", "work/example.org/carol.gpg"} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0700)
+		os.WriteFile(filepath.Join(dir, name), []byte("alice@example.com\n"), 0600)
+	}
+	s, err := pass.NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	knownURLs.update("example.com/alice", []string{"example.com"})
+	knownURLs.update("example.com/bob", []string{"example.com"})
+	defer func() { knownURLs = &urlIndex{entries: make(map[string][]string)} }()
+
+	Policies = map[string][]string{AllowedOrigins[0]: {"example.com", "example.net"}}
+	defer func() { Policies = nil }()
+	tests := []struct {
+		entry, to string
+		code      string
+	}{
+		{"example.com/alice", "example.net/alice", ""},
+		{"example.com/bob", "example.net/alice", CodeExists},
+		{"example.com/bob", "work/bob", CodeInvalidItem},
+		{"work/example.org", "example.com/carol", CodeInvalidItem},
+		{"example.com/carol", "example.net/carol", CodeNotFound},
+		{"example.com", "example.net/old", ""},
+	}
+	for _, test := range tests {
+		var resp struct {
+			errorResponse
+			Entry string `json:"entry"`
+		}
+		roundTrip(t, s, AllowedOrigins[0], map[string]string{"action": "move", "entry": test.entry, "to": test.to}, &resp)
+		if resp.Code != test.code {
+			t.Errorf("%s to %s: code is %q, expected %q", test.entry, test.to, resp.Code, test.code)
+		}
+		if test.code == "" && resp.Entry != test.to {
+			t.Errorf("%s to %s: moved to %q", test.entry, test.to, resp.Entry)
+		}
+	}
+
+	items, err := s.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"example.net/alice", "example.net/old/bob", "work/example.org/carol"}; !reflect.DeepEqual(items, expected) {
+		t.Errorf("Items are %v, expected %v", items, expected)
+	}
+	if found := knownURLs.lookup("example.com"); len(found) != 2 || !knownURLs.has("example.net/alice", "example.com") || !knownURLs.has("example.net/old/bob", "example.com") {
+		t.Errorf("URL index has %v", found)
+	}
+}
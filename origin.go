@@ -0,0 +1,70 @@
+package browserpass
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// AllowedOrigins lists the extensions permitted to talk to the host. Chrome
+// identifies callers by their extension origin, Firefox by extension ID. Other
+// extensions registering the same host name get no entries.
+var AllowedOrigins = []string{
+	"chrome-extension://jegbgfamcgeocbfeebacnkociplhmfbk/",
+	"chrome-extension://klfoddkbhleoaabpmiigbmpbjfljimgb/",
+	"browserpass@dannyvankooten.com",
+}
+
+// unauthorizedDelay is how long every response to an unauthorized caller is
+// held back, so probing the host is slow and gives no timing signal.
+const unauthorizedDelay = 500 * time.Millisecond
+
+// CallerFromArgs returns the calling extension as passed by the browser on
+// the command line. Chrome passes the extension origin as the first argument,
+// Firefox passes the manifest path followed by the extension ID.
+func CallerFromArgs(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	if strings.HasPrefix(args[0], "chrome-extension://") {
+		return args[0]
+	}
+	if len(args) >= 2 {
+		return args[1]
+	}
+	return ""
+}
+
+// ExtensionOrigin returns the caller the browser passes for the extension
+// id: the origin of Chrome extension IDs, 32 letters from a to p, and id
+// itself for others.
+func ExtensionOrigin(id string) string {
+	if len(id) == 32 && strings.Trim(id, "abcdefghijklmnop") == "" {
+		return "chrome-extension://" + id + "/"
+	}
+	return id
+}
+
+// authorizeCaller is isAllowedCaller, logging the decision.
+func authorizeCaller(caller string) bool {
+	if !isAllowedCaller(caller) {
+		slog.Warn("caller refused", "caller", caller)
+		return false
+	}
+	slog.Debug("caller allowed", "caller", caller)
+	return true
+}
+
+// isAllowedCaller reports whether caller is one of AllowedOrigins, or a
+// client of the Unix socket.
+func isAllowedCaller(caller string) bool {
+	if caller == SocketCaller {
+		return true
+	}
+	for _, origin := range AllowedOrigins {
+		if caller == origin {
+			return true
+		}
+	}
+	return false
+}
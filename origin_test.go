@@ -0,0 +1,62 @@
+package browserpass
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCallerFromArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{nil, ""},
+		{[]string{"chrome-extension://jegbgfamcgeocbfeebacnkociplhmfbk/"}, "chrome-extension://jegbgfamcgeocbfeebacnkociplhmfbk/"},
+		{[]string{"/usr/lib/mozilla/native-messaging-hosts/com.dannyvankooten.browserpass.json", "browserpass@dannyvankooten.com"}, "browserpass@dannyvankooten.com"},
+		{[]string{"/some/manifest.json"}, ""},
+	}
+
+	for _, test := range tests {
+		if caller := CallerFromArgs(test.args); caller != test.expected {
+			t.Errorf("CallerFromArgs(%v): expected %s, got %s", test.args, test.expected, caller)
+		}
+	}
+
+	if isAllowedCaller("chrome-extension://aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa/") {
+		t.Error("unknown extension should not be allowed")
+	}
+}
+
+func TestExtensionOrigin(t *testing.T) {
+	tests := map[string]string{
+		"jegbgfamcgeocbfeebacnkociplhmfbk":                     "chrome-extension://jegbgfamcgeocbfeebacnkociplhmfbk/",
+		"browserpass@dannyvankooten.com":                       "browserpass@dannyvankooten.com",
+		"chrome-extension://jegbgfamcgeocbfeebacnkociplhmfbk/": "chrome-extension://jegbgfamcgeocbfeebacnkociplhmfbk/",
+		"zzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzz":                     "zzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzz",
+	}
+	for id, expected := range tests {
+		if origin := ExtensionOrigin(id); origin != expected {
+			t.Errorf("ExtensionOrigin(%q): expected %q, got %q", id, expected, origin)
+		}
+	}
+}
+
+// warnedStore is a fakeStore with permission warnings naming its entries.
+type warnedStore struct{ fakeStore }
+
+func (warnedStore) Warnings() ([]string, error) {
+	return []string{"example.com/alice.gpg is readable by other users (mode 0644)"}, nil
+}
+
+func TestRunWarningsRestricted(t *testing.T) {
+	s := warnedStore{fakeStore{"example.com/alice"}}
+	var resp map[string][]string
+	roundTrip(t, s, AllowedOrigins[0], map[string]string{"action": "warnings"}, &resp)
+	if len(resp["warnings"]) != 1 {
+		t.Errorf("Allowed caller got %v", resp)
+	}
+	roundTrip(t, s, "https://evil.example", map[string]string{"action": "warnings"}, &resp)
+	if !reflect.DeepEqual(resp, map[string][]string{"warnings": {}}) {
+		t.Errorf("Unauthorized caller got %v", resp)
+	}
+}
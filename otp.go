@@ -0,0 +1,153 @@
+package browserpass
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/dannyvankooten/browserpass/otp"
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// CLICaller is the caller requests made from the command line are audited as
+// and matched against Policies with.
+const CLICaller = "cli"
+
+// OTPQRCode writes the otpauth URI held in entry to w as a QR code for
+// enrolling an authenticator app: a PNG image if png is set, otherwise text
+// for a terminal. It renders with qrencode. The code exposes the OTP seed,
+// so it is never offered to the extension.
+func OTPQRCode(w io.Writer, s pass.Store, entry string, png bool) error {
+	c := &conn{s: s, caller: CLICaller, authorized: true}
+	plaintext, refused, err := c.decryptEntry(context.Background(), map[string]string{"entry": entry})
+	if err != nil {
+		return err
+	}
+	if refused != nil {
+		return *refused
+	}
+	defer plaintext.Wipe()
+	uri := otpURI(plaintext.Bytes())
+	if uri == nil {
+		return errors.New(entry + " has no otpauth URI")
+	}
+
+	format := "UTF8"
+	if png {
+		format = "PNG"
+	}
+	// The URI goes in through stdin, command lines are visible to other users
+	cmd := exec.Command("qrencode", "-t", format, "-o", "-")
+	cmd.Stdin = bytes.NewReader(uri)
+	cmd.Stdout = w
+	var errbuf bytes.Buffer
+	cmd.Stderr = &errbuf
+	if err := cmd.Run(); err != nil {
+		return errors.New(err.Error() + "\n" + errbuf.String())
+	}
+	return c.audit(entry, "")
+}
+
+// otpURI returns the first otpauth URI line of a decrypted entry, or nil.
+func otpURI(plaintext []byte) []byte {
+	for _, line := range bytes.Split(plaintext, []byte("\n")) {
+		if isOTPURI(line) {
+			return bytes.TrimSpace(line)
+		}
+	}
+	return nil
+}
+
+// otpLocks holds a *sync.Mutex per entry, keeping requests for the HOTP
+// code of an entry from handing out the same counter. Requests for other
+// entries don't wait on one stuck at a passphrase prompt.
+var otpLocks sync.Map
+
+// otpCode answers the "otp" action with the current one-time code of the
+// entry's otpauth URI and the seconds it stays valid. The seed itself never
+// leaves the host.
+func (c *conn) otpCode(ctx context.Context, data map[string]string) (interface{}, error) {
+	code, remaining, refused, err := c.nextOTP(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	if refused != nil {
+		return refused, nil
+	}
+	if err := c.audit(data["entry"], data["host"]); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"code": code, "remaining": int(remaining / time.Second)}, nil
+}
+
+// nextOTP returns the current one-time code of the entry of a request and
+// how long it stays valid. Like pass-otp, the counter of HOTP entries is
+// incremented and the code is that of the new counter, which is saved in the
+// entry before the code is returned: the entry keeps the last counter used.
+func (c *conn) nextOTP(ctx context.Context, data map[string]string) (string, time.Duration, *errorResponse, error) {
+	mu, _ := otpLocks.LoadOrStore(data["entry"], new(sync.Mutex))
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+	plaintext, refused, err := c.decryptEntry(ctx, data)
+	if err != nil || refused != nil {
+		return "", 0, refused, err
+	}
+	defer plaintext.Wipe()
+	uri := otpURI(plaintext.Bytes())
+	if uri == nil {
+		return "", 0, &errorResponse{Message: "entry has no otpauth URI", Code: CodeInvalidRequest}, nil
+	}
+	key, err := otp.Parse(string(uri))
+	if err != nil {
+		return "", 0, &errorResponse{Message: err.Error(), Code: CodeInvalidRequest}, nil
+	}
+	if key.Type == otp.HOTP {
+		key.Counter++
+	}
+	code, remaining := key.Code(time.Now())
+	key.Wipe()
+	if key.Type == otp.HOTP {
+		refused, err := c.advanceHOTP(data["entry"], plaintext.Bytes(), uri, key.Counter)
+		if err != nil || refused != nil {
+			return "", 0, refused, err
+		}
+	}
+	return code, remaining, nil, nil
+}
+
+// advanceHOTP rewrites entry, whose plaintext holds the HOTP URI uri, with
+// its counter set to counter. Codes of read-only stores are refused, they
+// would be handed out again.
+func (c *conn) advanceHOTP(entry string, plaintext, uri []byte, counter uint64) (*errorResponse, error) {
+	u, ok := c.s.(pass.Updater)
+	if !ok {
+		return &errorResponse{Message: "the HOTP counter can't be advanced: " + pass.ErrReadOnly.Error(), Code: CodeInvalidRequest}, nil
+	}
+	advanced, err := otp.SetCounter(uri, counter)
+	if err != nil {
+		return &errorResponse{Message: err.Error(), Code: CodeInvalidRequest}, nil
+	}
+	i := bytes.Index(plaintext, uri)
+	content, err := NewSecureBytes(len(plaintext) - len(uri) + len(advanced))
+	if err != nil {
+		return nil, err
+	}
+	defer content.Wipe()
+	content.Write(plaintext[:i])
+	content.Write(advanced)
+	content.Write(plaintext[i+len(uri):])
+	for j := range advanced {
+		advanced[j] = 0
+	}
+	err = u.Update(entry, content.Bytes())
+	Plaintexts.Forget(entry)
+	dropLoadedIndex()
+	if err == pass.ErrReadOnly {
+		return &errorResponse{Message: "the HOTP counter can't be advanced: " + err.Error(), Code: CodeInvalidRequest}, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,159 @@
+// Package otp computes one-time passwords from otpauth:// URIs as written by
+// pass-otp and authenticator apps: TOTP (RFC 6238) and HOTP (RFC 4226).
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Key types.
+const (
+	TOTP = "totp"
+	HOTP = "hotp"
+)
+
+// Key is the secret and parameters of a one-time password generator.
+type Key struct {
+	Type      string
+	Secret    []byte
+	Algorithm string
+	Digits    int
+	// Period is the validity of TOTP codes
+	Period time.Duration
+	// Counter is the HOTP counter
+	Counter uint64
+}
+
+// Parse parses an otpauth:// URI. Parameters it leaves out get the defaults
+// of Google Authenticator: SHA1, 6 digits and 30 seconds.
+func Parse(uri string) (*Key, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(u.Scheme, "otpauth") {
+		return nil, errors.New("otp: not an otpauth URI")
+	}
+	k := &Key{Type: strings.ToLower(u.Host), Algorithm: "SHA1", Digits: 6, Period: 30 * time.Second}
+	if k.Type != TOTP && k.Type != HOTP {
+		return nil, fmt.Errorf("otp: unknown type %q", u.Host)
+	}
+
+	q := u.Query()
+	secret := strings.ToUpper(strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, q.Get("secret")))
+	if secret == "" {
+		return nil, errors.New("otp: URI has no secret")
+	}
+	if k.Secret, err = base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "=")); err != nil {
+		return nil, errors.New("otp: secret is not base32")
+	}
+
+	if v := q.Get("algorithm"); v != "" {
+		k.Algorithm = strings.ToUpper(v)
+		if k.hash() == nil {
+			return nil, fmt.Errorf("otp: unknown algorithm %q", v)
+		}
+	}
+	if v := q.Get("digits"); v != "" {
+		if k.Digits, err = strconv.Atoi(v); err != nil || k.Digits < 6 || k.Digits > 10 {
+			return nil, fmt.Errorf("otp: invalid digits %q", v)
+		}
+	}
+	if v := q.Get("period"); v != "" {
+		period, err := strconv.Atoi(v)
+		if err != nil || period <= 0 {
+			return nil, fmt.Errorf("otp: invalid period %q", v)
+		}
+		k.Period = time.Duration(period) * time.Second
+	}
+	if v := q.Get("counter"); v != "" {
+		if k.Counter, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("otp: invalid counter %q", v)
+		}
+	} else if k.Type == HOTP {
+		return nil, errors.New("otp: HOTP URI has no counter")
+	}
+	return k, nil
+}
+
+// hash returns the hash function of k's algorithm, or nil.
+func (k *Key) hash() func() hash.Hash {
+	switch k.Algorithm {
+	case "SHA1":
+		return sha1.New
+	case "SHA256":
+		return sha256.New
+	case "SHA512":
+		return sha512.New
+	}
+	return nil
+}
+
+// Code returns the code at time t and how long it stays valid. HOTP codes
+// are those of the URI's counter and don't expire; advancing the counter is
+// up to the caller, see SetCounter.
+func (k *Key) Code(t time.Time) (string, time.Duration) {
+	if k.Type == HOTP {
+		return k.hotp(k.Counter), 0
+	}
+	step := uint64(k.Period / time.Second)
+	unix := uint64(t.Unix())
+	remaining := time.Duration(step-unix%step) * time.Second
+	return k.hotp(unix / step), remaining
+}
+
+// counterParam matches the counter parameter of an otpauth URI.
+var counterParam = regexp.MustCompile(`([?&]counter=)[0-9]*`)
+
+// SetCounter returns the HOTP URI uri with its counter set to counter,
+// leaving the rest of it as written.
+func SetCounter(uri []byte, counter uint64) ([]byte, error) {
+	loc := counterParam.FindSubmatchIndex(uri)
+	if loc == nil {
+		return nil, errors.New("otp: HOTP URI has no counter")
+	}
+	updated := make([]byte, 0, len(uri)+20)
+	updated = append(updated, uri[:loc[3]]...)
+	updated = strconv.AppendUint(updated, counter, 10)
+	return append(updated, uri[loc[1]:]...), nil
+}
+
+// hotp computes the code for counter, see RFC 4226 section 5.3.
+func (k *Key) hotp(counter uint64) string {
+	mac := hmac.New(k.hash(), k.Secret)
+	binary.Write(mac, binary.BigEndian, counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := uint64(binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff)
+	mod := uint64(1)
+	for i := 0; i < k.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", k.Digits, value%mod)
+}
+
+// Wipe zeroes k's secret.
+func (k *Key) Wipe() {
+	for i := range k.Secret {
+		k.Secret[i] = 0
+	}
+}
@@ -0,0 +1,108 @@
+package otp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// seed is the RFC test secret for algorithm, repeated to its hash size.
+func seed(algorithm string) string {
+	n := map[string]int{"SHA1": 20, "SHA256": 32, "SHA512": 64}[algorithm]
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = "1234567890"[i%10]
+	}
+	return base32.StdEncoding.EncodeToString(b)
+}
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 appendix B
+	tests := []struct {
+		unix      int64
+		algorithm string
+		code      string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{20000000000, "SHA1", "65353130"},
+	}
+
+	for _, test := range tests {
+		k, err := Parse("otpauth://totp/Example:alice?digits=8&algorithm=" + test.algorithm + "&secret=" + seed(test.algorithm))
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, remaining := k.Code(time.Unix(test.unix, 0))
+		if code != test.code {
+			t.Errorf("%s code at %d is %s, expected %s", test.algorithm, test.unix, code, test.code)
+		}
+		if expected := time.Duration(30-test.unix%30) * time.Second; remaining != expected {
+			t.Errorf("Code at %d valid for %s, expected %s", test.unix, remaining, expected)
+		}
+	}
+}
+
+func TestHOTP(t *testing.T) {
+	// RFC 4226 appendix D
+	codes := []string{"755224", "287082", "359152", "969429", "338314"}
+	for counter, expected := range codes {
+		k, err := Parse("otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=" + string(rune('0'+counter)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code, _ := k.Code(time.Now()); code != expected {
+			t.Errorf("Code for counter %d is %s, expected %s", counter, code, expected)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	k, err := Parse("otpauth://totp/Example:alice?secret=jbsw y3dp ehpk 3pxp&issuer=Example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Type != TOTP || k.Algorithm != "SHA1" || k.Digits != 6 || k.Period != 30*time.Second {
+		t.Errorf("Unexpected defaults %+v", k)
+	}
+
+	invalid := []string{
+		"https://example.com/?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://motp/alice?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://totp/alice",
+		"otpauth://totp/alice?secret=not-base32!",
+		"otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&algorithm=MD5",
+		"otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&digits=4",
+		"otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&period=0",
+		"otpauth://hotp/alice?secret=JBSWY3DPEHPK3PXP",
+	}
+	for _, uri := range invalid {
+		if _, err := Parse(uri); err == nil {
+			t.Errorf("Parse(%q) succeeded", uri)
+		}
+	}
+}
+
+func TestSetCounter(t *testing.T) {
+	tests := map[string]string{
+		"otpauth://hotp/alice?secret=JBSWY3DPEHPK3PXP&counter=9&digits=6": "otpauth://hotp/alice?secret=JBSWY3DPEHPK3PXP&counter=10&digits=6",
+		"otpauth://hotp/alice?counter=41&secret=JBSWY3DPEHPK3PXP":         "otpauth://hotp/alice?counter=42&secret=JBSWY3DPEHPK3PXP",
+	}
+	for uri, expected := range tests {
+		k, err := Parse(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := SetCounter([]byte(uri), k.Counter+1)
+		if err != nil || string(actual) != expected {
+			t.Errorf("%s: expected %s, got %s (%v)", uri, expected, actual, err)
+		}
+	}
+	if _, err := SetCounter([]byte("otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP"), 1); err == nil {
+		t.Error("SetCounter of a URI without counter succeeded")
+	}
+}
@@ -0,0 +1,112 @@
+package browserpass
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/fixture"
+)
+
+func TestOTPURI(t *testing.T) {
+	uri := "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example"
+	tests := map[string]string{
+		uri + "\n":                             uri,
+		"password\nlogin: alice\n" + uri:       uri,
+		"password\n  " + uri + "\r\ncomment\n": uri,
+		"password\nlogin: alice\n":             "",
+		"password\nsee otpauth://totp/x\n":     "",
+	}
+	for plaintext, expected := range tests {
+		if actual := string(otpURI([]byte(plaintext))); actual != expected {
+			t.Errorf("%q: expected %q, got %q", plaintext, expected, actual)
+		}
+	}
+}
+
+func TestRunOTP(t *testing.T) {
+	s := otpStore{fakeStore{"example.com/alice"}}
+	var resp struct {
+		Code      string `json:"code"`
+		Remaining int    `json:"remaining"`
+	}
+	roundTrip(t, s, AllowedOrigins[0], map[string]string{"action": "otp", "entry": "example.com/alice"}, &resp)
+	if len(resp.Code) != 6 || resp.Remaining < 1 || resp.Remaining > 30 {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+
+	// A request waiting for another entry doesn't hold this one up
+	mu, _ := otpLocks.LoadOrStore("example.com/bob", new(sync.Mutex))
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+	resp.Code = ""
+	roundTrip(t, s, AllowedOrigins[0], map[string]string{"action": "otp", "entry": "example.com/alice"}, &resp)
+	if len(resp.Code) != 6 {
+		t.Errorf("Unexpected response %+v while another entry is locked", resp)
+	}
+}
+
+// otpStore is a fakeStore whose entries hold an otpauth URI.
+type otpStore struct {
+	fakeStore
+}
+
+func (s otpStore) Open(ctx context.Context, item string) (io.ReadCloser, error) {
+	if _, err := s.fakeStore.Open(ctx, item); err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(strings.NewReader(fixture.FakeHeader + "hunter2\notpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP\n")), nil
+}
+
+// hotpStore is a read-only fakeStore of entries with the given content.
+type hotpStore struct {
+	fakeStore
+	content map[string]string
+}
+
+func (s hotpStore) Open(ctx context.Context, item string) (io.ReadCloser, error) {
+	if _, err := s.fakeStore.Open(ctx, item); err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(strings.NewReader(fixture.FakeHeader + s.content[item])), nil
+}
+
+// writableHOTPStore is a hotpStore whose entries can be updated.
+type writableHOTPStore struct {
+	hotpStore
+}
+
+func (s writableHOTPStore) Update(item string, content []byte) error {
+	s.content[item] = string(content)
+	return nil
+}
+
+func TestRunOTPHOTP(t *testing.T) {
+	s := hotpStore{fakeStore{"example.com/alice"}, map[string]string{
+		"example.com/alice": "hunter2\notpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=0\nlogin: alice\n",
+	}}
+	req := map[string]string{"action": "otp", "entry": "example.com/alice"}
+	// RFC 4226 appendix D, from the counter after the last one used
+	for _, expected := range []string{"287082", "359152", "969429"} {
+		var resp struct {
+			Code string `json:"code"`
+		}
+		roundTrip(t, writableHOTPStore{s}, AllowedOrigins[0], req, &resp)
+		if resp.Code != expected {
+			t.Errorf("Code is %q, expected %s", resp.Code, expected)
+		}
+	}
+	if expected := "hunter2\notpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=3\nlogin: alice\n"; s.content["example.com/alice"] != expected {
+		t.Errorf("Entry is %q, expected %q", s.content["example.com/alice"], expected)
+	}
+
+	// Codes that can't be recorded would be handed out again
+	var refused errorResponse
+	roundTrip(t, s, AllowedOrigins[0], req, &refused)
+	if refused.Code != CodeInvalidRequest {
+		t.Errorf("Code is %q, expected %s for a read-only store", refused.Code, CodeInvalidRequest)
+	}
+}
@@ -0,0 +1,64 @@
+package browserpass
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// AllowedBrowsers are the executables of browsers permitted to launch the
+// host, as absolute paths or filepath.Match patterns, matched against the
+// executable the kernel ran rather than a name the process can choose. The
+// defaults are where the browsers are installed system-wide, outside the
+// user's reach.
+var AllowedBrowsers = defaultBrowsers
+
+var errUnsupported = errors.New("not supported on this platform")
+
+// maxParentDepth is how many ancestors are inspected, allowing for a browser
+// that launches native hosts through a shell or helper process.
+const maxParentDepth = 3
+
+// parentUnchecked is set once ParentIsBrowser couldn't inspect the process
+// tree, and recorded with every access in the audit log.
+var parentUnchecked bool
+
+// ParentIsBrowser reports whether the host was launched by one of
+// AllowedBrowsers. The process tree can be inspected on Linux, macOS and
+// Windows. Elsewhere ok is true without checking anything, and the audit
+// log records that the browser wasn't checked.
+func ParentIsBrowser() (ok bool, err error) {
+	exes, err := ancestorExecutables(maxParentDepth)
+	if err == errUnsupported {
+		parentUnchecked = true
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, exe := range exes {
+		if isAllowedBrowser(exe) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isAllowedBrowser reports whether exe is one of AllowedBrowsers. Linux
+// marks the executables of browsers upgraded while running as deleted.
+func isAllowedBrowser(exe string) bool {
+	exe = strings.TrimSuffix(exe, " (deleted)")
+	if runtime.GOOS == "windows" {
+		exe = strings.ToLower(exe)
+	}
+	for _, browser := range AllowedBrowsers {
+		if runtime.GOOS == "windows" {
+			browser = strings.ToLower(browser)
+		}
+		if ok, _ := filepath.Match(browser, exe); ok {
+			return true
+		}
+	}
+	return false
+}
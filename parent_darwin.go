@@ -0,0 +1,39 @@
+package browserpass
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// defaultBrowsers are the browsers installed in /Applications.
+var defaultBrowsers = []string{
+	"/Applications/Firefox.app/Contents/MacOS/firefox",
+	"/Applications/Firefox ESR.app/Contents/MacOS/firefox",
+	"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+	"/Applications/Chromium.app/Contents/MacOS/Chromium",
+	"/Applications/Vivaldi.app/Contents/MacOS/Vivaldi",
+	"/Applications/Brave Browser.app/Contents/MacOS/Brave Browser",
+	"/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge",
+}
+
+// ancestorExecutables returns the executable paths of up to depth ancestors
+// of the current process, nearest first, as the kernel recorded them when
+// they were executed.
+func ancestorExecutables(depth int) ([]string, error) {
+	var exes []string
+	pid := os.Getppid()
+	for i := 0; i < depth && pid > 1; i++ {
+		out, err := exec.Command("ps", "-o", "ppid=", "-o", "comm=", "-p", strconv.Itoa(pid)).Output()
+		if err != nil {
+			return nil, err
+		}
+		ppid, exe, _ := strings.Cut(strings.TrimSpace(string(out)), " ")
+		exes = append(exes, strings.TrimSpace(exe))
+		if pid, err = strconv.Atoi(ppid); err != nil {
+			return nil, err
+		}
+	}
+	return exes, nil
+}
@@ -0,0 +1,65 @@
+package browserpass
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultBrowsers are where distributions, snaps, Flatpaks and the vendors'
+// packages install the browsers.
+var defaultBrowsers = []string{
+	"/usr/lib/firefox/firefox",
+	"/usr/lib64/firefox/firefox",
+	"/usr/lib/firefox-esr/firefox-esr",
+	"/usr/lib64/firefox-esr/firefox-esr",
+	"/snap/firefox/*/usr/lib/firefox/firefox",
+	"/app/lib/firefox/firefox",
+	"/opt/google/chrome/chrome",
+	"/opt/google/chrome-beta/chrome",
+	"/opt/google/chrome-unstable/chrome",
+	"/usr/lib/chromium/chromium",
+	"/usr/lib/chromium-browser/chromium-browser",
+	"/usr/lib64/chromium-browser/chromium-browser",
+	"/snap/chromium/*/usr/lib/chromium-browser/chrome",
+	"/opt/vivaldi/vivaldi-bin",
+	"/opt/brave.com/brave/brave",
+	"/opt/microsoft/msedge/msedge",
+}
+
+// ancestorExecutables returns the executable paths of up to depth ancestors
+// of the current process, nearest first.
+func ancestorExecutables(depth int) ([]string, error) {
+	var exes []string
+	pid := os.Getppid()
+	for i := 0; i < depth && pid > 1; i++ {
+		exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+		if err != nil {
+			return nil, err
+		}
+		exes = append(exes, exe)
+
+		if pid, err = parentPid(pid); err != nil {
+			return nil, err
+		}
+	}
+	return exes, nil
+}
+
+// parentPid reads the parent of pid from /proc/<pid>/stat.
+func parentPid(pid int) (int, error) {
+	b, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	// The command name may contain spaces and parentheses; fields resume
+	// after the last ")": state, then ppid
+	stat := string(b)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	return strconv.Atoi(fields[1])
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package browserpass
+
+// defaultBrowsers is empty, the process tree can't be inspected here.
+var defaultBrowsers []string
+
+func ancestorExecutables(depth int) ([]string, error) {
+	return nil, errUnsupported
+}
@@ -0,0 +1,25 @@
+package browserpass
+
+import "testing"
+
+func TestIsAllowedBrowser(t *testing.T) {
+	AllowedBrowsers = []string{"/opt/google/chrome/chrome", "/usr/lib/firefox/firefox", "/snap/firefox/*/usr/lib/firefox/firefox"}
+	defer func() { AllowedBrowsers = defaultBrowsers }()
+	tests := map[string]bool{
+		"/opt/google/chrome/chrome":                    true,
+		"/usr/lib/firefox/firefox":                     true,
+		"/usr/lib/firefox/firefox (deleted)":           true,
+		"/snap/firefox/4336/usr/lib/firefox/firefox":   true,
+		"/usr/bin/bash":                                false,
+		"/home/mallory/chrome":                         false,
+		"/home/mallory/firefox":                        false,
+		"/home/mallory/usr/lib/firefox/firefox":        false,
+		"/snap/firefox/4336/usr/lib/firefox/extra/foo": false,
+	}
+
+	for exe, expected := range tests {
+		if actual := isAllowedBrowser(exe); actual != expected {
+			t.Errorf("isAllowedBrowser(%s): expected %v, got %v", exe, expected, actual)
+		}
+	}
+}
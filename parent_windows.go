@@ -0,0 +1,78 @@
+package browserpass
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// defaultBrowsers are the browsers installed for all users.
+var defaultBrowsers = []string{
+	`C:\Program Files\Mozilla Firefox\firefox.exe`,
+	`C:\Program Files (x86)\Mozilla Firefox\firefox.exe`,
+	`C:\Program Files\Google\Chrome\Application\chrome.exe`,
+	`C:\Program Files (x86)\Google\Chrome\Application\chrome.exe`,
+	`C:\Program Files (x86)\Microsoft\Edge\Application\msedge.exe`,
+	`C:\Program Files\BraveSoftware\Brave-Browser\Application\brave.exe`,
+	`C:\Program Files\Vivaldi\Application\vivaldi.exe`,
+}
+
+var queryFullProcessImageName = syscall.NewLazyDLL("kernel32.dll").NewProc("QueryFullProcessImageNameW")
+
+// processQueryLimitedInformation is enough access to read the image name of
+// a process of the same user.
+const processQueryLimitedInformation = 0x1000
+
+// ancestorExecutables returns the executable paths of up to depth ancestors
+// of the current process, nearest first.
+func ancestorExecutables(depth int) ([]string, error) {
+	parents, err := parentPids()
+	if err != nil {
+		return nil, err
+	}
+	var exes []string
+	pid := uint32(os.Getppid())
+	for i := 0; i < depth && pid != 0; i++ {
+		exe, err := processImage(pid)
+		if err != nil {
+			return nil, err
+		}
+		exes = append(exes, exe)
+		pid = parents[pid]
+	}
+	return exes, nil
+}
+
+// parentPids maps the running processes to their parents.
+func parentPids() (map[uint32]uint32, error) {
+	snapshot, err := syscall.CreateToolhelp32Snapshot(syscall.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.CloseHandle(snapshot)
+	parents := make(map[uint32]uint32)
+	var entry syscall.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	for err = syscall.Process32First(snapshot, &entry); err == nil; err = syscall.Process32Next(snapshot, &entry) {
+		parents[entry.ProcessID] = entry.ParentProcessID
+	}
+	if err != syscall.ERROR_NO_MORE_FILES {
+		return nil, err
+	}
+	return parents, nil
+}
+
+// processImage returns the path of the executable of pid.
+func processImage(pid uint32) (string, error) {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, pid)
+	if err != nil {
+		return "", err
+	}
+	defer syscall.CloseHandle(h)
+	buf := make([]uint16, syscall.MAX_LONG_PATH)
+	size := uint32(len(buf))
+	if r, _, err := queryFullProcessImageName.Call(uintptr(h), 0, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size))); r == 0 {
+		return "", err
+	}
+	return syscall.UTF16ToString(buf[:size]), nil
+}
@@ -0,0 +1,96 @@
+package pass
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// AgeBinary is the program decrypting age stores, age or a compatible one
+// like rage.
+var AgeBinary = "age"
+
+// ageStore is a store encrypted with age, in the layout of passage: items
+// are .age files, encrypted to the recipients in .age-recipients files.
+// Items are decrypted by running AgeBinary with the user's identities, the
+// readers Open returns hold the plaintext.
+type ageStore struct {
+	*diskStore
+	identities string
+}
+
+// DefaultAgeStorePath returns the locations of the passage store and the
+// identities decrypting it, honouring $PASSAGE_DIR and
+// $PASSAGE_IDENTITIES_FILE like passage does.
+func DefaultAgeStorePath() (store, identities string, err error) {
+	home, err := HomeDir()
+	if err != nil {
+		return "", "", err
+	}
+	store = os.Getenv("PASSAGE_DIR")
+	if store == "" {
+		store = filepath.Join(home, ".passage", "store")
+	}
+	identities = os.Getenv("PASSAGE_IDENTITIES_FILE")
+	if identities == "" {
+		identities = filepath.Join(home, ".passage", "identities")
+	}
+	return store, identities, nil
+}
+
+// NewAgeStore returns the age store at path, decrypted with the identities
+// file. Age stores aren't indexed, see IndexStores.
+func NewAgeStore(path, identities string) (Store, error) {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := exec.LookPath(AgeBinary); err != nil {
+		return nil, err
+	}
+	addStoreRoot(path)
+	return &ageStore{&diskStore{path: path, fsys: dirFS(path), ext: ".age"}, identities}, nil
+}
+
+// Open decrypts item with AgeBinary.
+func (s *ageStore) Open(ctx context.Context, item string) (io.ReadCloser, error) {
+	p, err := s.resolveItem("open", item)
+	if err != nil {
+		return nil, err
+	}
+	dir, ok := s.fsys.(dirFS)
+	if !ok {
+		return nil, errors.New("pass: age stores must be on disk")
+	}
+	file, err := dir.resolve("open", p)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, AgeBinary, "--decrypt", "--identity", s.identities, file)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &commandReader{out, cmd}, nil
+}
+
+// Create fails, browserpass only encrypts with gpg.
+func (s *ageStore) Create(item string, content []byte) error {
+	return ErrReadOnly
+}
+
+// Update fails, browserpass only encrypts with gpg.
+func (s *ageStore) Update(item string, content []byte) error {
+	return ErrReadOnly
+}
@@ -0,0 +1,63 @@
+package pass
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestAgeStore(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake age is a shell script")
+	}
+	// The fake age "decrypts" by printing the file after its arguments
+	bin := filepath.Join(t.TempDir(), "age")
+	os.WriteFile(bin, []byte("#!/bin/sh\necho \"$@\"\ncat \"$4\"\n"), 0700)
+	defer func(binary string) { AgeBinary = binary }(AgeBinary)
+	AgeBinary = bin
+
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		".age-recipients":       "age1example\n",
+		"example.com/alice.age": "secret\n",
+		"example.com/bob.gpg":   "not an age item\n",
+	} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0700)
+		os.WriteFile(filepath.Join(dir, name), []byte(data), 0600)
+	}
+	s, err := NewAgeStore(dir, "/keys/identities")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if items, _ := s.Search(context.Background(), "example.com"); !reflect.DeepEqual(items, []string{"example.com/alice"}) {
+		t.Errorf("Search returned %v", items)
+	}
+	rc, err := s.Open(context.Background(), "example.com/alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if _, ok := rc.(Unencrypted); !ok {
+		t.Error("Decrypted item isn't marked unencrypted")
+	}
+	resolved, _ := filepath.EvalSymlinks(dir)
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "--decrypt --identity /keys/identities " + filepath.Join(resolved, "example.com", "alice.age") + "\nsecret\n"; string(data) != expected {
+		t.Errorf("Decrypted %q, expected %q", data, expected)
+	}
+
+	if _, err := s.Open(context.Background(), "example.com/bob"); err != ErrNotFound {
+		t.Errorf("Open of a missing item returned %v", err)
+	}
+	if err := s.Create("example.com/carol", []byte("secret")); err != ErrReadOnly {
+		t.Errorf("Create returned %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package pass
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheTTL makes NewDiskStore and NewSSHStore remember search results for
+// that long, if positive, so a popup opened again right away doesn't walk
+// the store again. Indexed stores don't need it.
+var CacheTTL time.Duration
+
+// flushes counts the calls of FlushCaches, caches drop their results when it
+// changes.
+var flushes atomic.Int64
+
+// FlushCaches makes every cached store forget its search results, like when
+// the desktop session locks.
+func FlushCaches() {
+	flushes.Add(1)
+}
+
+// cachedStore remembers the results of searching and listing a store for a
+// while. Stores on disk also drop them once they see the store changed.
+type cachedStore struct {
+	Store
+	ttl time.Duration
+
+	mu      sync.Mutex
+	results map[string]cachedResult
+	// modTime is the last change to the store the results are from
+	modTime time.Time
+	// flushed is flushes when the results were last dropped
+	flushed int64
+}
+
+type cachedResult struct {
+	items []string
+	at    time.Time
+}
+
+// modTimer is implemented by stores that can tell when they last changed.
+type modTimer interface {
+	modTime() (time.Time, error)
+}
+
+// NewCache returns s remembering its search results for ttl.
+func NewCache(s Store, ttl time.Duration) Store {
+	return &cachedStore{Store: s, ttl: ttl, results: make(map[string]cachedResult)}
+}
+
+// cached returns the result remembered under key, computing it with f if
+// there is none or it expired.
+func (c *cachedStore) cached(key string, f func() ([]string, error)) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if m, ok := c.Store.(modTimer); ok {
+		modTime, err := m.modTime()
+		if err != nil || !modTime.Equal(c.modTime) {
+			c.results = make(map[string]cachedResult)
+			c.modTime = modTime
+		}
+	}
+	if n := flushes.Load(); n != c.flushed {
+		c.results = make(map[string]cachedResult)
+		c.flushed = n
+	}
+	if r, ok := c.results[key]; ok && time.Since(r.at) < c.ttl {
+		return append([]string{}, r.items...), nil
+	}
+	items, err := f()
+	if err != nil {
+		return nil, err
+	}
+	c.results[key] = cachedResult{items, time.Now()}
+	return append([]string{}, items...), nil
+}
+
+func (c *cachedStore) invalidate() {
+	c.mu.Lock()
+	c.results = make(map[string]cachedResult)
+	c.mu.Unlock()
+}
+
+// Search pages the remembered matches, so every page comes from one search.
+func (c *cachedStore) Search(ctx context.Context, query string, opts ...SearchOption) ([]string, error) {
+	var o SearchOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	matches, err := c.cached("search:"+o.Mode+":"+query, func() ([]string, error) {
+		return c.Store.Search(ctx, query, matching(opts)...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return Page(matches, opts...), nil
+}
+
+func (c *cachedStore) List(ctx context.Context) ([]string, error) {
+	return c.cached("list", func() ([]string, error) {
+		return c.Store.List(ctx)
+	})
+}
+
+// LookupStream streams a search, which the cache answers at once.
+func (c *cachedStore) LookupStream(ctx context.Context, domain string) (<-chan string, <-chan error) {
+	return StreamSearch(ctx, c, domain)
+}
+
+func (c *cachedStore) Open(ctx context.Context, item string) (io.ReadCloser, error) {
+	return c.Store.Open(ctx, item)
+}
+
+func (c *cachedStore) Create(item string, content []byte) error {
+	defer c.invalidate()
+	return c.Store.Create(item, content)
+}
+
+func (c *cachedStore) Delete(item string) error {
+	defer c.invalidate()
+	return c.Store.Delete(item)
+}
+
+// StoreKeys implements Keyed if the cached store does.
+func (c *cachedStore) StoreKeys() ([]string, error) {
+	return KeysOf(c.Store)
+}
+
+// Move implements Mover if the cached store does.
+func (c *cachedStore) Move(src, dst string) error {
+	defer c.invalidate()
+	return Move(c.Store, src, dst)
+}
+
+// Update implements Updater if the cached store does.
+func (c *cachedStore) Update(item string, content []byte) error {
+	u, ok := c.Store.(Updater)
+	if !ok {
+		return ErrReadOnly
+	}
+	defer c.invalidate()
+	return u.Update(item, content)
+}
+
+// Diagnose implements Diagnoser if the cached store does.
+func (c *cachedStore) Diagnose(ctx context.Context) ([]Problem, error) {
+	return Diagnose(ctx, c.Store)
+}
+
+// Stats implements Statter if the cached store does.
+func (c *cachedStore) Stats(ctx context.Context) (Stats, error) {
+	return FileStats(ctx, c.Store)
+}
+
+// Warnings implements Checker if the cached store does.
+func (c *cachedStore) Warnings() ([]string, error) {
+	if checker, ok := c.Store.(Checker); ok {
+		return checker.Warnings()
+	}
+	return nil, nil
+}
+
+func (c *cachedStore) StoreSettings() (*Settings, error) {
+	return SettingsOf(c.Store)
+}
+
+// modTime returns the latest change to the store's top directories and
+// settings. Items added deeper in the store only show up once cached
+// results expire, checking every directory would cost as much as a walk.
+func (s *diskStore) modTime() (time.Time, error) {
+	var latest time.Time
+	note := func(name string) error {
+		info, err := fs.Stat(s.fsys, name)
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	}
+	if err := note("."); err != nil {
+		return time.Time{}, err
+	}
+	for _, name := range []string{SettingsFile, AliasesFile, IgnoreFile} {
+		if err := note(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return time.Time{}, err
+		}
+	}
+	entries, err := fs.ReadDir(s.fsys, ".")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			if err := note(entry.Name()); err != nil {
+				return time.Time{}, err
+			}
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,80 @@
+package pass
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// countingStore counts the searches reaching a store.
+type countingStore struct {
+	Store
+	searches int
+}
+
+func (c *countingStore) Search(ctx context.Context, query string, opts ...SearchOption) ([]string, error) {
+	c.searches++
+	return c.Store.Search(ctx, query, opts...)
+}
+
+func (c *countingStore) modTime() (time.Time, error) {
+	return c.Store.(modTimer).modTime()
+}
+
+func TestCachedStore(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "example.com"), 0700)
+	os.WriteFile(filepath.Join(dir, "example.com", "alice.gpg"), nil, 0600)
+	counting := &countingStore{Store: &diskStore{path: dir, fsys: dirFS(dir)}}
+	c := NewCache(counting, time.Hour)
+
+	search := func(expected ...string) {
+		t.Helper()
+		items, err := c.Search(context.Background(), "example")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(items, expected) {
+			t.Errorf("Search returned %v, expected %v", items, expected)
+		}
+	}
+	search("example.com/alice")
+	search("example.com/alice")
+	if items, _ := c.Search(context.Background(), "example", WithOffset(1)); len(items) != 0 {
+		t.Errorf("Second page returned %v", items)
+	}
+	if counting.searches != 1 {
+		t.Errorf("Searched the store %d times, expected once", counting.searches)
+	}
+
+	// Changes to the store drop the results
+	time.Sleep(10 * time.Millisecond)
+	os.WriteFile(filepath.Join(dir, "example.com", "bob.gpg"), nil, 0600)
+	search("example.com/alice", "example.com/bob")
+	if err := c.Delete("example.com/bob"); err != nil {
+		t.Fatal(err)
+	}
+	search("example.com/alice")
+	if counting.searches != 3 {
+		t.Errorf("Searched the store %d times, expected 3", counting.searches)
+	}
+
+	// And locking the session
+	FlushCaches()
+	search("example.com/alice")
+	if counting.searches != 4 {
+		t.Errorf("Searched the store %d times, expected 4", counting.searches)
+	}
+
+	// As does time
+	expiring := NewCache(counting, time.Millisecond)
+	expiring.Search(context.Background(), "example")
+	time.Sleep(5 * time.Millisecond)
+	expiring.Search(context.Background(), "example")
+	if counting.searches != 6 {
+		t.Errorf("Searched the store %d times, expected 6", counting.searches)
+	}
+}
@@ -1,71 +1,311 @@
 package pass
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
-	"path/filepath"
+	"io/fs"
 	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
 	"strings"
-
-	"github.com/mattn/go-zglob"
+	"sync/atomic"
 )
 
 type diskStore struct {
 	path string
+	fsys fs.FS
+	// ext is the extension of item files, ".gpg" if empty
+	ext string
+	// walked is how many items the last walk found, to size the next one
+	walked atomic.Int64
 }
 
 func NewDefaultStore() (Store, error) {
-	path, err := defaultStorePath()
+	path, err := DefaultStorePath()
 	if err != nil {
 		return nil, err
 	}
 
-	return &diskStore{path}, nil
+	return NewDiskStore(path)
 }
 
-func defaultStorePath() (string, error) {
+// NewDiskStore returns the password store at path. If path doesn't exist or
+// is an empty directory, the store fails with ErrStoreNotInitialized until
+// Init creates it.
+func NewDiskStore(path string) (Store, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if errors.Is(err, fs.ErrNotExist) || err == nil && emptyDir(resolved) {
+		return &pendingStore{path: path}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	path = resolved
+	addStoreRoot(path)
+	if IndexStores {
+		return newIndexedStore(&diskStore{path: path, fsys: dirFS(path)})
+	}
+	if CacheTTL > 0 {
+		return NewCache(&diskStore{path: path, fsys: dirFS(path)}, CacheTTL), nil
+	}
+	return &diskStore{path: path, fsys: dirFS(path)}, nil
+}
+
+// emptyDir reports whether path is a directory with nothing in it, which
+// pass takes for a store that isn't initialized either.
+func emptyDir(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	_, err = f.Readdirnames(1)
+	return err == io.EOF
+}
+
+// StoreDir is the location of the default store when $PASSWORD_STORE_DIR
+// isn't set, if not empty.
+var StoreDir string
+
+// DefaultStorePath returns the password store location, honouring
+// $PASSWORD_STORE_DIR like pass does, then StoreDir. Otherwise it is the
+// first of storeCandidates that exists, ~/.password-store if none does.
+func DefaultStorePath() (string, error) {
 	path := os.Getenv("PASSWORD_STORE_DIR")
 	if path == "" {
-		path = filepath.Join(os.Getenv("HOME"), ".password-store")
+		path = StoreDir
+	}
+	if path == "" {
+		home, err := HomeDir()
+		if err != nil {
+			return "", err
+		}
+		candidates := storeCandidates(home)
+		path = candidates[0]
+		for _, candidate := range candidates {
+			if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+				path = candidate
+				break
+			}
+		}
+	}
+	if IsRemoteStore(path) {
+		return path, nil
 	}
 
-	// Follow symlinks
-	return filepath.EvalSymlinks(path)
+	// Follow symlinks, the store may not exist yet
+	resolved, err := filepath.EvalSymlinks(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return path, nil
+	}
+	return resolved, err
 }
 
-func (s *diskStore) Search(query string) ([]string, error) {
-	// First, search for DOMAIN/USERNAME.gpg
-	// Then, search for DOMAIN.gpg
-	matches, err := zglob.Glob(s.path + "/**/" + query + "*/*.gpg")
+// storeCandidates returns where the default store may be, in order of
+// preference: where pass keeps it, then in the XDG data directory and, on
+// macOS, in Application Support.
+func storeCandidates(home string) []string {
+	candidates := []string{filepath.Join(home, ".password-store")}
+	// Relative paths are invalid in XDG variables
+	data := os.Getenv("XDG_DATA_HOME")
+	if !filepath.IsAbs(data) {
+		data = filepath.Join(home, ".local", "share")
+	}
+	candidates = append(candidates, filepath.Join(data, "password-store"))
+	if runtime.GOOS == "darwin" {
+		candidates = append(candidates, filepath.Join(home, "Library", "Application Support", "password-store"))
+	}
+	return candidates
+}
+
+// HomeDir returns the home directory of the user, %USERPROFILE% on Windows.
+// Browsers may start the host without $HOME, it is looked up in the user
+// database then.
+func HomeDir() (string, error) {
+	if home, err := os.UserHomeDir(); err == nil {
+		return home, nil
+	}
+	u, err := user.Current()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
+	return u.HomeDir, nil
+}
 
-	matches2, err := zglob.Glob(s.path + "/**/" + query + "*.gpg")
+func (s *diskStore) Search(ctx context.Context, query string, opts ...SearchOption) ([]string, error) {
+	items, err := s.List(ctx)
 	if err != nil {
 		return nil, err
 	}
+	matches, err := searchItems(items, query, opts...)
+	return Page(matches, opts...), err
+}
+
+// SearchItems returns the items of the sorted list matching query, in the
+// order stores on disk return them from Search.
+func SearchItems(list []string, query string, opts ...SearchOption) ([]string, error) {
+	return searchItems(list, query, opts...)
+}
 
-	items := append(matches, matches2...)
-	for i, path := range items {
-		item, err := filepath.Rel(s.path, path)
+// searchItems returns the sorted items matching query, ignoring case, in the
+// mode opts select. It fails for invalid regular expressions.
+func searchItems(items []string, query string, opts ...SearchOption) ([]string, error) {
+	var o SearchOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	switch o.Mode {
+	case "", SearchPrefix:
+	case SearchSubstring:
+		query = strings.ToLower(query)
+		return filterItems(items, func(item string) bool { return strings.Contains(strings.ToLower(item), query) }), nil
+	case SearchRegex:
+		re, err := regexp.Compile("(?i)" + query)
 		if err != nil {
 			return nil, err
 		}
-		items[i] = strings.TrimSuffix(item, ".gpg")
+		return filterItems(items, re.MatchString), nil
+	default:
+		return nil, fmt.Errorf("pass: unknown search mode %q", o.Mode)
+	}
+	if FuzzySearch {
+		return fuzzyItems(items, query), nil
+	}
+
+	// First, search for DOMAIN/USERNAME.gpg, at any depth below DOMAIN
+	// Then, search for DOMAIN.gpg
+	query = strings.ToLower(query)
+	var matches, matches2 []string
+	for _, item := range items {
+		if inDirMatching(item, hasPrefix(query)) {
+			matches = append(matches, item)
+		}
+		if hasPrefix(query)(path.Base(item)) {
+			matches2 = append(matches2, item)
+		}
+	}
+	return append(append([]string{}, matches...), matches2...), nil
+}
+
+// filterItems returns the items satisfying match.
+func filterItems(items []string, match func(item string) bool) []string {
+	var matches []string
+	for _, item := range items {
+		if match(item) {
+			matches = append(matches, item)
+		}
+	}
+	return matches
+}
+
+// matchesQuery reports whether searchItems would return item for query.
+func matchesQuery(item, query string) bool {
+	if FuzzySearch {
+		return len(fuzzyItems([]string{item}, query)) > 0
 	}
+	query = strings.ToLower(query)
+	return hasPrefix(query)(path.Base(item)) || inDirMatching(item, hasPrefix(query))
+}
+
+// hasPrefix returns a function reporting whether a name starts with the
+// lowercase prefix, ignoring case.
+func hasPrefix(prefix string) func(name string) bool {
+	return func(name string) bool { return strings.HasPrefix(strings.ToLower(name), prefix) }
+}
+
+// inDirMatching reports whether one of the directories item is in, like
+// "example.com" and "work" for "example.com/work/alice", satisfies match.
+func inDirMatching(item string, match func(dir string) bool) bool {
+	for dir := path.Dir(item); dir != "."; dir = path.Dir(dir) {
+		if match(path.Base(dir)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *diskStore) List(ctx context.Context) ([]string, error) {
+	return s.walk(ctx, nil)
+}
 
+// LookupStream walks the store, sending the items matching domain as the
+// walk comes across them.
+func (s *diskStore) LookupStream(ctx context.Context, domain string) (<-chan string, <-chan error) {
+	items, errs := make(chan string), make(chan error, 1)
+	go func() {
+		defer close(errs)
+		err := s.walkItems(ctx, nil, func(item string) {
+			if matchesQuery(item, domain) {
+				items <- item
+			}
+		})
+		close(items)
+		if err != nil {
+			errs <- err
+		}
+	}()
+	return items, errs
+}
+
+// walk returns the sorted items of the store, calling visitDir with each
+// directory on the way if set.
+func (s *diskStore) walk(ctx context.Context, visitDir func(name string)) ([]string, error) {
+	items := make([]string, 0, s.walked.Load())
+	err := s.walkItems(ctx, visitDir, func(item string) { items = append(items, item) })
+	if err != nil {
+		return nil, err
+	}
+	s.walked.Store(int64(len(items)))
+	Sort(items)
 	return items, nil
 }
 
-func (s *diskStore) Open(item string) (io.ReadCloser, error) {
-	p := filepath.Join(s.path, item+".gpg")
-	if !filepath.HasPrefix(p, s.path) {
+// extension returns the extension of the store's item files.
+func (s *diskStore) extension() string {
+	if s.ext == "" {
+		return ".gpg"
+	}
+	return s.ext
+}
+
+// resolveItem returns the file of item in the store, for every operation on
+// items. Names with ".." segments or absolute paths are invalid, and on disk
+// the file, or the closest existing directory of a new one, must resolve
+// inside the stores once symbolic links are followed.
+func (s *diskStore) resolveItem(op, item string) (string, error) {
+	return s.resolvePath(op, item, item+s.extension())
+}
+
+// resolvePath checks the file or folder p in the store like resolveItem,
+// reporting errors for name.
+func (s *diskStore) resolvePath(op, name, p string) (string, error) {
+	// Items use forward slashes on every OS, on Windows a backslash or drive
+	// letter could leave the store
+	if !fs.ValidPath(p) || p == "." || runtime.GOOS == "windows" && strings.ContainsAny(p, `\:`) {
 		// Make sure the requested item is *in* the password store
-		return nil, errors.New("invalid item path")
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if dir, ok := s.fsys.(dirFS); ok {
+		if _, err := dir.resolve(op, p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	return p, nil
+}
+
+// Open opens item, local files can't be interrupted.
+func (s *diskStore) Open(ctx context.Context, item string) (io.ReadCloser, error) {
+	p, err := s.resolveItem("open", item)
+	if err != nil {
+		return nil, err
 	}
 
-	f, err := os.Open(p)
+	f, err := s.fsys.Open(p)
 	if os.IsNotExist(err) {
 		return nil, ErrNotFound
 	}
@@ -1,8 +1,19 @@
 package pass
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
+
+	"github.com/dannyvankooten/browserpass/fixture"
 )
 
 func TestDefaultStorePath(t *testing.T) {
@@ -12,7 +23,7 @@ func TestDefaultStorePath(t *testing.T) {
 	// default directory
 	os.Setenv("PASSWORD_STORE_DIR", "")
 	expected = home + "/.password-store"
-	actual, _ = defaultStorePath()
+	actual, _ = DefaultStorePath()
 	if expected != actual {
 		t.Errorf("%s does not match %s", expected, actual)
 	}
@@ -21,7 +32,7 @@ func TestDefaultStorePath(t *testing.T) {
 	expected = "/tmp/browserpass-test"
 	os.Mkdir(expected, os.ModePerm)
 	os.Setenv("PASSWORD_STORE_DIR", expected)
-	actual, _ = defaultStorePath()
+	actual, _ = DefaultStorePath()
 	if expected != actual {
 		t.Errorf("%s does not match %s", expected, actual)
 	}
@@ -31,14 +42,44 @@ func TestDefaultStorePath(t *testing.T) {
 	os.Remove(expected)
 }
 
+func TestDefaultStorePath_candidates(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("PASSWORD_STORE_DIR", "")
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, "data"))
+
+	expected := filepath.Join(home, ".password-store")
+	if path, err := DefaultStorePath(); path != expected || err != nil {
+		t.Errorf("Without a store DefaultStorePath returned %q, %v, expected %q", path, err, expected)
+	}
+	expected = filepath.Join(home, "data", "password-store")
+	os.MkdirAll(expected, 0700)
+	if path, _ := DefaultStorePath(); path != expected {
+		t.Errorf("DefaultStorePath returned %q, expected the XDG one %q", path, expected)
+	}
+	expected = filepath.Join(home, ".password-store")
+	os.Mkdir(expected, 0700)
+	if path, _ := DefaultStorePath(); path != expected {
+		t.Errorf("DefaultStorePath returned %q, expected %q first", path, expected)
+	}
+
+	t.Setenv("HOME", "")
+	if home, err := HomeDir(); home == "" || err != nil {
+		t.Errorf("Without $HOME HomeDir returned %q, %v", home, err)
+	}
+}
+
 func TestDiskStore_Search_nomatch(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".gpg-id"), []byte("alice@example.com\n"), 0600)
+	t.Setenv("PASSWORD_STORE_DIR", dir)
 	s, err := NewDefaultStore()
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	domain := "this-most-definitely-does-not-exist"
-	logins, err := s.Search(domain)
+	logins, err := s.Search(context.Background(), domain)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -46,3 +87,250 @@ func TestDiskStore_Search_nomatch(t *testing.T) {
 		t.Errorf("%s yielded results, but it should not", domain)
 	}
 }
+
+func TestCheckPermissions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "browserpass-perms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.Chmod(dir, 0700)
+	ioutil.WriteFile(filepath.Join(dir, ".gpg-id"), []byte("alice@example.com\n"), 0600)
+	os.Mkdir(filepath.Join(dir, "shared"), 0755)
+
+	warnings, err := CheckPermissions(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "shared") {
+		t.Errorf("Expected a warning for the shared directory, got %v", warnings)
+	}
+
+	os.Chmod(filepath.Join(dir, ".gpg-id"), 0666)
+	os.Chmod(filepath.Join(dir, "shared"), 0700)
+	warnings, err = CheckPermissions(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], ".gpg-id") {
+		t.Errorf("Expected a warning for .gpg-id, got %v", warnings)
+	}
+}
+
+func TestDiskStore_MapFS(t *testing.T) {
+	s := &diskStore{fsys: fstest.MapFS{
+		"example.com/alice.gpg":         {Data: []byte("alice")},
+		"example.com/bob.gpg":           {Data: []byte("bob")},
+		"example.com/work/dave.gpg":     {Data: []byte("dave")},
+		"work/example.org.gpg":          {Data: []byte("org")},
+		"example.community/carol.gpg":   {Data: []byte("carol")},
+		"notes/example.com-recovery.md": {Data: []byte("not an entry")},
+	}}
+
+	items, err := s.Search(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"example.com/alice", "example.com/bob", "example.com/work/dave", "example.community/carol"}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("Search returned %v, expected %v", items, expected)
+	}
+
+	if items, _ := s.Search(context.Background(), "EXAMPLE.com"); !reflect.DeepEqual(items, expected) {
+		t.Errorf("Search ignoring case returned %v, expected %v", items, expected)
+	}
+
+	if items, _ := s.Search(context.Background(), "example.org"); !reflect.DeepEqual(items, []string{"work/example.org"}) {
+		t.Errorf("Search for a flat entry returned %v", items)
+	}
+
+	expected = []string{"example.com/alice", "example.com/bob", "example.com/work/dave", "example.community/carol", "work/example.org"}
+	if items, _ := s.List(context.Background()); !reflect.DeepEqual(items, expected) {
+		t.Errorf("List returned %v, expected %v", items, expected)
+	}
+
+	rc, err := s.Open(context.Background(), "example.com/bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if b, _ := ioutil.ReadAll(rc); string(b) != "bob" {
+		t.Errorf("Open returned %q", b)
+	}
+
+	if _, err := s.Open(context.Background(), "example.com/nobody"); err != ErrNotFound {
+		t.Errorf("Open of missing item returned %v, expected ErrNotFound", err)
+	}
+	if _, err := s.Open(context.Background(), "../etc/passwd"); err == nil {
+		t.Error("Open escaped the store")
+	}
+}
+
+func TestDiskStore_SearchModes(t *testing.T) {
+	s := &diskStore{fsys: fstest.MapFS{
+		"example.com/alice.gpg":     {Data: []byte("alice")},
+		"mail.example.com/bob.gpg":  {Data: []byte("bob")},
+		"shop/[old]*carol.gpg":      {Data: []byte("carol")},
+		"shop/example.net/dave.gpg": {Data: []byte("dave")},
+	}}
+	tests := []struct {
+		mode, query string
+		expected    []string
+	}{
+		{SearchPrefix, "mail", []string{"mail.example.com/bob"}},
+		{SearchPrefix, "[old]*", []string{"shop/[old]*carol"}},
+		{SearchPrefix, "*", []string{}},
+		{SearchSubstring, "EXAMPLE", []string{"example.com/alice", "mail.example.com/bob", "shop/example.net/dave"}},
+		{SearchSubstring, "]*c", []string{"shop/[old]*carol"}},
+		{SearchRegex, `^(mail|shop)\..*/b`, []string{"mail.example.com/bob"}},
+		{SearchRegex, `example\.(net|org)`, []string{"shop/example.net/dave"}},
+	}
+	for _, test := range tests {
+		items, err := s.Search(context.Background(), test.query, WithMode(test.mode))
+		if err != nil || !reflect.DeepEqual(items, test.expected) {
+			t.Errorf("%s search for %q returned %v (%v), expected %v", test.mode, test.query, items, err, test.expected)
+		}
+	}
+	if _, err := s.Search(context.Background(), "[", WithMode(SearchRegex)); err == nil {
+		t.Error("Search for an invalid regular expression succeeded")
+	}
+}
+
+func TestDiskStore_Symlinks(t *testing.T) {
+	write := func(path string) {
+		os.MkdirAll(filepath.Dir(path), 0700)
+		if err := os.WriteFile(path, []byte("entry"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	shared, outside, dir := t.TempDir(), t.TempDir(), t.TempDir()
+	write(filepath.Join(shared, "team", "example.com", "alice.gpg"))
+	write(filepath.Join(outside, "example.com", "mallory.gpg"))
+	write(filepath.Join(dir, "example.org", "bob.gpg"))
+	if _, err := NewDiskStore(shared); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"team":            filepath.Join(shared, "team"),
+		"evil":            filepath.Join(outside, "example.com"),
+		"bob.gpg":         filepath.Join(dir, "example.org", "bob.gpg"),
+		"mallory.gpg":     filepath.Join(outside, "example.com", "mallory.gpg"),
+		"example.org/up":  dir,
+		"example.org/dup": filepath.Join(shared, "team"),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Skip("symlinks are not supported:", err)
+		}
+	}
+
+	s, err := NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := s.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A directory linked twice is only walked through the first link
+	if expected := []string{"bob", "example.org/bob", "example.org/dup/example.com/alice"}; !reflect.DeepEqual(items, expected) {
+		t.Errorf("List returned %v, expected %v", items, expected)
+	}
+
+	rc, err := s.Open(context.Background(), "team/example.com/alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc.Close()
+	if _, err := s.Open(context.Background(), "mallory"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Opening an entry linked from outside the stores returned %v", err)
+	}
+	if err := s.(*diskStore).fsys.(WriteFS).WriteFile("evil/eve.gpg", nil, 0600); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Writing through a link out of the stores returned %v", err)
+	}
+
+	// Every operation checks where items resolve to, also for directories
+	// merely named like the store
+	sibling := dir + "-backup"
+	write(filepath.Join(sibling, "carol.gpg"))
+	t.Cleanup(func() { os.RemoveAll(sibling) })
+	if err := os.Symlink(sibling, filepath.Join(dir, "backup")); err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range []string{"mallory", "evil/mallory", "backup/carol", "evil/eve"} {
+		if _, err := s.Open(context.Background(), item); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("Open(%s) returned %v", item, err)
+		}
+		if err := s.Create(item, []byte("secret")); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("Create(%s) returned %v", item, err)
+		}
+		if err := s.(*diskStore).Update(item, []byte("secret")); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("Update(%s) returned %v", item, err)
+		}
+		if err := s.Delete(item); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("Delete(%s) returned %v", item, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(sibling, "carol.gpg")); err != nil {
+		t.Errorf("Entry outside the store is gone: %v", err)
+	}
+}
+
+func TestDiskStore_Search_fixture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "browserpass-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := fixture.Generate(dir, fixture.Options{Entries: 200, Depth: 3, Unicode: true, Seed: 42}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &diskStore{path: dir, fsys: dirFS(dir)}
+	items, err := s.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 200 {
+		t.Errorf("List returned %d entries, expected 200", len(items))
+	}
+	for _, item := range items {
+		rc, err := s.Open(context.Background(), item)
+		if err != nil {
+			t.Fatalf("Open(%s): %v", item, err)
+		}
+		rc.Close()
+	}
+}
+
+func BenchmarkDiskStore(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		if testing.Short() && n > 10000 {
+			continue
+		}
+		dir := b.TempDir()
+		if err := fixture.Generate(dir, fixture.Options{Entries: n, Depth: 3, Seed: 42}); err != nil {
+			b.Fatal(err)
+		}
+		s := &diskStore{path: dir, fsys: dirFS(dir)}
+
+		b.Run(fmt.Sprintf("Search/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := s.Search(context.Background(), "example"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("Lookup/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				items, errs := s.LookupStream(context.Background(), "example")
+				for range items {
+				}
+				if err := <-errs; err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
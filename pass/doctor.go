@@ -0,0 +1,171 @@
+package pass
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/dannyvankooten/browserpass/gpg"
+)
+
+// Kinds of problems found by Diagnose and Duplicates.
+const (
+	ProblemUnreadable     = "unreadable"
+	ProblemBrokenLink     = "broken_link"
+	ProblemRecipients     = "recipients"
+	ProblemMissingKey     = "missing_key"
+	ProblemDuplicateEntry = "duplicate_entry"
+)
+
+// Problem is something wrong with a store, which may keep entries from
+// showing up or from being decrypted.
+type Problem struct {
+	Kind string `json:"kind"`
+	// Path is the file or item concerned, relative to the store
+	Path   string `json:"path,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// Diagnoser is implemented by stores that can check their files for
+// problems.
+type Diagnoser interface {
+	Diagnose(ctx context.Context) ([]Problem, error)
+}
+
+// Diagnose returns the problems of s, none if it can't check itself.
+func Diagnose(ctx context.Context, s Store) ([]Problem, error) {
+	if d, ok := s.(Diagnoser); ok {
+		return d.Diagnose(ctx)
+	}
+	return nil, nil
+}
+
+// Diagnose implements Diagnoser. It reports files that can't be read,
+// symbolic links the store doesn't follow and, for gpg stores, entries not
+// encrypted to the keys of their .gpg-id or recipients without a key in
+// the keyring. Ignored files are checked too.
+func (s *diskStore) Diagnose(ctx context.Context) ([]Problem, error) {
+	var problems []Problem
+	keys := make(map[string][]string)
+	err := fs.WalkDir(s.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			problems = append(problems, Problem{Kind: ProblemUnreadable, Path: p, Detail: err.Error()})
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir() && d.Name() == ".git" && p != ".":
+			return fs.SkipDir
+		case d.Type()&fs.ModeSymlink != 0:
+			if _, _, ok := s.followLink(p, make(map[string]bool)); !ok {
+				problems = append(problems, Problem{Kind: ProblemBrokenLink, Path: p, Detail: "the link is broken or leads outside the store"})
+			}
+			return nil
+		case d.IsDir() || !strings.HasSuffix(p, s.extension()):
+			return nil
+		}
+		found, err := s.diagnoseFile(ctx, p, keys)
+		problems = append(problems, found...)
+		return err
+	})
+	return problems, err
+}
+
+// diagnoseFile checks the item file p. keys caches the key IDs of the
+// recipients seen so far, nil for those without a key.
+func (s *diskStore) diagnoseFile(ctx context.Context, p string, keys map[string][]string) ([]Problem, error) {
+	f, err := s.fsys.Open(p)
+	if err != nil {
+		return []Problem{{Kind: ProblemUnreadable, Path: p, Detail: err.Error()}}, nil
+	}
+	defer f.Close()
+	if s.extension() != ".gpg" {
+		return nil, nil
+	}
+	recipients, err := s.recipients(path.Dir(p))
+	if err != nil {
+		return []Problem{{Kind: ProblemRecipients, Path: p, Detail: err.Error()}}, nil
+	}
+	encrypted, err := gpg.Recipients(ctx, f)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return []Problem{{Kind: ProblemUnreadable, Path: p, Detail: err.Error()}}, nil
+	}
+
+	var problems []Problem
+	unknown := make(map[string]bool, len(encrypted))
+	for _, id := range encrypted {
+		unknown[id] = true
+	}
+	complete := true
+	for _, recipient := range recipients {
+		ids, seen := keys[recipient]
+		if !seen {
+			ids, err = gpg.Keys(ctx, recipient)
+			if errors.Is(err, gpg.ErrNoKey) {
+				problems = append(problems, Problem{Kind: ProblemMissingKey, Path: p, Detail: "no key in the keyring for " + recipient})
+			} else if err != nil {
+				return nil, err
+			}
+			keys[recipient] = ids
+		}
+		if ids == nil {
+			complete = false
+			continue
+		}
+		found := false
+		for _, id := range ids {
+			found = found || unknown[id]
+			delete(unknown, id)
+		}
+		if !found {
+			problems = append(problems, Problem{Kind: ProblemRecipients, Path: p, Detail: "not encrypted to " + recipient})
+		}
+	}
+	// Keys of recipients without one in the keyring can't be told apart
+	if complete {
+		for _, id := range encrypted {
+			if unknown[id] {
+				problems = append(problems, Problem{Kind: ProblemRecipients, Path: p, Detail: "encrypted to key " + id + ", which isn't in .gpg-id"})
+			}
+		}
+	}
+	return problems, nil
+}
+
+// Duplicates reports the items that are the same login kept in several
+// places: those named alike from their domain on, like "github.com/alice"
+// and "work/github.com/alice" or "personal:github.com/alice".
+func Duplicates(items []string) []Problem {
+	places := make(map[string][]string)
+	var logins []string
+	for _, item := range items {
+		_, name := SplitQualified(item)
+		parts := strings.Split(name, "/")
+		for i, part := range parts {
+			if strings.Contains(part, ".") {
+				name = strings.Join(parts[i:], "/")
+				break
+			}
+		}
+		if places[name] == nil {
+			logins = append(logins, name)
+		}
+		places[name] = append(places[name], item)
+	}
+	var problems []Problem
+	for _, login := range logins {
+		if same := places[login]; len(same) > 1 {
+			for _, item := range same[1:] {
+				problems = append(problems, Problem{Kind: ProblemDuplicateEntry, Path: item, Detail: "same login as " + same[0]})
+			}
+		}
+	}
+	return problems
+}
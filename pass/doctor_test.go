@@ -0,0 +1,81 @@
+package pass
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/fixture"
+)
+
+func TestDiagnose(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not installed")
+	}
+	home := t.TempDir()
+	if err := fixture.NewKey(home); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("gpg", "--homedir", home, "--batch", "--pinentry-mode", "loopback", "--passphrase", "",
+		"--quick-generate-key", "bob@example.invalid", "default", "default", "never")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	t.Setenv("GNUPGHOME", home)
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".gpg-id"), []byte(fixture.KeyID+"\n"), 0600)
+	s, err := NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Create("example.com/alice", []byte("hunter2\n")); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, _ := os.ReadFile(filepath.Join(dir, "example.com/alice.gpg"))
+	for sub, recipients := range map[string]string{
+		"shared": fixture.KeyID + "\nbob@example.invalid\n",
+		"moved":  "bob@example.invalid\n",
+		"gone":   "nobody@example.invalid\n",
+	} {
+		os.MkdirAll(filepath.Join(dir, sub), 0700)
+		os.WriteFile(filepath.Join(dir, sub, ".gpg-id"), []byte(recipients), 0600)
+		os.WriteFile(filepath.Join(dir, sub, "alice.gpg"), ciphertext, 0600)
+	}
+	os.Symlink(filepath.Join(dir, "missing.gpg"), filepath.Join(dir, "dangling.gpg"))
+
+	problems, err := Diagnose(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []string
+	for _, p := range problems {
+		found = append(found, p.Kind+" "+p.Path)
+	}
+	sort.Strings(found)
+	expected := []string{
+		"broken_link dangling.gpg",
+		"missing_key gone/alice.gpg",
+		"recipients moved/alice.gpg",
+		"recipients moved/alice.gpg",
+		"recipients shared/alice.gpg",
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Diagnose found %v, expected %v", problems, expected)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	items := []string{"github.com/alice", "work/github.com/alice", "github.com/bob", "personal:github.com/alice", "notes", "old/notes"}
+	expected := []Problem{
+		{Kind: ProblemDuplicateEntry, Path: "work/github.com/alice", Detail: "same login as github.com/alice"},
+		{Kind: ProblemDuplicateEntry, Path: "personal:github.com/alice", Detail: "same login as github.com/alice"},
+	}
+	if actual := Duplicates(items); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Duplicates returned %v, expected %v", actual, expected)
+	}
+}
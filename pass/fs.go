@@ -0,0 +1,153 @@
+package pass
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// WriteFS is an fs.FS that can also be modified. Write operations on a store
+// require its file system to implement it.
+type WriteFS interface {
+	fs.FS
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+	Rename(oldname, newname string) error
+	Remove(name string) error
+	Chmod(name string, mode fs.FileMode) error
+}
+
+var (
+	rootsMu sync.Mutex
+	// storeRoots are the directories of the stores opened by NewDiskStore,
+	// which symbolic links in any store may point into.
+	storeRoots []string
+)
+
+// addStoreRoot allows symbolic links into the store at path, which must
+// have its symbolic links resolved.
+func addStoreRoot(path string) {
+	rootsMu.Lock()
+	defer rootsMu.Unlock()
+	storeRoots = append(storeRoots, path)
+}
+
+// inStoreRoot reports whether path, which must have its symbolic links
+// resolved, is inside one of roots or the stores opened so far.
+func inStoreRoot(path string, roots ...string) bool {
+	rootsMu.Lock()
+	roots = append(roots, storeRoots...)
+	rootsMu.Unlock()
+	for _, root := range roots {
+		if within(path, root) {
+			return true
+		}
+	}
+	return false
+}
+
+// within reports whether path is dir or inside it.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// dirFS is the WriteFS of a directory on disk. Symbolic links are followed
+// as long as they resolve inside a store.
+type dirFS string
+
+func (dir dirFS) Open(name string) (fs.File, error) {
+	path, err := dir.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// resolve returns the real path of name, failing with fs.ErrPermission if
+// it resolves outside the stores.
+func (dir dirFS) resolve(op, name string) (string, error) {
+	path, err := dir.join(op, name)
+	if err != nil {
+		return "", err
+	}
+	if path, err = filepath.EvalSymlinks(path); err != nil {
+		return "", err
+	}
+	root, err := filepath.EvalSymlinks(string(dir))
+	if err != nil {
+		return "", err
+	}
+	if !inStoreRoot(path, root) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	return path, nil
+}
+
+// join returns the path of name on disk. Its closest existing directory
+// must resolve inside the stores, so writes can't follow links out of them.
+func (dir dirFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	path := filepath.Join(string(dir), filepath.FromSlash(name))
+	root, err := filepath.EvalSymlinks(string(dir))
+	if err != nil {
+		return "", err
+	}
+	for parent := filepath.Dir(path); len(parent) >= len(string(dir)); parent = filepath.Dir(parent) {
+		if real, err := filepath.EvalSymlinks(parent); err == nil {
+			if !inStoreRoot(real, root) {
+				return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+			}
+			break
+		}
+	}
+	return path, nil
+}
+
+func (dir dirFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	path, err := dir.join("write", name)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, perm)
+}
+
+func (dir dirFS) MkdirAll(name string, perm fs.FileMode) error {
+	path, err := dir.join("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(path, perm)
+}
+
+func (dir dirFS) Rename(oldname, newname string) error {
+	oldpath, err := dir.join("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newpath, err := dir.join("rename", newname)
+	if err != nil {
+		return err
+	}
+	return os.Rename(oldpath, newpath)
+}
+
+func (dir dirFS) Remove(name string) error {
+	path, err := dir.join("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (dir dirFS) Chmod(name string, mode fs.FileMode) error {
+	path, err := dir.join("chmod", name)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(path, mode)
+}
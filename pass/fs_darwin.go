@@ -0,0 +1,18 @@
+package pass
+
+import "syscall"
+
+func isFATFilesystem(path string) (bool, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return false, err
+	}
+	var name []byte
+	for _, c := range st.Fstypename {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+	return string(name) == "msdos" || string(name) == "exfat", nil
+}
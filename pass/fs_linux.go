@@ -0,0 +1,17 @@
+package pass
+
+import "syscall"
+
+// Filesystem magic numbers from <linux/magic.h>.
+const (
+	msdosSuperMagic = 0x4d44
+	exfatSuperMagic = 0x2011bab0
+)
+
+func isFATFilesystem(path string) (bool, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return false, err
+	}
+	return st.Type == msdosSuperMagic || st.Type == exfatSuperMagic, nil
+}
@@ -0,0 +1,36 @@
+package pass
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// FuzzSearch checks that any query against any store layout returns items
+// that exist in the store.
+func FuzzSearch(f *testing.F) {
+	f.Add("example.com/alice.gpg", "example.com")
+	f.Add("münchen.de/ß.gpg", "münch")
+	f.Add("[a-z]*/?.gpg", "[a-z]*")
+	f.Add("a/b/c/d.gpg", "")
+
+	f.Fuzz(func(t *testing.T, name, query string) {
+		fsys := fstest.MapFS{}
+		// MapFS itself can't cope with invalid names
+		if fs.ValidPath(name) && name != "." {
+			fsys[name] = &fstest.MapFile{Data: []byte("x")}
+		}
+		s := &diskStore{fsys: fsys}
+
+		items, err := s.Search(context.Background(), query)
+		if err != nil {
+			return
+		}
+		for _, item := range items {
+			if _, ok := fsys[item+".gpg"]; !ok {
+				t.Errorf("Search(%q) returned %q, which isn't in the store", query, item)
+			}
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package pass
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// FuzzySearch makes stores match the query against items fuzzily, ranking
+// the best matches first, instead of by prefix. "amzn" finds "amazon.com"
+// and "gmal" or "gnail" finds "gmail.com".
+var FuzzySearch bool
+
+// fuzzyItems returns the items matching query fuzzily, best first. Items
+// scoring the same keep their order.
+func fuzzyItems(items []string, query string) []string {
+	query = strings.ToLower(query)
+	type match struct {
+		item  string
+		score int
+	}
+	var matches []match
+	for _, item := range items {
+		// Like prefix search, match the entry and the directories it is in
+		score, ok := fuzzyScore(query, strings.ToLower(path.Base(item)))
+		for dir := path.Dir(item); dir != "."; dir = path.Dir(dir) {
+			if s, dirOK := fuzzyScore(query, strings.ToLower(path.Base(dir))); dirOK && (!ok || s > score) {
+				score, ok = s, true
+			}
+		}
+		if ok {
+			matches = append(matches, match{item, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	result := make([]string, len(matches))
+	for i, m := range matches {
+		result[i] = m.item
+	}
+	return result
+}
+
+// fuzzyScore scores how well query matches name. Names holding the query as
+// a subsequence score above 0, less so the later it starts and the more it is
+// spread out. Names starting with a misspelling of the query, within a typo
+// for every four characters, score below 0.
+func fuzzyScore(query, name string) (int, bool) {
+	q, n := []rune(query), []rune(name)
+	if len(q) == 0 {
+		return 0, true
+	}
+
+	// Earliest subsequence match: its start and the characters skipped
+	start, gaps, i := -1, 0, 0
+	for j := 0; j < len(n) && i < len(q); j++ {
+		switch {
+		case n[j] == q[i]:
+			if start < 0 {
+				start = j
+			}
+			i++
+		case start >= 0:
+			gaps++
+		}
+	}
+	if i == len(q) {
+		return 1000 - 10*start - gaps, true
+	}
+
+	typos := prefixDistance(q, n)
+	if typos > 0 && typos <= len(q)/4 {
+		return -100 * typos, true
+	}
+	return 0, false
+}
+
+// prefixDistance returns the smallest Levenshtein distance between q and a
+// prefix of n.
+func prefixDistance(q, n []rune) int {
+	// row[j] is the distance between the query so far and n[:j]
+	row := make([]int, len(n)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(q); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(n); j++ {
+			cost := 1
+			if q[i-1] == n[j-1] {
+				cost = 0
+			}
+			d := prev + cost
+			if row[j]+1 < d {
+				d = row[j] + 1
+			}
+			if row[j-1]+1 < d {
+				d = row[j-1] + 1
+			}
+			prev, row[j] = row[j], d
+		}
+	}
+	best := row[0]
+	for _, d := range row {
+		if d < best {
+			best = d
+		}
+	}
+	return best
+}
@@ -0,0 +1,45 @@
+package pass
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuzzyItems(t *testing.T) {
+	items := []string{"amazon.com/alice", "example.com/amzn", "github.com/bob", "gmail.com/alice", "mail.google.com/bob", "gitlab.com/work/carol"}
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"amzn", []string{"example.com/amzn", "amazon.com/alice"}},
+		{"gmal", []string{"gmail.com/alice"}},
+		{"gnail", []string{"gmail.com/alice"}},
+		{"GitHub", []string{"github.com/bob"}},
+		{"gitlab", []string{"gitlab.com/work/carol"}},
+		{"mail", []string{"mail.google.com/bob", "gmail.com/alice"}},
+		{"xyz", nil},
+	}
+	for _, test := range tests {
+		if actual := fuzzyItems(items, test.query); !(len(actual) == 0 && len(test.expected) == 0) && !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%q: expected %v, got %v", test.query, test.expected, actual)
+		}
+	}
+}
+
+func TestPrefixDistance(t *testing.T) {
+	tests := []struct {
+		q, n     string
+		expected int
+	}{
+		{"gnail", "gmail.com", 1},
+		{"amazno", "amazon.com", 1},
+		{"abc", "abc", 0},
+		{"gmial", "gmail.com", 2},
+		{"abc", "", 3},
+	}
+	for _, test := range tests {
+		if d := prefixDistance([]rune(test.q), []rune(test.n)); d != test.expected {
+			t.Errorf("prefixDistance(%q, %q) is %d, expected %d", test.q, test.n, d, test.expected)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package pass
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GopassConfigPath returns the location of gopass's config file: the
+// gitconfig-style config of gopass 1.12 and later if it exists, otherwise
+// the config.yml of earlier versions.
+func GopassConfigPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := HomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	path := filepath.Join(dir, "gopass", "config")
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	return filepath.Join(dir, "gopass", "config.yml"), nil
+}
+
+// GopassMounts reads the gopass config at path and returns the directories
+// of its stores by mount point, the root store being mounted at "". Without
+// a config, the root store is gopass's default one.
+func GopassMounts(path string) (map[string]string, error) {
+	mounts := make(map[string]string)
+	f, err := os.Open(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		defer f.Close()
+		if strings.HasSuffix(path, ".yml") || strings.HasSuffix(path, ".yaml") {
+			err = parseGopassYAML(f, mounts)
+		} else {
+			err = parseGopassINI(f, mounts)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if mounts[""] == "" {
+		if dir := os.Getenv("PASSWORD_STORE_DIR"); dir != "" {
+			mounts[""] = dir
+		} else {
+			home, err := HomeDir()
+			if err != nil {
+				return nil, err
+			}
+			mounts[""] = filepath.Join(home, ".local", "share", "gopass", "stores", "root")
+		}
+	}
+	return mounts, nil
+}
+
+// parseGopassINI reads the mounts of a gitconfig-style gopass config:
+//
+//	[mounts]
+//		path = /home/alice/.local/share/gopass/stores/root
+//	[mounts "work"]
+//		path = /home/alice/.local/share/gopass/stores/work
+func parseGopassINI(r io.Reader, mounts map[string]string) error {
+	section, inMounts := "", false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+		case line[0] == '[':
+			name := strings.TrimSpace(strings.Trim(line, "[]"))
+			kind, sub, _ := strings.Cut(name, " ")
+			inMounts = strings.EqualFold(kind, "mounts")
+			section = strings.Trim(strings.TrimSpace(sub), `"`)
+		case inMounts:
+			key, value, ok := strings.Cut(line, "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "path") {
+				mounts[section] = gopassPath(strings.Trim(strings.TrimSpace(value), `"`))
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// parseGopassYAML reads the mounts of a config.yml of gopass before 1.12:
+//
+//	root:
+//	  path: gpgcli-gitcli-fs+file:///home/alice/.password-store
+//	mounts:
+//	  work:
+//	    path: gpgcli-gitcli-fs+file:///home/alice/.password-store-work
+func parseGopassYAML(r io.Reader, mounts map[string]string) error {
+	// The indentation and key of the mapping each line is in
+	var stack []struct {
+		indent int
+		key    string
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		text := scanner.Text()
+		line := strings.TrimSpace(text)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		indent := len(text) - len(strings.TrimLeft(text, " "))
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.Trim(strings.TrimSpace(value), `"'`)
+		if value == "" {
+			stack = append(stack, struct {
+				indent int
+				key    string
+			}{indent, key})
+			continue
+		}
+		if key != "path" {
+			continue
+		}
+		switch {
+		case len(stack) == 1 && stack[0].key == "root":
+			mounts[""] = gopassPath(value)
+		case len(stack) == 2 && stack[0].key == "mounts":
+			mounts[stack[1].key] = gopassPath(value)
+		}
+	}
+	return scanner.Err()
+}
+
+// gopassPath returns the directory of a gopass store path, which gopass
+// before 1.12 prefixes with its backends, like
+// "gpgcli-gitcli-fs+file:///home/alice/.password-store".
+func gopassPath(path string) string {
+	if i := strings.Index(path, "file://"); i >= 0 {
+		path = path[i+len("file://"):]
+	}
+	if strings.HasPrefix(path, "~/") {
+		if home, err := HomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
+// gopassMount is a store mounted at a point of a gopassStore.
+type gopassMount struct {
+	point string
+	Store
+}
+
+// gopassStore serves a gopass root store and the stores mounted into it.
+// Unlike a MultiStore, items of mounted stores are named after their mount
+// point like directories: "work/example.com/alice".
+type gopassStore []gopassMount
+
+// NewGopassStore returns a store serving stores by mount point, the root
+// store being mounted at "".
+func NewGopassStore(mounts map[string]Store) Store {
+	var g gopassStore
+	for point, s := range mounts {
+		g = append(g, gopassMount{strings.Trim(point, "/"), s})
+	}
+	// Deeper mount points shadow the stores they are in
+	sort.Slice(g, func(i, j int) bool { return len(g[i].point) > len(g[j].point) })
+	return g
+}
+
+// mount returns the index of the store holding item and the item's name in
+// it, or -1 if item is outside every store.
+func (g gopassStore) mount(item string) (int, string) {
+	for i, m := range g {
+		if m.point == "" {
+			return i, item
+		}
+		if rest := strings.TrimPrefix(item, m.point+"/"); rest != item {
+			return i, rest
+		}
+	}
+	return -1, ""
+}
+
+func (g gopassStore) List(ctx context.Context) ([]string, error) {
+	var items []string
+	for i, m := range g {
+		list, err := m.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list {
+			name := path.Join(m.point, item)
+			// Leave out items hidden by a store mounted over them
+			if j, _ := g.mount(name); j == i {
+				items = append(items, name)
+			}
+		}
+	}
+	Sort(items)
+	return items, nil
+}
+
+// Search matches items by their full name, so searching for a mount point
+// finds the items of its store.
+func (g gopassStore) Search(ctx context.Context, query string, opts ...SearchOption) ([]string, error) {
+	items, err := g.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := searchItems(items, query, opts...)
+	return Page(matches, opts...), err
+}
+
+func (g gopassStore) LookupStream(ctx context.Context, domain string) (<-chan string, <-chan error) {
+	return StreamSearch(ctx, g, domain)
+}
+
+func (g gopassStore) Open(ctx context.Context, item string) (io.ReadCloser, error) {
+	i, rest := g.mount(item)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	return g[i].Open(ctx, rest)
+}
+
+// Create creates item in the store mounted where it goes.
+func (g gopassStore) Create(item string, content []byte) error {
+	i, rest := g.mount(item)
+	if i < 0 {
+		return ErrNotFound
+	}
+	return g[i].Create(rest, content)
+}
+
+func (g gopassStore) Delete(item string) error {
+	i, rest := g.mount(item)
+	if i < 0 {
+		return ErrNotFound
+	}
+	return g[i].Delete(rest)
+}
+
+// Update implements Updater for the mounted stores that do.
+func (g gopassStore) Update(item string, content []byte) error {
+	i, rest := g.mount(item)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if u, ok := g[i].Store.(Updater); ok {
+		return u.Update(rest, content)
+	}
+	return ErrReadOnly
+}
+
+// StoreKeys implements Keyed for the root store.
+func (g gopassStore) StoreKeys() ([]string, error) {
+	i, _ := g.mount("")
+	if i < 0 {
+		return nil, nil
+	}
+	return KeysOf(g[i].Store)
+}
+
+// Move implements Mover within the mounted stores that do.
+func (g gopassStore) Move(src, dst string) error {
+	i, from := g.mount(src)
+	if i < 0 {
+		return ErrNotFound
+	}
+	j, to := g.mount(dst)
+	if j != i {
+		return ErrOtherStore
+	}
+	return Move(g[i].Store, from, to)
+}
+
+// Diagnose implements Diagnoser for the mounted stores that do, with paths
+// below their mount point.
+func (g gopassStore) Diagnose(ctx context.Context) ([]Problem, error) {
+	var problems []Problem
+	for _, m := range g {
+		found, err := Diagnose(ctx, m.Store)
+		for _, p := range found {
+			if m.point != "" {
+				p.Path = m.point + "/" + p.Path
+			}
+			problems = append(problems, p)
+		}
+		if err != nil {
+			return problems, err
+		}
+	}
+	return problems, nil
+}
+
+// Stats implements Statter for the mounted stores that do.
+func (g gopassStore) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	for _, m := range g {
+		found, err := FileStats(ctx, m.Store)
+		if err != nil {
+			return Stats{}, err
+		}
+		stats.add(found)
+	}
+	return stats, nil
+}
+
+// Warnings implements Checker for the mounted stores that do.
+func (g gopassStore) Warnings() ([]string, error) {
+	var warnings []string
+	for _, m := range g {
+		if c, ok := m.Store.(Checker); ok {
+			list, err := c.Warnings()
+			if err != nil {
+				return nil, err
+			}
+			warnings = append(warnings, list...)
+		}
+	}
+	return warnings, nil
+}
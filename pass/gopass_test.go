@@ -0,0 +1,90 @@
+package pass
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestGopassMounts(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("PASSWORD_STORE_DIR", "")
+	configs := map[string]string{
+		"config": `[core]
+	autosync = true
+[mounts]
+	path = /srv/gopass/root
+[mounts "work"]
+	path = ~/stores/work
+[mounts "work/team"]
+	path = "/srv/gopass/team"
+`,
+		"config.yml": `autosync: true
+root:
+  path: gpgcli-gitcli-fs+file:///srv/gopass/root
+mounts:
+  work:
+    path: gpgcli-gitcli-fs+file://~/stores/work
+  work/team:
+    path: "gpgcli-gitcli-fs+file:///srv/gopass/team"
+`,
+	}
+	expected := map[string]string{"": "/srv/gopass/root", "work": filepath.Join(home, "stores", "work"), "work/team": "/srv/gopass/team"}
+	for name, config := range configs {
+		path := filepath.Join(t.TempDir(), name)
+		os.WriteFile(path, []byte(config), 0600)
+		mounts, err := GopassMounts(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(mounts, expected) {
+			t.Errorf("%s: expected %v, got %v", name, expected, mounts)
+		}
+	}
+
+	mounts, err := GopassMounts(filepath.Join(t.TempDir(), "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]string{"": filepath.Join(home, ".local", "share", "gopass", "stores", "root")}; !reflect.DeepEqual(mounts, expected) {
+		t.Errorf("Without a config expected %v, got %v", expected, mounts)
+	}
+}
+
+func TestGopassStore(t *testing.T) {
+	g := NewGopassStore(map[string]Store{
+		"": &diskStore{fsys: fstest.MapFS{
+			"example.com/alice.gpg":      {Data: []byte("root")},
+			"work/example.com/alice.gpg": {Data: []byte("hidden")},
+		}},
+		"work": &diskStore{fsys: fstest.MapFS{"example.com/alice.gpg": {Data: []byte("work")}}},
+	})
+
+	items, err := g.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"example.com/alice", "work/example.com/alice"}; !reflect.DeepEqual(items, expected) {
+		t.Errorf("List returned %v, expected %v", items, expected)
+	}
+	if items, _ := g.Search(context.Background(), "work"); !reflect.DeepEqual(items, []string{"work/example.com/alice"}) {
+		t.Errorf("Search for the mount point returned %v", items)
+	}
+
+	for item, expected := range map[string]string{"example.com/alice": "root", "work/example.com/alice": "work"} {
+		rc, err := g.Open(context.Background(), item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		if string(data) != expected {
+			t.Errorf("%s opened from %s, expected %s", item, data, expected)
+		}
+	}
+}
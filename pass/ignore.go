@@ -0,0 +1,156 @@
+package pass
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFile is the file at the root of a store listing the files and
+// directories left out of it, in the syntax of .gitignore.
+const IgnoreFile = ".browserpass-ignore"
+
+// IgnorePatterns are patterns in the syntax of .gitignore leaving files and
+// directories out of every store, as if they were in its IgnoreFile.
+var IgnorePatterns []string
+
+// ignoreRule is a single pattern of an ignore list.
+type ignoreRule struct {
+	re *regexp.Regexp
+	// negated rules bring back what earlier rules left out
+	negated bool
+	// dirOnly rules only match directories
+	dirOnly bool
+}
+
+// ignoreRules match files and directories like .gitignore: the last rule
+// matching a name decides.
+type ignoreRules []ignoreRule
+
+// parseIgnore parses patterns, one a line. Blank lines and lines starting
+// with "#" are skipped.
+func parseIgnore(lines []string) (ignoreRules, error) {
+	var rules ignoreRules
+	for n, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negated, line = true, line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly, line = true, strings.TrimRight(line, "/")
+		}
+		// Patterns with a slash are relative to the root of the store,
+		// others match at any depth
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+		expr := globRegexp(line)
+		if !anchored {
+			expr = "(.*/)?" + expr
+		}
+		re, err := regexp.Compile("^" + expr + "$")
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid pattern %q", n+1, line)
+		}
+		rule.re = re
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// globRegexp translates a gitignore glob into a regular expression: "*" and
+// "?" don't match slashes, "**" matches any number of directories.
+func globRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// ignored reports whether the file or directory at name, relative to the
+// root of the store, is left out.
+func (rules ignoreRules) ignored(name string, isDir bool) bool {
+	ignored := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.re.MatchString(name) {
+			ignored = !rule.negated
+		}
+	}
+	return ignored
+}
+
+// ignoredItem reports whether the item in file, or one of its directories,
+// is left out, for stores listed without walking them.
+func (rules ignoreRules) ignoredItem(item, file string) bool {
+	for i, c := range file {
+		if c == '/' && rules.ignored(file[:i], true) {
+			return true
+		}
+	}
+	return rules.ignored(file, false) || rules.ignored(item, false)
+}
+
+// ignoreRules returns the rules of IgnorePatterns followed by those of the
+// store's IgnoreFile.
+func (s *diskStore) ignoreRules() (ignoreRules, error) {
+	rules, err := parseIgnore(IgnorePatterns)
+	if err != nil {
+		return nil, fmt.Errorf("pass: ignore patterns: %w", err)
+	}
+	data, err := fs.ReadFile(s.fsys, IgnoreFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return rules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	more, err := parseIgnore(strings.Split(string(data), "\n"))
+	if err != nil {
+		return nil, &fs.PathError{Op: "parse", Path: IgnoreFile, Err: err}
+	}
+	return append(rules, more...), nil
+}
@@ -0,0 +1,86 @@
+package pass
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	rules, err := parseIgnore([]string{
+		"# comments and blank lines are skipped",
+		"",
+		"notes/",
+		"*.md.gpg",
+		"/top.gpg",
+		"archive/**/old-*",
+		"secret-*",
+		"!secret-kept",
+		`\#hash`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		isDir   bool
+		ignored bool
+	}{
+		{"notes", true, true},
+		{"work/notes", true, true},
+		{"notes", false, false},
+		{"readme.md.gpg", false, true},
+		{"work/readme.md.gpg", false, true},
+		{"readme.gpg", false, false},
+		{"top.gpg", false, true},
+		{"work/top.gpg", false, false},
+		{"archive/old-bob", false, true},
+		{"archive/2019/01/old-bob", false, true},
+		{"work/archive/old-bob", false, false},
+		{"example.com/secret-alice", false, true},
+		{"example.com/secret-kept", false, false},
+		{"#hash", false, true},
+	}
+	for _, test := range tests {
+		if ignored := rules.ignored(test.name, test.isDir); ignored != test.ignored {
+			t.Errorf("%s (directory %v): ignored is %v, expected %v", test.name, test.isDir, ignored, test.ignored)
+		}
+	}
+	if !rules.ignoredItem("work/notes/todo", "work/notes/todo.gpg") || rules.ignoredItem("work/todo", "work/todo.gpg") {
+		t.Error("Items are ignored by their directories wrongly")
+	}
+
+	if _, err := parseIgnore([]string{"[z-a]"}); err == nil {
+		t.Error("Parsed an invalid pattern")
+	}
+}
+
+func TestStoreIgnoreFile(t *testing.T) {
+	defer func(patterns []string) { IgnorePatterns = patterns }(IgnorePatterns)
+	IgnorePatterns = []string{"*.md.gpg"}
+	s := &diskStore{fsys: fstest.MapFS{
+		IgnoreFile:                     {Data: []byte("notes/\nwork/old*\n")},
+		"example.com/alice.gpg":        {Data: []byte("alice")},
+		"example.com/readme.md.gpg":    {Data: []byte("readme")},
+		"notes/example.com/bob.gpg":    {Data: []byte("note")},
+		"work/notes.gpg":               {Data: []byte("not a directory")},
+		"work/old-example.org/bob.gpg": {Data: []byte("old")},
+		"work/example.org/bob.gpg":     {Data: []byte("bob")},
+	}}
+	items, err := s.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"example.com/alice", "work/example.org/bob", "work/notes"}; !reflect.DeepEqual(items, expected) {
+		t.Errorf("List returned %v, expected %v", items, expected)
+	}
+	if items, _ := s.Search(context.Background(), "bob"); !reflect.DeepEqual(items, []string{"work/example.org/bob"}) {
+		t.Errorf("Search returned %v", items)
+	}
+
+	s.fsys.(fstest.MapFS)[IgnoreFile] = &fstest.MapFile{Data: []byte("[z-a]\n")}
+	if _, err := s.List(context.Background()); err == nil {
+		t.Error("Listed a store with an invalid ignore file")
+	}
+}
@@ -0,0 +1,123 @@
+package pass
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+)
+
+// IndexStores makes NewDiskStore keep the names in a store in memory,
+// updated by watching the store for changes, instead of walking it on every
+// search. Long-running hosts set it. Where watching isn't supported stores
+// are walked every time.
+var IndexStores bool
+
+var errUnsupported = errors.New("pass: not supported on this platform")
+
+// indexedStore is a diskStore answering List and Search from an index of
+// its items. The index is rebuilt on the first call after the watcher saw a
+// change.
+type indexedStore struct {
+	*diskStore
+	watcher io.Closer
+
+	mu    sync.Mutex
+	items []string
+	stale bool
+	// add watches a directory, it is nil once the watcher failed
+	add func(dir string) error
+}
+
+func newIndexedStore(s *diskStore) (Store, error) {
+	x := &indexedStore{diskStore: s, stale: true}
+	watcher, add, err := watch(x.invalidate)
+	if err == errUnsupported {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	x.watcher, x.add = watcher, add
+	if _, err := x.List(context.Background()); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	return x, nil
+}
+
+func (x *indexedStore) invalidate() {
+	x.mu.Lock()
+	x.stale = true
+	x.mu.Unlock()
+}
+
+func (x *indexedStore) List(ctx context.Context) ([]string, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.stale || x.add == nil {
+		// Changes from here on must invalidate the new index
+		x.stale = false
+		var watchErr error
+		items, err := x.walk(ctx, func(dir string) {
+			if x.add != nil && watchErr == nil {
+				watchErr = x.add(filepath.Join(x.path, filepath.FromSlash(dir)))
+			}
+		})
+		if err != nil {
+			x.stale = true
+			return nil, err
+		}
+		// Without a complete set of watches the index can't be trusted
+		if watchErr != nil {
+			x.add = nil
+		}
+		x.items = items
+	}
+	return append([]string{}, x.items...), nil
+}
+
+func (x *indexedStore) Search(ctx context.Context, query string, opts ...SearchOption) ([]string, error) {
+	items, err := x.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := searchItems(items, query, opts...)
+	return Page(matches, opts...), err
+}
+
+// LookupStream searches the index, which is complete already.
+func (x *indexedStore) LookupStream(ctx context.Context, domain string) (<-chan string, <-chan error) {
+	return StreamSearch(ctx, x, domain)
+}
+
+func (x *indexedStore) Create(item string, content []byte) error {
+	if err := x.diskStore.Create(item, content); err != nil {
+		return err
+	}
+	// Don't wait for the watcher, the item must show up right away
+	x.invalidate()
+	return nil
+}
+
+func (x *indexedStore) Delete(item string) error {
+	if err := x.diskStore.Delete(item); err != nil {
+		return err
+	}
+	x.invalidate()
+	return nil
+}
+
+func (x *indexedStore) Move(src, dst string) error {
+	if err := x.diskStore.Move(src, dst); err != nil {
+		return err
+	}
+	x.invalidate()
+	return nil
+}
+
+// Close stops watching the store.
+func (x *indexedStore) Close() error {
+	return x.watcher.Close()
+}
@@ -0,0 +1,57 @@
+package pass
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIndexedStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "browserpass-index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "example.com"), 0700)
+	ioutil.WriteFile(filepath.Join(dir, "example.com", "alice.gpg"), nil, 0600)
+
+	IndexStores = true
+	defer func() { IndexStores = false }()
+	s, err := NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, ok := s.(*indexedStore)
+	if !ok {
+		t.Skip("stores can't be watched on this platform")
+	}
+	defer x.Close()
+
+	// A new directory and an entry in it must both be picked up
+	os.MkdirAll(filepath.Join(dir, "example.org"), 0700)
+	waitFor(t, s, []string{"example.com/alice"})
+	ioutil.WriteFile(filepath.Join(dir, "example.org", "bob.gpg"), nil, 0600)
+	waitFor(t, s, []string{"example.com/alice", "example.org/bob"})
+	os.Remove(filepath.Join(dir, "example.com", "alice.gpg"))
+	waitFor(t, s, []string{"example.org/bob"})
+}
+
+// waitFor waits for the items listed by s to become expected.
+func waitFor(t *testing.T, s Store, expected []string) {
+	t.Helper()
+	var items []string
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		var err error
+		if items, err = s.List(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if reflect.DeepEqual(items, expected) {
+			return
+		}
+	}
+	t.Errorf("Expected %v, got %v", expected, items)
+}
@@ -0,0 +1,195 @@
+package pass
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Initializer is implemented by stores that can be created on first use.
+type Initializer interface {
+	// Init creates the store with a .gpg-id listing recipients, like pass
+	// init does.
+	Init(recipients []string) error
+}
+
+// Init creates s for recipients, failing with ErrExists if it exists.
+func Init(s Store, recipients []string) error {
+	if i, ok := s.(Initializer); ok {
+		return i.Init(recipients)
+	}
+	return ErrExists
+}
+
+// pendingStore stands for a store whose directory doesn't exist yet. Its
+// operations fail with ErrStoreNotInitialized until Init creates it, from
+// then on they are those of the new store.
+type pendingStore struct {
+	path  string
+	mu    sync.Mutex
+	store Store
+}
+
+// opened returns the store once created.
+func (p *pendingStore) opened() (Store, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.store == nil {
+		return nil, ErrStoreNotInitialized
+	}
+	return p.store, nil
+}
+
+// Init implements Initializer, creating the directory of the store and its
+// .gpg-id.
+func (p *pendingStore) Init(recipients []string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.store != nil {
+		return ErrExists
+	}
+	if err := os.MkdirAll(p.path, 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(p.path, ".gpg-id"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return ErrExists
+	}
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(strings.Join(recipients, "\n") + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	s, err := NewDiskStore(p.path)
+	if err != nil {
+		return err
+	}
+	p.store = s
+	return nil
+}
+
+func (p *pendingStore) Search(ctx context.Context, query string, opts ...SearchOption) ([]string, error) {
+	s, err := p.opened()
+	if err != nil {
+		return nil, err
+	}
+	return s.Search(ctx, query, opts...)
+}
+
+func (p *pendingStore) List(ctx context.Context) ([]string, error) {
+	s, err := p.opened()
+	if err != nil {
+		return nil, err
+	}
+	return s.List(ctx)
+}
+
+func (p *pendingStore) LookupStream(ctx context.Context, domain string) (<-chan string, <-chan error) {
+	s, err := p.opened()
+	if err != nil {
+		return StreamSearch(ctx, p, domain)
+	}
+	return s.LookupStream(ctx, domain)
+}
+
+func (p *pendingStore) Open(ctx context.Context, item string) (io.ReadCloser, error) {
+	s, err := p.opened()
+	if err != nil {
+		return nil, err
+	}
+	return s.Open(ctx, item)
+}
+
+func (p *pendingStore) Create(item string, content []byte) error {
+	s, err := p.opened()
+	if err != nil {
+		return err
+	}
+	return s.Create(item, content)
+}
+
+// Update implements Updater once the store is created.
+func (p *pendingStore) Update(item string, content []byte) error {
+	s, err := p.opened()
+	if err != nil {
+		return err
+	}
+	if u, ok := s.(Updater); ok {
+		return u.Update(item, content)
+	}
+	return ErrReadOnly
+}
+
+func (p *pendingStore) Delete(item string) error {
+	s, err := p.opened()
+	if err != nil {
+		return err
+	}
+	return s.Delete(item)
+}
+
+// StoreKeys implements Keyed once the store is created.
+func (p *pendingStore) StoreKeys() ([]string, error) {
+	s, err := p.opened()
+	if err != nil {
+		return nil, err
+	}
+	return KeysOf(s)
+}
+
+// Move implements Mover once the store is created.
+func (p *pendingStore) Move(src, dst string) error {
+	s, err := p.opened()
+	if err != nil {
+		return err
+	}
+	return Move(s, src, dst)
+}
+
+// StoreSettings implements Configured, with no settings until the store is
+// created.
+func (p *pendingStore) StoreSettings() (*Settings, error) {
+	s, err := p.opened()
+	if err != nil {
+		return &Settings{}, nil
+	}
+	return SettingsOf(s)
+}
+
+// Warnings implements Checker once the store is created.
+func (p *pendingStore) Warnings() ([]string, error) {
+	s, err := p.opened()
+	if err != nil {
+		return nil, nil
+	}
+	if c, ok := s.(Checker); ok {
+		return c.Warnings()
+	}
+	return nil, nil
+}
+
+// Diagnose implements Diagnoser once the store is created.
+func (p *pendingStore) Diagnose(ctx context.Context) ([]Problem, error) {
+	s, err := p.opened()
+	if err != nil {
+		return nil, err
+	}
+	return Diagnose(ctx, s)
+}
+
+// Stats implements Statter once the store is created.
+func (p *pendingStore) Stats(ctx context.Context) (Stats, error) {
+	s, err := p.opened()
+	if err != nil {
+		return Stats{}, err
+	}
+	return FileStats(ctx, s)
+}
@@ -0,0 +1,55 @@
+package pass
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewDiskStore_missing(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "store")
+	t.Setenv("PASSWORD_STORE_DIR", dir)
+	if path, err := DefaultStorePath(); path != dir || err != nil {
+		t.Errorf("DefaultStorePath returned %q, %v", path, err)
+	}
+	s, err := NewDefaultStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.List(context.Background()); err != ErrStoreNotInitialized {
+		t.Errorf("List before Init returned %v", err)
+	}
+	if err := s.Create("example.com/alice", []byte("hunter2\n")); err != ErrStoreNotInitialized {
+		t.Errorf("Create before Init returned %v", err)
+	}
+	if err := Init(s, []string{"alice@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if items, err := s.List(context.Background()); len(items) != 0 || err != nil {
+		t.Errorf("List after Init returned %v, %v", items, err)
+	}
+	if err := Init(s, []string{"alice@example.com"}); err != ErrExists {
+		t.Errorf("Init again returned %v", err)
+	}
+}
+
+func TestNewDiskStore_empty(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.List(context.Background()); err != ErrStoreNotInitialized {
+		t.Errorf("List of an empty directory returned %v", err)
+	}
+	if err := Init(s, []string{"alice@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = NewDiskStore(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := Init(s, []string{"alice@example.com"}); err != ErrExists {
+		t.Errorf("Init of an initialized store returned %v", err)
+	}
+}
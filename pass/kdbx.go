@@ -0,0 +1,219 @@
+package pass
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KdbxPrefix is the directory KeePass entries appear under, as
+// "keepass/<group>/<title>".
+const KdbxPrefix = "keepass/"
+
+// KeePassXCBinary is the program reading KeePass databases.
+var KeePassXCBinary = "keepassxc-cli"
+
+// kdbxListTTL is how long the entries of a KeePass database are remembered,
+// unless the database changes, since every listing unlocks it.
+const kdbxListTTL = 5 * time.Minute
+
+// kdbxStore reads the entries of a KeePass database through keepassxc-cli.
+// Groups are directories and entry titles the last segment of items, so an
+// entry "alice" in a group "example.com" is "keepass/example.com/alice".
+type kdbxStore struct {
+	db      string
+	keyFile string
+	// unlock writes the database password, nil for databases only locked
+	// with the key file
+	unlock func(w io.Writer) error
+
+	mu    sync.Mutex
+	items []string
+	// listed is when items were listed, modTime the database's then
+	listed, modTime time.Time
+}
+
+// NewKdbxStore returns a read-only store of the KeePass database at db,
+// unlocked with keyFile if set and the password unlock writes if not nil.
+// Its items are not encrypted, see Unencrypted.
+func NewKdbxStore(db, keyFile string, unlock func(w io.Writer) error) (Store, error) {
+	if keyFile == "" && unlock == nil {
+		return nil, errors.New("pass: KeePass databases need a key file or a password")
+	}
+	db, err := filepath.EvalSymlinks(db)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := exec.LookPath(KeePassXCBinary); err != nil {
+		return nil, err
+	}
+	return &kdbxStore{db: db, keyFile: keyFile, unlock: unlock}, nil
+}
+
+// start runs keepassxc-cli's subcommand with options on the database, and
+// the entry if not empty, writing the password to its stdin.
+func (s *kdbxStore) start(ctx context.Context, subcommand string, options []string, entry string) (io.ReadCloser, *exec.Cmd, error) {
+	args := []string{subcommand, "--quiet"}
+	if s.keyFile != "" {
+		args = append(args, "--key-file", s.keyFile)
+	}
+	if s.unlock == nil {
+		args = append(args, "--no-password")
+	}
+	args = append(append(args, options...), s.db)
+	if entry != "" {
+		args = append(args, entry)
+	}
+	cmd := exec.CommandContext(ctx, KeePassXCBinary, args...)
+	cmd.Stderr = os.Stderr
+	if s.unlock != nil {
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		go func() {
+			if err := s.unlock(stdin); err == nil {
+				io.WriteString(stdin, "\n")
+			}
+			stdin.Close()
+		}()
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	return out, cmd, nil
+}
+
+// List returns the database's entries, leaving out its recycle bin. They are
+// listed again once the database changes or after kdbxListTTL.
+func (s *kdbxStore) List(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, err := os.Stat(s.db)
+	if err != nil {
+		return nil, err
+	}
+	if !s.listed.IsZero() && info.ModTime().Equal(s.modTime) && time.Since(s.listed) < kdbxListTTL {
+		return append([]string{}, s.items...), nil
+	}
+	items, err := s.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.items, s.listed, s.modTime = items, time.Now(), info.ModTime()
+	return append([]string{}, items...), nil
+}
+
+// list runs keepassxc-cli for List.
+func (s *kdbxStore) list(ctx context.Context) ([]string, error) {
+	out, cmd, err := s.start(ctx, "ls", []string{"--recursive", "--flatten"}, "")
+	if err != nil {
+		return nil, err
+	}
+	var items []string
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasSuffix(entry, "/") || strings.HasPrefix(entry, "Recycle Bin/") {
+			continue
+		}
+		items = append(items, KdbxPrefix+entry)
+	}
+	err = scanner.Err()
+	io.Copy(io.Discard, out)
+	if werr := cmd.Wait(); err == nil {
+		err = werr
+	}
+	if err != nil {
+		return nil, err
+	}
+	Sort(items)
+	return items, nil
+}
+
+func (s *kdbxStore) Search(ctx context.Context, query string, opts ...SearchOption) ([]string, error) {
+	items, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := searchItems(items, query, opts...)
+	return Page(matches, opts...), err
+}
+
+func (s *kdbxStore) LookupStream(ctx context.Context, domain string) (<-chan string, <-chan error) {
+	return StreamSearch(ctx, s, domain)
+}
+
+// Open reads the password, username and URL of item, laid out like a pass
+// entry.
+func (s *kdbxStore) Open(ctx context.Context, item string) (io.ReadCloser, error) {
+	entry := strings.TrimPrefix(item, KdbxPrefix)
+	if entry == item || entry == "" {
+		return nil, ErrNotFound
+	}
+	options := []string{"--show-protected", "--attributes", "Password", "--attributes", "UserName", "--attributes", "URL"}
+	out, cmd, err := s.start(ctx, "show", options, entry)
+	if err != nil {
+		return nil, err
+	}
+	return &labelReader{rc: &commandReader{out, cmd}, labels: []string{"login: ", "url: "}}, nil
+}
+
+// Create fails, the database belongs to KeePass.
+func (s *kdbxStore) Create(item string, content []byte) error {
+	return ErrReadOnly
+}
+
+// Delete fails, the database belongs to KeePass.
+func (s *kdbxStore) Delete(item string) error {
+	return ErrReadOnly
+}
+
+// labelReader prefixes the lines after the first with labels, turning the
+// attribute values keepassxc-cli prints into "key: value" lines. It reads
+// a byte at a time so no secret is buffered.
+type labelReader struct {
+	rc      io.ReadCloser
+	labels  []string
+	pending string
+}
+
+func (r *labelReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if r.pending != "" {
+			c := copy(p[n:], r.pending)
+			r.pending = r.pending[c:]
+			n += c
+			continue
+		}
+		if _, err := io.ReadFull(r.rc, p[n:n+1]); err != nil {
+			return n, err
+		}
+		n++
+		if p[n-1] == '\n' {
+			if len(r.labels) > 0 {
+				r.pending, r.labels = r.labels[0], r.labels[1:]
+			}
+			break
+		}
+	}
+	return n, nil
+}
+
+func (r *labelReader) Close() error {
+	return r.rc.Close()
+}
+
+func (r *labelReader) Unencrypted() {}
@@ -0,0 +1,97 @@
+package pass
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestKdbxStore(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake keepassxc-cli is a shell script")
+	}
+	// The fake keepassxc-cli checks the password and prints a listing or
+	// the entry after the database
+	bin := filepath.Join(t.TempDir(), "keepassxc-cli")
+	os.WriteFile(bin, []byte(`#!/bin/sh
+read password
+[ "$password" = hunter2 ] || { echo "wrong password" >&2; exit 1; }
+case "$1" in
+ls) printf 'example.com/\nexample.com/alice\nwork/\nwork/example.org\nRecycle Bin/\nRecycle Bin/old\n' ;;
+show) eval entry=\${$#}; printf 'secret of %s\nalice\nhttps://example.com/login\n' "$entry" ;;
+esac
+`), 0700)
+	defer func(binary string) { KeePassXCBinary = binary }(KeePassXCBinary)
+	KeePassXCBinary = bin
+
+	db := filepath.Join(t.TempDir(), "passwords.kdbx")
+	os.WriteFile(db, nil, 0600)
+	if _, err := NewKdbxStore(db, "", nil); err == nil {
+		t.Error("Opened a database without a password or key file")
+	}
+	unlocks := 0
+	s, err := NewKdbxStore(db, "", func(w io.Writer) error {
+		unlocks++
+		_, err := io.WriteString(w, "hunter2")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := s.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"keepass/example.com/alice", "keepass/work/example.org"}; !reflect.DeepEqual(items, expected) {
+		t.Errorf("List returned %v, expected %v", items, expected)
+	}
+	if items, _ := s.Search(context.Background(), "example.org"); !reflect.DeepEqual(items, []string{"keepass/work/example.org"}) {
+		t.Errorf("Search returned %v", items)
+	}
+	if unlocks != 1 {
+		t.Errorf("Listed with %d unlocks, expected the listing to be remembered", unlocks)
+	}
+	os.Chtimes(db, time.Now(), time.Now().Add(time.Minute))
+	s.List(context.Background())
+	if unlocks != 2 {
+		t.Errorf("Listed with %d unlocks, expected a changed database to be listed again", unlocks)
+	}
+
+	rc, err := s.Open(context.Background(), "keepass/example.com/alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if _, ok := rc.(Unencrypted); !ok {
+		t.Error("Entry isn't marked unencrypted")
+	}
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "secret of example.com/alice\nlogin: alice\nurl: https://example.com/login\n"; string(data) != expected {
+		t.Errorf("Opened %q, expected %q", data, expected)
+	}
+
+	if _, err := s.Open(context.Background(), "example.com/alice"); err != ErrNotFound {
+		t.Errorf("Open outside the database returned %v", err)
+	}
+	if err := s.Create("keepass/example.com/bob", []byte("secret")); err != ErrReadOnly {
+		t.Errorf("Create returned %v", err)
+	}
+
+	wrong, _ := NewKdbxStore(db, "", func(w io.Writer) error {
+		_, err := io.WriteString(w, "wrong")
+		return err
+	})
+	if _, err := wrong.List(context.Background()); err == nil {
+		t.Errorf("List with the wrong password returned %v", err)
+	}
+}
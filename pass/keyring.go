@@ -0,0 +1,159 @@
+package pass
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// KeyringPrefix is the directory OS keyring items appear under, as
+// "keyring/<server>/<account>".
+const KeyringPrefix = "keyring/"
+
+// keyringStore reads internet passwords from the OS keyring: the
+// freedesktop Secret Service through secret-tool on Linux, the login
+// keychain through security on macOS.
+type keyringStore struct{}
+
+// NewKeyringStore returns a read-only store of the OS keyring's internet
+// passwords. Its items are not encrypted, see Unencrypted.
+func NewKeyringStore() (Store, error) {
+	if keyringTool == "" {
+		return nil, errors.New("pass: no OS keyring support on this platform")
+	}
+	if _, err := exec.LookPath(keyringTool); err != nil {
+		return nil, err
+	}
+	return keyringStore{}, nil
+}
+
+// Search returns the keyring items for the server named query. Keyrings
+// only match servers exactly.
+func (keyringStore) Search(ctx context.Context, query string, opts ...SearchOption) ([]string, error) {
+	if query == "" {
+		return nil, nil
+	}
+	cmd := keyringSearchCommand(ctx, query)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	accounts, err := parseKeyringAccounts(out)
+	io.Copy(io.Discard, out)
+	if werr := cmd.Wait(); err == nil && werr != nil && len(accounts) > 0 {
+		err = werr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, account := range accounts {
+		matches = append(matches, KeyringPrefix+query+"/"+account)
+	}
+	Sort(matches)
+	return Page(matches, opts...), nil
+}
+
+// List returns no items: the keyring tools can't enumerate items without
+// reading every secret, so keyring items only show up in searches naming
+// their server.
+func (keyringStore) List(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+// LookupStream runs a search, the keyring tools only answer whole queries.
+func (k keyringStore) LookupStream(ctx context.Context, domain string) (<-chan string, <-chan error) {
+	return StreamSearch(ctx, k, domain)
+}
+
+// Create fails, the keyring belongs to other apps.
+func (keyringStore) Create(item string, content []byte) error {
+	return ErrReadOnly
+}
+
+// Delete fails, the keyring belongs to other apps.
+func (keyringStore) Delete(item string) error {
+	return ErrReadOnly
+}
+
+func (keyringStore) Open(ctx context.Context, item string) (io.ReadCloser, error) {
+	server, account, ok := strings.Cut(strings.TrimPrefix(item, KeyringPrefix), "/")
+	if !strings.HasPrefix(item, KeyringPrefix) || !ok || server == "" || account == "" {
+		return nil, ErrNotFound
+	}
+	cmd := keyringLookupCommand(ctx, server, account)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &commandReader{out, cmd}, nil
+}
+
+// commandReader streams a secret from the output of a command like the
+// keyring tool, so it is never buffered outside the caller's memory.
+// Failures of the command surface at the end of the stream.
+type commandReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (r *commandReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err == io.EOF && r.cmd != nil {
+		cmd := r.cmd
+		r.cmd = nil
+		if werr := cmd.Wait(); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (r *commandReader) Close() error {
+	err := r.ReadCloser.Close()
+	if r.cmd != nil {
+		r.cmd.Wait()
+		r.cmd = nil
+	}
+	return err
+}
+
+func (r *commandReader) Unencrypted() {}
+
+var secretToolUser = regexp.MustCompile(`^attribute\.user = (.+)$`)
+
+// parseSecretToolAccounts reads the users of the items listed by
+// "secret-tool search". Its output includes the secrets, which are skipped.
+func parseSecretToolAccounts(r io.Reader) ([]string, error) {
+	return scanAccounts(r, secretToolUser)
+}
+
+var securityAccount = regexp.MustCompile(`^\s*"acct"<blob>="(.+)"$`)
+
+// parseSecurityAccounts reads the accounts of the items printed by
+// "security find-internet-password".
+func parseSecurityAccounts(r io.Reader) ([]string, error) {
+	return scanAccounts(r, securityAccount)
+}
+
+func scanAccounts(r io.Reader, re *regexp.Regexp) ([]string, error) {
+	var accounts []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if m := re.FindStringSubmatch(scanner.Text()); m != nil && !strings.Contains(m[1], "/") {
+			accounts = append(accounts, m[1])
+		}
+	}
+	return accounts, scanner.Err()
+}
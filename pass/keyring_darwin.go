@@ -0,0 +1,18 @@
+package pass
+
+import (
+	"context"
+	"os/exec"
+)
+
+const keyringTool = "security"
+
+var parseKeyringAccounts = parseSecurityAccounts
+
+func keyringSearchCommand(ctx context.Context, server string) *exec.Cmd {
+	return exec.CommandContext(ctx, keyringTool, "find-internet-password", "-s", server)
+}
+
+func keyringLookupCommand(ctx context.Context, server, account string) *exec.Cmd {
+	return exec.CommandContext(ctx, keyringTool, "find-internet-password", "-s", server, "-a", account, "-w")
+}
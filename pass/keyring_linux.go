@@ -0,0 +1,18 @@
+package pass
+
+import (
+	"context"
+	"os/exec"
+)
+
+const keyringTool = "secret-tool"
+
+var parseKeyringAccounts = parseSecretToolAccounts
+
+func keyringSearchCommand(ctx context.Context, server string) *exec.Cmd {
+	return exec.CommandContext(ctx, keyringTool, "search", "--all", "server", server)
+}
+
+func keyringLookupCommand(ctx context.Context, server, account string) *exec.Cmd {
+	return exec.CommandContext(ctx, keyringTool, "lookup", "server", server, "user", account)
+}
@@ -0,0 +1,21 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package pass
+
+import (
+	"context"
+	"os/exec"
+)
+
+const keyringTool = ""
+
+var parseKeyringAccounts = parseSecretToolAccounts
+
+func keyringSearchCommand(ctx context.Context, server string) *exec.Cmd {
+	return nil
+}
+
+func keyringLookupCommand(ctx context.Context, server, account string) *exec.Cmd {
+	return nil
+}
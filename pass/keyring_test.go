@@ -0,0 +1,53 @@
+package pass
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseKeyringAccounts(t *testing.T) {
+	secretTool := `[/org/freedesktop/secrets/collection/login/12]
+label = github.com
+secret = hunter2
+schema = org.gnome.keyring.NetworkPassword
+attribute.user = alice
+attribute.server = github.com
+[/org/freedesktop/secrets/collection/login/13]
+label = github.com
+secret = attribute.user = mallory
+attribute.user = bob
+`
+	security := `keychain: "/Users/alice/Library/Keychains/login.keychain-db"
+class: "inet"
+attributes:
+    "acct"<blob>="alice"
+    "srvr"<blob>="github.com"
+`
+	tests := []struct {
+		parse    func(string) ([]string, error)
+		output   string
+		expected []string
+	}{
+		{func(s string) ([]string, error) { return parseSecretToolAccounts(strings.NewReader(s)) }, secretTool, []string{"alice", "bob"}},
+		{func(s string) ([]string, error) { return parseSecurityAccounts(strings.NewReader(s)) }, security, []string{"alice"}},
+	}
+	for _, test := range tests {
+		accounts, err := test.parse(test.output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(accounts, test.expected) {
+			t.Errorf("Expected %v, got %v", test.expected, accounts)
+		}
+	}
+}
+
+func TestKeyringOpenInvalidItem(t *testing.T) {
+	for _, item := range []string{"github.com/alice", "keyring/github.com", "keyring//alice"} {
+		if _, err := (keyringStore{}).Open(context.Background(), item); err != ErrNotFound {
+			t.Errorf("Open(%s): expected ErrNotFound, got %v", item, err)
+		}
+	}
+}
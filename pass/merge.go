@@ -0,0 +1,190 @@
+package pass
+
+import (
+	"context"
+	"io"
+)
+
+// mergedStore searches several stores, preferring the first store holding an
+// item.
+type mergedStore []Store
+
+// Merge returns a store with the items of primary and then of each of
+// others. Items are opened from the first store that has them. Searches and
+// listings leave out others that fail, like a KeePass database that can't be
+// unlocked, rather than failing too.
+func Merge(primary Store, others ...Store) Store {
+	return mergedStore(append([]Store{primary}, others...))
+}
+
+// failed returns the error of the store at i failing a search or listing,
+// nil for other stores than the primary one unless ctx is done.
+func failed(ctx context.Context, i int, err error) error {
+	if i == 0 || ctx.Err() != nil {
+		return err
+	}
+	return nil
+}
+
+// Search pages the items of all stores together.
+func (m mergedStore) Search(ctx context.Context, query string, opts ...SearchOption) ([]string, error) {
+	var matches []string
+	seen := make(map[string]bool)
+	for i, s := range m {
+		list, err := s.Search(ctx, query, matching(opts)...)
+		if err = failed(ctx, i, err); err != nil {
+			return nil, err
+		}
+		for _, item := range list {
+			if !seen[item] {
+				seen[item] = true
+				matches = append(matches, item)
+			}
+		}
+	}
+	return Page(matches, opts...), nil
+}
+
+func (m mergedStore) List(ctx context.Context) ([]string, error) {
+	var items []string
+	seen := make(map[string]bool)
+	for i, s := range m {
+		list, err := s.List(ctx)
+		if err = failed(ctx, i, err); err != nil {
+			return nil, err
+		}
+		for _, item := range list {
+			if !seen[item] {
+				seen[item] = true
+				items = append(items, item)
+			}
+		}
+	}
+	return items, nil
+}
+
+// LookupStream streams the items of each store in turn, skipping items
+// found in an earlier store and, like Search, stores that fail after the
+// primary one.
+func (m mergedStore) LookupStream(ctx context.Context, domain string) (<-chan string, <-chan error) {
+	seen := make(map[string]bool)
+	return chainStreams(len(m), func(i int) (<-chan string, <-chan error) {
+		items, errs := m[i].LookupStream(ctx, domain)
+		kept := make(chan error, 1)
+		go func() {
+			kept <- failed(ctx, i, <-errs)
+		}()
+		return items, kept
+	}, func(_ int, item string) (string, bool) {
+		if seen[item] {
+			return "", false
+		}
+		seen[item] = true
+		return item, true
+	})
+}
+
+func (m mergedStore) Open(ctx context.Context, item string) (io.ReadCloser, error) {
+	for _, s := range m {
+		rc, err := s.Open(ctx, item)
+		if err != ErrNotFound {
+			return rc, err
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Create creates item in the primary store.
+func (m mergedStore) Create(item string, content []byte) error {
+	return m[0].Create(item, content)
+}
+
+// Delete deletes item from the first store holding it.
+func (m mergedStore) Delete(item string) error {
+	for _, s := range m {
+		if err := s.Delete(item); err != ErrNotFound {
+			return err
+		}
+	}
+	return ErrNotFound
+}
+
+// Update implements Updater, updating item in the first store holding it.
+func (m mergedStore) Update(item string, content []byte) error {
+	for _, s := range m {
+		rc, err := s.Open(context.Background(), item)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		rc.Close()
+		if u, ok := s.(Updater); ok {
+			return u.Update(item, content)
+		}
+		return ErrReadOnly
+	}
+	return ErrNotFound
+}
+
+// Move implements Mover, moving src in the first store holding it.
+func (m mergedStore) Move(src, dst string) error {
+	for _, s := range m {
+		if err := Move(s, src, dst); err != ErrNotFound {
+			return err
+		}
+	}
+	return ErrNotFound
+}
+
+// Diagnose implements Diagnoser for the merged stores that do.
+func (m mergedStore) Diagnose(ctx context.Context) ([]Problem, error) {
+	var problems []Problem
+	for _, s := range m {
+		found, err := Diagnose(ctx, s)
+		problems = append(problems, found...)
+		if err != nil {
+			return problems, err
+		}
+	}
+	return problems, nil
+}
+
+// Stats implements Statter for the merged stores that do.
+func (m mergedStore) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	for _, s := range m {
+		found, err := FileStats(ctx, s)
+		if err != nil {
+			return Stats{}, err
+		}
+		stats.add(found)
+	}
+	return stats, nil
+}
+
+// StoreKeys implements Keyed for the primary store.
+func (m mergedStore) StoreKeys() ([]string, error) {
+	return KeysOf(m[0])
+}
+
+// Init implements Initializer for the primary store.
+func (m mergedStore) Init(recipients []string) error {
+	return Init(m[0], recipients)
+}
+
+// Warnings implements Checker for the merged stores that do.
+func (m mergedStore) Warnings() ([]string, error) {
+	var warnings []string
+	for _, s := range m {
+		if c, ok := s.(Checker); ok {
+			list, err := c.Warnings()
+			if err != nil {
+				return nil, err
+			}
+			warnings = append(warnings, list...)
+		}
+	}
+	return warnings, nil
+}
@@ -0,0 +1,73 @@
+package pass
+
+import (
+	"context"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMerge(t *testing.T) {
+	primary := &diskStore{fsys: fstest.MapFS{
+		"github.com/alice.gpg": {Data: []byte("primary")},
+	}}
+	secondary := &diskStore{fsys: fstest.MapFS{
+		"github.com/alice.gpg": {Data: []byte("secondary")},
+		"github.com/bob.gpg":   {Data: []byte("secondary")},
+	}}
+	s := Merge(primary, secondary)
+
+	list, err := s.Search(context.Background(), "github.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"github.com/alice", "github.com/bob"}; !reflect.DeepEqual(list, expected) {
+		t.Errorf("Expected %v, got %v", expected, list)
+	}
+
+	for item, expected := range map[string]string{"github.com/alice": "primary", "github.com/bob": "secondary"} {
+		rc, err := s.Open(context.Background(), item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		if string(data) != expected {
+			t.Errorf("%s opened from %s, expected %s", item, data, expected)
+		}
+	}
+	if list, _ := s.List(context.Background()); !reflect.DeepEqual(list, []string{"github.com/alice", "github.com/bob"}) {
+		t.Errorf("List returned %v", list)
+	}
+	if _, err := s.Open(context.Background(), "gitlab.com/alice"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMergeFailing(t *testing.T) {
+	primary := &diskStore{fsys: fstest.MapFS{
+		"github.com/alice.gpg": {Data: []byte("primary")},
+	}}
+	s := Merge(primary, &pendingStore{path: t.TempDir()})
+	if list, err := s.Search(context.Background(), "github.com"); err != nil || !reflect.DeepEqual(list, []string{"github.com/alice"}) {
+		t.Errorf("Search returned %v, %v", list, err)
+	}
+	if list, err := s.List(context.Background()); err != nil || !reflect.DeepEqual(list, []string{"github.com/alice"}) {
+		t.Errorf("List returned %v, %v", list, err)
+	}
+	items, errs := s.LookupStream(context.Background(), "github.com")
+	var list []string
+	for item := range items {
+		list = append(list, item)
+	}
+	if err := <-errs; err != nil || !reflect.DeepEqual(list, []string{"github.com/alice"}) {
+		t.Errorf("LookupStream returned %v, %v", list, err)
+	}
+
+	// The primary store failing still fails them
+	s = Merge(&pendingStore{path: t.TempDir()}, primary)
+	if _, err := s.Search(context.Background(), "github.com"); err != ErrStoreNotInitialized {
+		t.Errorf("Search returned %v", err)
+	}
+}
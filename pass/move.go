@@ -0,0 +1,135 @@
+package pass
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ErrOtherRecipients is returned when moving entries to a folder whose
+// .gpg-id lists other keys, which would need them re-encrypted.
+var ErrOtherRecipients = errors.New("pass: destination is encrypted to other keys")
+
+// ErrOtherStore is returned when moving entries between the stores of a
+// MultiStore or gopass mounts.
+var ErrOtherStore = errors.New("pass: can't move entries to another store")
+
+// Mover is implemented by stores that can rename entries and folders.
+type Mover interface {
+	// Move renames the entry src to dst, like pass mv. With a trailing
+	// slash, or if there is no such entry, src names a folder, which is
+	// moved with everything in it. dst is the new name; Move fails with
+	// ErrExists if it is taken and with ErrNotFound if src doesn't exist.
+	Move(src, dst string) error
+}
+
+// Move renames src to dst in s, see Mover.
+func Move(s Store, src, dst string) error {
+	if m, ok := s.(Mover); ok {
+		return m.Move(src, dst)
+	}
+	return ErrReadOnly
+}
+
+// IsFolder reports whether src names a folder for Move even if there is an
+// entry of that name, by ending in a slash.
+func IsFolder(src string) bool {
+	return strings.HasSuffix(src, "/")
+}
+
+// Moved returns the name of item once the entry src, or with a trailing
+// slash the folder src, moved to dst, and whether it moved at all.
+func Moved(item, src, dst string) (string, bool) {
+	if !IsFolder(src) {
+		if item == src {
+			return dst, true
+		}
+		return item, false
+	}
+	if rest, ok := strings.CutPrefix(item, src); ok {
+		return path.Join(dst, rest), true
+	}
+	return item, false
+}
+
+// Move implements Mover. Folders that move take their .gpg-id along, entries
+// that would end up under another one are refused with ErrOtherRecipients.
+// The folders src leaves empty are removed, like with Delete.
+func (s *diskStore) Move(src, dst string) error {
+	wfs, ok := s.fsys.(WriteFS)
+	if !ok {
+		return ErrReadOnly
+	}
+	from, to, err := s.resolveMove(src, dst)
+	if err != nil {
+		return err
+	}
+	if to == from || strings.HasPrefix(to, from+"/") {
+		return &fs.PathError{Op: "move", Path: dst, Err: fs.ErrInvalid}
+	}
+	if _, err := fs.Stat(s.fsys, to); err == nil {
+		return ErrExists
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	// A folder with its own .gpg-id keeps its keys wherever it goes
+	keys := path.Dir(from)
+	if _, err := fs.Stat(s.fsys, path.Join(from, ".gpg-id")); err == nil {
+		keys = from
+	}
+	if keys != from {
+		before, err := s.recipients(keys)
+		if err != nil {
+			return err
+		}
+		after, err := s.recipients(path.Dir(to))
+		if err != nil {
+			return err
+		}
+		slices.Sort(before)
+		slices.Sort(after)
+		if !slices.Equal(before, after) {
+			return ErrOtherRecipients
+		}
+	}
+
+	if err := wfs.MkdirAll(path.Dir(to), 0700); err != nil {
+		return err
+	}
+	if err := wfs.Rename(from, to); err != nil {
+		return err
+	}
+	removeEmpty(wfs, path.Dir(from))
+	return nil
+}
+
+// resolveMove returns the files or folders of a move from src to dst.
+func (s *diskStore) resolveMove(src, dst string) (from, to string, err error) {
+	if !IsFolder(src) {
+		if from, err = s.resolveItem("move", src); err != nil {
+			return "", "", err
+		}
+		if _, err := fs.Stat(s.fsys, from); err == nil {
+			to, err = s.resolveItem("move", strings.TrimSuffix(dst, "/"))
+			return from, to, err
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", "", err
+		}
+	}
+
+	if from, err = s.resolvePath("move", src, strings.TrimSuffix(src, "/")); err != nil {
+		return "", "", err
+	}
+	info, err := fs.Stat(s.fsys, from)
+	if errors.Is(err, fs.ErrNotExist) || err == nil && !info.IsDir() {
+		return "", "", ErrNotFound
+	}
+	if err != nil {
+		return "", "", err
+	}
+	to, err = s.resolvePath("move", dst, strings.TrimSuffix(dst, "/"))
+	return from, to, err
+}
@@ -0,0 +1,80 @@
+package pass
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMove(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		".gpg-id":                  "alice@example.com\n",
+		"example.com/alice.gpg":    "",
+		"example.com/bob.gpg":      "",
+		"old/example.org/dan.gpg":  "",
+		"work/.gpg-id":             "alice@example.com\nbob@example.com\n",
+		"work/example.com/eve.gpg": "",
+		"shared/.gpg-id":           "bob@example.com\n",
+	} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0700)
+		os.WriteFile(filepath.Join(dir, name), []byte(data), 0600)
+	}
+	s, err := NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		src, dst string
+		err      error
+	}{
+		{"example.com/alice", "example.net/alice", nil},
+		{"example.com/", "example.org", nil},
+		{"old/example.org", "example.edu", nil},
+		{"work", "team", nil},
+		{"example.org/bob", "example.net/alice", ErrExists},
+		{"example.org/carol", "example.net/carol", ErrNotFound},
+		{"example.net/alice", "shared/alice", ErrOtherRecipients},
+		{"example.net", "example.net/sub", fs.ErrInvalid},
+		{"example.net/alice", "../alice", fs.ErrInvalid},
+	}
+	for _, test := range tests {
+		if err := Move(s, test.src, test.dst); !errors.Is(err, test.err) {
+			t.Errorf("Move(%q, %q) returned %v, expected %v", test.src, test.dst, err, test.err)
+		}
+	}
+
+	items, err := s.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"example.edu/dan", "example.net/alice", "example.org/bob", "team/example.com/eve"}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("Items are %v, expected %v", items, expected)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old")); !os.IsNotExist(err) {
+		t.Errorf("Emptied folder is left: %v", err)
+	}
+}
+
+func TestMoved(t *testing.T) {
+	tests := []struct {
+		item, src, name string
+		moved           bool
+	}{
+		{"example.com/alice", "example.com/alice", "new", true},
+		{"example.com/alice", "example.com/", "new/alice", true},
+		{"example.com/alice", "example.com", "example.com/alice", false},
+		{"example.community/alice", "example.com/", "example.community/alice", false},
+	}
+	for _, test := range tests {
+		if name, moved := Moved(test.item, test.src, "new"); name != test.name || moved != test.moved {
+			t.Errorf("Moved(%q, %q) returned %q, %v", test.item, test.src, name, moved)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package pass
+
+import (
+	"context"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// NamedStore is a store of a MultiStore.
+type NamedStore struct {
+	Name string
+	Store
+}
+
+// MultiStore serves several stores at once, such as a personal and a work
+// store. Items of a named store are qualified with its name, as
+// "work:example.com/alice"; the store named "" keeps unqualified names.
+type MultiStore []NamedStore
+
+// Search pages the items of all stores together.
+func (m MultiStore) Search(ctx context.Context, query string, opts ...SearchOption) ([]string, error) {
+	items, err := m.collect(func(s Store) ([]string, error) { return s.Search(ctx, query, matching(opts)...) })
+	return Page(items, opts...), err
+}
+
+func (m MultiStore) List(ctx context.Context) ([]string, error) {
+	return m.collect(func(s Store) ([]string, error) { return s.List(ctx) })
+}
+
+// LookupStream streams the items of each store in turn, qualified with its
+// name.
+func (m MultiStore) LookupStream(ctx context.Context, domain string) (<-chan string, <-chan error) {
+	return chainStreams(len(m), func(i int) (<-chan string, <-chan error) {
+		return m[i].LookupStream(ctx, domain)
+	}, func(i int, item string) (string, bool) {
+		return Qualify(m[i].Name, item), true
+	})
+}
+
+func (m MultiStore) collect(f func(Store) ([]string, error)) ([]string, error) {
+	var items []string
+	for _, s := range m {
+		list, err := f(s.Store)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list {
+			items = append(items, Qualify(s.Name, item))
+		}
+	}
+	return items, nil
+}
+
+// route returns the index of the store item is in and its name there: the
+// store it is qualified with if one has that name, otherwise the unqualified
+// store, so "localhost:8080/admin" is an item of the latter. It returns -1
+// if there is no such store.
+func (m MultiStore) route(item string) (int, string) {
+	name, rest := SplitQualified(item)
+	if name != "" {
+		for i, s := range m {
+			if s.Name == name {
+				return i, rest
+			}
+		}
+	}
+	for i, s := range m {
+		if s.Name == "" {
+			return i, item
+		}
+	}
+	return -1, ""
+}
+
+func (m MultiStore) Open(ctx context.Context, item string) (io.ReadCloser, error) {
+	i, rest := m.route(item)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	return m[i].Open(ctx, rest)
+}
+
+// Create creates item in the store it is qualified with.
+func (m MultiStore) Create(item string, content []byte) error {
+	i, rest := m.route(item)
+	if i < 0 {
+		return ErrNotFound
+	}
+	return m[i].Create(rest, content)
+}
+
+// Delete deletes item from the store it is qualified with.
+func (m MultiStore) Delete(item string) error {
+	i, rest := m.route(item)
+	if i < 0 {
+		return ErrNotFound
+	}
+	return m[i].Delete(rest)
+}
+
+// Update implements Updater for the stores that do.
+func (m MultiStore) Update(item string, content []byte) error {
+	i, rest := m.route(item)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if u, ok := m[i].Store.(Updater); ok {
+		return u.Update(rest, content)
+	}
+	return ErrReadOnly
+}
+
+// Move implements Mover within the stores that do, src and dst must be
+// in the same one.
+func (m MultiStore) Move(src, dst string) error {
+	i, from := m.route(src)
+	if i < 0 {
+		return ErrNotFound
+	}
+	j, to := m.route(dst)
+	if j != i {
+		return ErrOtherStore
+	}
+	return Move(m[i].Store, from, to)
+}
+
+// Warnings implements Checker for the stores that do.
+func (m MultiStore) Warnings() ([]string, error) {
+	var warnings []string
+	for _, s := range m {
+		if c, ok := s.Store.(Checker); ok {
+			list, err := c.Warnings()
+			if err != nil {
+				return nil, err
+			}
+			warnings = append(warnings, list...)
+		}
+	}
+	return warnings, nil
+}
+
+// Diagnose implements Diagnoser for the stores that do, qualifying paths
+// with the name of their store.
+func (m MultiStore) Diagnose(ctx context.Context) ([]Problem, error) {
+	var problems []Problem
+	for _, s := range m {
+		found, err := Diagnose(ctx, s.Store)
+		for _, p := range found {
+			p.Path = Qualify(s.Name, p.Path)
+			problems = append(problems, p)
+		}
+		if err != nil {
+			return problems, err
+		}
+	}
+	return problems, nil
+}
+
+// Stats implements Statter for the stores that do.
+func (m MultiStore) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	for _, s := range m {
+		found, err := FileStats(ctx, s.Store)
+		if err != nil {
+			return Stats{}, err
+		}
+		stats.add(found)
+	}
+	return stats, nil
+}
+
+// StoreKeys implements Keyed for the unnamed store.
+func (m MultiStore) StoreKeys() ([]string, error) {
+	return KeysOf(m[0].Store)
+}
+
+// Init implements Initializer for the unnamed store.
+func (m MultiStore) Init(recipients []string) error {
+	return Init(m[0].Store, recipients)
+}
+
+// Qualify returns item qualified with the name of its store.
+func Qualify(store, item string) string {
+	if store == "" {
+		return item
+	}
+	return store + ":" + item
+}
+
+// SplitQualified splits a qualified item into its store name and its name
+// in that store. Store names can't contain ".", so "example.com:8443/alice"
+// is an unqualified item.
+func SplitQualified(item string) (store, rest string) {
+	name, rest, ok := strings.Cut(item, ":")
+	if !ok || name == "" || strings.ContainsAny(name, "./\\") {
+		return "", item
+	}
+	return name, rest
+}
+
+// NewMultiStore returns the store at primary, unqualified, along with the
+// stores in dirs under their names, ordered by name. dirs may hold URLs of
+// remote stores, see OpenStore.
+func NewMultiStore(primary Store, dirs map[string]string) (MultiStore, error) {
+	m := MultiStore{{"", primary}}
+	var names []string
+	for name := range dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if store, _ := SplitQualified(name + ":"); store != name {
+			return nil, &os.PathError{Op: "open store", Path: name, Err: os.ErrInvalid}
+		}
+		s, err := OpenStore(dirs[name])
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, NamedStore{name, s})
+	}
+	return m, nil
+}
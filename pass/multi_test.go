@@ -0,0 +1,69 @@
+package pass
+
+import (
+	"context"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMultiStore(t *testing.T) {
+	m := MultiStore{
+		{"", &diskStore{fsys: fstest.MapFS{"example.com/alice.gpg": {Data: []byte("personal")}, "localhost:8080/admin.gpg": {Data: []byte("router")}}}},
+		{"work", &diskStore{fsys: fstest.MapFS{"example.com/alice.gpg": {Data: []byte("work")}}}},
+	}
+
+	list, err := m.Search(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"example.com/alice", "work:example.com/alice"}; !reflect.DeepEqual(list, expected) {
+		t.Errorf("Expected %v, got %v", expected, list)
+	}
+
+	// Names with a colon are only qualified if a store has that name
+	for item, expected := range map[string]string{"example.com/alice": "personal", "work:example.com/alice": "work", "localhost:8080/admin": "router"} {
+		rc, err := m.Open(context.Background(), item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		if string(data) != expected {
+			t.Errorf("%s opened from %s, expected %s", item, data, expected)
+		}
+	}
+	if _, err := m.Open(context.Background(), "home:example.com/alice"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an unknown store, got %v", err)
+	}
+	if err := m.Delete("localhost:8080/admin"); err != ErrReadOnly {
+		t.Errorf("Delete went to another store than the unqualified one: %v", err)
+	}
+	if err := m.Move("localhost:8080/admin", "work:localhost/admin"); err != ErrOtherStore {
+		t.Errorf("Move to another store returned %v", err)
+	}
+
+	settings, err := SettingsOf(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := settings.DomainNames("localhost:8080/admin"); !reflect.DeepEqual(names, []string{"localhost:8080", "admin"}) {
+		t.Errorf("DomainNames returned %v", names)
+	}
+}
+
+func TestSplitQualified(t *testing.T) {
+	tests := map[string][2]string{
+		"work:example.com/alice": {"work", "example.com/alice"},
+		"example.com/alice":      {"", "example.com/alice"},
+		"example.com:8443/alice": {"", "example.com:8443/alice"},
+		"a/b:c":                  {"", "a/b:c"},
+		":example.com":           {"", ":example.com"},
+	}
+	for item, expected := range tests {
+		if store, rest := SplitQualified(item); store != expected[0] || rest != expected[1] {
+			t.Errorf("SplitQualified(%s): expected %q, %q, got %q, %q", item, expected[0], expected[1], store, rest)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package pass
+
+// Modes of matching queries, see WithMode.
+const (
+	// SearchPrefix matches items with a name or directory starting with the
+	// query, or fuzzily if FuzzySearch is set
+	SearchPrefix = "prefix"
+	// SearchSubstring matches items whose name holds the query anywhere
+	SearchSubstring = "substring"
+	// SearchRegex matches items whose name matches the query as a regular
+	// expression, see regexp/syntax
+	SearchRegex = "regex"
+)
+
+// SearchOptions select how queries match and a page of search results.
+type SearchOptions struct {
+	// Mode is how queries match, SearchPrefix if empty
+	Mode string
+	// Limit is the most results returned, 0 for all
+	Limit int
+	// Offset is the number of results skipped
+	Offset int
+}
+
+// SearchOption sets a field of SearchOptions.
+type SearchOption func(*SearchOptions)
+
+// WithMode matches queries in mode, one of SearchPrefix, SearchSubstring
+// and SearchRegex. Queries match case-insensitively and, but as regular
+// expressions, literally.
+func WithMode(mode string) SearchOption {
+	return func(o *SearchOptions) { o.Mode = mode }
+}
+
+// matching returns the options among opts selecting how queries match, for
+// stores passing a search on before paging its results themselves.
+func matching(opts []SearchOption) []SearchOption {
+	var o SearchOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.Mode == "" {
+		return nil
+	}
+	return []SearchOption{WithMode(o.Mode)}
+}
+
+// WithLimit returns at most n results.
+func WithLimit(n int) SearchOption {
+	return func(o *SearchOptions) { o.Limit = n }
+}
+
+// WithOffset skips the first n results.
+func WithOffset(n int) SearchOption {
+	return func(o *SearchOptions) { o.Offset = n }
+}
+
+// Page returns the page of items opts select.
+func Page(items []string, opts ...SearchOption) []string {
+	var o SearchOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.Offset > len(items) {
+		o.Offset = len(items)
+	}
+	if o.Offset > 0 {
+		items = items[o.Offset:]
+	}
+	if o.Limit > 0 && o.Limit < len(items) {
+		items = items[:o.Limit]
+	}
+	return items
+}
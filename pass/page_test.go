@@ -0,0 +1,26 @@
+package pass
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPage(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e"}
+	tests := []struct {
+		opts     []SearchOption
+		expected []string
+	}{
+		{nil, items},
+		{[]SearchOption{WithLimit(2)}, []string{"a", "b"}},
+		{[]SearchOption{WithLimit(2), WithOffset(2)}, []string{"c", "d"}},
+		{[]SearchOption{WithOffset(4), WithLimit(2)}, []string{"e"}},
+		{[]SearchOption{WithOffset(9)}, []string{}},
+		{[]SearchOption{WithLimit(9)}, items},
+	}
+	for _, test := range tests {
+		if actual := Page(items, test.opts...); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Expected %v, got %v", test.expected, actual)
+		}
+	}
+}
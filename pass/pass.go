@@ -2,15 +2,68 @@
 package pass
 
 import (
+	"context"
 	"errors"
 	"io"
+
+	"github.com/dannyvankooten/browserpass/gpg"
+)
+
+var (
+	// ErrNotFound is returned by Store.Open if the requested item is not
+	// found.
+	ErrNotFound = errors.New("pass: not found")
+	// ErrExists is returned by Store.Create if the item already exists.
+	ErrExists = errors.New("pass: already exists")
+	// ErrReadOnly is returned by write operations on stores that can't be
+	// modified.
+	ErrReadOnly = errors.New("pass: store is read-only")
+	// ErrStoreNotInitialized is returned by the operations of stores whose
+	// directory doesn't exist yet, until Init creates it.
+	ErrStoreNotInitialized = errors.New("pass: store is not initialized")
 )
 
-// ErrNotFound is returned by Store.Open if the requested item is not found.
-var ErrNotFound = errors.New("pass: not found")
+// Causes of failed decryptions, told from the status output of gpg. Errors
+// of Open and of reading its content match them with errors.Is.
+var (
+	ErrBadPassphrase = gpg.ErrBadPassphrase
+	ErrNoSecretKey   = gpg.ErrNoSecretKey
+	ErrKeyExpired    = gpg.ErrKeyExpired
+	ErrCanceled      = gpg.ErrCanceled
+)
 
-// Store is a password store.
+// Store is a password store. Walks, searches and the commands stores run
+// stop once their context is done, failing with its error.
 type Store interface {
-	Search(query string) ([]string, error)
-	Open(item string) (io.ReadCloser, error)
+	// Search returns the items matching query, the page of them opts ask
+	// for if any.
+	Search(ctx context.Context, query string, opts ...SearchOption) ([]string, error)
+	// List returns every item in the store.
+	List(ctx context.Context) ([]string, error)
+	Open(ctx context.Context, item string) (io.ReadCloser, error)
+	// Create adds item to the store, encrypting content for the store's
+	// recipients.
+	Create(item string, content []byte) error
+	// Delete removes item from the store.
+	Delete(item string) error
+	// LookupStream sends the items matching domain, like Search, as they
+	// are found, in no particular order. The items channel is closed once
+	// done, then the error channel yields the error of the search, if any.
+	// Callers must drain the items channel.
+	LookupStream(ctx context.Context, domain string) (<-chan string, <-chan error)
+}
+
+// Updater is implemented by stores whose items can be changed.
+type Updater interface {
+	// Update replaces the content of an existing item, re-encrypting it
+	// for the store's recipients.
+	Update(item string, content []byte) error
+}
+
+// Unencrypted is implemented by the readers Store.Open returns for items
+// their backend keeps unencrypted, like OS keyring entries. They hold the
+// plaintext and must not be decrypted.
+type Unencrypted interface {
+	io.ReadCloser
+	Unencrypted()
 }
@@ -0,0 +1,54 @@
+package pass
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Checker is implemented by stores that can detect configuration problems
+// which expose the store, such as loose file permissions.
+type Checker interface {
+	Warnings() ([]string, error)
+}
+
+// CheckPermissions returns a warning for each way the password store at path
+// is exposed to other users: group or world accessible directories, a
+// .gpg-id others can modify, or a filesystem without permission support.
+func CheckPermissions(path string) ([]string, error) {
+	var warnings []string
+
+	fat, err := isFATFilesystem(path)
+	if err != nil {
+		return nil, err
+	}
+	if fat {
+		warnings = append(warnings, fmt.Sprintf("%s is on a FAT filesystem, which can't restrict access to the store", path))
+	}
+
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(path, p)
+		switch {
+		case info.IsDir() && info.Name() == ".git" && p != path:
+			return filepath.SkipDir
+		case info.IsDir() && info.Mode().Perm()&0077 != 0:
+			warnings = append(warnings, fmt.Sprintf("directory %s is accessible by other users (mode %04o)", rel, info.Mode().Perm()))
+		case info.Name() == ".gpg-id" && info.Mode().Perm()&0022 != 0:
+			warnings = append(warnings, fmt.Sprintf("%s is writable by other users (mode %04o)", rel, info.Mode().Perm()))
+		}
+		return nil
+	})
+	if fat {
+		// Every file would be reported, the filesystem warning says it all
+		return warnings[:1], err
+	}
+	return warnings, err
+}
+
+// Warnings reports permission problems of the store.
+func (s *diskStore) Warnings() ([]string, error) {
+	return CheckPermissions(s.path)
+}
@@ -0,0 +1,273 @@
+package pass
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	// SettingsFile is the file at the root of a store holding its settings.
+	SettingsFile = ".browserpass.json"
+	// AliasesFile is the file at the root of a store listing the domains
+	// that use the entries of another domain, one domain a line followed by
+	// its aliases: "login.corp.com: jira.corp.com, wiki.corp.com".
+	AliasesFile = ".aliases"
+)
+
+// Sources of usernames, see Settings.UsernameFrom.
+const (
+	// UsernameFromBody is the lines of the entry, like "login: alice"
+	UsernameFromBody = "body"
+	// UsernameFromFilename is the name of the entry, like "example.com/alice"
+	UsernameFromFilename = "filename"
+	// UsernameFromDirectory is the name of the entry's directory, like
+	// "example.com/alice/main"
+	UsernameFromDirectory = "directory"
+)
+
+// DefaultUsernameFrom is the order usernames are looked for in if a store
+// doesn't set one.
+var DefaultUsernameFrom = []string{UsernameFromBody, UsernameFromFilename}
+
+// Settings are the settings a store carries in its SettingsFile, so that
+// everyone sharing the store gets the same behavior.
+type Settings struct {
+	// UsernameFields are the keys of the lines holding the username, like
+	// "email", tried before the usual "login:" and "username:" lines.
+	UsernameFields []string `json:"username_fields"`
+	// UsernameFrom lists the sources of the username in the order they are
+	// tried, DefaultUsernameFrom if empty.
+	UsernameFrom []string `json:"username_from"`
+	// Aliases maps domains to the domain whose entries they use, like
+	// "youtube.com" to "google.com".
+	Aliases map[string]string `json:"aliases"`
+	// Ignore holds path.Match patterns of items and directories left out
+	// of the store, like "old/*".
+	Ignore []string `json:"ignore"`
+	// DomainSegments are the positions of the segments of entry names that
+	// name domains, counted from 0 at the root or from -1 for the last
+	// segment backwards: [1] or [-2] for "sites/example.com/alice". Every
+	// segment may name a domain if it is empty.
+	DomainSegments []int `json:"domain_segments"`
+
+	// usernameFrom and domainSegments hold the UsernameFrom and
+	// DomainSegments of merged stores by store name
+	usernameFrom   map[string][]string
+	domainSegments map[string][]int
+	// stores are the names of the merged stores, which items may be
+	// qualified with; any name may be if nil
+	stores map[string]bool
+}
+
+// Configured is implemented by stores with settings.
+type Configured interface {
+	StoreSettings() (*Settings, error)
+}
+
+// SettingsOf returns the settings of s, which are empty if s has none.
+func SettingsOf(s Store) (*Settings, error) {
+	if c, ok := s.(Configured); ok {
+		return c.StoreSettings()
+	}
+	return &Settings{}, nil
+}
+
+// StoreSettings implements Configured, reading the SettingsFile and
+// AliasesFile of the store if there are any. Aliases in the SettingsFile
+// win.
+func (s *diskStore) StoreSettings() (*Settings, error) {
+	settings := new(Settings)
+	data, err := fs.ReadFile(s.fsys, SettingsFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, settings); err != nil {
+			return nil, &fs.PathError{Op: "parse", Path: SettingsFile, Err: err}
+		}
+		for _, pattern := range settings.Ignore {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, &fs.PathError{Op: "parse", Path: SettingsFile, Err: err}
+			}
+		}
+		for _, source := range settings.UsernameFrom {
+			if source != UsernameFromBody && source != UsernameFromFilename && source != UsernameFromDirectory {
+				return nil, &fs.PathError{Op: "parse", Path: SettingsFile, Err: fmt.Errorf("unknown username source %q", source)}
+			}
+		}
+	}
+
+	data, err = fs.ReadFile(s.fsys, AliasesFile)
+	if os.IsNotExist(err) {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := parseAliases(string(data))
+	if err != nil {
+		return nil, &fs.PathError{Op: "parse", Path: AliasesFile, Err: err}
+	}
+	settings.merge("", &Settings{Aliases: aliases})
+	return settings, nil
+}
+
+// parseAliases parses the lines of an AliasesFile into a map of aliases to
+// the domain they use. Blank lines and lines starting with "#" are skipped.
+func parseAliases(data string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	for n, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domain, list, ok := strings.Cut(line, ":")
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if !ok || domain == "" {
+			return nil, fmt.Errorf("line %d: expected \"domain: alias, ...\"", n+1)
+		}
+		for _, alias := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			aliases[strings.ToLower(alias)] = domain
+		}
+	}
+	return aliases, nil
+}
+
+// ignored reports whether name, an item or directory, matches one of the
+// Ignore patterns.
+func (st *Settings) ignored(name string) bool {
+	for _, pattern := range st.Ignore {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// UsernameOrder returns the sources of the username of item in the order
+// they are tried, those of the store item is in.
+func (st *Settings) UsernameOrder(item string) []string {
+	store, _ := st.split(item)
+	if order := st.usernameFrom[store]; len(order) > 0 {
+		return order
+	}
+	if order := st.ownUsernameFrom(); len(order) > 0 {
+		return order
+	}
+	return DefaultUsernameFrom
+}
+
+// ownUsernameFrom returns the username order of the unqualified store.
+func (st *Settings) ownUsernameFrom() []string {
+	if order := st.usernameFrom[""]; len(order) > 0 {
+		return order
+	}
+	return st.UsernameFrom
+}
+
+// DomainNames returns the segments of the name of item that may name its
+// domain, following the DomainSegments of the store item is in.
+func (st *Settings) DomainNames(item string) []string {
+	store, name := st.split(item)
+	segments := strings.Split(name, "/")
+	positions := st.domainSegments[store]
+	if len(positions) == 0 {
+		positions = st.ownDomainSegments()
+	}
+	if len(positions) == 0 {
+		return segments
+	}
+	var names []string
+	for _, i := range positions {
+		if i < 0 {
+			i += len(segments)
+		}
+		if i >= 0 && i < len(segments) {
+			names = append(names, segments[i])
+		}
+	}
+	return names
+}
+
+// ownDomainSegments returns the DomainSegments of the unqualified store.
+func (st *Settings) ownDomainSegments() []int {
+	if positions := st.domainSegments[""]; len(positions) > 0 {
+		return positions
+	}
+	return st.DomainSegments
+}
+
+// split is SplitQualified for the stores merged into st: items qualified
+// with another name, like "localhost:8080/admin", are unqualified.
+func (st *Settings) split(item string) (store, rest string) {
+	store, rest = SplitQualified(item)
+	if store != "" && st.stores != nil && !st.stores[store] {
+		return "", item
+	}
+	return store, rest
+}
+
+// merge adds the settings of other, qualified with its store name, to st.
+// Earlier aliases, username orders and domain segments win.
+func (st *Settings) merge(store string, other *Settings) {
+	if st.stores == nil {
+		st.stores = make(map[string]bool)
+	}
+	st.stores[store] = true
+	st.UsernameFields = append(st.UsernameFields, other.UsernameFields...)
+	if order := other.ownUsernameFrom(); len(order) > 0 && st.usernameFrom[store] == nil {
+		if st.usernameFrom == nil {
+			st.usernameFrom = make(map[string][]string)
+		}
+		st.usernameFrom[store] = order
+	}
+	if positions := other.ownDomainSegments(); len(positions) > 0 && st.domainSegments[store] == nil {
+		if st.domainSegments == nil {
+			st.domainSegments = make(map[string][]int)
+		}
+		st.domainSegments[store] = positions
+	}
+	for domain, alias := range other.Aliases {
+		if _, ok := st.Aliases[domain]; !ok {
+			if st.Aliases == nil {
+				st.Aliases = make(map[string]string)
+			}
+			st.Aliases[domain] = alias
+		}
+	}
+	for _, pattern := range other.Ignore {
+		st.Ignore = append(st.Ignore, Qualify(store, pattern))
+	}
+}
+
+// StoreSettings implements Configured with the settings of every store that
+// has some.
+func (m mergedStore) StoreSettings() (*Settings, error) {
+	settings := new(Settings)
+	for _, s := range m {
+		other, err := SettingsOf(s)
+		if err != nil {
+			return nil, err
+		}
+		settings.merge("", other)
+	}
+	return settings, nil
+}
+
+// StoreSettings implements Configured with the settings of every store that
+// has some, their Ignore patterns qualified with the store's name.
+func (m MultiStore) StoreSettings() (*Settings, error) {
+	settings := new(Settings)
+	for _, s := range m {
+		other, err := SettingsOf(s.Store)
+		if err != nil {
+			return nil, err
+		}
+		settings.merge(s.Name, other)
+	}
+	return settings, nil
+}
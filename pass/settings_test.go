@@ -0,0 +1,143 @@
+package pass
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStoreSettings(t *testing.T) {
+	s := &diskStore{fsys: fstest.MapFS{
+		SettingsFile: {Data: []byte(`{
+			"username_fields": ["email"],
+			"aliases": {"youtube.com": "google.com"},
+			"ignore": ["old", "*/test-*"]
+		}`)},
+		"google.com/alice.gpg":        {Data: []byte("alice")},
+		"old/google.com/alice.gpg":    {Data: []byte("old")},
+		"example.com/test-bob.gpg":    {Data: []byte("test")},
+		"example.com/bob.gpg":         {Data: []byte("bob")},
+		"example.com/test-bob.gpg.md": {Data: []byte("not an entry")},
+	}}
+
+	settings, err := s.StoreSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Settings{
+		UsernameFields: []string{"email"},
+		Aliases:        map[string]string{"youtube.com": "google.com"},
+		Ignore:         []string{"old", "*/test-*"},
+	}
+	if !reflect.DeepEqual(settings, expected) {
+		t.Errorf("Settings are %+v, expected %+v", settings, expected)
+	}
+
+	items, err := s.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"example.com/bob", "google.com/alice"}; !reflect.DeepEqual(items, expected) {
+		t.Errorf("List returned %v, expected %v", items, expected)
+	}
+}
+
+func TestStoreSettingsMissing(t *testing.T) {
+	s := &diskStore{fsys: fstest.MapFS{"example.com/alice.gpg": {Data: []byte("alice")}}}
+	settings, err := s.StoreSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(settings, &Settings{}) {
+		t.Errorf("Store without settings has %+v", settings)
+	}
+}
+
+func TestStoreSettingsInvalid(t *testing.T) {
+	for _, data := range []string{`{"ignore": "old"}`, `{"ignore": ["[old"]}`, `{"username_from": ["notes"]}`} {
+		s := &diskStore{fsys: fstest.MapFS{SettingsFile: {Data: []byte(data)}}}
+		if _, err := s.StoreSettings(); err == nil {
+			t.Errorf("Loaded invalid settings %s", data)
+		}
+		if _, err := s.List(context.Background()); err == nil {
+			t.Errorf("Listed a store with invalid settings %s", data)
+		}
+	}
+}
+
+func TestStoreSettingsAliasesFile(t *testing.T) {
+	s := &diskStore{fsys: fstest.MapFS{
+		SettingsFile: {Data: []byte(`{"aliases": {"wiki.corp.com": "sso.corp.com"}}`)},
+		AliasesFile:  {Data: []byte("# SSO\nLogin.corp.com: jira.corp.com, wiki.corp.com\n\ngoogle.com: youtube.com\n")},
+	}}
+	settings, err := s.StoreSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"jira.corp.com": "login.corp.com", "wiki.corp.com": "sso.corp.com", "youtube.com": "google.com"}
+	if !reflect.DeepEqual(settings.Aliases, expected) {
+		t.Errorf("Aliases are %v, expected %v", settings.Aliases, expected)
+	}
+
+	s.fsys.(fstest.MapFS)[AliasesFile] = &fstest.MapFile{Data: []byte("google.com youtube.com\n")}
+	if _, err := s.StoreSettings(); err == nil {
+		t.Error("Loaded an invalid aliases file")
+	}
+}
+
+func TestMultiStoreSettings(t *testing.T) {
+	m := MultiStore{
+		{"", &diskStore{fsys: fstest.MapFS{SettingsFile: {Data: []byte(`{"aliases": {"youtube.com": "google.com"}}`)}}}},
+		{"work", &diskStore{fsys: fstest.MapFS{SettingsFile: {Data: []byte(`{
+			"username_fields": ["email"],
+			"aliases": {"youtube.com": "example.com"},
+			"ignore": ["old"]
+		}`)}}}},
+	}
+	settings, err := SettingsOf(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Settings{
+		UsernameFields: []string{"email"},
+		Aliases:        map[string]string{"youtube.com": "google.com"},
+		Ignore:         []string{"work:old"},
+		stores:         map[string]bool{"": true, "work": true},
+	}
+	if !reflect.DeepEqual(settings, expected) {
+		t.Errorf("Settings are %+v, expected %+v", settings, expected)
+	}
+}
+
+func TestUsernameOrder(t *testing.T) {
+	personal := &diskStore{fsys: fstest.MapFS{}}
+	work := &diskStore{fsys: fstest.MapFS{SettingsFile: {Data: []byte(`{"username_from": ["directory", "body"]}`)}}}
+	m := MultiStore{{"", personal}, {"work", Merge(work, personal)}}
+	settings, err := SettingsOf(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order := settings.UsernameOrder("example.com/alice"); !reflect.DeepEqual(order, DefaultUsernameFrom) {
+		t.Errorf("Personal store tries %v", order)
+	}
+	if order := settings.UsernameOrder("work:example.com/alice/main"); !reflect.DeepEqual(order, []string{"directory", "body"}) {
+		t.Errorf("Work store tries %v", order)
+	}
+}
+
+func TestDomainNames(t *testing.T) {
+	personal := &diskStore{fsys: fstest.MapFS{}}
+	work := &diskStore{fsys: fstest.MapFS{SettingsFile: {Data: []byte(`{"domain_segments": [1, -5]}`)}}}
+	m := MultiStore{{"", personal}, {"work", Merge(work, personal)}}
+	settings, err := SettingsOf(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := settings.DomainNames("example.com/sub/alice"); !reflect.DeepEqual(names, []string{"example.com", "sub", "alice"}) {
+		t.Errorf("Personal store names domains with %v", names)
+	}
+	if names := settings.DomainNames("work:sites/example.com/alice"); !reflect.DeepEqual(names, []string{"example.com"}) {
+		t.Errorf("Work store names domains with %v", names)
+	}
+}
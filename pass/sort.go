@@ -0,0 +1,119 @@
+package pass
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Collation is an order for the items returned by Search.
+type Collation int
+
+const (
+	// CollateNatural ignores case and accents and compares runs of digits
+	// by value, so "server2" sorts before "server10". Items equal under
+	// these rules are ordered by bytes.
+	CollateNatural Collation = iota
+	// CollateBytes orders items by their bytes.
+	CollateBytes
+)
+
+// SortOrder is the collation stores sort Search results with.
+var SortOrder = CollateNatural
+
+// Sort sorts items with SortOrder.
+func Sort(items []string) {
+	if SortOrder == CollateBytes {
+		sort.Strings(items)
+		return
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return compareNatural(items[i], items[j]) < 0
+	})
+}
+
+// compareNatural compares a and b under CollateNatural.
+func compareNatural(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		if isDigit(ra[i]) && isDigit(rb[j]) {
+			ei, ej := digitsEnd(ra, i), digitsEnd(rb, j)
+			if c := compareNumbers(ra[i:ei], rb[j:ej]); c != 0 {
+				return c
+			}
+			i, j = ei, ej
+			continue
+		}
+		ca, cb := foldRune(ra[i]), foldRune(rb[j])
+		if ca != cb {
+			if ca < cb {
+				return -1
+			}
+			return 1
+		}
+		i++
+		j++
+	}
+	switch {
+	case i < len(ra):
+		return 1
+	case j < len(rb):
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func digitsEnd(r []rune, i int) int {
+	for i < len(r) && isDigit(r[i]) {
+		i++
+	}
+	return i
+}
+
+// compareNumbers compares two runs of ASCII digits by value.
+func compareNumbers(a, b []rune) int {
+	for len(a) > 1 && a[0] == '0' {
+		a = a[1:]
+	}
+	for len(b) > 1 && b[0] == '0' {
+		b = b[1:]
+	}
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(string(a), string(b))
+}
+
+// accents maps accented Latin letters to their base letter.
+var accents = map[rune]rune{}
+
+func init() {
+	for base, letters := range map[rune]string{
+		'a': "àáâãäåāăą", 'c': "çćĉċč", 'd': "ďđ", 'e': "èéêëēĕėęě",
+		'g': "ĝğġģ", 'h': "ĥħ", 'i': "ìíîïĩīĭįı", 'j': "ĵ", 'k': "ķ",
+		'l': "ĺļľŀł", 'n': "ñńņňŉ", 'o': "òóôõöøōŏő", 'r': "ŕŗř",
+		's': "śŝşš", 't': "ţťŧ", 'u': "ùúûüũūŭůűų", 'w': "ŵ", 'y': "ýÿŷ",
+		'z': "źżž",
+	} {
+		for _, r := range letters {
+			accents[r] = base
+		}
+	}
+}
+
+// foldRune returns the lower case base letter of r.
+func foldRune(r rune) rune {
+	r = unicode.ToLower(r)
+	if base, ok := accents[r]; ok {
+		return base
+	}
+	return r
+}
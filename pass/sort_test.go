@@ -0,0 +1,23 @@
+package pass
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSort(t *testing.T) {
+	items := []string{"server10", "Zeta", "server2", "éclair", "alpha", "Émile", "eclair", "server02", "beta"}
+	tests := map[Collation][]string{
+		CollateNatural: {"alpha", "beta", "eclair", "éclair", "Émile", "server02", "server2", "server10", "Zeta"},
+		CollateBytes:   {"Zeta", "alpha", "beta", "eclair", "server02", "server10", "server2", "Émile", "éclair"},
+	}
+	defer func() { SortOrder = CollateNatural }()
+	for collation, expected := range tests {
+		SortOrder = collation
+		actual := append([]string{}, items...)
+		Sort(actual)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Collation %d: expected %v, got %v", collation, expected, actual)
+		}
+	}
+}
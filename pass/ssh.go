@@ -0,0 +1,177 @@
+package pass
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// SSHBinary is the program connecting to remote stores.
+var SSHBinary = "ssh"
+
+// SSHTimeout is how many seconds connecting to a remote store, or a server
+// not answering, may take.
+var SSHTimeout = "10"
+
+// exitMissing is the exit status of the remote command for missing items.
+const exitMissing = 44
+
+// IsRemoteStore reports whether path is the URL of a store on an SSH server,
+// like "ssh://alice@example.com/home/alice/.password-store".
+func IsRemoteStore(path string) bool {
+	return strings.HasPrefix(path, "ssh://")
+}
+
+// OpenStore returns the store at path, a directory or the URL of a remote
+// store, see IsRemoteStore.
+func OpenStore(path string) (Store, error) {
+	if IsRemoteStore(path) {
+		return NewSSHStore(path)
+	}
+	return NewDiskStore(path)
+}
+
+// sshStore reads a store kept on an SSH server, for users who don't want
+// copies of it on their machines. Only the encrypted items are fetched,
+// they are decrypted locally like those of a diskStore. Connections are
+// shared between requests through an OpenSSH control master.
+type sshStore struct {
+	// target is the server, as "user@host"
+	target string
+	port   string
+	root   string
+	// control is the socket of the control master, empty to connect for
+	// every request
+	control string
+}
+
+// NewSSHStore returns the read-only store at an ssh:// URL. The server is
+// reached with SSHBinary, which gets its keys and host keys the usual way
+// but can't prompt for anything.
+func NewSSHStore(rawURL string) (Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ssh" || u.Hostname() == "" || u.Path == "" {
+		return nil, &os.PathError{Op: "open store", Path: rawURL, Err: os.ErrInvalid}
+	}
+	if _, err := exec.LookPath(SSHBinary); err != nil {
+		return nil, err
+	}
+	s := &sshStore{target: u.Hostname(), port: u.Port(), root: u.Path}
+	if u.User != nil {
+		s.target = u.User.Username() + "@" + s.target
+	}
+	// The OpenSSH of Windows has no control masters
+	if dir, err := os.UserCacheDir(); err == nil && runtime.GOOS != "windows" {
+		dir = filepath.Join(dir, "browserpass", "ssh")
+		if err := os.MkdirAll(dir, 0700); err == nil {
+			s.control = filepath.Join(dir, "%C")
+		}
+	}
+	if CacheTTL > 0 {
+		return NewCache(s, CacheTTL), nil
+	}
+	return s, nil
+}
+
+// run runs script in the store on the server, returning its output.
+func (s *sshStore) run(ctx context.Context, script string) ([]byte, error) {
+	args := []string{
+		"-o", "BatchMode=yes",
+		"-o", "ConnectTimeout=" + SSHTimeout,
+		"-o", "ServerAliveInterval=" + SSHTimeout,
+		"-o", "ServerAliveCountMax=1",
+	}
+	if s.control != "" {
+		args = append(args, "-o", "ControlMaster=auto", "-o", "ControlPath="+s.control, "-o", "ControlPersist=300")
+	}
+	if s.port != "" {
+		args = append(args, "-p", s.port)
+	}
+	cmd := exec.CommandContext(ctx, SSHBinary, append(args, s.target, "cd "+shellQuote(s.root)+" && "+script)...)
+	cmd.Stderr = os.Stderr
+	return cmd.Output()
+}
+
+// List walks the store on the server, leaving out hidden directories like
+// .git and what IgnorePatterns match. The server's IgnoreFile isn't read.
+func (s *sshStore) List(ctx context.Context) ([]string, error) {
+	rules, err := parseIgnore(IgnorePatterns)
+	if err != nil {
+		return nil, fmt.Errorf("pass: ignore patterns: %w", err)
+	}
+	out, err := s.run(ctx, `find -L . -path './.*' -prune -o -type f -name '*.gpg' -print`)
+	if err != nil {
+		return nil, err
+	}
+	var items []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		file := strings.TrimPrefix(scanner.Text(), "./")
+		item := strings.TrimSuffix(file, ".gpg")
+		if item != "" && fs.ValidPath(item) && !rules.ignoredItem(item, file) {
+			items = append(items, item)
+		}
+	}
+	Sort(items)
+	return items, scanner.Err()
+}
+
+func (s *sshStore) Search(ctx context.Context, query string, opts ...SearchOption) ([]string, error) {
+	items, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := searchItems(items, query, opts...)
+	return Page(matches, opts...), err
+}
+
+// LookupStream runs a search, the whole listing comes in one answer.
+func (s *sshStore) LookupStream(ctx context.Context, domain string) (<-chan string, <-chan error) {
+	return StreamSearch(ctx, s, domain)
+}
+
+// Open fetches the encrypted item. Unlike secrets, it is fine to buffer.
+func (s *sshStore) Open(ctx context.Context, item string) (io.ReadCloser, error) {
+	p := item + ".gpg"
+	if !fs.ValidPath(p) {
+		return nil, &fs.PathError{Op: "open", Path: item, Err: fs.ErrInvalid}
+	}
+	out, err := s.run(ctx, "test -f "+shellQuote(p)+" || exit "+strconv.Itoa(exitMissing)+"; cat "+shellQuote(p))
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == exitMissing {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(out)), nil
+}
+
+// Create fails, browserpass doesn't write to remote stores.
+func (s *sshStore) Create(item string, content []byte) error {
+	return ErrReadOnly
+}
+
+// Delete fails, browserpass doesn't write to remote stores.
+func (s *sshStore) Delete(item string) error {
+	return ErrReadOnly
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
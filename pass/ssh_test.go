@@ -0,0 +1,76 @@
+package pass
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestSSHStore(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ssh is a shell script")
+	}
+	// The fake ssh runs the remote command locally
+	bin := filepath.Join(t.TempDir(), "ssh")
+	os.WriteFile(bin, []byte("#!/bin/sh\nfor command; do :; done\nexec sh -c \"$command\"\n"), 0700)
+	defer func(binary string) { SSHBinary = binary }(SSHBinary)
+	SSHBinary = bin
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	root := t.TempDir()
+	for name, data := range map[string]string{
+		"example.com/alice.gpg": "alice",
+		"it's/bob.gpg":          "bob",
+		".git/config.gpg":       "hidden",
+		"notes.txt":             "not an item",
+	} {
+		os.MkdirAll(filepath.Join(root, filepath.Dir(name)), 0700)
+		os.WriteFile(filepath.Join(root, name), []byte(data), 0600)
+	}
+	if _, err := OpenStore("ssh://example.com"); err == nil {
+		t.Error("Opened a remote store without a path")
+	}
+	s, err := OpenStore("ssh://alice@example.com:2222" + root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := s.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"example.com/alice", "it's/bob"}; !reflect.DeepEqual(items, expected) {
+		t.Errorf("List returned %v, expected %v", items, expected)
+	}
+	defer func(patterns []string) { IgnorePatterns = patterns }(IgnorePatterns)
+	IgnorePatterns = []string{"it's/"}
+	if items, _ := s.List(context.Background()); !reflect.DeepEqual(items, []string{"example.com/alice"}) {
+		t.Errorf("List with ignore patterns returned %v", items)
+	}
+	IgnorePatterns = nil
+
+	for item, expected := range map[string]string{"example.com/alice": "alice", "it's/bob": "bob"} {
+		rc, err := s.Open(context.Background(), item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		if string(data) != expected {
+			t.Errorf("%s fetched as %q, expected %q", item, data, expected)
+		}
+	}
+	if _, err := s.Open(context.Background(), "example.com/carol"); err != ErrNotFound {
+		t.Errorf("Open of a missing item returned %v", err)
+	}
+	if _, err := s.Open(context.Background(), "../example.com/alice"); err == nil {
+		t.Error("Opened an item outside the store")
+	}
+	if err := s.Create("example.com/carol", []byte("secret")); err != ErrReadOnly {
+		t.Errorf("Create returned %v", err)
+	}
+}
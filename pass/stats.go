@@ -0,0 +1,121 @@
+package pass
+
+import (
+	"context"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// Stats describe a store, so clients can show it and tell when it changed.
+type Stats struct {
+	Entries int `json:"entries"`
+	// Domains is how many domains the entries are for
+	Domains int `json:"domains"`
+	// Size is the total size in bytes of the entry files
+	Size int64 `json:"size"`
+	// Modified is the latest change to an entry file or directory
+	Modified time.Time `json:"modified"`
+	// Head is the commit checked out in the git repository of the store,
+	// that of the first store kept in one for stores combining several
+	Head string `json:"head,omitempty"`
+}
+
+// Statter is implemented by stores that can describe their files, setting
+// the Size, Modified and Head of Stats.
+type Statter interface {
+	Stats(ctx context.Context) (Stats, error)
+}
+
+// StatsOf returns the stats of s, counting its entries and their domains.
+// Stores that can't describe their files only get those counted.
+func StatsOf(ctx context.Context, s Store) (Stats, error) {
+	stats, err := FileStats(ctx, s)
+	if err != nil {
+		return Stats{}, err
+	}
+	items, err := s.List(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+	settings, err := SettingsOf(s)
+	if err != nil {
+		return Stats{}, err
+	}
+	stats.Entries = len(items)
+	stats.Domains = CountDomains(items, settings)
+	return stats, nil
+}
+
+// FileStats returns the Size, Modified and Head of s, none if it can't
+// tell.
+func FileStats(ctx context.Context, s Store) (Stats, error) {
+	if st, ok := s.(Statter); ok {
+		return st.Stats(ctx)
+	}
+	return Stats{}, nil
+}
+
+// add adds the files of other to st.
+func (st *Stats) add(other Stats) {
+	st.Size += other.Size
+	if other.Modified.After(st.Modified) {
+		st.Modified = other.Modified
+	}
+	if st.Head == "" {
+		st.Head = other.Head
+	}
+}
+
+// CountDomains returns how many domains items are for, see DomainOf.
+func CountDomains(items []string, settings *Settings) int {
+	domains := make(map[string]bool)
+	for _, item := range items {
+		if domain := DomainOf(item, settings); domain != "" {
+			domains[domain] = true
+		}
+	}
+	return len(domains)
+}
+
+// DomainOf returns the domain item is for, in lowercase: the first of its
+// DomainNames, following settings, that has a dot. It is empty if there is
+// none.
+func DomainOf(item string, settings *Settings) string {
+	for _, name := range settings.DomainNames(item) {
+		if strings.Contains(name, ".") {
+			return strings.ToLower(name)
+		}
+	}
+	return ""
+}
+
+// Stats implements Statter with the entry files and directories the store
+// walks.
+func (s *diskStore) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	var statErr error
+	note := func(name string, entry bool) {
+		info, err := fs.Stat(s.fsys, name)
+		if err != nil {
+			if statErr == nil {
+				statErr = err
+			}
+			return
+		}
+		if entry {
+			stats.Size += info.Size()
+		}
+		if info.ModTime().After(stats.Modified) {
+			stats.Modified = info.ModTime()
+		}
+	}
+	err := s.walkItems(ctx, func(name string) { note(name, false) }, func(item string) { note(item+s.extension(), true) })
+	if err == nil {
+		err = statErr
+	}
+	if err != nil {
+		return Stats{}, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,35 @@
+package pass
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestStatsOf(t *testing.T) {
+	latest := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := &diskStore{fsys: fstest.MapFS{
+		"example.com/alice.gpg": {Data: []byte("alice"), ModTime: latest.Add(-time.Hour)},
+		"Example.com/bob.gpg":   {Data: []byte("bob")},
+		"work/example.org.gpg":  {Data: []byte("org")},
+		"work":                  {Mode: fs.ModeDir, ModTime: latest},
+		"notes/readme.md":       {Data: []byte("not an entry"), ModTime: latest.Add(time.Hour)},
+	}}
+
+	stats, err := StatsOf(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Stats{Entries: 3, Domains: 2, Size: 11, Modified: latest}
+	if stats != expected {
+		t.Errorf("StatsOf returned %+v, expected %+v", stats, expected)
+	}
+
+	other := &diskStore{fsys: fstest.MapFS{"example.net/carol.gpg": {Data: []byte("carol")}}}
+	merged := Merge(s, other)
+	if stats, err := StatsOf(context.Background(), merged); err != nil || stats.Entries != 4 || stats.Domains != 3 || stats.Size != 16 || !stats.Modified.Equal(latest) {
+		t.Errorf("StatsOf merged stores returned %+v, %v", stats, err)
+	}
+}
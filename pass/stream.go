@@ -0,0 +1,45 @@
+package pass
+
+import "context"
+
+// StreamSearch implements Store.LookupStream for stores that can't do
+// better than Search, sending its results once it returns.
+func StreamSearch(ctx context.Context, s Store, domain string) (<-chan string, <-chan error) {
+	items, errs := make(chan string), make(chan error, 1)
+	go func() {
+		defer close(errs)
+		list, err := s.Search(ctx, domain)
+		for _, item := range list {
+			items <- item
+		}
+		close(items)
+		if err != nil {
+			errs <- err
+		}
+	}()
+	return items, errs
+}
+
+// chainStreams streams the items of n streams, started one after the other
+// by start, passing them through keep. The first error ends the stream.
+func chainStreams(n int, start func(i int) (<-chan string, <-chan error), keep func(i int, item string) (string, bool)) (<-chan string, <-chan error) {
+	items, errs := make(chan string), make(chan error, 1)
+	go func() {
+		defer close(errs)
+		for i := 0; i < n; i++ {
+			list, listErrs := start(i)
+			for item := range list {
+				if item, ok := keep(i, item); ok {
+					items <- item
+				}
+			}
+			if err := <-listErrs; err != nil {
+				close(items)
+				errs <- err
+				return
+			}
+		}
+		close(items)
+	}()
+	return items, errs
+}
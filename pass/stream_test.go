@@ -0,0 +1,72 @@
+package pass
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+// collect drains a stream, returning its items sorted.
+func collect(items <-chan string, errs <-chan error) ([]string, error) {
+	var list []string
+	for item := range items {
+		list = append(list, item)
+	}
+	Sort(list)
+	return list, <-errs
+}
+
+func TestLookupStream(t *testing.T) {
+	s := &diskStore{fsys: fstest.MapFS{
+		"example.com/alice.gpg":      {Data: []byte("alice")},
+		"example.com/work/bob.gpg":   {Data: []byte("bob")},
+		"work/example.com.gpg":       {Data: []byte("flat")},
+		"example.org/carol.gpg":      {Data: []byte("carol")},
+		"example.community/dave.gpg": {Data: []byte("dave")},
+	}}
+
+	expected, err := s.Search(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Sort(expected)
+	items, err := collect(s.LookupStream(context.Background(), "example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("Streamed %v, expected %v", items, expected)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := collect(s.LookupStream(ctx, "example.com")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Canceled stream returned %v", err)
+	}
+
+	broken := &diskStore{fsys: fstest.MapFS{SettingsFile: {Data: []byte("{")}}}
+	if _, err := collect(broken.LookupStream(context.Background(), "example.com")); err == nil {
+		t.Error("Stream of a broken store succeeded")
+	}
+}
+
+func TestMultiStoreLookupStream(t *testing.T) {
+	m := MultiStore{
+		{"", &diskStore{fsys: fstest.MapFS{"example.com/alice.gpg": {Data: []byte("personal")}}}},
+		{"work", &diskStore{fsys: fstest.MapFS{"example.com/alice.gpg": {Data: []byte("work")}}}},
+	}
+	items, err := collect(m.LookupStream(context.Background(), "example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"example.com/alice", "work:example.com/alice"}; !reflect.DeepEqual(items, expected) {
+		t.Errorf("Streamed %v, expected %v", items, expected)
+	}
+
+	merged := Merge(m[0].Store, m[1].Store)
+	if items, _ := collect(merged.LookupStream(context.Background(), "example.com")); !reflect.DeepEqual(items, []string{"example.com/alice"}) {
+		t.Errorf("Merged stores streamed %v", items)
+	}
+}
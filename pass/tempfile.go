@@ -0,0 +1,191 @@
+package pass
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// privateTempDir returns a directory only the current user can access for
+// temporary files: $XDG_RUNTIME_DIR (usually a tmpfs) if set, the user cache
+// directory otherwise. Never the shared /tmp.
+func privateTempDir() (string, error) {
+	base := os.Getenv("XDG_RUNTIME_DIR")
+	if base == "" {
+		var err error
+		if base, err = os.UserCacheDir(); err != nil {
+			return "", err
+		}
+	}
+	dir := filepath.Join(base, "browserpass")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	// MkdirAll leaves the mode of an existing directory alone
+	if err := os.Chmod(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// unnamed is the base name of the files of CreateTemp that have none.
+const unnamed = "(unnamed)"
+
+// CreateTemp creates a new 0600 file in dir, or in privateTempDir if dir is
+// empty, for files put in place with Replace once written, so dir should be
+// their destination directory. Where the system supports it (O_TMPFILE on
+// Linux) the file has no name until then: no other process can open it and
+// nothing is left behind if the host dies while writing it. Files that
+// aren't put in place should be removed with Shred.
+func CreateTemp(dir string) (*os.File, error) {
+	if dir == "" {
+		var err error
+		if dir, err = privateTempDir(); err != nil {
+			return nil, err
+		}
+	}
+	if f, err := openUnnamed(dir); err == nil {
+		return f, nil
+	}
+	return createNamed(dir)
+}
+
+// createNamed creates a new hidden 0600 file in dir.
+func createNamed(dir string) (*os.File, error) {
+	for {
+		name, err := tempName(dir)
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		return f, err
+	}
+}
+
+// tempName returns a random hidden file name in dir.
+func tempName(dir string) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, ".browserpass-"+hex.EncodeToString(b)), nil
+}
+
+// Replace closes f, a file of CreateTemp, and atomically puts it in place
+// of the file name in the same directory, if there is one. f is removed if
+// it can't be.
+func Replace(f *os.File, name string) error {
+	if filepath.Base(f.Name()) == unnamed {
+		defer f.Close()
+		return replaceUnnamed(f, name)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), name); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// replaceUnnamed is Replace for a file without a name. Links can't replace
+// files, so the complete file is linked to a temporary name and renamed.
+func replaceUnnamed(f *os.File, name string) error {
+	for {
+		tmp, err := tempName(filepath.Dir(name))
+		if err != nil {
+			return err
+		}
+		err = linkUnnamed(f, tmp)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			// Without /proc, like in the sandbox, the file is copied
+			return copyReplace(f, name)
+		}
+		if err := os.Rename(tmp, name); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		return nil
+	}
+}
+
+// copyReplace puts a copy of the unnamed file f in place of name.
+func copyReplace(f *os.File, name string) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	named, err := createNamed(filepath.Dir(name))
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		Shred(named)
+		return err
+	}
+	if _, err := io.Copy(named, f); err != nil {
+		Shred(named)
+		return err
+	}
+	if err := named.Chmod(info.Mode().Perm()); err != nil {
+		Shred(named)
+		return err
+	}
+	return Replace(named, name)
+}
+
+// writeReplace atomically replaces the file name with data, giving it mode
+// perm regardless of the umask.
+func writeReplace(name string, data []byte, perm os.FileMode) error {
+	f, err := CreateTemp(filepath.Dir(name))
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		Shred(f)
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		Shred(f)
+		return err
+	}
+	return Replace(f, name)
+}
+
+// Shred overwrites the contents of f with zeros, closes it and removes it
+// if it has a name.
+func Shred(f *os.File) error {
+	info, err := f.Stat()
+	if err == nil {
+		if _, err = f.Seek(0, io.SeekStart); err == nil {
+			_, err = io.CopyN(f, zeroReader{}, info.Size())
+		}
+		if err == nil {
+			err = f.Sync()
+		}
+	}
+	f.Close()
+	if rmErr := os.Remove(f.Name()); err == nil && rmErr != nil && !os.IsNotExist(rmErr) {
+		err = rmErr
+	}
+	return err
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
@@ -0,0 +1,44 @@
+package pass
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// oTmpfile is O_TMPFILE from <fcntl.h>, which includes O_DIRECTORY.
+const oTmpfile = 0x410000
+
+// atSymlinkFollow is AT_SYMLINK_FOLLOW from <fcntl.h>.
+const atSymlinkFollow = 0x400
+
+// openUnnamed creates a file in dir that has no name until linkUnnamed
+// gives it one.
+func openUnnamed(dir string) (*os.File, error) {
+	fd, err := syscall.Open(dir, oTmpfile|syscall.O_RDWR|syscall.O_CLOEXEC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), filepath.Join(dir, unnamed)), nil
+}
+
+// linkUnnamed names f, a file of openUnnamed, newname. It links the file
+// through /proc: linking the descriptor itself needs CAP_DAC_READ_SEARCH.
+func linkUnnamed(f *os.File, newname string) error {
+	oldp, err := syscall.BytePtrFromString("/proc/self/fd/" + strconv.Itoa(int(f.Fd())))
+	if err != nil {
+		return err
+	}
+	newp, err := syscall.BytePtrFromString(newname)
+	if err != nil {
+		return err
+	}
+	cwd := -100 // AT_FDCWD
+	_, _, errno := syscall.Syscall6(syscall.SYS_LINKAT, uintptr(cwd), uintptr(unsafe.Pointer(oldp)), uintptr(cwd), uintptr(unsafe.Pointer(newp)), atSymlinkFollow, 0)
+	if errno != 0 {
+		return &os.LinkError{Op: "link", Old: f.Name(), New: newname, Err: errno}
+	}
+	return nil
+}
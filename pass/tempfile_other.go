@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package pass
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoUnnamed = errors.New("unnamed files not supported")
+
+func openUnnamed(dir string) (*os.File, error) {
+	return nil, errNoUnnamed
+}
+
+func linkUnnamed(f *os.File, newname string) error {
+	return errNoUnnamed
+}
@@ -0,0 +1,82 @@
+package pass
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCreateTempAndShred(t *testing.T) {
+	dir, err := ioutil.TempDir("", "browserpass-temp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("XDG_RUNTIME_DIR", dir)
+	defer os.Unsetenv("XDG_RUNTIME_DIR")
+
+	f, err := CreateTemp("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := f.Stat()
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Temp file mode is %04o, expected 0600", info.Mode().Perm())
+	}
+	f.WriteString("secret")
+	if err := Shred(f); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(f.Name()); !os.IsNotExist(err) {
+		t.Error("Shredded file still exists")
+	}
+	if entries, _ := ioutil.ReadDir(dir + "/browserpass"); len(entries) != 0 {
+		t.Errorf("Temp files are left in %s", dir)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "entry.gpg")
+	os.WriteFile(name, []byte("old"), 0600)
+
+	f, err := CreateTemp(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("new")
+	if runtime.GOOS == "linux" {
+		if entries, _ := ioutil.ReadDir(dir); len(entries) != 1 {
+			t.Errorf("Temp file is visible in %s before it is in place", dir)
+		}
+	}
+	if err := Replace(f, name); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(name); string(data) != "new" {
+		t.Errorf("File holds %q after Replace", data)
+	}
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Temp files are left in %s", dir)
+	}
+
+	// Without /proc the file is copied, keeping its mode
+	f, err = CreateTemp(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("copied")
+	f.Chmod(0640)
+	if err := copyReplace(f, name); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if info, _ := os.Stat(name); info.Mode().Perm() != 0640 {
+		t.Errorf("Copied file mode is %04o, expected 0640", info.Mode().Perm())
+	}
+	if data, _ := os.ReadFile(name); string(data) != "copied" {
+		t.Errorf("File holds %q after copyReplace", data)
+	}
+}
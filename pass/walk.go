@@ -0,0 +1,176 @@
+package pass
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// walkWorkers is how many directories a walk reads at once.
+var walkWorkers = 16
+
+// dirListing is a directory read ahead of the walk, done once its entries
+// or error are set.
+type dirListing struct {
+	// real is the path of the directory on disk, empty when the store isn't
+	// a directory
+	real    string
+	done    chan struct{}
+	entries []fs.DirEntry
+	err     error
+}
+
+// wait returns the entries of l once read.
+func (l *dirListing) wait(ctx context.Context) ([]fs.DirEntry, error) {
+	select {
+	case <-l.done:
+		return l.entries, l.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// walker reads the directories of a walk on a pool of walkWorkers.
+type walker struct {
+	ctx  context.Context
+	fsys fs.FS
+	sem  chan struct{}
+}
+
+// read starts reading the directory p, by its real path if known.
+func (w *walker) read(p, real string) *dirListing {
+	l := &dirListing{real: real, done: make(chan struct{})}
+	go func() {
+		defer close(l.done)
+		select {
+		case w.sem <- struct{}{}:
+		case <-w.ctx.Done():
+			l.err = w.ctx.Err()
+			return
+		}
+		defer func() { <-w.sem }()
+		if real != "" {
+			l.entries, l.err = os.ReadDir(real)
+		} else {
+			l.entries, l.err = fs.ReadDir(w.fsys, p)
+		}
+	}()
+	return l
+}
+
+// walkItems walks the store depth first in lexical order, calling visitDir
+// with each directory if set and visitItem with each item. Items and
+// directories the store's settings or ignore rules leave out are skipped.
+// The walk stops once ctx is done.
+//
+// The subdirectories of each directory are read ahead on the worker pool,
+// while callbacks and link decisions stay in walk order. On disk, only links
+// are resolved: other directories are read below the real path of their
+// parent.
+func (s *diskStore) walkItems(ctx context.Context, visitDir func(name string), visitItem func(item string)) error {
+	settings, err := s.StoreSettings()
+	if err != nil {
+		return err
+	}
+	rules, err := s.ignoreRules()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := &walker{ctx: ctx, fsys: s.fsys, sem: make(chan struct{}, walkWorkers)}
+	var root string
+	if dir, ok := s.fsys.(dirFS); ok {
+		if root, err = dir.resolve("walk", "."); err != nil {
+			return err
+		}
+	}
+	skip := func(p string) bool {
+		return settings.ignored(p) || rules.ignored(p, true)
+	}
+	below := func(real, name string) string {
+		if real == "" {
+			return ""
+		}
+		return filepath.Join(real, name)
+	}
+
+	linked := make(map[string]bool)
+	var walk func(p string, l *dirListing) error
+	walk = func(p string, l *dirListing) error {
+		if visitDir != nil {
+			visitDir(p)
+		}
+		entries, err := l.wait(ctx)
+		if err != nil {
+			return err
+		}
+		subdirs := make([]*dirListing, len(entries))
+		for i, d := range entries {
+			if child := path.Join(p, d.Name()); d.IsDir() && !skip(child) {
+				subdirs[i] = w.read(child, below(l.real, d.Name()))
+			}
+		}
+		for i, d := range entries {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			child := path.Join(p, d.Name())
+			if d.Type()&fs.ModeSymlink != 0 {
+				target, resolved, ok := s.followLink(child, linked)
+				switch {
+				case !ok:
+					continue
+				case target.IsDir() && skip(child):
+					continue
+				case target.IsDir():
+					subdirs[i] = w.read(child, resolved)
+				default:
+					d = fs.FileInfoToDirEntry(target)
+				}
+			}
+			if subdirs[i] != nil {
+				if err := walk(child, subdirs[i]); err != nil {
+					return err
+				}
+				continue
+			}
+			if item := strings.TrimSuffix(child, s.extension()); !d.IsDir() && item != child && !settings.ignored(item) && !rules.ignored(child, false) && !rules.ignored(item, false) {
+				visitItem(item)
+			}
+		}
+		return nil
+	}
+	return walk(".", w.read(".", root))
+}
+
+// followLink stats the target of the symbolic link p, returning it with its
+// real path. Links resolving outside the stores or to a directory holding
+// them are skipped, as are links to directories already in linked, which
+// keeps the walk finite.
+func (s *diskStore) followLink(p string, linked map[string]bool) (target fs.FileInfo, resolved string, ok bool) {
+	dir, isDirFS := s.fsys.(dirFS)
+	if !isDirFS {
+		return nil, "", false
+	}
+	resolved, err := dir.resolve("walk", p)
+	if err != nil {
+		return nil, "", false
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return nil, "", false
+	}
+	if !info.IsDir() {
+		return info, resolved, true
+	}
+	parent, err := dir.resolve("walk", path.Dir(p))
+	if err != nil || within(parent, resolved) || linked[resolved] {
+		return nil, "", false
+	}
+	linked[resolved] = true
+	return info, resolved, true
+}
@@ -0,0 +1,48 @@
+package pass
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyMask selects the events changing which items a store holds.
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// watch calls changed whenever a file is created, deleted or renamed in one
+// of the directories passed to add, using inotify.
+func watch(changed func()) (io.Closer, func(dir string) error, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, nil, os.NewSyscallError("inotify_init1", err)
+	}
+	// A non-blocking file goes through the runtime poller, so closing it
+	// stops the reader below
+	f := os.NewFile(uintptr(fd), "inotify")
+
+	go func() {
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+			for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+				if event.Mask&(inotifyMask|syscall.IN_Q_OVERFLOW) != 0 {
+					changed()
+				}
+				off += syscall.SizeofInotifyEvent + int(event.Len)
+			}
+		}
+	}()
+
+	add := func(dir string) error {
+		// Watching a directory again is a no-op
+		_, err := syscall.InotifyAddWatch(fd, dir, inotifyMask|syscall.IN_ONLYDIR)
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+	return f, add, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package pass
+
+import "io"
+
+func watch(changed func()) (io.Closer, func(dir string) error, error) {
+	return nil, nil, errUnsupported
+}
@@ -0,0 +1,182 @@
+package pass
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/dannyvankooten/browserpass/gpg"
+)
+
+// Create encrypts content to the recipients in the .gpg-id file nearest to
+// item, like pass insert, and writes it as a new item.
+func (s *diskStore) Create(item string, content []byte) error {
+	p, err := s.resolveItem("create", item)
+	if err != nil {
+		return err
+	}
+	if _, err := fs.Stat(s.fsys, p); err == nil {
+		return ErrExists
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return s.write(p, content, 0600)
+}
+
+// Update re-encrypts item with content, keeping the mode of its file.
+func (s *diskStore) Update(item string, content []byte) error {
+	p, err := s.resolveItem("update", item)
+	if err != nil {
+		return err
+	}
+	info, err := fs.Stat(s.fsys, p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.write(p, content, info.Mode().Perm())
+}
+
+// Delete removes item and then the directories it leaves empty, like pass
+// rm.
+func (s *diskStore) Delete(item string) error {
+	wfs, ok := s.fsys.(WriteFS)
+	if !ok {
+		return ErrReadOnly
+	}
+	p, err := s.resolveItem("delete", item)
+	if err != nil {
+		return err
+	}
+	if err := wfs.Remove(p); errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	removeEmpty(wfs, path.Dir(p))
+	return nil
+}
+
+// removeEmpty removes dir and then its parents until one isn't empty.
+func removeEmpty(wfs WriteFS, dir string) {
+	// Removing a directory fails once it isn't empty
+	for ; dir != "."; dir = path.Dir(dir) {
+		if wfs.Remove(dir) != nil {
+			break
+		}
+	}
+}
+
+// write encrypts content and atomically replaces the file p with it: the
+// ciphertext goes to a temporary file in the same directory, which then
+// takes the place of p. The file gets mode perm regardless of the umask.
+func (s *diskStore) write(p string, content []byte, perm fs.FileMode) error {
+	wfs, ok := s.fsys.(WriteFS)
+	if !ok {
+		return ErrReadOnly
+	}
+	dir := path.Dir(p)
+	recipients, err := s.recipients(dir)
+	if err != nil {
+		return err
+	}
+	var ciphertext bytes.Buffer
+	if err := gpg.Encrypt(&ciphertext, bytes.NewReader(content), recipients...); err != nil {
+		return err
+	}
+
+	if err := wfs.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	// Stores on disk write through CreateTemp and Replace
+	if d, ok := wfs.(dirFS); ok {
+		real, err := d.join("write", p)
+		if err != nil {
+			return err
+		}
+		return writeReplace(real, ciphertext.Bytes(), perm)
+	}
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	tmp := path.Join(dir, ".browserpass-"+hex.EncodeToString(b))
+	if err := wfs.WriteFile(tmp, ciphertext.Bytes(), 0600); err != nil {
+		wfs.Remove(tmp)
+		return err
+	}
+	if err := wfs.Chmod(tmp, perm); err != nil {
+		wfs.Remove(tmp)
+		return err
+	}
+	if err := wfs.Rename(tmp, p); err != nil {
+		wfs.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// recipients returns the GPG key IDs in the .gpg-id file of dir, or of its
+// closest parent that has one, which is how pass lets subfolders be shared
+// with other people.
+func (s *diskStore) recipients(dir string) ([]string, error) {
+	for {
+		data, err := fs.ReadFile(s.fsys, path.Join(dir, ".gpg-id"))
+		if err == nil {
+			return parseGPGID(data)
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if dir == "." {
+			return nil, errors.New("pass: store has no .gpg-id")
+		}
+		dir = path.Dir(dir)
+	}
+}
+
+// Keyed is implemented by stores encrypted to keys listed in .gpg-id files.
+type Keyed interface {
+	// StoreKeys returns the keys in the .gpg-id at the root of the store.
+	StoreKeys() ([]string, error)
+}
+
+// KeysOf returns the keys s is encrypted to, none if it can't tell.
+func KeysOf(s Store) ([]string, error) {
+	if k, ok := s.(Keyed); ok {
+		return k.StoreKeys()
+	}
+	return nil, nil
+}
+
+// StoreKeys implements Keyed.
+func (s *diskStore) StoreKeys() ([]string, error) {
+	return s.recipients(".")
+}
+
+// parseGPGID parses a .gpg-id file, one key ID per line. Blank lines and
+// lines starting with "#" are ignored.
+func parseGPGID(data []byte) ([]string, error) {
+	var ids []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			ids = append(ids, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("pass: .gpg-id lists no keys")
+	}
+	return ids, nil
+}
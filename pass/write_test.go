@@ -0,0 +1,169 @@
+package pass
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/dannyvankooten/browserpass/fixture"
+	"github.com/dannyvankooten/browserpass/gpg"
+)
+
+func TestRecipients(t *testing.T) {
+	s := &diskStore{fsys: fstest.MapFS{
+		".gpg-id":          {Data: []byte("alice@example.com\n")},
+		"work/.gpg-id":     {Data: []byte("# team\nalice@example.com\n\nbob@example.com\n")},
+		"work/sub/x.gpg":   {},
+		"empty/.gpg-id":    {Data: []byte("# nobody\n")},
+		"example.com/.gpg": {},
+	}}
+	tests := map[string][]string{
+		".":           {"alice@example.com"},
+		"example.com": {"alice@example.com"},
+		"work":        {"alice@example.com", "bob@example.com"},
+		"work/sub":    {"alice@example.com", "bob@example.com"},
+		"empty":       nil,
+	}
+	for dir, expected := range tests {
+		actual, err := s.recipients(dir)
+		if expected == nil {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", dir, actual)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: expected %v, got %v", dir, expected, actual)
+		}
+	}
+
+	if keys, err := KeysOf(NewCache(s, time.Minute)); !reflect.DeepEqual(keys, tests["."]) || err != nil {
+		t.Errorf("KeysOf returned %v, %v", keys, err)
+	}
+
+	if err := s.Create("example.com/alice", []byte("x")); err != ErrReadOnly {
+		t.Errorf("Create on a read-only file system returned %v", err)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not installed")
+	}
+	home := t.TempDir()
+	if err := fixture.NewKey(home); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GNUPGHOME", home)
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".gpg-id"), []byte(fixture.KeyID+"\n"), 0600)
+	s, err := NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Create("example.com/alice", []byte("hunter2\nlogin: alice\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Create("example.com/alice", []byte("other")); err != ErrExists {
+		t.Errorf("Second Create returned %v, expected ErrExists", err)
+	}
+	if err := s.Create("../outside", []byte("x")); err == nil {
+		t.Error("Created an item outside the store")
+	}
+
+	rc, err := s.Open(context.Background(), "example.com/alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	plaintext, err := gpg.Decrypt(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "hunter2\nlogin: alice\n" {
+		t.Errorf("Decrypted %q", plaintext)
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(dir, "example.com"))
+	if len(entries) != 1 {
+		t.Errorf("Temporary files left behind: %v", entries)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not installed")
+	}
+	home := t.TempDir()
+	if err := fixture.NewKey(home); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GNUPGHOME", home)
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".gpg-id"), []byte(fixture.KeyID+"\n"), 0600)
+	s, err := NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := s.(Updater)
+
+	if err := u.Update("example.com/alice", []byte("x")); err != ErrNotFound {
+		t.Errorf("Update of a missing item returned %v", err)
+	}
+	if err := s.Create("example.com/alice", []byte("old\n")); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "example.com", "alice.gpg")
+	os.Chmod(file, 0640)
+	if err := u.Update("example.com/alice", []byte("new\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("Mode not preserved: %v %v", info.Mode(), err)
+	}
+	rc, err := s.Open(context.Background(), "example.com/alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if plaintext, err := gpg.Decrypt(rc); err != nil || string(plaintext) != "new\n" {
+		t.Errorf("Decrypted %q, %v", plaintext, err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "work", "example.com"), 0700)
+	os.WriteFile(filepath.Join(dir, "work", ".gpg-id"), []byte("alice@example.com\n"), 0600)
+	os.WriteFile(filepath.Join(dir, "work", "example.com", "alice.gpg"), nil, 0600)
+	s, err := NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Delete("work/example.com/alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("work/example.com/alice"); err != ErrNotFound {
+		t.Errorf("Second Delete returned %v, expected ErrNotFound", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "work", "example.com")); !os.IsNotExist(err) {
+		t.Error("Empty directory left behind")
+	}
+	// Its .gpg-id keeps the folder around
+	if _, err := os.Stat(filepath.Join(dir, "work", ".gpg-id")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,182 @@
+// Package passkey stores WebAuthn credentials in password store entries and
+// produces assertions with them, acting as a software authenticator.
+//
+// A passkey is kept in an entry as "passkey_*" fields, so it can live next to
+// a regular password:
+//
+//	the-password
+//	passkey_rp_id: example.com
+//	passkey_credential_id: <base64url>
+//	passkey_user_handle: <base64url>
+//	passkey_user_name: alice
+//	passkey_private_key: <base64 PKCS #8, ECDSA P-256>
+package passkey
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoPasskey is returned by Parse for entries without a passkey.
+var ErrNoPasskey = errors.New("passkey: entry has no passkey")
+
+// Authenticator data flags, see the WebAuthn specification.
+const (
+	FlagUserPresent  = 0x01
+	FlagUserVerified = 0x04
+	FlagAttestedData = 0x40
+)
+
+// Passkey is a WebAuthn credential using ES256.
+type Passkey struct {
+	RPID         string
+	CredentialID []byte
+	UserHandle   []byte
+	UserName     string
+	PrivateKey   *ecdsa.PrivateKey
+}
+
+// New creates a passkey with a fresh key pair and credential ID.
+func New(rpID, userName string, userHandle []byte) (*Passkey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return &Passkey{RPID: rpID, CredentialID: id, UserHandle: userHandle, UserName: userName, PrivateKey: key}, nil
+}
+
+// Parse reads the passkey fields of a decrypted entry.
+func Parse(plaintext []byte) (*Passkey, error) {
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(plaintext))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if ok && strings.HasPrefix(key, "passkey_") {
+			fields[strings.TrimPrefix(key, "passkey_")] = strings.TrimSpace(value)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, ErrNoPasskey
+	}
+
+	p := &Passkey{RPID: fields["rp_id"], UserName: fields["user_name"]}
+	var err error
+	if p.CredentialID, err = base64.RawURLEncoding.DecodeString(fields["credential_id"]); err != nil || len(p.CredentialID) == 0 {
+		return nil, errors.New("passkey: invalid credential_id")
+	}
+	if p.UserHandle, err = base64.RawURLEncoding.DecodeString(fields["user_handle"]); err != nil {
+		return nil, errors.New("passkey: invalid user_handle")
+	}
+	der, err := base64.StdEncoding.DecodeString(fields["private_key"])
+	if err != nil {
+		return nil, errors.New("passkey: invalid private_key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("passkey: invalid private_key: %v", err)
+	}
+	var ok bool
+	if p.PrivateKey, ok = key.(*ecdsa.PrivateKey); !ok || p.PrivateKey.Curve != elliptic.P256() {
+		return nil, errors.New("passkey: private_key is not an ECDSA P-256 key")
+	}
+	if p.RPID == "" {
+		return nil, errors.New("passkey: missing rp_id")
+	}
+	return p, nil
+}
+
+// Marshal returns the passkey as entry fields, see the package
+// documentation.
+func (p *Passkey) Marshal() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(p.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "passkey_rp_id: %s\n", p.RPID)
+	fmt.Fprintf(&b, "passkey_credential_id: %s\n", base64.RawURLEncoding.EncodeToString(p.CredentialID))
+	fmt.Fprintf(&b, "passkey_user_handle: %s\n", base64.RawURLEncoding.EncodeToString(p.UserHandle))
+	fmt.Fprintf(&b, "passkey_user_name: %s\n", p.UserName)
+	fmt.Fprintf(&b, "passkey_private_key: %s\n", base64.StdEncoding.EncodeToString(der))
+	return b.Bytes(), nil
+}
+
+// AuthenticatorData returns the authenticator data for flags. Synced
+// passkeys don't keep a signature counter, so it is always zero. The
+// attested credential data is included if flags has FlagAttestedData.
+func (p *Passkey) AuthenticatorData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(p.RPID))
+	data := append(rpIDHash[:], flags, 0, 0, 0, 0)
+	if flags&FlagAttestedData != 0 {
+		// All-zero AAGUID, the authenticator model isn't attested
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(p.CredentialID)))
+		data = append(data, p.CredentialID...)
+		data = append(data, p.PublicKeyCOSE()...)
+	}
+	return data
+}
+
+// AttestationObject returns the CBOR encoded attestation object of a newly
+// created passkey with the "none" attestation format, for authenticator data
+// with flags and the attested credential data.
+func (p *Passkey) AttestationObject(flags byte) []byte {
+	authData := p.AuthenticatorData(flags | FlagAttestedData)
+	// {"fmt": "none", "attStmt": {}, "authData": authData}
+	obj := []byte{0xa3, 0x63, 'f', 'm', 't', 0x64, 'n', 'o', 'n', 'e'}
+	obj = append(obj, 0x67, 'a', 't', 't', 'S', 't', 'm', 't', 0xa0)
+	obj = append(obj, 0x68, 'a', 'u', 't', 'h', 'D', 'a', 't', 'a')
+	switch n := len(authData); {
+	case n < 24:
+		obj = append(obj, 0x40|byte(n))
+	case n < 256:
+		obj = append(obj, 0x58, byte(n))
+	default:
+		obj = binary.BigEndian.AppendUint16(append(obj, 0x59), uint16(n))
+	}
+	return append(obj, authData...)
+}
+
+// PublicKeyCOSE returns the public key as a CBOR encoded COSE_Key.
+func (p *Passkey) PublicKeyCOSE() []byte {
+	x := p.PrivateKey.PublicKey.X.FillBytes(make([]byte, 32))
+	y := p.PrivateKey.PublicKey.Y.FillBytes(make([]byte, 32))
+	// {1: 2 (EC2), 3: -7 (ES256), -1: 1 (P-256), -2: x, -3: y}
+	key := []byte{0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01, 0x21, 0x58, 0x20}
+	key = append(key, x...)
+	key = append(key, 0x22, 0x58, 0x20)
+	return append(key, y...)
+}
+
+// Assert signs a WebAuthn assertion for the SHA-256 hash of the client data,
+// returning the authenticator data and the ASN.1 encoded signature over it.
+func (p *Passkey) Assert(clientDataHash []byte, flags byte) (authData, signature []byte, err error) {
+	if len(clientDataHash) != sha256.Size {
+		return nil, nil, errors.New("passkey: client data hash must be a SHA-256 hash")
+	}
+	authData = p.AuthenticatorData(flags &^ FlagAttestedData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash...))
+	signature, err = ecdsa.SignASN1(rand.Reader, p.PrivateKey, digest[:])
+	return authData, signature, err
+}
+
+// ValidRPID reports whether a page on host may use credentials scoped to
+// rpID: host must be rpID or one of its subdomains.
+func ValidRPID(rpID, host string) bool {
+	rpID, host = strings.ToLower(rpID), strings.ToLower(host)
+	return host == rpID || strings.HasSuffix(host, "."+rpID)
+}
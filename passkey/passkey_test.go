@@ -0,0 +1,74 @@
+package passkey
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestRoundTripAndAssert(t *testing.T) {
+	p, err := New("example.com", "alice", []byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := p.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := Parse(append([]byte("the-password\nlogin: alice\n"), fields...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.RPID != "example.com" || parsed.UserName != "alice" || string(parsed.CredentialID) != string(p.CredentialID) {
+		t.Errorf("Parsed passkey %+v does not match %+v", parsed, p)
+	}
+
+	clientDataHash := sha256.Sum256([]byte(`{"type":"webauthn.get"}`))
+	authData, sig, err := parsed.Assert(clientDataHash[:], FlagUserPresent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(authData) != 37 {
+		t.Errorf("Authenticator data is %d bytes, expected 37", len(authData))
+	}
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	if !ecdsa.VerifyASN1(&p.PrivateKey.PublicKey, digest[:], sig) {
+		t.Error("Signature does not verify with the original public key")
+	}
+
+	if _, err := Parse([]byte("the-password\nlogin: alice\n")); err != ErrNoPasskey {
+		t.Errorf("Parse of a plain entry returned %v, expected ErrNoPasskey", err)
+	}
+}
+
+func TestAttestationObject(t *testing.T) {
+	p, err := New("example.com", "alice", []byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := p.AttestationObject(FlagUserPresent)
+	authData := p.AuthenticatorData(FlagUserPresent | FlagAttestedData)
+	header := "\xa3cfmtdnonegattStmt\xa0hauthDataX" + string([]byte{byte(len(authData))})
+	if len(authData) >= 256 || string(obj[:len(header)]) != header || !bytes.Equal(obj[len(header):], authData) {
+		t.Errorf("Attestation object is %x", obj)
+	}
+	if flags := authData[32]; flags != FlagUserPresent|FlagAttestedData {
+		t.Errorf("Flags are %#x", flags)
+	}
+}
+
+func TestValidRPID(t *testing.T) {
+	tests := map[string]bool{
+		"example.com":          true,
+		"login.example.com":    true,
+		"evilexample.com":      false,
+		"example.com.evil.org": false,
+	}
+	for host, expected := range tests {
+		if actual := ValidRPID("example.com", host); actual != expected {
+			t.Errorf("ValidRPID(example.com, %s): expected %v, got %v", host, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package browserpass
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// solLocal and localPeerCred are SOL_LOCAL and LOCAL_PEERCRED from
+// <sys/un.h>.
+const (
+	solLocal      = 0
+	localPeerCred = 1
+)
+
+// xucred is struct xucred from <sys/ucred.h>.
+type xucred struct {
+	Version uint32
+	UID     uint32
+	NGroups int16
+	Groups  [16]uint32
+}
+
+// peerUID returns the user ID of the process at the other end of conn.
+func peerUID(conn *net.UnixConn) (int, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return -1, err
+	}
+	var cred xucred
+	var errno syscall.Errno
+	err = raw.Control(func(fd uintptr) {
+		size := uint32(unsafe.Sizeof(cred))
+		_, _, errno = syscall.Syscall6(syscall.SYS_GETSOCKOPT, fd, solLocal, localPeerCred, uintptr(unsafe.Pointer(&cred)), uintptr(unsafe.Pointer(&size)), 0)
+	})
+	if err == nil && errno != 0 {
+		err = errno
+	}
+	if err != nil {
+		return -1, err
+	}
+	return int(cred.UID), nil
+}
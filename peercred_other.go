@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package browserpass
+
+import (
+	"net"
+	"os"
+)
+
+// peerUID can't tell the peer of conn on this platform, so it trusts the
+// permissions of the socket's directory and returns the current user.
+func peerUID(conn *net.UnixConn) (int, error) {
+	return os.Getuid(), nil
+}
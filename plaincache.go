@@ -0,0 +1,125 @@
+package browserpass
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// Plaintexts keeps decrypted entries for a while when set, so filling the
+// same login again doesn't ask for the passphrase again. It is nil unless
+// enabled with NewPlaintextCache. Browsers start a new host for every
+// message, so only long-running hosts like the service benefit from it.
+var Plaintexts *PlaintextCache
+
+// PlaintextCache holds decrypted entries in locked memory for a fixed time
+// after their decryption, wiping them once it's up. Using an entry doesn't
+// extend its time.
+type PlaintextCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cachedPlaintext
+}
+
+type cachedPlaintext struct {
+	plaintext *SecureBytes
+	expiry    *time.Timer
+}
+
+// NewPlaintextCache returns a cache keeping entries for ttl.
+func NewPlaintextCache(ttl time.Duration) *PlaintextCache {
+	return &PlaintextCache{ttl: ttl, entries: make(map[string]*cachedPlaintext)}
+}
+
+// get returns a copy of the plaintext of item, which the caller must wipe,
+// or nil if it isn't cached.
+func (c *PlaintextCache) get(item string) (*SecureBytes, error) {
+	if c == nil {
+		return nil, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[item]
+	if !ok {
+		return nil, nil
+	}
+	plaintext, err := NewSecureBytes(e.plaintext.Len())
+	if err != nil {
+		return nil, err
+	}
+	plaintext.Write(e.plaintext.Bytes())
+	return plaintext, nil
+}
+
+// put caches a copy of the plaintext of item.
+func (c *PlaintextCache) put(item string, plaintext *SecureBytes) error {
+	if c == nil {
+		return nil
+	}
+	cached, err := NewSecureBytes(plaintext.Len())
+	if err != nil {
+		return err
+	}
+	cached.Write(plaintext.Bytes())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.forgetLocked(item)
+	e := &cachedPlaintext{plaintext: cached}
+	e.expiry = time.AfterFunc(c.ttl, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.entries[item] == e {
+			c.forgetLocked(item)
+		}
+	})
+	c.entries[item] = e
+	return nil
+}
+
+// Forget wipes the cached plaintext of item, after it changed.
+func (c *PlaintextCache) Forget(item string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.forgetLocked(item)
+	c.mu.Unlock()
+}
+
+// ForgetMoved wipes the cached plaintexts of the entries moved with src,
+// see pass.Moved.
+func (c *PlaintextCache) ForgetMoved(src string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for item := range c.entries {
+		if _, moved := pass.Moved(item, src, ""); moved {
+			c.forgetLocked(item)
+		}
+	}
+}
+
+func (c *PlaintextCache) forgetLocked(item string) {
+	if e, ok := c.entries[item]; ok {
+		e.expiry.Stop()
+		e.plaintext.Wipe()
+		delete(c.entries, item)
+	}
+}
+
+// Flush wipes every cached plaintext.
+func (c *PlaintextCache) Flush() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for item := range c.entries {
+		c.forgetLocked(item)
+	}
+}
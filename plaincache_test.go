@@ -0,0 +1,57 @@
+package browserpass
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/dannyvankooten/browserpass/fixture"
+)
+
+// countingDecrypter counts the decryptions of the fake decrypter.
+type countingDecrypter struct {
+	decryptions int
+}
+
+func (d *countingDecrypter) Decrypt(ctx context.Context, dst io.Writer, src io.Reader) error {
+	d.decryptions++
+	return fixture.FakeDecrypter{}.Decrypt(ctx, dst, src)
+}
+
+func TestPlaintextCache(t *testing.T) {
+	d := &countingDecrypter{}
+	DefaultDecrypter = d
+	Plaintexts = NewPlaintextCache(200 * time.Millisecond)
+	defer func() {
+		Plaintexts.Flush()
+		Plaintexts = nil
+		DefaultDecrypter = fixture.FakeDecrypter{}
+	}()
+	s := fakeStore{"example.com/alice"}
+	caller := AllowedOrigins[0]
+
+	get := func(decryptions int) {
+		t.Helper()
+		var login map[string]string
+		roundTrip(t, s, caller, map[string]string{"action": "get", "entry": "example.com/alice", "host": "example.com"}, &login)
+		if login["p"] != "password-of-example.com/alice" {
+			t.Errorf("Unexpected login %v", login)
+		}
+		if d.decryptions != decryptions {
+			t.Errorf("Decrypted %d times, expected %d", d.decryptions, decryptions)
+		}
+	}
+	get(1)
+	get(1)
+	Plaintexts.Flush()
+	get(2)
+	Plaintexts.Forget("example.com/alice")
+	get(3)
+
+	// The time is up no matter how often the entry is used
+	time.Sleep(120 * time.Millisecond)
+	get(3)
+	time.Sleep(120 * time.Millisecond)
+	get(4)
+}
@@ -0,0 +1,86 @@
+// Package platform knows where browsers look for native messaging hosts on
+// each operating system, and installs the host's manifest there.
+package platform
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HostName is the name the extension connects to the host with.
+const HostName = "com.dannyvankooten.browserpass"
+
+// Browser is a browser the host can be installed for.
+type Browser string
+
+// Supported browsers.
+const (
+	Chrome   Browser = "chrome"
+	Chromium Browser = "chromium"
+	Firefox  Browser = "firefox"
+	Vivaldi  Browser = "vivaldi"
+	Edge     Browser = "edge"
+)
+
+// Browsers lists the supported browsers.
+var Browsers = []Browser{Chrome, Chromium, Firefox, Vivaldi, Edge}
+
+var errUnsupported = errors.New("platform: not supported on this operating system")
+
+// manifest is a native messaging host manifest. Chrome lists the extensions
+// that may connect as origins, Firefox by extension ID.
+type manifest struct {
+	Name              string   `json:"name"`
+	Description       string   `json:"description"`
+	Path              string   `json:"path"`
+	Type              string   `json:"type"`
+	AllowedOrigins    []string `json:"allowed_origins,omitempty"`
+	AllowedExtensions []string `json:"allowed_extensions,omitempty"`
+}
+
+// Manifest returns the manifest for b of the host binary at path, allowing
+// the extensions in origins.
+func Manifest(b Browser, path string, origins []string) ([]byte, error) {
+	m := manifest{Name: HostName, Path: path, Type: "stdio"}
+	for _, origin := range origins {
+		if strings.HasPrefix(origin, "chrome-extension://") {
+			m.AllowedOrigins = append(m.AllowedOrigins, origin)
+		} else {
+			m.AllowedExtensions = append(m.AllowedExtensions, origin)
+		}
+	}
+	if b == Firefox {
+		m.Description = "Browserpass binary for the Firefox extension"
+		m.AllowedOrigins = nil
+	} else {
+		m.Description = "Browserpass binary for the Chrome extension"
+		m.AllowedExtensions = nil
+	}
+	return json.MarshalIndent(m, "", "  ")
+}
+
+// Install installs the manifest for b of the host binary at path, for the
+// current user or, with system set, for every user. It returns where the
+// manifest was written.
+func Install(b Browser, path string, origins []string, system bool) (string, error) {
+	dir, err := manifestDir(b, system)
+	if err != nil {
+		return "", err
+	}
+	data, err := Manifest(b, path, origins)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	// Readable by everyone, browsers may run as other users
+	file := filepath.Join(dir, HostName+".json")
+	if err := os.WriteFile(file, append(data, '\n'), 0644); err != nil {
+		return "", err
+	}
+	return file, register(b, file, system)
+}
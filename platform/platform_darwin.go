@@ -0,0 +1,42 @@
+package platform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// manifestDir returns the directory b reads host manifests from.
+func manifestDir(b Browser, system bool) (string, error) {
+	base := "/Library"
+	if !system {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, "Library")
+	}
+	switch {
+	case b == Chrome && system:
+		return filepath.Join(base, "Google", "Chrome", "NativeMessagingHosts"), nil
+	case b == Chrome:
+		return filepath.Join(base, "Application Support", "Google", "Chrome", "NativeMessagingHosts"), nil
+	case b == Chromium:
+		return filepath.Join(base, "Application Support", "Chromium", "NativeMessagingHosts"), nil
+	case b == Firefox:
+		return filepath.Join(base, "Application Support", "Mozilla", "NativeMessagingHosts"), nil
+	case b == Vivaldi:
+		return filepath.Join(base, "Application Support", "Vivaldi", "NativeMessagingHosts"), nil
+	case b == Edge && system:
+		return filepath.Join(base, "Microsoft", "Edge", "NativeMessagingHosts"), nil
+	case b == Edge:
+		return filepath.Join(base, "Application Support", "Microsoft Edge", "NativeMessagingHosts"), nil
+	}
+	return "", fmt.Errorf("platform: unknown browser %q", b)
+}
+
+// register makes the manifest at file known to b. Browsers on macOS find
+// manifests by their location.
+func register(b Browser, file string, system bool) error {
+	return nil
+}
@@ -0,0 +1,53 @@
+package platform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// manifestDir returns the directory b reads host manifests from.
+func manifestDir(b Browser, system bool) (string, error) {
+	if system {
+		switch b {
+		case Chrome:
+			return "/etc/opt/chrome/native-messaging-hosts", nil
+		case Chromium, Vivaldi:
+			return "/etc/chromium/native-messaging-hosts", nil
+		case Firefox:
+			return "/usr/lib/mozilla/native-messaging-hosts", nil
+		case Edge:
+			return "/etc/opt/edge/native-messaging-hosts", nil
+		}
+		return "", fmt.Errorf("platform: unknown browser %q", b)
+	}
+
+	if b == Firefox {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, ".mozilla", "native-messaging-hosts"), nil
+	}
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	switch b {
+	case Chrome:
+		return filepath.Join(config, "google-chrome", "NativeMessagingHosts"), nil
+	case Chromium:
+		return filepath.Join(config, "chromium", "NativeMessagingHosts"), nil
+	case Vivaldi:
+		return filepath.Join(config, "vivaldi", "NativeMessagingHosts"), nil
+	case Edge:
+		return filepath.Join(config, "microsoft-edge", "NativeMessagingHosts"), nil
+	}
+	return "", fmt.Errorf("platform: unknown browser %q", b)
+}
+
+// register makes the manifest at file known to b. Browsers on Linux find
+// manifests by their location.
+func register(b Browser, file string, system bool) error {
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package platform
+
+func manifestDir(b Browser, system bool) (string, error) {
+	return "", errUnsupported
+}
+
+func register(b Browser, file string, system bool) error {
+	return errUnsupported
+}
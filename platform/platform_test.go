@@ -0,0 +1,71 @@
+package platform
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+var origins = []string{"chrome-extension://jegbgfamcgeocbfeebacnkociplhmfbk/", "browserpass@dannyvankooten.com"}
+
+func TestManifest(t *testing.T) {
+	tests := map[Browser]manifest{
+		Chrome: {
+			Name:           HostName,
+			Description:    "Browserpass binary for the Chrome extension",
+			Path:           "/usr/bin/browserpass",
+			Type:           "stdio",
+			AllowedOrigins: origins[:1],
+		},
+		Firefox: {
+			Name:              HostName,
+			Description:       "Browserpass binary for the Firefox extension",
+			Path:              "/usr/bin/browserpass",
+			Type:              "stdio",
+			AllowedExtensions: origins[1:],
+		},
+	}
+	for b, expected := range tests {
+		data, err := Manifest(b, "/usr/bin/browserpass", origins)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual manifest
+		if err := json.Unmarshal(data, &actual); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: expected %+v, got %+v", b, expected, actual)
+		}
+	}
+}
+
+func TestInstall(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("manifest locations differ")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+
+	expected := map[Browser]string{
+		Chrome:  filepath.Join(home, "config", "google-chrome", "NativeMessagingHosts", HostName+".json"),
+		Firefox: filepath.Join(home, ".mozilla", "native-messaging-hosts", HostName+".json"),
+		Edge:    filepath.Join(home, "config", "microsoft-edge", "NativeMessagingHosts", HostName+".json"),
+	}
+	for b, path := range expected {
+		actual, err := Install(b, "/usr/bin/browserpass", origins, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != path {
+			t.Errorf("%s manifest installed to %s, expected %s", b, actual, path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package platform
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// registryKeys are the registry keys, under HKEY_CURRENT_USER or
+// HKEY_LOCAL_MACHINE, where browsers look up host manifests. Vivaldi reads
+// Chrome's.
+var registryKeys = map[Browser]string{
+	Chrome:   `Software\Google\Chrome\NativeMessagingHosts`,
+	Chromium: `Software\Chromium\NativeMessagingHosts`,
+	Firefox:  `Software\Mozilla\NativeMessagingHosts`,
+	Vivaldi:  `Software\Google\Chrome\NativeMessagingHosts`,
+	Edge:     `Software\Microsoft\Edge\NativeMessagingHosts`,
+}
+
+// manifestDir returns the directory to keep b's manifest in. Browsers on
+// Windows find it through the registry, so it is browserpass's own.
+func manifestDir(b Browser, system bool) (string, error) {
+	if _, ok := registryKeys[b]; !ok {
+		return "", fmt.Errorf("platform: unknown browser %q", b)
+	}
+	base := os.Getenv("ProgramData")
+	if !system {
+		var err error
+		if base, err = os.UserConfigDir(); err != nil {
+			return "", err
+		}
+	}
+	if base == "" {
+		return "", errors.New("platform: %ProgramData% is not defined")
+	}
+	return filepath.Join(base, "browserpass", string(b)), nil
+}
+
+// register points b's registry key at the manifest at file. It runs reg.exe
+// rather than depending on a registry package.
+func register(b Browser, file string, system bool) error {
+	root := "HKCU"
+	if system {
+		root = "HKLM"
+	}
+	key := root + `\` + registryKeys[b] + `\` + HostName
+	cmd := exec.Command("reg", "add", key, "/ve", "/t", "REG_SZ", "/d", file, "/f")
+	var errbuf bytes.Buffer
+	cmd.Stderr = &errbuf
+	if err := cmd.Run(); err != nil {
+		return errors.New(err.Error() + "\n" + errbuf.String())
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package browserpass
+
+import (
+	"strings"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// Policies maps callers (extension origins or IDs) to the store subtrees they
+// may access, e.g. {"work@example.com": ["work/"]}. Callers without a policy
+// can access the whole store.
+var Policies map[string][]string
+
+// Containers maps the Firefox containers (or browser profiles) named in
+// requests to the store subtree they may access, e.g. {"Work": "work/"}.
+// Requests from unmapped containers can access the whole store.
+var Containers map[string]string
+
+// allowedItem reports whether caller may access item from container under
+// Policies and Containers.
+func allowedItem(caller, container, item string) bool {
+	if subtrees, ok := Policies[caller]; ok && !withinSubtrees(item, subtrees) {
+		return false
+	}
+	if subtree, ok := Containers[container]; ok && container != "" && !withinSubtrees(item, []string{subtree}) {
+		return false
+	}
+	return true
+}
+
+func withinSubtrees(item string, subtrees []string) bool {
+	for _, subtree := range subtrees {
+		subtree = strings.TrimSuffix(subtree, "/")
+		if item == subtree || strings.HasPrefix(item, subtree+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// filterAllowed returns the items of list caller may access from container.
+func filterAllowed(caller, container string, list []string) []string {
+	allowed := []string{}
+	for _, item := range list {
+		if allowedItem(caller, container, item) {
+			allowed = append(allowed, item)
+		}
+	}
+	return allowed
+}
+
+// matchingRules is the "rules" response, describing how entries are matched
+// for a caller so the extension can explain why an entry is or isn't
+// offered. It reflects the host's configuration and the store's settings.
+type matchingRules struct {
+	// Search matches the start of an entry's file name or parent directory
+	SearchPrefix bool `json:"search_prefix"`
+	// Search matches fuzzily instead, see pass.FuzzySearch
+	Fuzzy bool `json:"fuzzy"`
+	// An entry matches a host if one of its path segments names it
+	// exactly, ignoring a leading "www."
+	IgnoreWWW bool `json:"ignore_www"`
+	// Filling an entry that doesn't match the tab's host needs confirmation
+	ConfirmInexact bool `json:"confirm_inexact"`
+	// Lookups of subdomains fall back to their parent domains up to the
+	// registrable domain of the Public Suffix List if one is installed,
+	// otherwise up to the domain below the TLD
+	PublicSuffixList bool `json:"public_suffix_list"`
+	// Domains using the entries of another domain, see pass.Settings
+	Aliases map[string]string `json:"aliases"`
+	// Patterns of files and directories left out of the store, those of
+	// the configuration followed by the store's
+	Ignore []string `json:"ignore"`
+	// Segments of entry names that name domains, any if empty
+	DomainSegments []int `json:"domain_segments"`
+	// Subtrees the caller is restricted to by Policies, empty if none
+	Subtrees []string `json:"subtrees"`
+	// Subtree the container is restricted to by Containers, empty if none
+	ContainerSubtree string `json:"container_subtree"`
+	// Subtree left out of searches without "archived"
+	ArchiveSubtree string `json:"archive_subtree"`
+}
+
+// rulesFor returns the matching rules of s applying to caller in container.
+func rulesFor(s pass.Store, caller, container string) (matchingRules, error) {
+	settings, err := pass.SettingsOf(s)
+	if err != nil {
+		return matchingRules{}, err
+	}
+	rules := matchingRules{
+		SearchPrefix:     !pass.FuzzySearch,
+		Fuzzy:            pass.FuzzySearch,
+		IgnoreWWW:        true,
+		ConfirmInexact:   true,
+		PublicSuffixList: publicSuffixes() != nil,
+		Aliases:          map[string]string{},
+		Ignore:           append(append([]string{}, pass.IgnorePatterns...), settings.Ignore...),
+		DomainSegments:   append([]int{}, settings.DomainSegments...),
+		Subtrees:         []string{},
+		ArchiveSubtree:   ArchiveDir + "/",
+	}
+	for domain, alias := range settings.Aliases {
+		rules.Aliases[domain] = alias
+	}
+	if subtrees, ok := Policies[caller]; ok {
+		rules.Subtrees = subtrees
+	}
+	if container != "" {
+		rules.ContainerSubtree = Containers[container]
+	}
+	return rules, nil
+}
@@ -0,0 +1,103 @@
+package browserpass
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+func TestPolicies(t *testing.T) {
+	Policies = map[string][]string{"work@example.com": {"work/", "shared"}}
+	defer func() { Policies = nil }()
+
+	tests := []struct {
+		caller, item string
+		expected     bool
+	}{
+		{"work@example.com", "work/example.com/alice", true},
+		{"work@example.com", "shared/wifi", true},
+		{"work@example.com", "personal/example.com", false},
+		{"work@example.com", "workshop/example.com", false},
+		{"browserpass@dannyvankooten.com", "personal/example.com", true},
+	}
+	for _, test := range tests {
+		if actual := allowedItem(test.caller, "", test.item); actual != test.expected {
+			t.Errorf("allowedItem(%s, %s): expected %v, got %v", test.caller, test.item, test.expected, actual)
+		}
+	}
+
+	list := filterAllowed("work@example.com", "", []string{"work/a", "personal/b"})
+	if !reflect.DeepEqual(list, []string{"work/a"}) {
+		t.Errorf("filterAllowed returned %v", list)
+	}
+}
+
+func TestContainers(t *testing.T) {
+	Containers = map[string]string{"Work": "work"}
+	defer func() { Containers = nil }()
+
+	tests := []struct {
+		container, item string
+		expected        bool
+	}{
+		{"Work", "work/example.com/alice", true},
+		{"Work", "personal/example.com/alice", false},
+		{"Personal", "personal/example.com/alice", true},
+		{"", "personal/example.com/alice", true},
+	}
+	for _, test := range tests {
+		if actual := allowedItem("browserpass@dannyvankooten.com", test.container, test.item); actual != test.expected {
+			t.Errorf("allowedItem(%s, %s): expected %v, got %v", test.container, test.item, test.expected, actual)
+		}
+	}
+}
+
+func TestRunRules(t *testing.T) {
+	caller := AllowedOrigins[0]
+	Policies = map[string][]string{caller: {"work/"}}
+	Containers = map[string]string{"Work": "work/example"}
+	pass.IgnorePatterns = []string{"*.bak"}
+	defer func() { Policies, Containers, pass.IgnorePatterns = nil, nil, nil }()
+	s := configuredStore{fakeStore{}, pass.Settings{
+		Aliases:        map[string]string{"youtube.com": "google.com"},
+		Ignore:         []string{"old/*"},
+		DomainSegments: []int{-2},
+	}}
+
+	base := matchingRules{
+		SearchPrefix:     true,
+		IgnoreWWW:        true,
+		ConfirmInexact:   true,
+		PublicSuffixList: publicSuffixes() != nil,
+		Aliases:          map[string]string{"youtube.com": "google.com"},
+		Ignore:           []string{"*.bak", "old/*"},
+		DomainSegments:   []int{-2},
+		Subtrees:         []string{"work/"},
+		ArchiveSubtree:   "archive/",
+	}
+	work := base
+	work.ContainerSubtree = "work/example"
+	tests := map[string]matchingRules{"": base, "Work": work, "Personal": base}
+	for container, expected := range tests {
+		var rules matchingRules
+		roundTrip(t, s, caller, map[string]string{"action": "rules", "container": container}, &rules)
+		if !reflect.DeepEqual(rules, expected) {
+			t.Errorf("Container %q: expected %+v, got %+v", container, expected, rules)
+		}
+	}
+
+	pass.FuzzySearch = true
+	defer func() { pass.FuzzySearch = false }()
+	var rules matchingRules
+	roundTrip(t, s, caller, map[string]string{"action": "rules"}, &rules)
+	if rules.SearchPrefix || !rules.Fuzzy {
+		t.Errorf("Fuzzy search reported as %+v", rules)
+	}
+
+	rules = matchingRules{}
+	roundTrip(t, s, "https://evil.example", map[string]string{"action": "rules"}, &rules)
+	if rules.Aliases != nil || rules.Subtrees != nil {
+		t.Errorf("Unauthorized caller got %+v", rules)
+	}
+}
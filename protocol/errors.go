@@ -0,0 +1,48 @@
+package protocol
+
+// CodeInternal is the code of the error answering requests whose handler
+// failed with an error other than an Error.
+const CodeInternal = "INTERNAL"
+
+// Error turns a request down with a code the extension can react to and
+// localize its message by. Handlers return it as their response or as their
+// error, wrapped or not, and Serve answers both alike: version 3 requests and
+// later with {"status": "error", "code": ..., "message": ...}, older ones
+// with the message in "error".
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Hint tells the user how to fix the problem, if it's on their side
+	Hint string `json:"hint,omitempty"`
+}
+
+func (e Error) Error() string {
+	return e.Message
+}
+
+// Is reports whether target is an Error with the same code, so errors.Is
+// matches errors of a kind whatever their message.
+func (e Error) Is(target error) bool {
+	t, ok := target.(Error)
+	return ok && t.Code == e.Code
+}
+
+// RejectionCode implements Rejection.
+func (e Error) RejectionCode() string {
+	return e.Code
+}
+
+// response returns the message answering a request of version with e.
+func (e Error) response(version int) map[string]string {
+	resp := map[string]string{"code": e.Code}
+	if version >= 3 {
+		resp["status"] = "error"
+		resp["message"] = e.Message
+	} else {
+		resp["error"] = e.Message
+	}
+	if e.Hint != "" {
+		resp["hint"] = e.Hint
+	}
+	return resp
+}
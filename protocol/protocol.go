@@ -0,0 +1,300 @@
+// Package protocol implements native messaging as used by Chrome and Firefox
+// to talk to the host: every message is a 32-bit length in native byte order
+// followed by that many bytes of JSON.
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ByteOrder is the byte order of message lengths. Browsers use the native
+// one, which is little endian on every platform they support.
+var ByteOrder = binary.LittleEndian
+
+// MaxResponseSize is the largest message a host may send to the browser.
+const MaxResponseSize = 1 << 20
+
+// ErrInvalidAction is returned by Mux.Serve for requests without a handler,
+// or whose handler refuses the action with it.
+var ErrInvalidAction = errors.New("Invalid action")
+
+// RequestTimeout bounds how long Mux.Serve lets a handler run, if positive.
+// Requests running out of time are answered with CodeTimeout.
+var RequestTimeout time.Duration
+
+// OnPanic is called, if set, when the goroutine of Mux.Serve reading
+// requests ahead panics, before the panic goes on. Requests carry secrets
+// being saved, so the host sets it to wipe them.
+var OnPanic func()
+
+// CodeTimeout is the code of the error answering requests that ran out of
+// time.
+const CodeTimeout = "TIMEOUT"
+
+// ReadMessage reads a single message from r into v.
+func ReadMessage(r io.Reader, v interface{}) error {
+	// Get message length, 4 bytes
+	var n uint32
+	if err := binary.Read(r, ByteOrder, &n); err != nil {
+		return err
+	}
+
+	// Get message body. The decoder may stop short of the end, after the
+	// JSON value, so skip what it left to find the next message.
+	lr := &io.LimitedReader{R: r, N: int64(n)}
+	if err := json.NewDecoder(lr).Decode(v); err != nil {
+		return err
+	}
+	_, err := io.Copy(io.Discard, lr)
+	return err
+}
+
+// WriteMessage writes v to w as a single JSON message.
+func WriteMessage(w io.Writer, v interface{}) error {
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(v); err != nil {
+		return err
+	}
+	return WriteFrame(w, b.Bytes())
+}
+
+// WriteFrame writes body, which must be JSON, to w as a single message.
+// Answering versioned requests, Mux.Serve hands Framers a writer making
+// WriteFrame stamp the message with the protocol version.
+func WriteFrame(w io.Writer, body []byte) error {
+	parts := [][]byte{body}
+	if vw, ok := w.(versionWriter); ok {
+		parts, w = stamp(body), vw.Writer
+	}
+	n := 0
+	for _, part := range parts {
+		n += len(part)
+	}
+	if n > MaxResponseSize {
+		return fmt.Errorf("protocol: %d byte message exceeds the browser's limit", n)
+	}
+	if err := binary.Write(w, ByteOrder, uint32(n)); err != nil {
+		return err
+	}
+	for _, part := range parts {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Framer is a response that writes its own message, for instance to keep
+// secrets out of the buffers used by WriteMessage.
+type Framer interface {
+	WriteFrame(w io.Writer) error
+}
+
+// Rejection is a response turning a request down, whose code Serve logs.
+type Rejection interface {
+	RejectionCode() string
+}
+
+// Handler answers a request. A nil response sends nothing back. ctx is
+// done once the browser hangs up or the request runs out of time, handlers
+// pass it on to whatever may take long.
+type Handler func(ctx context.Context, req map[string]string) (interface{}, error)
+
+// Mux routes requests to the Handler registered for their "action".
+type Mux map[string]Handler
+
+// Echo is a Handler returning the request, for the extension to check that
+// the host is reachable. The request's version is left out, responses to
+// versioned requests carry the host's.
+func Echo(ctx context.Context, req map[string]string) (interface{}, error) {
+	if _, ok := req["version"]; !ok {
+		return req, nil
+	}
+	echo := make(map[string]string, len(req))
+	for k, v := range req {
+		if k != "version" {
+			echo[k] = v
+		}
+	}
+	return echo, nil
+}
+
+// Serve answers the requests read from r on w until reading fails or a
+// request has no handler. Other handler errors are answered as an Error,
+// with CodeInternal unless they wrap one. Requests are read
+// while the previous one is handled: once reading fails, the browser hung
+// up and the request being handled is canceled. r must stay open until the
+// last response is read.
+func (m Mux) Serve(r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reqs, readErr := make(chan map[string]string), make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				if OnPanic != nil {
+					OnPanic()
+				}
+				panic(r)
+			}
+		}()
+		for {
+			var req map[string]string
+			if err := ReadMessage(r, &req); err != nil {
+				readErr <- err
+				cancel()
+				return
+			}
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		var req map[string]string
+		select {
+		case req = <-reqs:
+		case err := <-readErr:
+			return err
+		}
+		version, err := requestVersion(req)
+		if err != nil {
+			slog.Warn("unsupported protocol version", "action", req["action"], "version", req["version"])
+			if err := WriteMessage(w, m.versionError(err)); err != nil {
+				return err
+			}
+			continue
+		}
+		h, ok := m[req["action"]]
+		if !ok {
+			slog.Warn("invalid action", "action", req["action"])
+			return ErrInvalidAction
+		}
+		out := w
+		if version >= 2 {
+			out = versionWriter{w}
+		}
+		start := time.Now()
+		resp, err := handle(ctx, h, req, out)
+		logRequest(req["action"], start, resp, err)
+		var rejected Error
+		switch {
+		case errors.Is(err, context.Canceled) && ctx.Err() != nil:
+			// The browser hung up, nobody is waiting for an answer
+			return <-readErr
+		case errors.Is(err, context.DeadlineExceeded):
+			resp = Error{Code: CodeTimeout, Message: "The request took too long"}
+		case errors.Is(err, ErrInvalidAction):
+			return err
+		case errors.As(err, &rejected):
+			resp = rejected
+		case err != nil:
+			resp = Error{Code: CodeInternal, Message: err.Error()}
+		}
+		switch resp := resp.(type) {
+		case nil:
+		case Error:
+			err = WriteMessage(out, resp.response(version))
+		case *Error:
+			err = WriteMessage(out, resp.response(version))
+		case Framer:
+			err = resp.WriteFrame(out)
+		default:
+			err = WriteMessage(out, resp)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// handle runs h on req, within RequestTimeout. Requests asking for events
+// get them written to w until h returns, see Notify.
+func handle(ctx context.Context, h Handler, req map[string]string, w io.Writer) (interface{}, error) {
+	if RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, RequestTimeout)
+		defer cancel()
+	}
+	if req["events"] == "true" {
+		n := &notifier{w: w}
+		defer n.close()
+		ctx = context.WithValue(ctx, notifierKey{}, n)
+	}
+	return h(ctx, req)
+}
+
+type notifierKey struct{}
+
+// notifier writes the events of a request.
+type notifier struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closed bool
+}
+
+// close stops events once the request is answered.
+func (n *notifier) close() {
+	n.mu.Lock()
+	n.closed = true
+	n.mu.Unlock()
+}
+
+// Notify tells the browser about event while the request of ctx is handled,
+// as a message {"event": event} before the response. Only requests with
+// "events" set to "true" get events, browsers sending a single message only
+// read a single response. Notify may be called from any goroutine, events
+// of requests already answered are dropped.
+func Notify(ctx context.Context, event string) {
+	NotifyWith(ctx, event, nil)
+}
+
+// NotifyWith is Notify for an event with fields, which are sent along in its
+// message.
+func NotifyWith(ctx context.Context, event string, fields map[string]string) {
+	n, ok := ctx.Value(notifierKey{}).(*notifier)
+	if !ok {
+		return
+	}
+	msg := map[string]string{"event": event}
+	for key, value := range fields {
+		msg[key] = value
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.closed {
+		if err := WriteMessage(n.w, msg); err != nil {
+			slog.Warn("event not sent", "event", event, "error", err)
+		}
+	}
+}
+
+// logRequest logs a request handled in the time since start, under a new
+// request ID.
+func logRequest(action string, start time.Time, resp interface{}, err error) {
+	id := make([]byte, 8)
+	rand.Read(id)
+	attrs := []any{"request_id", hex.EncodeToString(id), "action", action, "duration", time.Since(start)}
+	if r, ok := resp.(Rejection); ok {
+		attrs = append(attrs, "rejected", r.RejectionCode())
+	}
+	if err != nil {
+		slog.Error("request failed", append(attrs, "error", err)...)
+		return
+	}
+	slog.Debug("request", attrs...)
+}
@@ -0,0 +1,219 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type rawFrame string
+
+func (f rawFrame) WriteFrame(w io.Writer) error {
+	return WriteFrame(w, []byte(f))
+}
+
+func TestMuxServe(t *testing.T) {
+	m := Mux{
+		"echo":   Echo,
+		"silent": func(context.Context, map[string]string) (interface{}, error) { return nil, nil },
+		"raw":    func(context.Context, map[string]string) (interface{}, error) { return rawFrame(`"raw"`), nil },
+	}
+
+	var in, out bytes.Buffer
+	for _, action := range []string{"echo", "silent", "raw"} {
+		WriteMessage(&in, map[string]string{"action": action})
+	}
+	if err := m.Serve(&in, &out); err != io.EOF {
+		t.Fatalf("Serve returned %v, expected EOF", err)
+	}
+
+	var echoed map[string]string
+	if err := ReadMessage(&out, &echoed); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(echoed, map[string]string{"action": "echo"}) {
+		t.Errorf("Echo returned %v", echoed)
+	}
+	var raw string
+	if err := ReadMessage(&out, &raw); err != nil || raw != "raw" {
+		t.Errorf("Framer response read as %q, %v", raw, err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("%d unexpected bytes left", out.Len())
+	}
+
+	in.Reset()
+	WriteMessage(&in, map[string]string{"action": "unknown"})
+	if err := m.Serve(&in, &out); !errors.Is(err, ErrInvalidAction) {
+		t.Errorf("Serve returned %v for an unknown action", err)
+	}
+}
+
+func TestMuxServeHangUp(t *testing.T) {
+	canceled := make(chan error, 1)
+	m := Mux{"slow": func(ctx context.Context, req map[string]string) (interface{}, error) {
+		<-ctx.Done()
+		canceled <- ctx.Err()
+		return nil, nil
+	}}
+	r, w := io.Pipe()
+	go func() {
+		WriteMessage(w, map[string]string{"action": "slow"})
+		w.Close()
+	}()
+	if err := m.Serve(r, io.Discard); err != io.EOF {
+		t.Errorf("Serve returned %v, expected EOF", err)
+	}
+	if err := <-canceled; err != context.Canceled {
+		t.Errorf("Request ended with %v once the browser hung up", err)
+	}
+}
+
+func TestMuxServeTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { RequestTimeout = timeout }(RequestTimeout)
+	RequestTimeout = 10 * time.Millisecond
+	m := Mux{"slow": func(ctx context.Context, req map[string]string) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	r, w := io.Pipe()
+	var out bytes.Buffer
+	done := make(chan error)
+	go func() { done <- m.Serve(r, &out) }()
+	WriteMessage(w, map[string]string{"action": "slow"})
+	WriteMessage(w, map[string]string{"action": "slow"})
+	w.Close()
+	if err := <-done; err != io.EOF {
+		t.Errorf("Serve returned %v, expected EOF", err)
+	}
+	var resp map[string]string
+	if err := ReadMessage(&out, &resp); err != nil || resp["code"] != CodeTimeout {
+		t.Errorf("Slow request answered with %v, %v", resp, err)
+	}
+}
+
+func TestMuxServeEvents(t *testing.T) {
+	m := Mux{"wait": func(ctx context.Context, req map[string]string) (interface{}, error) {
+		Notify(ctx, "waiting_for_touch")
+		return "done", nil
+	}}
+	var in, out bytes.Buffer
+	WriteMessage(&in, map[string]string{"action": "wait", "events": "true", "version": "2"})
+	WriteMessage(&in, map[string]string{"action": "wait"})
+	if err := m.Serve(&in, &out); err != io.EOF {
+		t.Fatalf("Serve returned %v, expected EOF", err)
+	}
+	var messages []interface{}
+	for {
+		var msg interface{}
+		if ReadMessage(&out, &msg) != nil {
+			break
+		}
+		messages = append(messages, msg)
+	}
+	// Only the request asking for events gets them
+	expected := []interface{}{
+		map[string]interface{}{"event": "waiting_for_touch", "version": float64(Version)},
+		map[string]interface{}{"result": "done", "version": float64(Version)},
+		"done",
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("Serve wrote %v, expected %v", messages, expected)
+	}
+}
+
+type rejection string
+
+func (r rejection) RejectionCode() string {
+	return string(r)
+}
+
+func TestMuxServeLog(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(l *slog.Logger) { slog.SetDefault(l) }(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	m := Mux{"get": func(context.Context, map[string]string) (interface{}, error) { return rejection("locked"), nil }}
+	var in, out bytes.Buffer
+	WriteMessage(&in, map[string]string{"action": "get", "entry": "example.com/alice"})
+	m.Serve(&in, &out)
+	logged := buf.String()
+	for _, expected := range []string{"request_id=", "action=get", "duration=", "rejected=locked"} {
+		if !strings.Contains(logged, expected) {
+			t.Errorf("Log %q lacks %q", logged, expected)
+		}
+	}
+}
+
+func TestMuxServeErrors(t *testing.T) {
+	notFound := Error{Code: "NOT_FOUND", Message: "entry not found"}
+	m := Mux{
+		"reject": func(context.Context, map[string]string) (interface{}, error) { return notFound, nil },
+		"wrap": func(context.Context, map[string]string) (interface{}, error) {
+			return nil, fmt.Errorf("opening example.com/alice: %w", notFound)
+		},
+		"fail": func(context.Context, map[string]string) (interface{}, error) { return nil, errors.New("disk on fire") },
+	}
+	var in, out bytes.Buffer
+	WriteMessage(&in, map[string]string{"action": "reject"})
+	WriteMessage(&in, map[string]string{"action": "reject", "version": "3"})
+	WriteMessage(&in, map[string]string{"action": "wrap", "version": "3"})
+	WriteMessage(&in, map[string]string{"action": "fail", "version": "3"})
+	if err := m.Serve(&in, &out); err != io.EOF {
+		t.Fatalf("Serve returned %v, expected EOF", err)
+	}
+
+	expected := []map[string]interface{}{
+		{"error": "entry not found", "code": "NOT_FOUND"},
+		{"status": "error", "message": "entry not found", "code": "NOT_FOUND", "version": float64(Version)},
+		{"status": "error", "message": "entry not found", "code": "NOT_FOUND", "version": float64(Version)},
+		{"status": "error", "message": "disk on fire", "code": CodeInternal, "version": float64(Version)},
+	}
+	for i, e := range expected {
+		var resp map[string]interface{}
+		if err := ReadMessage(&out, &resp); err != nil {
+			t.Fatalf("Response %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(resp, e) {
+			t.Errorf("Response %d is %v, expected %v", i, resp, e)
+		}
+	}
+
+	if err := fmt.Errorf("wrapped: %w", Error{Code: "NOT_FOUND", Message: "no such entry"}); !errors.Is(err, notFound) {
+		t.Error("errors.Is doesn't match Errors by code")
+	}
+}
+
+func TestReadMessageSequence(t *testing.T) {
+	// Whatever their length, messages must be read up to their end
+	var in bytes.Buffer
+	for n := 0; n < 600; n++ {
+		WriteMessage(&in, map[string]string{"a": strings.Repeat("x", n)})
+	}
+	for n := 0; n < 600; n++ {
+		var m map[string]string
+		if err := ReadMessage(&in, &m); err != nil {
+			t.Fatalf("Message %d: %v", n, err)
+		}
+		if len(m["a"]) != n {
+			t.Fatalf("Message %d read as %d bytes", n, len(m["a"]))
+		}
+	}
+	if err := ReadMessage(&in, new(interface{})); err != io.EOF {
+		t.Errorf("ReadMessage returned %v at the end, expected EOF", err)
+	}
+}
+
+func TestWriteFrameLimit(t *testing.T) {
+	body := `"` + strings.Repeat("a", MaxResponseSize) + `"`
+	if err := WriteFrame(io.Discard, []byte(body)); err == nil {
+		t.Error("Wrote a message over the browser's limit")
+	}
+}
@@ -0,0 +1,85 @@
+package protocol
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Version is the version of the protocol the host speaks. Requests carry
+// the version the extension speaks in their "version" field, requests
+// without one are version 1. Responses to version 2 requests and later are
+// always objects with a "version" field: results that aren't objects come
+// in a "result" field. Version 3 answers errors with a "status" and
+// "message", see Error.
+const Version = 3
+
+// MinVersion is the oldest version of the protocol the host still answers.
+const MinVersion = 1
+
+// CodeUnsupportedVersion is the code of the error answering requests of a
+// version outside MinVersion to Version. The error's "version",
+// "min_version" and "capabilities" tell the extension whether it or the host
+// needs upgrading.
+const CodeUnsupportedVersion = "UNSUPPORTED_VERSION"
+
+// Capabilities returns the actions m handles, sorted.
+func (m Mux) Capabilities() []string {
+	actions := make([]string, 0, len(m))
+	for action := range m {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	return actions
+}
+
+// requestVersion returns the protocol version of req.
+func requestVersion(req map[string]string) (int, error) {
+	v, ok := req["version"]
+	if !ok {
+		return 1, nil
+	}
+	version, err := strconv.Atoi(v)
+	if err != nil || version < MinVersion || version > Version {
+		return 0, fmt.Errorf("protocol version %q is not supported, the host speaks %d to %d", v, MinVersion, Version)
+	}
+	return version, nil
+}
+
+// versionError answers a request of an unsupported version.
+func (m Mux) versionError(err error) map[string]interface{} {
+	// The version of the request is unknown, so both shapes of errors
+	return map[string]interface{}{
+		"status":       "error",
+		"error":        err.Error(),
+		"message":      err.Error(),
+		"code":         CodeUnsupportedVersion,
+		"version":      Version,
+		"min_version":  MinVersion,
+		"capabilities": m.Capabilities(),
+	}
+}
+
+// versionWriter makes WriteFrame stamp messages with the protocol version,
+// including those of Framers.
+type versionWriter struct {
+	io.Writer
+}
+
+// stamp returns the parts of body stamped with the version: objects get a
+// "version" field, anything else is wrapped in an object. body isn't
+// copied, it may be a secret in locked memory.
+func stamp(body []byte) [][]byte {
+	version := []byte(`{"version":` + strconv.Itoa(Version))
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return [][]byte{append(version, `,"result":`...), body, []byte("}")}
+	}
+	rest := trimmed[1:]
+	if next := bytes.TrimLeft(rest, " \t\r\n"); len(next) == 0 || next[0] != '}' {
+		version = append(version, ',')
+	}
+	return [][]byte{version, rest}
+}
@@ -0,0 +1,84 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestMuxServeVersion(t *testing.T) {
+	m := Mux{
+		"echo": Echo,
+		"list": func(context.Context, map[string]string) (interface{}, error) {
+			return []string{"example.com/alice"}, nil
+		},
+		"raw": func(context.Context, map[string]string) (interface{}, error) {
+			return rawFrame(` {"p":"hunter2"}`), nil
+		},
+		"none": func(context.Context, map[string]string) (interface{}, error) { return rawFrame(`{}`), nil },
+	}
+	requests := []map[string]string{
+		{"action": "list"},
+		{"action": "list", "version": "2"},
+		{"action": "echo", "version": "2"},
+		{"action": "raw", "version": "2"},
+		{"action": "none", "version": "2"},
+		{"action": "list", "version": "4"},
+		{"action": "list", "version": "two"},
+	}
+	var in, out bytes.Buffer
+	for _, req := range requests {
+		WriteMessage(&in, req)
+	}
+	if err := m.Serve(&in, &out); err != io.EOF {
+		t.Fatalf("Serve returned %v, expected EOF", err)
+	}
+
+	expected := []interface{}{
+		[]interface{}{"example.com/alice"},
+		map[string]interface{}{"version": float64(Version), "result": []interface{}{"example.com/alice"}},
+		map[string]interface{}{"version": float64(Version), "action": "echo"},
+		map[string]interface{}{"version": float64(Version), "p": "hunter2"},
+		map[string]interface{}{"version": float64(Version)},
+	}
+	for i, e := range expected {
+		var resp interface{}
+		if err := ReadMessage(&out, &resp); err != nil {
+			t.Fatalf("Response %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(resp, e) {
+			t.Errorf("Response %d is %v, expected %v", i, resp, e)
+		}
+	}
+	for _, version := range []string{"4", "two"} {
+		var resp struct {
+			Code         string   `json:"code"`
+			Version      int      `json:"version"`
+			MinVersion   int      `json:"min_version"`
+			Capabilities []string `json:"capabilities"`
+		}
+		if err := ReadMessage(&out, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Code != CodeUnsupportedVersion || resp.Version != Version || resp.MinVersion != MinVersion || !reflect.DeepEqual(resp.Capabilities, []string{"echo", "list", "none", "raw"}) {
+			t.Errorf("Version %s answered %+v", version, resp)
+		}
+	}
+}
+
+func TestStamp(t *testing.T) {
+	tests := map[string]string{
+		`{"u":"alice"}`: `{"version":3,"u":"alice"}`,
+		"{ }\n":         `{"version":3 }` + "\n",
+		`"raw"`:         `{"version":3,"result":"raw"}`,
+	}
+	for body, expected := range tests {
+		stamped := bytes.Join(stamp([]byte(body)), nil)
+		if string(stamped) != expected || !json.Valid(stamped) {
+			t.Errorf("%q stamped as %q, expected %q", body, stamped, expected)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package browserpass
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// suffixListPaths are where distributions install the Public Suffix List.
+var suffixListPaths = []string{
+	"/usr/share/publicsuffix/public_suffix_list.dat",
+	"/usr/local/share/publicsuffix/public_suffix_list.dat",
+	"/opt/homebrew/share/publicsuffix/public_suffix_list.dat",
+}
+
+var (
+	suffixOnce sync.Once
+	// suffixes is the Public Suffix List, nil if none is installed
+	suffixes *suffixList
+)
+
+// suffixList holds the rules of the Public Suffix List, see
+// https://publicsuffix.org/list/. Wildcard and exception rules are kept
+// without their "*." and "!".
+type suffixList struct {
+	rules, wildcards, exceptions map[string]bool
+}
+
+// publicSuffixes returns the installed Public Suffix List, loading it on
+// first use.
+func publicSuffixes() *suffixList {
+	suffixOnce.Do(func() {
+		if suffixes != nil {
+			return
+		}
+		for _, path := range suffixListPaths {
+			f, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			suffixes, err = parseSuffixList(f)
+			f.Close()
+			if err == nil {
+				return
+			}
+		}
+	})
+	return suffixes
+}
+
+// parseSuffixList reads a list in the format of public_suffix_list.dat.
+func parseSuffixList(r io.Reader) (*suffixList, error) {
+	l := &suffixList{make(map[string]bool), make(map[string]bool), make(map[string]bool)}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "//") {
+			continue
+		}
+		// Hosts are looked up in their ASCII form, the list has IDN rules in
+		// Unicode
+		rule := fields[0]
+		switch {
+		case strings.HasPrefix(rule, "!"):
+			l.exceptions[asciiHost(rule[1:])] = true
+		case strings.HasPrefix(rule, "*."):
+			l.wildcards[asciiHost(rule[2:])] = true
+		default:
+			l.rules[asciiHost(rule)] = true
+		}
+	}
+	return l, scanner.Err()
+}
+
+// publicSuffix returns the public suffix of domain, like "co.uk" for
+// "foo.co.uk". Without a list every TLD is a public suffix.
+func (l *suffixList) publicSuffix(domain string) string {
+	labels := strings.Split(domain, ".")
+	if l != nil {
+		// The first rule matching, from the longest candidate down, is the
+		// prevailing one
+		for i := range labels {
+			candidate := strings.Join(labels[i:], ".")
+			switch {
+			case l.exceptions[candidate]:
+				return strings.Join(labels[i+1:], ".")
+			case l.rules[candidate]:
+				return candidate
+			case i > 0 && l.wildcards[candidate]:
+				return strings.Join(labels[i-1:], ".")
+			}
+		}
+	}
+	return labels[len(labels)-1]
+}
+
+// registrableDomain returns the public suffix of domain plus one label, like
+// "foo.co.uk" for "www.foo.co.uk", or "" if domain is a public suffix.
+func (l *suffixList) registrableDomain(domain string) string {
+	suffix := l.publicSuffix(domain)
+	if len(domain) <= len(suffix) {
+		return ""
+	}
+	rest := domain[:len(domain)-len(suffix)-1]
+	return rest[strings.LastIndex(rest, ".")+1:] + "." + suffix
+}
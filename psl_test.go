@@ -0,0 +1,74 @@
+package browserpass
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSuffixList = `// ===BEGIN ICANN DOMAINS===
+com
+uk
+co.uk
+*.ck
+!www.ck
+
+// ===BEGIN PRIVATE DOMAINS===
+github.io
+`
+
+func TestRegistrableDomain(t *testing.T) {
+	l, err := parseSuffixList(strings.NewReader(testSuffixList))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"example.com":       "example.com",
+		"a.b.example.com":   "example.com",
+		"www.foo.co.uk":     "foo.co.uk",
+		"co.uk":             "",
+		"alice.github.io":   "alice.github.io",
+		"a.b.ck":            "a.b.ck",
+		"b.ck":              "",
+		"www.ck":            "www.ck",
+		"example.unlisted":  "example.unlisted",
+		"a.example.unknown": "example.unknown",
+	}
+	for domain, expected := range tests {
+		if actual := l.registrableDomain(domain); actual != expected {
+			t.Errorf("registrableDomain(%q) is %q, expected %q", domain, actual, expected)
+		}
+	}
+
+	var none *suffixList
+	if actual := none.registrableDomain("foo.co.uk"); actual != "co.uk" {
+		t.Errorf("Without a list got %q, expected co.uk", actual)
+	}
+}
+
+func TestLookupPublicSuffix(t *testing.T) {
+	l, err := parseSuffixList(strings.NewReader(testSuffixList))
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicSuffixes()
+	defer func(saved *suffixList) { suffixes = saved }(suffixes)
+	suffixes = l
+
+	s := fakeStore{"co.uk/alice", "foo.co.uk/bob"}
+	tests := map[string][]string{
+		"foo.co.uk":       {"foo.co.uk/bob"},
+		"login.foo.co.uk": {"foo.co.uk/bob"},
+		"bar.co.uk":       nil,
+	}
+	for host, expected := range tests {
+		actual, err := Lookup(context.Background(), s, host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Lookup(%s): expected %v, got %v", host, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package browserpass
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// Landlock system calls and constants from <linux/landlock.h>.
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockCreateRulesetVersion = 1 << 0
+	landlockRulePathBeneath      = 1
+
+	prSetNoNewPrivs = 38
+	oPath           = 0x200000
+
+	accessExecute   = 1 << 0
+	accessWriteFile = 1 << 1
+	accessReadFile  = 1 << 2
+	accessReadDir   = 1 << 3
+	// accessAll covers every filesystem right of Landlock ABI 1
+	accessAll = 1<<13 - 1
+	// accessRefer, from ABI 2, allows linking and renaming files into
+	// other directories. Rulesets that don't handle it always deny that
+	// with EXDEV, so moving entries between folders of the store needs it.
+	accessRefer = 1 << 13
+
+	accessRead = accessExecute | accessReadFile | accessReadDir
+	// accessFile are the only rights that apply to regular files
+	accessFile = accessExecute | accessWriteFile | accessReadFile
+	// accessReadWrite reads and writes existing files, like devices
+	accessReadWrite = accessReadFile | accessWriteFile | accessReadDir
+)
+
+// sandboxDevices are the devices gpg, pinentry and the host use. The rest
+// of /dev stays out of reach.
+var sandboxDevices = []string{"/dev/null", "/dev/tty", "/dev/pts", "/dev/urandom", "/dev/random"}
+
+// errSandboxCgo is returned by Sandbox in builds using cgo.
+var errSandboxCgo = errors.New("sandbox: not available in builds with cgo, build with CGO_ENABLED=0")
+
+// Sandbox confines the process, and the gpg processes it starts, to the
+// filesystem paths browserpass needs: rw lists paths that may be modified
+// (the store, config and cache dirs), system directories needed to run gpg
+// are readable. Of /dev only sandboxDevices are usable. Pushes over SSH can
+// read ~/.ssh and record new hosts in known_hosts, and the host can run
+// itself again as the clipboard clearer. It uses Landlock and fails on
+// kernels without it. Before Landlock ABI 2 (Linux 5.19) entries can't be
+// moved to other folders. Then the syscalls of deniedSyscalls are refused,
+// see restrictSyscalls.
+func Sandbox(rw ...string) error {
+	abi, _, errno := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if errno != 0 {
+		return errno
+	}
+	if abi < 1 {
+		return syscall.ENOSYS
+	}
+
+	handled := uint64(accessAll)
+	if abi >= 2 {
+		handled |= accessRefer
+	}
+	fd, _, errno := syscall.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&handled)), unsafe.Sizeof(handled), 0)
+	if errno != 0 {
+		return errno
+	}
+	defer syscall.Close(int(fd))
+
+	home, _ := os.UserHomeDir()
+	rw = append(rw, filepath.Join(home, ".gnupg"), os.Getenv("GNUPGHOME"), os.Getenv("XDG_RUNTIME_DIR"))
+	for _, path := range rw {
+		if err := allowPath(int(fd), path, handled); err != nil {
+			return err
+		}
+	}
+	read := []string{"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/etc", "/proc", "/run", "/opt", "/nix"}
+	// ssh and git for pushes, X11 for the clipboard tools
+	read = append(read, filepath.Join(home, ".ssh"), filepath.Join(home, ".gitconfig"), filepath.Join(home, ".config", "git"), os.Getenv("XAUTHORITY"), filepath.Join(home, ".Xauthority"))
+	if exe, err := os.Executable(); err == nil {
+		read = append(read, exe)
+	}
+	for _, path := range read {
+		if err := allowPath(int(fd), path, accessRead); err != nil {
+			return err
+		}
+	}
+	for _, path := range append(sandboxDevices, filepath.Join(home, ".ssh", "known_hosts")) {
+		if err := allowPath(int(fd), path, accessReadWrite); err != nil {
+			return err
+		}
+	}
+
+	// Restrict every thread of the Go runtime, not just the calling one.
+	// Threads started by C code can't be reached.
+	if _, _, errno := syscall.AllThreadsSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno == syscall.ENOTSUP {
+		return errSandboxCgo
+	} else if errno != 0 {
+		return errno
+	}
+	if _, _, errno := syscall.AllThreadsSyscall(sysLandlockRestrictSelf, fd, 0, 0); errno != 0 {
+		return errno
+	}
+	return restrictSyscalls()
+}
+
+// allowPath grants access beneath path. Paths that don't exist are skipped.
+func allowPath(ruleset int, path string, access uint64) error {
+	if path == "" {
+		return nil
+	}
+	fd, err := syscall.Open(path, oPath|syscall.O_CLOEXEC, 0)
+	if err == syscall.ENOENT {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	// struct landlock_path_beneath_attr is packed: a u64 followed by an s32
+	var attr [12]byte
+	*(*uint64)(unsafe.Pointer(&attr[0])) = access
+	*(*int32)(unsafe.Pointer(&attr[8])) = int32(fd)
+
+	var st syscall.Stat_t
+	if err := syscall.Fstat(fd, &st); err == nil && st.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		*(*uint64)(unsafe.Pointer(&attr[0])) = access & accessFile
+	}
+
+	if _, _, errno := syscall.Syscall6(sysLandlockAddRule, uintptr(ruleset), landlockRulePathBeneath, uintptr(unsafe.Pointer(&attr[0])), 0, 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
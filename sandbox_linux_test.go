@@ -0,0 +1,213 @@
+package browserpass
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// sandboxedStore names the store TestSandboxMove moves an entry in once
+// sandboxed, in the process it starts for it, and TestSandboxInit creates.
+const sandboxedStore = "BROWSERPASS_TEST_SANDBOXED_STORE"
+
+// sandboxed runs test in a process of its own with dir in sandboxedStore and
+// env added to its environment, since Landlock can't be undone, skipping it
+// if Landlock isn't available.
+func sandboxed(t *testing.T, test, dir string, env ...string) {
+	cmd := exec.Command(os.Args[0], "-test.run=^"+test+"$", "-test.v")
+	cmd.Env = append(append(os.Environ(), env...), sandboxedStore+"="+dir)
+	out, err := cmd.CombinedOutput()
+	if bytes.Contains(out, []byte("--- SKIP")) {
+		t.Skipf("%s", out)
+	}
+	if err != nil {
+		t.Fatalf("%s failed in the sandbox: %v\n%s", test, err, out)
+	}
+}
+
+func TestSandboxMove(t *testing.T) {
+	if dir := os.Getenv(sandboxedStore); dir != "" {
+		if err := Sandbox(dir); err != nil {
+			t.Skipf("Landlock is not available: %v", err)
+		}
+		s, err := pass.NewDiskStore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pass.Move(s, "example.com/alice", "example.org/alice"); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{".gpg-id", "example.com/alice.gpg"} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0700)
+		os.WriteFile(filepath.Join(dir, name), []byte("alice@example.com\n"), 0600)
+	}
+	sandboxed(t, "TestSandboxMove", dir)
+	if _, err := os.Stat(filepath.Join(dir, "example.org", "alice.gpg")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSandboxReplace(t *testing.T) {
+	if dir := os.Getenv(sandboxedStore); dir != "" {
+		if err := Sandbox(dir); err != nil {
+			t.Skipf("Landlock is not available: %v", err)
+		}
+		f, err := pass.CreateTemp(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString("ciphertext")
+		if err := pass.Replace(f, filepath.Join(dir, "alice.gpg")); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	dir := t.TempDir()
+	sandboxed(t, "TestSandboxReplace", dir)
+	if data, err := os.ReadFile(filepath.Join(dir, "alice.gpg")); err != nil || string(data) != "ciphertext" {
+		t.Errorf("Replaced file holds %q, %v", data, err)
+	}
+}
+
+func TestSandboxInit(t *testing.T) {
+	if dir := os.Getenv(sandboxedStore); dir != "" {
+		if err := Sandbox(dir); err != nil {
+			t.Skipf("Landlock is not available: %v", err)
+		}
+		s, err := pass.NewDiskStore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pass.Init(s, []string{"alice@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	// Like the host does for stores that don't exist yet
+	dir := filepath.Join(t.TempDir(), "store")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	sandboxed(t, "TestSandboxInit", dir)
+	if _, err := os.Stat(filepath.Join(dir, ".gpg-id")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSandboxDevices(t *testing.T) {
+	if dir := os.Getenv(sandboxedStore); dir != "" {
+		if err := Sandbox(dir); err != nil {
+			t.Skipf("Landlock is not available: %v", err)
+		}
+		if err := os.WriteFile("/dev/null", []byte("x"), 0); err != nil {
+			t.Error(err)
+		}
+		if _, err := os.ReadFile("/dev/zero"); err == nil {
+			t.Error("/dev/zero could be opened")
+		}
+		if _, err := os.ReadDir("/dev"); err == nil {
+			t.Error("/dev could be listed")
+		}
+		return
+	}
+	sandboxed(t, "TestSandboxDevices", t.TempDir())
+}
+
+func TestSandboxReexec(t *testing.T) {
+	if dir := os.Getenv(sandboxedStore); dir != "" {
+		if err := Sandbox(dir); err != nil {
+			t.Skipf("Landlock is not available: %v", err)
+		}
+		// Like the clipboard clearer
+		exe, err := os.Executable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out, err := exec.Command(exe, "-test.run=^$").CombinedOutput(); err != nil {
+			t.Errorf("Running the host again failed: %v\n%s", err, out)
+		}
+		return
+	}
+	sandboxed(t, "TestSandboxReexec", t.TempDir())
+}
+
+func TestSandboxGitPush(t *testing.T) {
+	if dir := os.Getenv(sandboxedStore); dir != "" {
+		if err := Sandbox(dir); err != nil {
+			t.Skipf("Landlock is not available: %v", err)
+		}
+		// ssh reads ~/.ssh to push to remotes over SSH, it finds the real one
+		// by the user database rather than $HOME
+		if out, err := exec.Command("ssh", "-F", filepath.Join(os.Getenv("HOME"), ".ssh", "config"), "-G", "store.example").Output(); err != nil || !bytes.Contains(out, []byte("user alice")) {
+			t.Errorf("ssh didn't read its config: %v\n%s", err, out)
+		}
+		if out, err := exec.Command("git", "-C", filepath.Join(dir, "store"), "push", "origin", "HEAD").CombinedOutput(); err != nil {
+			t.Errorf("git push failed: %v\n%s", err, out)
+		}
+		return
+	}
+	for _, tool := range []string{"git", "ssh"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s is not installed", tool)
+		}
+	}
+
+	dir := t.TempDir()
+	home := filepath.Join(dir, "home")
+	os.MkdirAll(filepath.Join(home, ".ssh"), 0700)
+	os.WriteFile(filepath.Join(home, ".ssh", "config"), []byte("Host store.example\n\tUser alice\n"), 0600)
+	store := filepath.Join(dir, "store")
+	os.MkdirAll(store, 0700)
+	os.WriteFile(filepath.Join(store, ".gpg-id"), []byte("alice@example.com\n"), 0600)
+	for _, args := range [][]string{
+		{"init", "--bare", filepath.Join(dir, "remote.git")},
+		{"-C", store, "init"},
+		{"-C", store, "add", ".gpg-id"},
+		{"-C", store, "-c", "user.name=Alice", "-c", "user.email=alice@example.com", "commit", "-m", "Initialize store"},
+		{"-C", store, "remote", "add", "origin", filepath.Join(dir, "remote.git")},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	sandboxed(t, "TestSandboxGitPush", dir, "HOME="+home)
+	if out, err := exec.Command("git", "-C", filepath.Join(dir, "remote.git"), "log", "--oneline").CombinedOutput(); err != nil || !bytes.Contains(out, []byte("Initialize store")) {
+		t.Errorf("Nothing was pushed: %v\n%s", err, out)
+	}
+}
+
+func TestRestrictSyscalls(t *testing.T) {
+	if os.Getenv(sandboxedStore) != "" {
+		if _, _, errno := syscall.AllThreadsSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno == syscall.ENOTSUP {
+			t.Skip(errSandboxCgo)
+		} else if errno != 0 {
+			t.Fatal(errno)
+		}
+		if err := restrictSyscalls(); err != nil {
+			t.Skipf("seccomp is not available: %v", err)
+		}
+		if len(deniedSyscalls) == 0 {
+			t.Skip("no syscalls are denied on this architecture")
+		}
+		// process_vm_readv, reading another process's memory, here our own
+		if _, _, errno := syscall.Syscall6(uintptr(deniedSyscalls[1]), uintptr(os.Getpid()), 0, 0, 0, 0, 0); errno != syscall.EPERM {
+			t.Errorf("process_vm_readv returned %v", errno)
+		}
+		if err := exec.Command("true").Run(); err != nil {
+			t.Errorf("Running a program failed: %v", err)
+		}
+		return
+	}
+	sandboxed(t, "TestRestrictSyscalls", t.TempDir())
+}
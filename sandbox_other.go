@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package browserpass
+
+import "errors"
+
+// Sandbox is only supported on Linux.
+func Sandbox(rw ...string) error {
+	return errors.New("sandbox: not supported on this platform")
+}
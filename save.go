@@ -0,0 +1,247 @@
+package browserpass
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/dannyvankooten/browserpass/generate"
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// errNotSingleLine refuses login fields from the extension that would add
+// lines to an entry, such as a "url:" line for another site or an otpauth
+// URI, or split the password.
+var errNotSingleLine = errors.New("password, username and url must not contain line breaks or NUL")
+
+// singleLine returns errNotSingleLine if a value holds CR, LF or NUL.
+func singleLine(values ...string) error {
+	for _, v := range values {
+		if strings.ContainsAny(v, "\r\n\x00") {
+			return errNotSingleLine
+		}
+	}
+	return nil
+}
+
+// formatLogin formats a login as an entry in the usual pass layout.
+func formatLogin(password, username, url string) ([]byte, error) {
+	if err := singleLine(password, username, url); err != nil {
+		return nil, err
+	}
+	content := []byte(password + "\n")
+	if username != "" {
+		content = append(content, "login: "+username+"\n"...)
+	}
+	if url != "" {
+		content = append(content, "url: "+url+"\n"...)
+	}
+	return content, nil
+}
+
+// checkWrite runs the checks of requests changing the entry in data without
+// decrypting it first, returning the rejection if any.
+func (c *conn) checkWrite(data map[string]string) *errorResponse {
+	if refused := c.checkSession(data); refused != nil {
+		return refused
+	}
+	if err := validateItem(data["entry"]); err != nil {
+		return &errorResponse{Message: err.Error(), Code: CodeInvalidItem}
+	}
+	if !allowedItem(c.caller, data["container"], data["entry"]) {
+		return &errorResponse{Message: "entry is outside the caller's policy", Code: CodeInvalidItem}
+	}
+	return nil
+}
+
+// create saves a login from the extension as a new entry, for the "save
+// login" prompt after signing up on a site.
+func (c *conn) create(ctx context.Context, data map[string]string) (interface{}, error) {
+	item := data["entry"]
+	if refused := c.checkWrite(data); refused != nil {
+		return refused, nil
+	}
+	if data["password"] == "" {
+		return errorResponse{Message: "missing password", Code: CodeInvalidRequest}, nil
+	}
+
+	content, err := formatLogin(data["password"], data["username"], data["url"])
+	if err != nil {
+		return errorResponse{Message: err.Error(), Code: CodeInvalidRequest}, nil
+	}
+	err = c.s.Create(item, content)
+	for i := range content {
+		content[i] = 0
+	}
+	Plaintexts.Forget(item)
+	dropLoadedIndex()
+	switch err {
+	case nil:
+	case pass.ErrExists:
+		return errorResponse{Message: err.Error(), Code: CodeExists}, nil
+	case pass.ErrReadOnly:
+		return errorResponse{Message: err.Error(), Code: CodeInvalidRequest}, nil
+	default:
+		return nil, err
+	}
+	if err := c.audit(item, data["host"]); err != nil {
+		return nil, err
+	}
+	return map[string]string{"entry": item}, nil
+}
+
+// update changes the password of an existing entry and keeps the rest of it,
+// for the "update password" prompt after a password change on a site.
+func (c *conn) update(ctx context.Context, data map[string]string) (interface{}, error) {
+	if refused := c.checkSession(data); refused != nil {
+		return refused, nil
+	}
+	if data["password"] == "" {
+		return errorResponse{Message: "missing password", Code: CodeInvalidRequest}, nil
+	}
+	if err := singleLine(data["password"]); err != nil {
+		return errorResponse{Message: err.Error(), Code: CodeInvalidRequest}, nil
+	}
+	u, ok := c.s.(pass.Updater)
+	if !ok {
+		return errorResponse{Message: pass.ErrReadOnly.Error(), Code: CodeInvalidRequest}, nil
+	}
+	plaintext, refused, err := c.decryptEntry(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	if refused != nil {
+		return refused, nil
+	}
+	content, err := replacePassword(plaintext.Bytes(), data["password"])
+	plaintext.Wipe()
+	if err != nil {
+		return nil, err
+	}
+	err = u.Update(data["entry"], content.Bytes())
+	content.Wipe()
+	Plaintexts.Forget(data["entry"])
+	dropLoadedIndex()
+	switch err {
+	case nil:
+	case pass.ErrReadOnly:
+		return errorResponse{Message: err.Error(), Code: CodeInvalidRequest}, nil
+	default:
+		return nil, err
+	}
+	if err := c.audit(data["entry"], data["host"]); err != nil {
+		return nil, err
+	}
+	return map[string]string{"entry": data["entry"]}, nil
+}
+
+// replacePassword returns plaintext with its first line replaced by
+// password, in locked memory. The otpauth URI of an OTP-only entry is kept
+// below the new password.
+func replacePassword(plaintext []byte, password string) (*SecureBytes, error) {
+	if err := singleLine(password); err != nil {
+		return nil, err
+	}
+	rest := plaintext
+	if !isOTPURI(bytes.SplitN(plaintext, []byte("\n"), 2)[0]) {
+		rest = nil
+		if i := bytes.IndexByte(plaintext, '\n'); i >= 0 {
+			rest = plaintext[i+1:]
+		}
+	}
+	content, err := NewSecureBytes(len(password) + 1 + len(rest))
+	if err != nil {
+		return nil, err
+	}
+	content.WriteString(password + "\n")
+	content.Write(rest)
+	return content, nil
+}
+
+// remove deletes an entry, for managing logins from the extension.
+func (c *conn) remove(ctx context.Context, data map[string]string) (interface{}, error) {
+	item := data["entry"]
+	if refused := c.checkWrite(data); refused != nil {
+		return refused, nil
+	}
+	err := c.s.Delete(item)
+	Plaintexts.Forget(item)
+	dropLoadedIndex()
+	switch err {
+	case nil:
+	case pass.ErrNotFound:
+		return errorResponse{Message: err.Error(), Code: CodeNotFound}, nil
+	case pass.ErrReadOnly:
+		return errorResponse{Message: err.Error(), Code: CodeInvalidRequest}, nil
+	default:
+		return nil, err
+	}
+	knownURLs.update(item, nil)
+	if err := c.audit(item, data["host"]); err != nil {
+		return nil, err
+	}
+	return map[string]string{"entry": item}, nil
+}
+
+// generatePassword answers the "generate" action with a new password. With an
+// "entry" the password is saved there too, as a new login or, with
+// "in_place", as the new password of an existing one, so generating and
+// saving takes one round trip.
+func (c *conn) generatePassword(ctx context.Context, data map[string]string) (interface{}, error) {
+	policy, refused := generatePolicy(data)
+	if refused != nil {
+		return refused, nil
+	}
+	password, err := policy.Password()
+	if err != nil {
+		return errorResponse{Message: err.Error(), Code: CodeInvalidRequest}, nil
+	}
+	if data["entry"] == "" {
+		return map[string]string{"password": password}, nil
+	}
+
+	save := make(map[string]string, len(data)+1)
+	for key, value := range data {
+		save[key] = value
+	}
+	save["password"] = password
+	write := c.create
+	if data["in_place"] == "true" {
+		write = c.update
+	}
+	resp, err := write(ctx, save)
+	if err != nil {
+		return nil, err
+	}
+	if saved, ok := resp.(map[string]string); ok {
+		saved["password"] = password
+	}
+	return resp, nil
+}
+
+// generatePolicy returns the policy of pass generate adjusted by the
+// optional "length", "symbols", "charset", "pronounceable", "words" and
+// "separator" of a request.
+func generatePolicy(data map[string]string) (generate.Policy, *errorResponse) {
+	p := generate.DefaultPolicy(data["symbols"] == "false")
+	if data["charset"] != "" {
+		p.Charset = data["charset"]
+	}
+	p.Pronounceable = data["pronounceable"] == "true"
+	if separator, ok := data["separator"]; ok {
+		p.Separator = separator
+	}
+	for key, n := range map[string]*int{"length": &p.Length, "words": &p.Words} {
+		if data[key] == "" {
+			continue
+		}
+		v, err := strconv.Atoi(data[key])
+		if err != nil || v < 0 {
+			return p, &errorResponse{Message: key + " must be a non-negative number", Code: CodeInvalidRequest}
+		}
+		*n = v
+	}
+	return p, nil
+}
@@ -0,0 +1,150 @@
+package browserpass
+
+import (
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/generate"
+)
+
+func TestFormatLogin(t *testing.T) {
+	tests := []struct {
+		password, username, url string
+		expected                string
+	}{
+		{"hunter2", "alice", "https://example.com/login", "hunter2\nlogin: alice\nurl: https://example.com/login\n"},
+		{"hunter2", "", "", "hunter2\n"},
+	}
+	for _, test := range tests {
+		actual, err := formatLogin(test.password, test.username, test.url)
+		if err != nil || string(actual) != test.expected {
+			t.Errorf("Expected %q, got %q, %v", test.expected, actual, err)
+		}
+	}
+
+	// Values must not add lines to the entry
+	for _, fields := range [][3]string{
+		{"hunter2\notpauth://totp/x?secret=JBSWY3DPEHPK3PXP", "", ""},
+		{"hunter2", "alice\rurl: evil.example", ""},
+		{"hunter2", "", "https://example.com/\nurl: evil.example"},
+		{"hunter2\x00", "", ""},
+	} {
+		if content, err := formatLogin(fields[0], fields[1], fields[2]); err != errNotSingleLine {
+			t.Errorf("%q: formatted %q, %v", fields, content, err)
+		}
+	}
+}
+
+func TestRunCreate(t *testing.T) {
+	s := fakeStore{"example.com/alice"}
+	caller := AllowedOrigins[0]
+	tests := []struct {
+		req  map[string]string
+		code string
+	}{
+		{map[string]string{"action": "create", "entry": "example.com/bob", "password": "hunter2"}, ""},
+		{map[string]string{"action": "create", "entry": "example.com/alice", "password": "hunter2"}, CodeExists},
+		{map[string]string{"action": "create", "entry": "../bob", "password": "hunter2"}, CodeInvalidItem},
+		{map[string]string{"action": "create", "entry": "example.com/bob"}, CodeInvalidRequest},
+		{map[string]string{"action": "create", "entry": "example.com/bob", "password": "hunter2", "url": "https://example.com/\nurl: evil.example"}, CodeInvalidRequest},
+	}
+	for _, test := range tests {
+		var resp struct {
+			errorResponse
+			Entry string `json:"entry"`
+		}
+		roundTrip(t, s, caller, test.req, &resp)
+		if resp.Code != test.code {
+			t.Errorf("%v: code is %q, expected %q", test.req, resp.Code, test.code)
+		}
+		if test.code == "" && resp.Entry != test.req["entry"] {
+			t.Errorf("%v: created %q", test.req, resp.Entry)
+		}
+	}
+}
+
+func TestReplacePassword(t *testing.T) {
+	uri := "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP"
+	tests := map[string]string{
+		"old\nlogin: alice\nnotes\n": "new\nlogin: alice\nnotes\n",
+		"old":                        "new\n",
+		uri + "\nlogin: alice\n":     "new\n" + uri + "\nlogin: alice\n",
+	}
+	for plaintext, expected := range tests {
+		content, err := replacePassword([]byte(plaintext), "new")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := string(content.Bytes()); actual != expected {
+			t.Errorf("%q: expected %q, got %q", plaintext, expected, actual)
+		}
+		content.Wipe()
+	}
+
+	if _, err := replacePassword([]byte("old\n"), "new\nurl: evil.example"); err != errNotSingleLine {
+		t.Errorf("replacePassword with a line break returned %v", err)
+	}
+}
+
+func TestRunUpdateReadOnly(t *testing.T) {
+	var resp errorResponse
+	roundTrip(t, fakeStore{"example.com/alice"}, AllowedOrigins[0], map[string]string{"action": "update", "entry": "example.com/alice", "password": "new"}, &resp)
+	if resp.Code != CodeInvalidRequest {
+		t.Errorf("Code is %q, expected %s for a read-only store", resp.Code, CodeInvalidRequest)
+	}
+}
+
+func TestRunUpdateLineBreak(t *testing.T) {
+	content := "old\nlogin: alice\n"
+	s := writableHOTPStore{hotpStore{fakeStore{"example.com/alice"}, map[string]string{"example.com/alice": content}}}
+	var resp errorResponse
+	roundTrip(t, s, AllowedOrigins[0], map[string]string{"action": "update", "entry": "example.com/alice", "password": "new\nurl: evil.example"}, &resp)
+	if resp.Code != CodeInvalidRequest || s.content["example.com/alice"] != content {
+		t.Errorf("Update with a line break returned %+v, entry is %q", resp, s.content["example.com/alice"])
+	}
+}
+
+func TestRunDelete(t *testing.T) {
+	s := fakeStore{"example.com/alice"}
+	tests := map[string]string{
+		"example.com/alice": "",
+		"example.com/bob":   CodeNotFound,
+		"/etc/passwd":       CodeInvalidItem,
+	}
+	for entry, code := range tests {
+		var resp errorResponse
+		roundTrip(t, s, AllowedOrigins[0], map[string]string{"action": "delete", "entry": entry}, &resp)
+		if resp.Code != code {
+			t.Errorf("%s: code is %q, expected %q", entry, resp.Code, code)
+		}
+	}
+}
+
+func TestRunGenerate(t *testing.T) {
+	t.Setenv("PASSWORD_STORE_GENERATED_LENGTH", "")
+	t.Setenv("PASSWORD_STORE_CHARACTER_SET", "")
+	s := fakeStore{"example.com/alice"}
+	caller := AllowedOrigins[0]
+	tests := []struct {
+		req    map[string]string
+		length int
+		code   string
+	}{
+		{map[string]string{"action": "generate"}, generate.DefaultLength, ""},
+		{map[string]string{"action": "generate", "length": "12", "charset": "[:digit:]"}, 12, ""},
+		{map[string]string{"action": "generate", "entry": "example.com/bob", "length": "16"}, 16, ""},
+		{map[string]string{"action": "generate", "entry": "example.com/alice"}, 0, CodeExists},
+		{map[string]string{"action": "generate", "length": "-1"}, 0, CodeInvalidRequest},
+		{map[string]string{"action": "generate", "charset": "[:emoji:]"}, 0, CodeInvalidRequest},
+	}
+	for _, test := range tests {
+		var resp struct {
+			errorResponse
+			Entry    string `json:"entry"`
+			Password string `json:"password"`
+		}
+		roundTrip(t, s, caller, test.req, &resp)
+		if resp.Code != test.code || len(resp.Password) != test.length || resp.Entry != test.req["entry"] && test.code == "" {
+			t.Errorf("%v: unexpected response %+v", test.req, resp)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package browserpass
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// seccomp and BPF constants from <linux/seccomp.h> and <linux/filter.h>.
+const (
+	prSetSeccomp      = 22
+	seccompModeFilter = 2
+
+	seccompRetAllow = 0x7fff0000
+	seccompRetErrno = 0x00050000
+
+	bpfLd  = 0x00
+	bpfJmp = 0x05
+	bpfRet = 0x06
+	bpfW   = 0x00
+	bpfAbs = 0x20
+	bpfJeq = 0x10
+	bpfJge = 0x30
+	bpfK   = 0x00
+
+	// Offsets in struct seccomp_data
+	seccompDataNr   = 0
+	seccompDataArch = 4
+)
+
+// sockFilter is struct sock_filter, an instruction of a BPF program.
+type sockFilter struct {
+	code   uint16
+	jt, jf uint8
+	k      uint32
+}
+
+// sockFprog is struct sock_fprog.
+type sockFprog struct {
+	len    uint16
+	filter *sockFilter
+}
+
+// restrictSyscalls makes deniedSyscalls, and every syscall of another
+// architecture, fail with EPERM for the process and what it runs. It is a
+// deny-list rather than an allow-list: the filter is inherited by gpg,
+// pinentry, git and ssh, whose needs differ between distributions and
+// versions. What it takes away is reaching into other processes, like the
+// browser, and the kernel. no_new_privs must be set already.
+func restrictSyscalls() error {
+	if len(deniedSyscalls) == 0 {
+		return nil
+	}
+	prog := []sockFilter{
+		{bpfLd | bpfW | bpfAbs, 0, 0, seccompDataArch},
+		{bpfJmp | bpfJeq | bpfK, 1, 0, auditArch},
+		{bpfRet | bpfK, 0, 0, seccompRetErrno | uint32(syscall.EPERM)},
+		{bpfLd | bpfW | bpfAbs, 0, 0, seccompDataNr},
+	}
+	checks := len(deniedSyscalls)
+	if x32SyscallBit != 0 {
+		checks++
+	}
+	// Every check jumps to the last instruction, denying, if it matches,
+	// otherwise the instruction after the checks allows
+	if x32SyscallBit != 0 {
+		prog = append(prog, sockFilter{bpfJmp | bpfJge | bpfK, uint8(checks), 0, x32SyscallBit})
+	}
+	for _, nr := range deniedSyscalls {
+		prog = append(prog, sockFilter{bpfJmp | bpfJeq | bpfK, uint8(checks - (len(prog) - 4)), 0, nr})
+	}
+	prog = append(prog,
+		sockFilter{bpfRet | bpfK, 0, 0, seccompRetAllow},
+		sockFilter{bpfRet | bpfK, 0, 0, seccompRetErrno | uint32(syscall.EPERM)},
+	)
+	fprog := sockFprog{len: uint16(len(prog)), filter: &prog[0]}
+	if _, _, errno := syscall.AllThreadsSyscall(syscall.SYS_PRCTL, prSetSeccomp, seccompModeFilter, uintptr(unsafe.Pointer(&fprog))); errno != 0 {
+		return errno
+	}
+	return nil
+}
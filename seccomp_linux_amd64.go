@@ -0,0 +1,15 @@
+package browserpass
+
+// auditArch is AUDIT_ARCH_X86_64, x32SyscallBit marks syscalls of the x32
+// ABI, which are all denied.
+const (
+	auditArch     = 0xc000003e
+	x32SyscallBit = 0x40000000
+)
+
+// deniedSyscalls are refused by restrictSyscalls: ptrace, process_vm_readv,
+// process_vm_writev, kexec_load, kexec_file_load, init_module,
+// finit_module, delete_module, mount, umount2, pivot_root, bpf,
+// perf_event_open, userfaultfd, swapon, swapoff, reboot, acct, iopl, ioperm,
+// name_to_handle_at and open_by_handle_at.
+var deniedSyscalls = []uint32{101, 310, 311, 246, 320, 175, 313, 176, 165, 166, 155, 321, 298, 323, 167, 168, 169, 163, 172, 173, 303, 304}
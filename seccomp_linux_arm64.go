@@ -0,0 +1,14 @@
+package browserpass
+
+// auditArch is AUDIT_ARCH_AARCH64, which has no second ABI.
+const (
+	auditArch     = 0xc00000b7
+	x32SyscallBit = 0
+)
+
+// deniedSyscalls are refused by restrictSyscalls: ptrace, process_vm_readv,
+// process_vm_writev, kexec_load, kexec_file_load, init_module,
+// finit_module, delete_module, mount, umount2, pivot_root, bpf,
+// perf_event_open, userfaultfd, swapon, swapoff, reboot, acct,
+// name_to_handle_at and open_by_handle_at.
+var deniedSyscalls = []uint32{117, 270, 271, 104, 294, 105, 273, 106, 40, 39, 41, 280, 241, 282, 224, 225, 142, 89, 264, 265}
@@ -0,0 +1,13 @@
+//go:build linux && !amd64 && !arm64
+// +build linux,!amd64,!arm64
+
+package browserpass
+
+// The syscalls of other architectures aren't filtered, Landlock still
+// applies.
+const (
+	auditArch     = 0
+	x32SyscallBit = 0
+)
+
+var deniedSyscalls []uint32
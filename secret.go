@@ -0,0 +1,64 @@
+package browserpass
+
+import "regexp"
+
+// Kinds of secrets served to non-browser consumers by the "secret" action.
+const (
+	SecretSSHKey = "ssh-key"
+	SecretToken  = "token"
+)
+
+var (
+	pemBegin   = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)
+	pemEnd     = regexp.MustCompile(`-----END [A-Z ]*PRIVATE KEY-----\r?\n?`)
+	tokenField = regexp.MustCompile(`(?im)^(token|api[_-]?key|api[_-]?token):[ \t]*(\S+)`)
+)
+
+// parseSecret extracts an SSH private key or an API token from a decrypted
+// entry. It returns an empty kind if the entry holds neither.
+func parseSecret(plaintext []byte) (string, *SecureBytes, error) {
+	var kind string
+	var value []byte
+	if begin := pemBegin.FindIndex(plaintext); begin != nil {
+		if end := pemEnd.FindIndex(plaintext[begin[1]:]); end != nil {
+			kind, value = SecretSSHKey, plaintext[begin[0]:begin[1]+end[1]]
+		}
+	} else if m := tokenField.FindSubmatchIndex(plaintext); m != nil {
+		kind, value = SecretToken, plaintext[m[4]:m[5]]
+	}
+	if kind == "" {
+		return "", nil, nil
+	}
+
+	secret, err := NewSecureBytes(len(value))
+	if err != nil {
+		return "", nil, err
+	}
+	secret.Write(value)
+	return kind, secret, nil
+}
+
+// secretFrame encodes a secret of kind as a native message in locked
+// memory.
+func secretFrame(kind string, secret *SecureBytes) (lockedFrame, error) {
+	buf, err := NewSecureBytes(64 + 2*secret.Len())
+	if err != nil {
+		return lockedFrame{}, err
+	}
+
+	buf.WriteString(`{"kind":`)
+	if err := buf.appendJSONString([]byte(kind)); err != nil {
+		buf.Wipe()
+		return lockedFrame{}, err
+	}
+	buf.WriteString(`,"secret":`)
+	if err := buf.appendJSONString(secret.Bytes()); err != nil {
+		buf.Wipe()
+		return lockedFrame{}, err
+	}
+	if _, err := buf.WriteString("}\n"); err != nil {
+		buf.Wipe()
+		return lockedFrame{}, err
+	}
+	return lockedFrame{buf}, nil
+}
@@ -0,0 +1,182 @@
+package browserpass
+
+import (
+	"io"
+	"sync"
+	"unicode/utf8"
+)
+
+// SecureBytes holds secret data, such as a decrypted entry, in memory that is
+// locked against being swapped to disk. Call Wipe once the secret is no longer
+// needed to zero and release the memory.
+type SecureBytes struct {
+	buf    []byte
+	n      int
+	locked bool
+}
+
+// NewSecureBytes allocates a locked buffer with room for at least size bytes.
+// If the memory can't be locked (e.g. RLIMIT_MEMLOCK is exhausted) the buffer
+// is still usable, but may be swapped.
+func NewSecureBytes(size int) (*SecureBytes, error) {
+	if size < 1 {
+		size = 1
+	}
+	buf, err := allocLocked(size)
+	if err != nil {
+		return nil, err
+	}
+	s := &SecureBytes{buf: buf, locked: lockMemory(buf) == nil}
+	live.Lock()
+	live.m[s] = struct{}{}
+	live.Unlock()
+	return s, nil
+}
+
+// live tracks every SecureBytes that hasn't been wiped yet, for WipeAll.
+var live = struct {
+	sync.Mutex
+	m map[*SecureBytes]struct{}
+}{m: make(map[*SecureBytes]struct{})}
+
+// WipeAll wipes every SecureBytes that is still alive.
+func WipeAll() {
+	live.Lock()
+	all := make([]*SecureBytes, 0, len(live.m))
+	for s := range live.m {
+		all = append(all, s)
+	}
+	live.Unlock()
+
+	for _, s := range all {
+		s.Wipe()
+	}
+}
+
+// Bytes returns the secret. The slice is only valid until the next write to
+// or Wipe of s.
+func (s *SecureBytes) Bytes() []byte {
+	if s == nil {
+		return nil
+	}
+	return s.buf[:s.n]
+}
+
+// Len returns the length of the secret.
+func (s *SecureBytes) Len() int {
+	if s == nil {
+		return 0
+	}
+	return s.n
+}
+
+// Write appends p to s, moving the secret to a larger locked buffer if needed.
+func (s *SecureBytes) Write(p []byte) (int, error) {
+	if err := s.grow(len(p)); err != nil {
+		return 0, err
+	}
+	s.n += copy(s.buf[s.n:], p)
+	return len(p), nil
+}
+
+// WriteString appends str to s.
+func (s *SecureBytes) WriteString(str string) (int, error) {
+	if err := s.grow(len(str)); err != nil {
+		return 0, err
+	}
+	s.n += copy(s.buf[s.n:], str)
+	return len(str), nil
+}
+
+// ReadFrom reads r until EOF directly into locked memory.
+func (s *SecureBytes) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+	for {
+		if s.n == len(s.buf) {
+			if err := s.grow(len(s.buf)); err != nil {
+				return total, err
+			}
+		}
+		n, err := r.Read(s.buf[s.n:])
+		s.n += n
+		total += int64(n)
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+func (s *SecureBytes) grow(n int) error {
+	if s.n+n <= len(s.buf) {
+		return nil
+	}
+	size := 2 * len(s.buf)
+	if size < s.n+n {
+		size = s.n + n
+	}
+	bigger, err := NewSecureBytes(size)
+	if err != nil {
+		return err
+	}
+	bigger.n = copy(bigger.buf, s.buf[:s.n])
+	s.Wipe()
+	*s = *bigger
+
+	// s now owns bigger's memory
+	live.Lock()
+	delete(live.m, bigger)
+	live.m[s] = struct{}{}
+	live.Unlock()
+	return nil
+}
+
+// Wipe zeroes the secret and releases its memory. s is empty afterwards.
+func (s *SecureBytes) Wipe() {
+	if s == nil || s.buf == nil {
+		return
+	}
+	for i := range s.buf {
+		s.buf[i] = 0
+	}
+	if s.locked {
+		unlockMemory(s.buf)
+	}
+	freeLocked(s.buf)
+	s.buf, s.n, s.locked = nil, 0, false
+
+	live.Lock()
+	delete(live.m, s)
+	live.Unlock()
+}
+
+// appendJSONString writes p to s as a quoted JSON string without passing it
+// through encoding/json, whose buffers aren't locked.
+func (s *SecureBytes) appendJSONString(p []byte) error {
+	const hex = "0123456789abcdef"
+	if _, err := s.WriteString(`"`); err != nil {
+		return err
+	}
+	for len(p) > 0 {
+		r, size := utf8.DecodeRune(p)
+		var err error
+		switch {
+		case r == utf8.RuneError && size == 1:
+			_, err = s.WriteString(`\ufffd`)
+		case r == '"' || r == '\\':
+			_, err = s.Write([]byte{'\\', byte(r)})
+		case r < 0x20:
+			_, err = s.Write([]byte{'\\', 'u', '0', '0', hex[r>>4], hex[r&0xf]})
+		default:
+			_, err = s.Write(p[:size])
+		}
+		if err != nil {
+			return err
+		}
+		p = p[size:]
+	}
+	_, err := s.WriteString(`"`)
+	return err
+}
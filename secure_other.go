@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package browserpass
+
+import "errors"
+
+// allocLocked allocates the buffer on the Go heap, the syscall package has no
+// mlock on this system to keep it from being swapped.
+func allocLocked(size int) ([]byte, error) {
+	return make([]byte, size), nil
+}
+
+func freeLocked(b []byte) {}
+
+func lockMemory(b []byte) error {
+	return errors.New("memory locking is not supported")
+}
+
+func unlockMemory(b []byte) {}
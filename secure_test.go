@@ -0,0 +1,40 @@
+package browserpass
+
+import "testing"
+
+func TestWipeAll(t *testing.T) {
+	s, err := NewSecureBytes(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Growing moves the secret to a new buffer, which must still be tracked
+	s.WriteString("a secret longer than four bytes")
+
+	WipeAll()
+	if s.Len() != 0 || s.Bytes() != nil {
+		t.Error("WipeAll left secret behind")
+	}
+	s.Wipe()
+}
+
+func TestGuard(t *testing.T) {
+	s, err := NewSecureBytes(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.WriteString("a secret")
+
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		defer Guard()
+		panic("handler bug")
+	}()
+	if recovered != "handler bug" {
+		t.Errorf("Guard didn't pass the panic on, got %v", recovered)
+	}
+	if s.Len() != 0 {
+		t.Error("Guard left the secret behind")
+	}
+	s.Wipe()
+}
@@ -0,0 +1,24 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package browserpass
+
+import "syscall"
+
+// allocLocked maps anonymous memory outside the Go heap, so the garbage
+// collector never copies the secret elsewhere.
+func allocLocked(size int) ([]byte, error) {
+	return syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+}
+
+func freeLocked(b []byte) {
+	syscall.Munmap(b)
+}
+
+func lockMemory(b []byte) error {
+	return syscall.Mlock(b)
+}
+
+func unlockMemory(b []byte) {
+	syscall.Munlock(b)
+}
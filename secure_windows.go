@@ -0,0 +1,22 @@
+package browserpass
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// allocLocked allocates the buffer on the Go heap; its garbage collector
+// doesn't move objects, so locking the backing pages is sufficient.
+func allocLocked(size int) ([]byte, error) {
+	return make([]byte, size), nil
+}
+
+func freeLocked(b []byte) {}
+
+func lockMemory(b []byte) error {
+	return syscall.VirtualLock(uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)))
+}
+
+func unlockMemory(b []byte) {
+	syscall.VirtualUnlock(uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)))
+}
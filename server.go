@@ -0,0 +1,105 @@
+package browserpass
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/dannyvankooten/browserpass/pass"
+	"github.com/dannyvankooten/browserpass/protocol"
+)
+
+// SocketCaller is the caller of requests received over the Unix socket.
+// The socket is only accessible to the user running the host, and Serve
+// checks connections come from processes of that user.
+const SocketCaller = "unix-socket"
+
+// SocketPath returns the default location of the host's Unix socket.
+func SocketPath() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "browserpass", "browserpass.sock")
+}
+
+// Listen creates the Unix socket at path, in a directory only the current
+// user can access. A stale socket left behind by a previous host is removed.
+func Listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, &net.OpError{Op: "listen", Net: "unix", Err: os.ErrExist}
+	}
+	os.Remove(path)
+	return net.Listen("unix", path)
+}
+
+// Serve accepts connections on l and serves the native messaging protocol
+// on each of them until l is closed. Connections from other users, or not
+// over a Unix socket, are closed right away.
+func Serve(l net.Listener, s pass.Store) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer Guard()
+			defer conn.Close()
+			if err := checkPeer(conn); err != nil {
+				log.Println("refused connection:", err)
+				return
+			}
+			if err := Run(conn, conn, s, SocketCaller); err != nil && err != io.EOF {
+				log.Println(err)
+			}
+		}()
+	}
+}
+
+// checkPeer returns an error unless conn comes from a process of the user
+// running the host.
+func checkPeer(conn net.Conn) error {
+	unix, ok := conn.(*net.UnixConn)
+	if !ok {
+		return fmt.Errorf("%s isn't a Unix socket", conn.RemoteAddr().Network())
+	}
+	uid, err := peerUID(unix)
+	if err != nil {
+		return fmt.Errorf("could not get peer credentials: %v", err)
+	}
+	if uid != os.Getuid() {
+		return fmt.Errorf("peer runs as user %d", uid)
+	}
+	return nil
+}
+
+// Proxy relays a browser's native messaging connection on stdin and stdout
+// to a host serving conn. It lets sandboxed browsers, which can't start the
+// host themselves, use a host running outside the sandbox. The browser's
+// caller is forwarded so the host applies the same checks as for a browser
+// it was started by.
+func Proxy(stdin io.Reader, stdout io.Writer, conn net.Conn, caller string) error {
+	if err := protocol.WriteMessage(conn, map[string]string{"action": "proxy", "caller": caller}); err != nil {
+		return err
+	}
+
+	go func() {
+		io.Copy(conn, stdin)
+		// Let the host see the browser hang up
+		if c, ok := conn.(interface{ CloseWrite() error }); ok {
+			c.CloseWrite()
+		}
+	}()
+	_, err := io.Copy(stdout, conn)
+	return err
+}
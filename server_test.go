@@ -0,0 +1,137 @@
+package browserpass
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/protocol"
+)
+
+func TestServe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "browserpass-socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "run", "browserpass.sock")
+
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go Serve(l, fakeStore{"example.com/alice"})
+
+	if _, err := Listen(path); err == nil {
+		t.Error("Second host listened on a socket in use")
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	protocol.WriteMessage(conn, map[string]string{"action": "search", "domain": "example.com"})
+
+	var results []string
+	if err := protocol.ReadMessage(conn, &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0] != "example.com/alice" {
+		t.Errorf("Unexpected results %v", results)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "browserpass-socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "browserpass.sock")
+
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go Serve(l, fakeStore{"example.com/alice"})
+
+	search := func(caller string) []string {
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		var in, out bytes.Buffer
+		protocol.WriteMessage(&in, map[string]string{"action": "search", "domain": "example.com"})
+		if err := Proxy(&in, &out, conn, caller); err != nil {
+			t.Fatal(err)
+		}
+
+		var results []string
+		if err := protocol.ReadMessage(&out, &results); err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+
+	if results := search(AllowedOrigins[0]); len(results) != 1 {
+		t.Errorf("Allowed caller got %v", results)
+	}
+	if results := search("rogue@example.com"); len(results) != 0 {
+		t.Errorf("Unknown caller got %v", results)
+	}
+	if results := search(SocketCaller); len(results) != 0 {
+		t.Errorf("Proxy claiming the socket caller got %v", results)
+	}
+}
+
+func TestServeChecksPeer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go Serve(l, fakeStore{"example.com/alice"})
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	protocol.WriteMessage(conn, map[string]string{"action": "search", "domain": "example.com"})
+	var results []string
+	if err := protocol.ReadMessage(conn, &results); err == nil {
+		t.Errorf("Connection over TCP got %v", results)
+	}
+}
+
+func TestPeerUID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "browserpass.sock")
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	client, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn, err := l.AcceptUnix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if uid, err := peerUID(conn); err != nil || uid != os.Getuid() {
+		t.Errorf("peerUID returned %d, %v, expected %d", uid, err, os.Getuid())
+	}
+}
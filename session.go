@@ -0,0 +1,86 @@
+package browserpass
+
+import (
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// sessionPollInterval is how long the lock state of the session is trusted
+// when it can't be watched.
+const sessionPollInterval = 5 * time.Second
+
+var (
+	// sessionWatched is set while a watcher reports lock changes, then
+	// sessionIsLocked is the last state it reported
+	sessionWatched, sessionIsLocked atomic.Bool
+
+	watchOnce sync.Once
+
+	pollMu       sync.Mutex
+	polledAt     time.Time
+	polledLocked bool
+)
+
+// WatchSession starts watching the desktop session for locks, forgetting
+// every secret as soon as it locks instead of at the next decryption. It
+// must be called before the host is sandboxed, the watcher is a separate
+// program. Where the session can't be watched it is asked whether it is
+// locked at most every sessionPollInterval.
+func WatchSession() {
+	watchOnce.Do(func() {
+		changes, err := watchSessionLocks()
+		if err != nil {
+			return
+		}
+		locked, _ := querySessionLocked()
+		sessionIsLocked.Store(locked)
+		sessionWatched.Store(true)
+		go func() {
+			defer Guard()
+			for locked := range changes {
+				sessionIsLocked.Store(locked)
+				if locked {
+					lockSession()
+				}
+			}
+			sessionWatched.Store(false)
+		}()
+	})
+}
+
+// sessionLocked reports whether the desktop session is locked.
+func sessionLocked() (bool, error) {
+	if sessionWatched.Load() {
+		return sessionIsLocked.Load(), nil
+	}
+	pollMu.Lock()
+	defer pollMu.Unlock()
+	if time.Since(polledAt) < sessionPollInterval {
+		return polledLocked, nil
+	}
+	locked, err := querySessionLocked()
+	if err != nil {
+		return false, err
+	}
+	polledAt, polledLocked = time.Now(), locked
+	return locked, nil
+}
+
+// lockSession forgets what a locked session must not leave behind: gpg's
+// passphrases, decrypted entries and search results.
+func lockSession() {
+	forgetPassphrases()
+	Plaintexts.Flush()
+	pass.FlushCaches()
+}
+
+// forgetPassphrases tells gpg-agent to drop its cached passphrases, so the
+// next decryption after the session is unlocked asks for the passphrase
+// again.
+func forgetPassphrases() error {
+	return exec.Command("gpg-connect-agent", "reloadagent", "/bye").Run()
+}
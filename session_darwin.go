@@ -0,0 +1,27 @@
+package browserpass
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+)
+
+// querySessionLocked reports whether the screen is locked, as recorded by
+// the window server in the IO registry.
+func querySessionLocked() (bool, error) {
+	out, err := exec.Command("ioreg", "-n", "Root", "-d1", "-a").Output()
+	if err != nil {
+		return false, err
+	}
+	i := bytes.Index(out, []byte("<key>CGSSessionScreenIsLocked</key>"))
+	if i < 0 {
+		return false, nil
+	}
+	return bytes.HasPrefix(bytes.TrimSpace(out[i+len("<key>CGSSessionScreenIsLocked</key>"):]), []byte("<true/>")), nil
+}
+
+// watchSessionLocks can't watch the session without linking against the
+// notification center, the IO registry is polled instead.
+func watchSessionLocks() (<-chan bool, error) {
+	return nil, errors.New("session locks can't be watched on macOS")
+}
@@ -0,0 +1,118 @@
+package browserpass
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// sessionID returns logind's ID of the desktop session the host runs in.
+func sessionID() (string, error) {
+	if id := os.Getenv("XDG_SESSION_ID"); id != "" {
+		return id, nil
+	}
+	out, err := exec.Command("loginctl", "show-session", "auto", "--property=Id", "--value").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// querySessionLocked asks logind whether the desktop session the host runs
+// in is locked.
+func querySessionLocked() (bool, error) {
+	id, err := sessionID()
+	if err != nil {
+		return false, err
+	}
+	out, err := exec.Command("loginctl", "show-session", "--property=LockedHint", "--value", id).Output()
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(out)) == "yes", nil
+}
+
+// watchSessionLocks reports the lock state of the desktop session whenever
+// it changes: logind's Lock and Unlock signals and LockedHint changes of
+// the session, and the screen saver's ActiveChanged signal. It runs gdbus
+// monitor, which dies with the host.
+func watchSessionLocks() (<-chan bool, error) {
+	id, err := sessionID()
+	if err != nil {
+		return nil, err
+	}
+	changes := make(chan bool)
+	var wg sync.WaitGroup
+	monitor := func(args ...string) error {
+		cmd := exec.Command("gdbus", append([]string{"monitor"}, args...)...)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scanner := bufio.NewScanner(out)
+			for scanner.Scan() {
+				if locked, ok := parseSessionEvent(scanner.Text()); ok {
+					changes <- locked
+				}
+			}
+			cmd.Wait()
+		}()
+		return nil
+	}
+	if err := monitor("--system", "--dest", "org.freedesktop.login1", "--object-path", "/org/freedesktop/login1/session/"+busPathLabel(id)); err != nil {
+		return nil, err
+	}
+	// Not every desktop has a screen saver on the bus
+	monitor("--session", "--dest", "org.freedesktop.ScreenSaver", "--object-path", "/org/freedesktop/ScreenSaver")
+	go func() {
+		wg.Wait()
+		close(changes)
+	}()
+	return changes, nil
+}
+
+// parseSessionEvent parses a line of gdbus monitor, reporting whether it
+// locks or unlocks the session and whether it does either.
+func parseSessionEvent(line string) (locked, ok bool) {
+	switch {
+	case strings.Contains(line, "org.freedesktop.login1.Session.Lock "):
+		return true, true
+	case strings.Contains(line, "org.freedesktop.login1.Session.Unlock "):
+		return false, true
+	case strings.Contains(line, "'LockedHint': <true>"):
+		return true, true
+	case strings.Contains(line, "'LockedHint': <false>"):
+		return false, true
+	case strings.Contains(line, ".ScreenSaver.ActiveChanged (true"):
+		return true, true
+	case strings.Contains(line, ".ScreenSaver.ActiveChanged (false"):
+		return false, true
+	}
+	return false, false
+}
+
+// busPathLabel escapes s for a D-Bus object path like sd_bus_path_encode:
+// "2" becomes "_32".
+func busPathLabel(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "_%02x", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,32 @@
+package browserpass
+
+import "testing"
+
+func TestParseSessionEvent(t *testing.T) {
+	tests := []struct {
+		line       string
+		locked, ok bool
+	}{
+		{"/org/freedesktop/login1/session/_32: org.freedesktop.login1.Session.Lock ()", true, true},
+		{"/org/freedesktop/login1/session/_32: org.freedesktop.login1.Session.Unlock ()", false, true},
+		{"/org/freedesktop/login1/session/_32: org.freedesktop.DBus.Properties.PropertiesChanged ('org.freedesktop.login1.Session', {'LockedHint': <true>}, @as [])", true, true},
+		{"/org/freedesktop/login1/session/_32: org.freedesktop.DBus.Properties.PropertiesChanged ('org.freedesktop.login1.Session', {'LockedHint': <false>}, @as [])", false, true},
+		{"/org/freedesktop/ScreenSaver: org.freedesktop.ScreenSaver.ActiveChanged (true,)", true, true},
+		{"/org/freedesktop/ScreenSaver: org.freedesktop.ScreenSaver.ActiveChanged (false,)", false, true},
+		{"/org/freedesktop/login1/session/_32: org.freedesktop.DBus.Properties.PropertiesChanged ('org.freedesktop.login1.Session', {'IdleHint': <true>}, @as [])", false, false},
+		{"The name org.freedesktop.ScreenSaver does not have an owner", false, false},
+	}
+	for _, test := range tests {
+		if locked, ok := parseSessionEvent(test.line); locked != test.locked || ok != test.ok {
+			t.Errorf("parseSessionEvent(%q) = %v, %v, expected %v, %v", test.line, locked, ok, test.locked, test.ok)
+		}
+	}
+}
+
+func TestBusPathLabel(t *testing.T) {
+	for id, expected := range map[string]string{"2": "_32", "c1": "c1", "12": "_312"} {
+		if label := busPathLabel(id); label != expected {
+			t.Errorf("busPathLabel(%q) = %q, expected %q", id, label, expected)
+		}
+	}
+}
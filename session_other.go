@@ -0,0 +1,17 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package browserpass
+
+import "errors"
+
+// querySessionLocked can't tell on this platform and assumes an unlocked
+// session.
+func querySessionLocked() (bool, error) {
+	return false, nil
+}
+
+// watchSessionLocks can't watch the session on this platform.
+func watchSessionLocks() (<-chan bool, error) {
+	return nil, errors.New("session locks can't be watched on this platform")
+}
@@ -0,0 +1,34 @@
+package browserpass
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	"github.com/dannyvankooten/browserpass/gpg"
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// ShareEntry writes entry to w encrypted to the GPG key of recipient, as
+// ASCII armor, for handing a single credential to someone else. The
+// recipient's key must be in the keyring and trusted.
+func ShareEntry(w io.Writer, s pass.Store, entry, recipient string) error {
+	if recipient == "" {
+		return errors.New("missing recipient")
+	}
+	c := &conn{s: s, caller: CLICaller, authorized: true}
+	plaintext, refused, err := c.decryptEntry(context.Background(), map[string]string{"entry": entry})
+	if err != nil {
+		return err
+	}
+	if refused != nil {
+		return *refused
+	}
+	defer plaintext.Wipe()
+
+	if err := gpg.Encrypt(w, bytes.NewReader(plaintext.Bytes()), recipient); err != nil {
+		return err
+	}
+	return c.audit(entry, "")
+}
@@ -0,0 +1,49 @@
+package browserpass
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/fixture"
+)
+
+func TestShareEntry(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not installed")
+	}
+	home, err := ioutil.TempDir("", "browserpass-share")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	if err := fixture.NewKey(home); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GNUPGHOME", home)
+
+	var armored bytes.Buffer
+	if err := ShareEntry(&armored, fakeStore{"example.com/alice"}, "example.com/alice", fixture.KeyID); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(armored.String(), "-----BEGIN PGP MESSAGE-----") {
+		t.Fatalf("Output is not ASCII armored: %q", armored.String())
+	}
+
+	cmd := exec.Command("gpg", "--batch", "--quiet", "--decrypt")
+	cmd.Stdin = &armored
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "password-of-example.com/alice\n"; string(out) != expected {
+		t.Errorf("Decrypted %q, expected %q", out, expected)
+	}
+
+	if err := ShareEntry(ioutil.Discard, fakeStore{"example.com/alice"}, "example.com/alice", "nobody@example.invalid"); err == nil {
+		t.Error("Shared an entry with a recipient without a key")
+	}
+}
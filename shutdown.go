@@ -0,0 +1,28 @@
+package browserpass
+
+import (
+	"github.com/dannyvankooten/browserpass/clipboard"
+	"github.com/dannyvankooten/browserpass/gpg"
+)
+
+// Shutdown leaves no secrets behind: it kills running gpg processes, wipes
+// cached plaintexts and every SecureBytes and clears clipboard contents the
+// host set. It must be called on every exit path, including panics and
+// signals.
+func Shutdown() {
+	gpg.KillAll()
+
+	Plaintexts.Flush()
+	WipeAll()
+	clipboard.ClearOwned()
+}
+
+// Guard runs Shutdown if the goroutine deferring it panics, then lets the
+// panic take the host down. The recover in main only sees panics of the
+// main goroutine, so every goroutine handling secrets defers Guard first.
+func Guard() {
+	if r := recover(); r != nil {
+		Shutdown()
+		panic(r)
+	}
+}
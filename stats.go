@@ -0,0 +1,29 @@
+package browserpass
+
+import (
+	"context"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+// stats answers the "stats" action with the pass.Stats of the store, so the
+// extension can show it and tell when its lists are stale. Only the entries
+// the caller may access are counted.
+func (c *conn) stats(ctx context.Context, data map[string]string) (interface{}, error) {
+	stats, err := pass.FileStats(ctx, c.s)
+	if err != nil {
+		return nil, err
+	}
+	list, err := c.s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := pass.SettingsOf(c.s)
+	if err != nil {
+		return nil, err
+	}
+	list = filterAllowed(c.caller, data["container"], list)
+	stats.Entries = len(list)
+	stats.Domains = pass.CountDomains(list, settings)
+	return stats, nil
+}
@@ -0,0 +1,24 @@
+package browserpass
+
+import (
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+func TestRunStats(t *testing.T) {
+	s := fakeStore{"example.com/alice", "example.com/bob", "work/example.org/carol"}
+
+	var stats pass.Stats
+	roundTrip(t, s, AllowedOrigins[0], map[string]string{"action": "stats"}, &stats)
+	if stats.Entries != 3 || stats.Domains != 2 {
+		t.Errorf("stats returned %+v", stats)
+	}
+
+	Policies = map[string][]string{AllowedOrigins[0]: {"work"}}
+	defer func() { Policies = nil }()
+	roundTrip(t, s, AllowedOrigins[0], map[string]string{"action": "stats"}, &stats)
+	if stats.Entries != 1 || stats.Domains != 1 {
+		t.Errorf("stats under a policy returned %+v", stats)
+	}
+}
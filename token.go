@@ -0,0 +1,64 @@
+package browserpass
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"time"
+)
+
+// sessionTimeout is how long a session token stays valid without use.
+const sessionTimeout = 5 * time.Minute
+
+// session is the token issued by a "handshake" on a long-lived connection.
+// Once issued, privileged actions on the connection must present it, so
+// messages injected into a hijacked pipe are rejected.
+type session struct {
+	token    string
+	lastUsed time.Time
+}
+
+// start issues a new random token, replacing any previous one.
+func (s *session) start(now time.Time) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	s.token = hex.EncodeToString(b)
+	s.lastUsed = now
+	return s.token, nil
+}
+
+// verify checks token for a privileged action at now and refreshes the
+// session. Without a handshake no token is valid.
+func (s *session) verify(token string, now time.Time) bool {
+	if s.token == "" {
+		return false
+	}
+	// An expired session stays closed until the next handshake
+	if now.Sub(s.lastUsed) > sessionTimeout {
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+		return false
+	}
+	s.lastUsed = now
+	return true
+}
+
+// checkSession returns the rejection of a privileged request on c without a
+// valid session token, ErrNoSession if c never did a handshake.
+func (c *conn) checkSession(data map[string]string) *errorResponse {
+	var refused errorResponse
+	switch {
+	case !c.needsSession:
+		return nil
+	case c.sess.token == "":
+		refused = ErrNoSession
+	case !c.sess.verify(data["token"], time.Now()):
+		refused = ErrBadSession
+	default:
+		return nil
+	}
+	return &refused
+}
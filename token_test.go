@@ -0,0 +1,44 @@
+package browserpass
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSession(t *testing.T) {
+	var s session
+	now := time.Now()
+
+	if s.verify("", now) {
+		t.Error("Connection without handshake was accepted")
+	}
+
+	token, err := s.start(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.verify("", now) || s.verify("bogus", now) {
+		t.Error("Missing or wrong token was accepted")
+	}
+	if !s.verify(token, now.Add(sessionTimeout/2)) {
+		t.Error("Valid token was rejected")
+	}
+	if !s.verify(token, now.Add(sessionTimeout)) {
+		t.Error("Use did not extend the session")
+	}
+	if s.verify(token, now.Add(3*sessionTimeout)) {
+		t.Error("Expired token was accepted")
+	}
+}
+
+func TestRunWithoutHandshake(t *testing.T) {
+	s := fakeStore{"example.com/alice"}
+	for _, action := range []string{"fetch", "copy", "create", "update", "delete"} {
+		// An empty token keeps roundTrip from doing a handshake
+		var resp errorResponse
+		roundTrip(t, s, AllowedOrigins[0], map[string]string{"action": action, "entry": "example.com/alice", "host": "example.com", "token": ""}, &resp)
+		if resp.Code != CodeNoSession {
+			t.Errorf("%s without a handshake returned %s: %s", action, resp.Code, resp.Message)
+		}
+	}
+}
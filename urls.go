@@ -0,0 +1,152 @@
+package browserpass
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+var (
+	urlLine   = regexp.MustCompile(`(?i)^\s*urls?\s*:(.*)$`)
+	aliasLine = regexp.MustCompile(`(?i)^\s*aliases\s*:(.*)$`)
+	listItem  = regexp.MustCompile(`^\s*-\s+(\S+)\s*$`)
+)
+
+// entryURLs returns the URLs in a decrypted entry: any number of "url:"
+// lines, and "urls:" lines listing several URLs on the line or as "- " items
+// on the lines following it.
+func entryURLs(plaintext []byte) []string {
+	var urls []string
+	inList := false
+	scanner := bufio.NewScanner(bytes.NewReader(plaintext))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := urlLine.FindStringSubmatch(line); m != nil {
+			urls = append(urls, strings.FieldsFunc(m[1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })...)
+			inList = strings.TrimSpace(m[1]) == ""
+			continue
+		}
+		if m := listItem.FindStringSubmatch(line); m != nil && inList {
+			urls = append(urls, m[1])
+			continue
+		}
+		inList = false
+	}
+	return urls
+}
+
+// entryHosts returns the hosts of the URLs in a decrypted entry, and the
+// domains listed in its "aliases:" lines.
+func entryHosts(plaintext []byte) []string {
+	var hosts []string
+	for _, u := range entryURLs(plaintext) {
+		hosts = appendHost(hosts, u)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(plaintext))
+	for scanner.Scan() {
+		if m := aliasLine.FindStringSubmatch(scanner.Text()); m != nil {
+			for _, domain := range strings.FieldsFunc(m[1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+				hosts = appendHost(hosts, domain)
+			}
+		}
+	}
+	return hosts
+}
+
+// appendHost appends the host of rawurl, which may lack a scheme, to hosts.
+func appendHost(hosts []string, rawurl string) []string {
+	if !strings.Contains(rawurl, "://") {
+		rawurl = "https://" + rawurl
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Hostname() == "" {
+		return hosts
+	}
+	return append(hosts, canonicalHost(u.Hostname()))
+}
+
+// urlIndex maps hosts to the entries listing them in URL lines. Entries are
+// encrypted, so it is filled as they get decrypted and only knows the
+// entries used since the host started.
+type urlIndex struct {
+	mu      sync.Mutex
+	entries map[string][]string
+}
+
+// knownURLs indexes the URLs of the entries decrypted by this process.
+var knownURLs = &urlIndex{entries: make(map[string][]string)}
+
+// update records hosts as the URL hosts of entry, replacing earlier ones.
+func (x *urlIndex) update(entry string, hosts []string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if len(hosts) == 0 {
+		delete(x.entries, entry)
+		return
+	}
+	x.entries[entry] = hosts
+}
+
+// move renames the entries moved from src to dst, see pass.Moved.
+func (x *urlIndex) move(src, dst string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for entry, hosts := range x.entries {
+		if name, moved := pass.Moved(entry, src, dst); moved {
+			delete(x.entries, entry)
+			x.entries[name] = hosts
+		}
+	}
+}
+
+// lookup returns the entries with a URL for host.
+func (x *urlIndex) lookup(host string) []string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	var entries []string
+	for entry, hosts := range x.entries {
+		if containsHost(hosts, host) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// has reports whether entry has a URL for host.
+func (x *urlIndex) has(entry, host string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return containsHost(x.entries[entry], host)
+}
+
+// appendKnownURLs appends the indexed entries with a URL for host to list,
+// skipping those already in it.
+func appendKnownURLs(list []string, host string) []string {
+	found := make(map[string]bool, len(list))
+	for _, entry := range list {
+		found[entry] = true
+	}
+	var extra []string
+	for _, entry := range knownURLs.lookup(host) {
+		if !found[entry] {
+			extra = append(extra, entry)
+		}
+	}
+	pass.Sort(extra)
+	return append(list, extra...)
+}
+
+func containsHost(hosts []string, host string) bool {
+	host = canonicalHost(host)
+	for _, h := range hosts {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
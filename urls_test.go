@@ -0,0 +1,97 @@
+package browserpass
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/fixture"
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+func TestEntryHosts(t *testing.T) {
+	tests := map[string][]string{
+		"password\nurl: https://login.example.com/sso\nURL: sso.example.net":        {"login.example.com", "sso.example.net"},
+		"password\nurls: https://www.example.com, example.org:8443 vanity.io":       {"example.com", "example.org", "vanity.io"},
+		"password\nurls:\n  - example.com\n  - https://sso.example.net/\nnote: - x": {"example.com", "sso.example.net"},
+		"password\n- example.com\nurl:":                                             nil,
+		"password\nlogin: alice":                                                    nil,
+		"password\nurl: login.corp.com\naliases: jira.corp.com, wiki.corp.com":      {"login.corp.com", "jira.corp.com", "wiki.corp.com"},
+	}
+	for plaintext, expected := range tests {
+		if actual := entryHosts([]byte(plaintext)); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%q: expected %v, got %v", plaintext, expected, actual)
+		}
+	}
+}
+
+// urlStore holds a single entry listing several URLs.
+type urlStore struct{}
+
+func (urlStore) Search(ctx context.Context, query string, opts ...pass.SearchOption) ([]string, error) {
+	if strings.HasPrefix("example.com/alice", query) {
+		return []string{"example.com/alice"}, nil
+	}
+	return nil, nil
+}
+
+func (urlStore) List(ctx context.Context) ([]string, error) {
+	return []string{"example.com/alice"}, nil
+}
+
+func (urlStore) Open(ctx context.Context, item string) (io.ReadCloser, error) {
+	if item != "example.com/alice" {
+		return nil, pass.ErrNotFound
+	}
+	return ioutil.NopCloser(strings.NewReader(fixture.FakeHeader + "secret\nurls: sso.example.net, vanity.io\n")), nil
+}
+
+func (urlStore) Create(item string, content []byte) error {
+	return pass.ErrReadOnly
+}
+
+func (urlStore) Delete(item string) error {
+	return pass.ErrReadOnly
+}
+
+func (s urlStore) LookupStream(ctx context.Context, domain string) (<-chan string, <-chan error) {
+	return pass.StreamSearch(ctx, s, domain)
+}
+
+func TestRunURLs(t *testing.T) {
+	defer func() { knownURLs = &urlIndex{entries: make(map[string][]string)} }()
+	caller := AllowedOrigins[0]
+
+	var login map[string]string
+	roundTrip(t, urlStore{}, caller, map[string]string{"action": "get", "entry": "example.com/alice", "host": "vanity.io"}, &login)
+	if login["p"] != "secret" {
+		t.Fatalf("Entry with a URL for the host wasn't filled: %v", login)
+	}
+
+	var results []searchResult
+	roundTrip(t, urlStore{}, caller, map[string]string{"action": "search", "domain": "sso.example.net", "host": "sso.example.net"}, &results)
+	if expected := []searchResult{{Entry: "example.com/alice"}}; !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %v, got %v", expected, results)
+	}
+
+	var resp errorResponse
+	roundTrip(t, urlStore{}, caller, map[string]string{"action": "get", "entry": "example.com/alice", "host": "evil.com"}, &resp)
+	if resp.Code != CodeConfirmationRequired {
+		t.Errorf("Code is %q, expected %s", resp.Code, CodeConfirmationRequired)
+	}
+
+	// Leaving the host out is no way around the confirmation
+	resp = errorResponse{}
+	roundTrip(t, urlStore{}, caller, map[string]string{"action": "get", "entry": "example.com/alice"}, &resp)
+	if resp.Code != CodeConfirmationRequired {
+		t.Errorf("Code without a host is %q, expected %s", resp.Code, CodeConfirmationRequired)
+	}
+	login = nil
+	roundTrip(t, urlStore{}, caller, map[string]string{"action": "get", "entry": "example.com/alice", "confirmed": "true"}, &login)
+	if login["p"] != "secret" {
+		t.Errorf("Confirmed entry wasn't filled: %v", login)
+	}
+}
@@ -0,0 +1,63 @@
+package browserpass
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// Confidence of the username detection heuristics, from an explicit
+// "login:" line down to a bare second line.
+const (
+	confidenceLabel       = 1.0
+	confidenceLegacyLabel = 0.8
+	confidenceEmail       = 0.6
+	confidenceSecondLine  = 0.3
+
+	// minUsernameConfidence is the confidence a detected username needs to
+	// be preferred over one guessed from the entry's name.
+	minUsernameConfidence = 0.5
+)
+
+var (
+	usernameLabel = regexp.MustCompile(`(?i)^(login|username|user):`)
+	legacyLabel   = regexp.MustCompile(`(?i)^\s*(e-?mail|mail|account|acct|user ?name|user ?id|login ?id|handle)\s*[:=]?\s+(\S+)\s*$`)
+	emailAddress  = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+)
+
+// detectUsername finds the username in the lines following the password of
+// a free-form entry, with a confidence between 0 and 1. In order of
+// preference it looks for a "login:", "username:" or "user:" line, another
+// common label such as "acct alice", an email address and finally a second
+// line holding a single word.
+func detectUsername(lines [][]byte) (string, float64) {
+	var username string
+	for _, line := range lines {
+		if loc := usernameLabel.FindIndex(line); loc != nil {
+			username = string(bytes.TrimSpace(line[loc[1]:]))
+		}
+	}
+	if username != "" {
+		return username, confidenceLabel
+	}
+
+	for _, line := range lines {
+		if m := legacyLabel.FindSubmatch(line); m != nil {
+			return string(m[2]), confidenceLegacyLabel
+		}
+	}
+	for _, line := range lines {
+		if bytes.Contains(line, []byte("://")) {
+			continue
+		}
+		if email := emailAddress.Find(line); email != nil {
+			return string(email), confidenceEmail
+		}
+	}
+	if len(lines) > 0 {
+		second := bytes.TrimSpace(lines[0])
+		if len(second) > 0 && bytes.IndexAny(second, " \t:") < 0 && !isOTPURI(second) {
+			return string(second), confidenceSecondLine
+		}
+	}
+	return "", 0
+}
@@ -0,0 +1,72 @@
+package browserpass
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dannyvankooten/browserpass/pass"
+)
+
+func TestDetectUsername(t *testing.T) {
+	tests := []struct {
+		lines      string
+		username   string
+		confidence float64
+	}{
+		{"login: alice\nuser: bob", "bob", confidenceLabel},
+		{"acct alice@example.com\nnotes", "alice@example.com", confidenceLegacyLabel},
+		{"Email = alice@example.com", "alice@example.com", confidenceLegacyLabel},
+		{"registered with alice@example.com in 2009", "alice@example.com", confidenceEmail},
+		{"alice\nurl: https://bob@example.com/", "alice", confidenceSecondLine},
+		{"alice\nsecurity question: pet", "alice", confidenceSecondLine},
+		{"otpauth://totp/x?secret=ABC", "", 0},
+		{"my account has no name", "", 0},
+		{"", "", 0},
+	}
+	for _, test := range tests {
+		username, confidence := detectUsername(bytes.Split([]byte(test.lines), []byte("\n")))
+		if username != test.username || confidence != test.confidence {
+			t.Errorf("%q: expected %q (%v), got %q (%v)", test.lines, test.username, test.confidence, username, confidence)
+		}
+	}
+}
+
+func TestFillUsername(t *testing.T) {
+	tests := []struct {
+		login    Login
+		entry    string
+		expected string
+	}{
+		{Login{Username: "alice", confidence: confidenceLabel}, "example.com/bob", "alice"},
+		{Login{Username: "alice", confidence: confidenceEmail}, "example.com/bob", "alice"},
+		{Login{Username: "alice", confidence: confidenceSecondLine}, "example.com/bob", "bob"},
+		{Login{Username: "alice", confidence: confidenceSecondLine}, "example.com", "alice"},
+		{Login{}, "example.com/bob", "bob"},
+	}
+	for _, test := range tests {
+		test.login.fillUsername(test.entry, pass.DefaultUsernameFrom)
+		if test.login.Username != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.entry, test.expected, test.login.Username)
+		}
+	}
+}
+
+func TestFillUsernameOrder(t *testing.T) {
+	tests := []struct {
+		order    []string
+		expected string
+	}{
+		{[]string{"body", "filename"}, "alice"},
+		{[]string{"filename", "body"}, "main"},
+		{[]string{"directory", "body"}, "bob"},
+		{[]string{"directory"}, "bob"},
+		{nil, "alice"},
+	}
+	for _, test := range tests {
+		login := Login{Username: "alice", confidence: confidenceLabel}
+		login.fillUsername("example.com/bob/main", test.order)
+		if login.Username != test.expected {
+			t.Errorf("%v: expected %q, got %q", test.order, test.expected, login.Username)
+		}
+	}
+}